@@ -0,0 +1,160 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AnthropicAdapter moderates through the Anthropic Messages API using the
+// same prompt and compact result contract as DeepSeekAdapter.
+type AnthropicAdapter struct {
+	chatClient
+}
+
+// AnthropicOptions configures adapter.
+type AnthropicOptions struct {
+	APIKey string
+	// BaseURL defaults to https://api.anthropic.com/v1.
+	BaseURL string
+	Model   string
+	// Version is sent as the anthropic-version header. Defaults to 2023-06-01.
+	Version      string
+	Timeout      time.Duration
+	SystemPrompt string
+	// PlainTextSingle, IncludeTriggers, MaxContextMessages, MaxRetries,
+	// RetryBaseDelay, OnUsage, HTTPClient, Headers and TopP behave as in
+	// DeepSeekOptions. Temperature must be in [0, 1]. MaxTokens is required
+	// by the API and defaults to 1024.
+	PlainTextSingle    bool
+	IncludeTriggers    bool
+	MaxContextMessages int
+	MaxRetries         int
+	RetryBaseDelay     time.Duration
+	OnUsage            func(Usage)
+	HTTPClient         *http.Client
+	Headers            map[string]string
+	Temperature        *float64
+	MaxTokens          int
+	TopP               *float64
+}
+
+const (
+	defaultAnthropicVersion   = "2023-06-01"
+	defaultAnthropicMaxTokens = 1024
+)
+
+// NewAnthropicAdapter creates adapter instance. The key is sent in the
+// x-api-key header.
+func NewAnthropicAdapter(opt AnthropicOptions) (*AnthropicAdapter, error) {
+	if strings.TrimSpace(opt.APIKey) == "" {
+		return nil, errors.New("ai: API key is required")
+	}
+	if strings.TrimSpace(opt.BaseURL) == "" {
+		opt.BaseURL = "https://api.anthropic.com/v1"
+	}
+	if strings.TrimSpace(opt.Model) == "" {
+		opt.Model = "claude-3-5-haiku-latest"
+	}
+	if strings.TrimSpace(opt.Version) == "" {
+		opt.Version = defaultAnthropicVersion
+	}
+	if opt.Temperature != nil && *opt.Temperature > 1 {
+		return nil, errors.New("ai: temperature must be between 0 and 1")
+	}
+	if err := validateSampling(opt.Temperature, opt.MaxTokens, opt.TopP); err != nil {
+		return nil, err
+	}
+	if opt.MaxTokens == 0 {
+		opt.MaxTokens = defaultAnthropicMaxTokens
+	}
+	client := newChatClient(chatConfig{
+		BaseURL:            opt.BaseURL,
+		Model:              opt.Model,
+		Timeout:            opt.Timeout,
+		SystemPrompt:       opt.SystemPrompt,
+		PlainTextSingle:    opt.PlainTextSingle,
+		IncludeTriggers:    opt.IncludeTriggers,
+		MaxContextMessages: opt.MaxContextMessages,
+		MaxRetries:         opt.MaxRetries,
+		RetryBaseDelay:     opt.RetryBaseDelay,
+		OnUsage:            opt.OnUsage,
+		HTTPClient:         opt.HTTPClient,
+		Headers:            opt.Headers,
+		Temperature:        opt.Temperature,
+		MaxTokens:          opt.MaxTokens,
+		TopP:               opt.TopP,
+	})
+	client.client.SetHeader("x-api-key", opt.APIKey).
+		SetHeader("anthropic-version", opt.Version)
+	client.endpoint = client.baseURL + "/messages"
+	client.wire = anthropicWire{}
+	return &AnthropicAdapter{chatClient: client}, nil
+}
+
+func (a *AnthropicAdapter) Name() string { return "anthropic" }
+
+// anthropicWire speaks the Messages API format: the system prompt is a
+// top-level field and the reply is a list of content blocks.
+type anthropicWire struct{}
+
+func (anthropicWire) request(model string, params sampling, system, user string) any {
+	type requestPayload struct {
+		Model       string        `json:"model"`
+		System      string        `json:"system"`
+		Messages    []chatMessage `json:"messages"`
+		MaxTokens   int           `json:"max_tokens"`
+		Temperature float64       `json:"temperature"`
+		TopP        *float64      `json:"top_p,omitempty"`
+	}
+	return requestPayload{
+		Model:       model,
+		System:      system,
+		Messages:    []chatMessage{{Role: "user", Content: user}},
+		MaxTokens:   params.maxTokens,
+		Temperature: params.temperature,
+		TopP:        params.topP,
+	}
+}
+
+func (anthropicWire) content(body []byte) (string, error) { return extractAnthropicContent(body) }
+
+// usage maps the input and output token counts; the API reports no total.
+func (anthropicWire) usage(body []byte) Usage {
+	var resp struct {
+		Usage struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     resp.Usage.InputTokens,
+		CompletionTokens: resp.Usage.OutputTokens,
+		TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+	}
+}
+
+// extractAnthropicContent joins the text blocks of the reply.
+func extractAnthropicContent(body []byte) (string, error) {
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			b.WriteString(block.Text)
+		}
+	}
+	return cleanContent(b.String())
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+var _ interfaces.BatchAIAnalyzer = (*AnthropicAdapter)(nil)
+
+func anthropicReply(text string) *http.Response {
+	raw, _ := json.Marshal(map[string]any{
+		"type":        "message",
+		"role":        "assistant",
+		"content":     []map[string]string{{"type": "text", "text": text}},
+		"stop_reason": "end_turn",
+		"usage":       map[string]int{"input_tokens": 12, "output_tokens": 5},
+	})
+	return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(string(raw)))}
+}
+
+func TestNewAnthropicAdapterValidationAndDefaults(t *testing.T) {
+	if _, err := NewAnthropicAdapter(AnthropicOptions{}); err == nil {
+		t.Fatalf("expected error")
+	}
+	hot := 1.5
+	if _, err := NewAnthropicAdapter(AnthropicOptions{APIKey: "k", Temperature: &hot}); err == nil {
+		t.Fatalf("expected temperature error")
+	}
+	a, err := NewAnthropicAdapter(AnthropicOptions{APIKey: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name() != "anthropic" || a.endpoint != "https://api.anthropic.com/v1/messages" || a.sampling.maxTokens != defaultAnthropicMaxTokens {
+		t.Fatalf("unexpected defaults: name=%s endpoint=%s max_tokens=%d", a.Name(), a.endpoint, a.sampling.maxTokens)
+	}
+}
+
+func TestAnthropicAnalyzeSingle(t *testing.T) {
+	var usage Usage
+	a, err := NewAnthropicAdapter(AnthropicOptions{APIKey: "k", BaseURL: "http://claude/v1/", Model: "c", OnUsage: func(u Usage) { usage = u }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/messages" {
+			t.Fatalf("unexpected endpoint: %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "k" || r.Header.Get("anthropic-version") != defaultAnthropicVersion || r.Header.Get("Authorization") != "" {
+			t.Fatalf("unexpected headers: %v", r.Header)
+		}
+		var payload struct {
+			Model     string        `json:"model"`
+			System    string        `json:"system"`
+			Messages  []chatMessage `json:"messages"`
+			MaxTokens int           `json:"max_tokens"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if payload.Model != "c" || !strings.Contains(payload.System, "status_code") || payload.MaxTokens != defaultAnthropicMaxTokens {
+			t.Fatalf("unexpected payload: %+v", payload)
+		}
+		if len(payload.Messages) != 1 || payload.Messages[0].Role != "user" || !strings.Contains(payload.Messages[0].Content, "idiot") {
+			t.Fatalf("unexpected messages: %+v", payload.Messages)
+		}
+		return anthropicReply(`{"a":2,"c":0.7,"d":["idiot"],"f":5}`), nil
+	}))
+	res, err := a.Analyze(context.Background(), models.Message{ID: 5, User: 9, Data: "idiot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != models.StatusNonCriticalAbuse || res.MessageID != 5 || res.ViolatorUserID != 9 || len(res.TriggerTokens) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if usage.PromptTokens != 12 || usage.CompletionTokens != 5 || usage.TotalTokens != 17 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestAnthropicAnalyzeBatch(t *testing.T) {
+	a, err := NewAnthropicAdapter(AnthropicOptions{APIKey: "k", Model: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return anthropicReply("Here you go:\n[{\"a\":6,\"c\":0.95,\"d\":[\"drugs\"],\"f\":2},{\"a\":1,\"c\":0.9,\"d\":[],\"f\":1}]"), nil
+	}))
+	res, err := a.AnalyzeBatch(context.Background(), []models.Message{{ID: 1, User: 1, Data: "hi"}, {ID: 2, User: 2, Data: "drugs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].StatusCode != models.StatusClean || res[1].StatusCode != models.StatusDangerousIllegal {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,118 @@
+package ai
+
+import (
+	"context"
+	"errors"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+// ChainAnalyzer asks a primary analyzer first and re-analyzes with a secondary
+// one only the messages the primary failed on or was not confident about.
+type ChainAnalyzer struct {
+	primary       interfaces.AIAnalyzer
+	secondary     interfaces.AIAnalyzer
+	minConfidence float64
+}
+
+// NewChainAnalyzer creates a fallback chain. Primary verdicts with confidence
+// below minConfidence, messages the primary left unanswered and all messages of
+// a failed primary call go to secondary.
+func NewChainAnalyzer(primary, secondary interfaces.AIAnalyzer, minConfidence float64) *ChainAnalyzer {
+	return &ChainAnalyzer{primary: primary, secondary: secondary, minConfidence: minConfidence}
+}
+
+func (c *ChainAnalyzer) Name() string {
+	return c.primary.Name() + "+" + c.secondary.Name()
+}
+
+func (c *ChainAnalyzer) Analyze(ctx context.Context, message models.Message) (models.AIResult, error) {
+	results, err := c.AnalyzeBatch(ctx, []models.Message{message})
+	if err != nil {
+		return models.AIResult{}, err
+	}
+	if len(results) == 0 {
+		return models.AIResult{}, errors.New("ai: empty response")
+	}
+	return results[0], nil
+}
+
+// AnalyzeBatch returns one result per answered message in input order. A
+// secondary failure fails the whole call.
+func (c *ChainAnalyzer) AnalyzeBatch(ctx context.Context, messages []models.Message) ([]models.AIResult, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	if duplicateIDs(messages) {
+		return c.analyzePositional(ctx, messages)
+	}
+	byID := make(map[int64]models.AIResult, len(messages))
+	primary, err := analyzeWith(ctx, c.primary, messages)
+	if err == nil {
+		for _, r := range alignResults(messages, primary) {
+			if r.Confidence >= c.minConfidence {
+				byID[r.MessageID] = r
+			}
+		}
+	} else if ctx.Err() != nil {
+		return nil, err
+	}
+
+	retry := make([]models.Message, 0, len(messages)-len(byID))
+	for _, msg := range messages {
+		if _, ok := byID[msg.ID]; !ok {
+			retry = append(retry, msg)
+		}
+	}
+	if len(retry) > 0 {
+		secondary, err := analyzeWith(ctx, c.secondary, retry)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range alignResults(retry, secondary) {
+			byID[r.MessageID] = r
+		}
+	}
+
+	out := make([]models.AIResult, 0, len(messages))
+	for _, msg := range messages {
+		if r, ok := byID[msg.ID]; ok {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// analyzePositional runs AnalyzeBatch with batch positions as IDs, so
+// messages sharing an ID keep their own verdicts, then restores the IDs.
+func (c *ChainAnalyzer) analyzePositional(ctx context.Context, messages []models.Message) ([]models.AIResult, error) {
+	keyed := make([]models.Message, len(messages))
+	for i, msg := range messages {
+		keyed[i] = msg
+		keyed[i].ID = int64(i + 1)
+	}
+	results, err := c.AnalyzeBatch(ctx, keyed)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].MessageID = messages[results[i].MessageID-1].ID
+	}
+	return results, nil
+}
+
+func analyzeWith(ctx context.Context, a interfaces.AIAnalyzer, messages []models.Message) ([]models.AIResult, error) {
+	if batch, ok := a.(interfaces.BatchAIAnalyzer); ok {
+		return batch.AnalyzeBatch(ctx, messages)
+	}
+	out := make([]models.AIResult, 0, len(messages))
+	for _, msg := range messages {
+		res, err := a.Analyze(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
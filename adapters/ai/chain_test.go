@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+var _ interfaces.BatchAIAnalyzer = (*ChainAnalyzer)(nil)
+
+type stubAnalyzer struct {
+	name       string
+	confidence map[int64]float64
+	err        error
+	seen       []int64
+}
+
+func (s *stubAnalyzer) Name() string { return s.name }
+
+func (s *stubAnalyzer) Analyze(ctx context.Context, m models.Message) (models.AIResult, error) {
+	s.seen = append(s.seen, m.ID)
+	if s.err != nil {
+		return models.AIResult{}, s.err
+	}
+	return models.AIResult{MessageID: m.ID, StatusCode: models.StatusSuspicious, Confidence: s.confidence[m.ID]}, nil
+}
+
+func TestChainAnalyzerFallsBackOnLowConfidence(t *testing.T) {
+	primary := &stubAnalyzer{name: "local", confidence: map[int64]float64{1: 0.9, 2: 0.3, 3: 0.8}}
+	secondary := &stubAnalyzer{name: "remote", confidence: map[int64]float64{2: 0.95}}
+	c := NewChainAnalyzer(primary, secondary, 0.7)
+
+	msgs := []models.Message{{ID: 1, User: 10}, {ID: 2, User: 20}, {ID: 3, User: 30}}
+	res, err := c.AnalyzeBatch(context.Background(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secondary.seen) != 1 || secondary.seen[0] != 2 {
+		t.Fatalf("secondary should only see message 2, saw %v", secondary.seen)
+	}
+	if len(res) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(res))
+	}
+	want := []float64{0.9, 0.95, 0.8}
+	for i, r := range res {
+		if r.MessageID != msgs[i].ID || r.ViolatorUserID != msgs[i].User || r.Confidence != want[i] {
+			t.Fatalf("unexpected result %d: %+v", i, r)
+		}
+	}
+	if c.Name() != "local+remote" {
+		t.Fatalf("unexpected name: %s", c.Name())
+	}
+}
+
+func TestChainAnalyzerDuplicateIDs(t *testing.T) {
+	// The stubs see batch positions 1..3 as IDs.
+	primary := &stubAnalyzer{name: "local", confidence: map[int64]float64{1: 0.9, 2: 0.3, 3: 0.8}}
+	secondary := &stubAnalyzer{name: "remote", confidence: map[int64]float64{2: 0.95}}
+	c := NewChainAnalyzer(primary, secondary, 0.7)
+
+	msgs := []models.Message{{ID: 5, User: 10}, {ID: 5, User: 20}, {ID: 6, User: 30}}
+	res, err := c.AnalyzeBatch(context.Background(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secondary.seen) != 1 || secondary.seen[0] != 2 {
+		t.Fatalf("secondary should only see the second message, saw %v", secondary.seen)
+	}
+	want := []float64{0.9, 0.95, 0.8}
+	if len(res) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(res))
+	}
+	for i, r := range res {
+		if r.MessageID != msgs[i].ID || r.ViolatorUserID != msgs[i].User || r.Confidence != want[i] {
+			t.Fatalf("unexpected result %d: %+v", i, r)
+		}
+	}
+}
+
+func TestChainAnalyzerFallsBackOnPrimaryError(t *testing.T) {
+	primary := &stubAnalyzer{name: "local", err: errors.New("down")}
+	secondary := &stubAnalyzer{name: "remote", confidence: map[int64]float64{1: 0.6, 2: 0.7}}
+	c := NewChainAnalyzer(primary, secondary, 0.9)
+
+	res, err := c.AnalyzeBatch(context.Background(), []models.Message{{ID: 1}, {ID: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secondary.seen) != 2 || len(res) != 2 || res[0].MessageID != 1 || res[1].MessageID != 2 {
+		t.Fatalf("unexpected fallback: seen=%v res=%+v", secondary.seen, res)
+	}
+}
+
+func TestChainAnalyzerSkipsSecondaryWhenConfident(t *testing.T) {
+	primary := &stubAnalyzer{name: "local", confidence: map[int64]float64{1: 0.9}}
+	secondary := &stubAnalyzer{name: "remote", err: errors.New("unused")}
+	c := NewChainAnalyzer(primary, secondary, 0.5)
+
+	res, err := c.Analyze(context.Background(), models.Message{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MessageID != 1 || len(secondary.seen) != 0 {
+		t.Fatalf("unexpected: res=%+v seen=%v", res, secondary.seen)
+	}
+}
+
+func TestChainAnalyzerSecondaryError(t *testing.T) {
+	primary := &stubAnalyzer{name: "local", confidence: map[int64]float64{1: 0.1}}
+	secondary := &stubAnalyzer{name: "remote", err: errors.New("remote down")}
+	c := NewChainAnalyzer(primary, secondary, 0.5)
+
+	if _, err := c.AnalyzeBatch(context.Background(), []models.Message{{ID: 1}}); err == nil || err.Error() != "remote down" {
+		t.Fatalf("expected secondary error, got %v", err)
+	}
+}
@@ -0,0 +1,1070 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elum-utils/censor/models"
+	"github.com/go-resty/resty/v2"
+)
+
+const defaultSystemPromptBase = `
+Classify messages for an anonymous messenger.
+Return JSON only.
+
+Priority (highest first): 6 > 5 > 4 > 3 > 2 > 1.
+If multiple levels match — return the highest priority.
+
+CRITICAL RULE:
+Only classify at a level if there is sufficient explicit evidence.
+If context is insufficient for a higher level — downgrade to the highest level that is clearly supported.
+Never assume hidden intent.
+Never infer payment, bypass, or danger without clear signals.
+
+--------------------------------
+IMPORTANT DISTINCTION (SELLER vs BUYER):
+
+- Distinguish between seller and buyer roles.
+- Only the seller (who offers or initiates payment/content exchange) can trigger level 5.
+- Buyer-side messages alone must NOT trigger level 5.
+
+Level 5 applies ONLY to the party initiating or offering a commercial transaction.
+
+Do NOT use level 5 for buyer behavior:
+- Asking about price
+- Requesting preview before paying
+- Showing interest in buying
+- Evaluating what is being sold
+
+Examples that are NOT level 5:
+- "сколько стоит?"
+- "покажи перед оплатой"
+- "за что платить?"
+- "что входит?"
+- "чтоб видел за что плачу"
+
+These should be level 1 or level 3 depending on clarity.
+
+--------------------------------
+Codes:
+1 clean
+2 non-critical abuse
+3 human review required
+4 suspicious competitor bypass
+5 commercial / selling / paid off-platform
+6 dangerous / illegal (extreme only)
+
+--------------------------------
+GENERAL PRINCIPLES:
+
+- Sexual conversations between consenting adults are allowed.
+- Explicit sexual text alone is NOT a violation.
+- Neutral contact exchange (Telegram, Instagram, etc.) is allowed.
+- Detect intent, not keywords alone.
+- Context matters, but do NOT over-infer.
+- For levels 1-3 omit triggers.
+- For levels 4-6 include short trigger tokens (max 255 chars each).
+
+Important distinction:
+
+Allowed (Level 1):
+- flirting
+- sexual conversation
+- adult sexting text
+- intimate chat without exchanging media
+
+Human review required (Level 3):
+- exchanging nude or intimate photos/videos
+- inviting others to trade sexual content
+- searching for partners to exchange intimate media
+- suggesting disappearing messages for intimate content
+
+--------------------------------
+LEVEL 6 — DANGEROUS / ILLEGAL (EXTREME ONLY)
+
+Use ONLY for:
+- Suicide encouragement
+- Self-harm instructions
+- Real-world violence threats
+- Weapons/drug trafficking
+- Sexual exploitation of minors
+- Terrorism
+- Serious criminal activity
+
+--------------------------------
+LEVEL 5 — COMMERCIAL / PAID (SELLER ONLY)
+
+Use ONLY if:
+- User INITIATES selling or payment exchange
+- Offers content/service for money
+- Mentions price / payment / transaction
+- Redirects to another platform for payment
+
+Examples:
+- "продаю фото"
+- "скинь деньги — покажу"
+- "прайс в тг"
+- "пиши в тг для покупки"
+
+If payment intent is unclear → DO NOT use 5.
+
+--------------------------------
+LEVEL 4 — COMPETITOR BYPASS
+
+Use ONLY if:
+- User says platform is worse
+- Encourages leaving platform explicitly
+- Mentions bypassing moderation
+
+Do NOT use for:
+- "давай в тг"
+- username sharing
+
+--------------------------------
+LEVEL 3 — HUMAN REVIEW
+
+Use when:
+- Possible payment but unclear
+- Possible selling but unclear
+- Ambiguous intent
+- Searching for partners to exchange intimate photos/videos
+- Inviting others to share nude or sexual content
+- Suggesting intimate content exchange in external messengers
+- Suggesting disappearing messages for sexual content
+
+Common signals:
+- "обмен интим"
+- "обмен нюд"
+- "обмен фото 18+"
+- "нюд за нюд"
+- "nudes for nudes"
+- "trade nudes"
+- "обмен интим в тг"
+- "обмен фото в тг"
+- "исчезающие сообщения для интим"
+
+--------------------------------
+LEVEL 2 — NON-CRITICAL ABUSE
+
+Insults, rude language, harassment without real threat.
+
+--------------------------------
+LEVEL 1 — CLEAN
+
+- Normal conversation
+- Flirting
+- Explicit sexual chat (no payment)
+- Buyer behavior
+- Neutral contact exchange
+
+--------------------------------
+FEW-SHOT EXAMPLES:
+
+Message:
+"продаю фото и видео, интересует?"
+Output:
+{"a":5,"c":0.95,"d":["продаю","видео"]}
+
+Message:
+"скинешь деньги — покажу"
+Output:
+{"a":5,"c":0.97,"d":["деньги","покажу"]}
+
+Message:
+"д22 скинешь на вкусняшки?, а я тебе себя покажу?)"
+Output:
+{"a":5,"c":0.94,"d":["скинешь","покажу"]}
+
+Message:
+"ищу парня для обмена интим в тг"
+Output:
+{"a":3,"c":0.91,"d":[]}
+
+Message:
+"обмен интим в тг исчезающими"
+Output:
+{"a":3,"c":0.94,"d":[]}
+
+Message:
+"ищу девушку для обмена интим фото в тг"
+Output:
+{"a":3,"c":0.92,"d":[]}
+
+Message:
+"чтоб видел за что плачу"
+Output:
+{"a":1,"c":0.90,"d":[]}
+
+Message:
+"покажи перед оплатой"
+Output:
+{"a":1,"c":0.90,"d":[]}
+
+Message:
+"за что платить?"
+Output:
+{"a":1,"c":0.90,"d":[]}
+
+Message:
+"сколько стоит?"
+Output:
+{"a":1,"c":0.90,"d":[]}
+
+Message:
+"покажи фото"
+Output:
+{"a":1,"c":0.88,"d":[]}
+
+Message:
+"давай в тг"
+Output:
+{"a":1,"c":0.85,"d":[]}
+
+Message:
+"этот сайт говно, пиши в тг"
+Output:
+{"a":4,"c":0.92,"d":["говно","в тг"]}
+
+--------------------------------
+DECISION FLOW:
+
+1. Explicit extreme danger → 6
+2. Clear seller payment intent → 5
+3. Clear competitor bypass → 4
+4. Ambiguous high-risk (including intimate media exchange) → 3
+5. Abuse → 2
+6. Otherwise → 1
+`
+
+const defaultSystemPromptSingleOutput = `
+Return compact JSON:
+{"a":status_code,"f":message_id,"c":confidence,"d":["token"]}
+`
+
+const defaultSystemPromptPlainSingleOutput = `
+The user message is the raw text of one message.
+Return compact JSON:
+{"a":status_code,"c":confidence,"d":["token"]}
+`
+
+const defaultSystemPromptTriggersHint = `
+Input messages may carry "triggers": keywords a prefilter matched.
+Use them to focus attention, not as evidence on their own.
+`
+
+const defaultSystemPromptContextHint = `
+Input messages may carry "context": earlier dialog turns, oldest first.
+Use them to understand roles and intent, but classify only "data".
+`
+
+const defaultSystemPromptBatchOutput = `
+Return compact JSON array:
+[{"a":status_code,"f":message_id,"c":confidence,"d":["token"]}]
+`
+
+// RateLimitError reports an HTTP 429 from the provider. Wait is taken from the
+// Retry-After header and is zero when the header is missing or malformed.
+type RateLimitError struct {
+	Wait time.Duration
+	Body string
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Wait > 0 {
+		return fmt.Sprintf("ai: rate limited, retry after %s: %s", e.Wait, e.Body)
+	}
+	return "ai: rate limited: " + e.Body
+}
+
+// RetryAfter returns how long callers should pause before the next request.
+func (e *RateLimitError) RetryAfter() time.Duration { return e.Wait }
+
+// AIResponseError reports model output that breaks the response contract
+// under strict parsing. Raw is the content as returned by the model.
+type AIResponseError struct {
+	Raw string
+	Err error
+}
+
+func (e *AIResponseError) Error() string { return "ai: invalid response: " + e.Err.Error() }
+
+func (e *AIResponseError) Unwrap() error { return e.Err }
+
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// Usage counts model tokens billed by the provider.
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+}
+
+type usageCounter struct {
+	prompt     atomic.Int64
+	completion atomic.Int64
+	total      atomic.Int64
+}
+
+// Usage returns the tokens used by all successful requests so far.
+func (c *chatClient) Usage() Usage {
+	return Usage{
+		PromptTokens:     c.usage.prompt.Load(),
+		CompletionTokens: c.usage.completion.Load(),
+		TotalTokens:      c.usage.total.Load(),
+	}
+}
+
+func (c *chatClient) recordUsage(u Usage) {
+	if u == (Usage{}) {
+		return
+	}
+	c.usage.prompt.Add(u.PromptTokens)
+	c.usage.completion.Add(u.CompletionTokens)
+	c.usage.total.Add(u.TotalTokens)
+	if c.onUsage != nil {
+		c.onUsage(u)
+	}
+}
+
+// chatClient is the shared chat client behind the HTTP adapters. Adapters
+// embed it, add Name and pick the wire format.
+type chatClient struct {
+	baseURL        string
+	model          string
+	client         *resty.Client
+	prompt         string
+	customPrompt   bool
+	plainSingle    bool
+	withTriggers   bool
+	maxContext     int
+	maxRetries     int
+	retryBaseDelay time.Duration
+	endpoint       string
+	wire           chatWire
+	usage          *usageCounter
+	onUsage        func(Usage)
+	sampling       sampling
+	promptByLang   map[string]string
+	strict         bool
+	maxPayload     int
+}
+
+// sampling holds generation parameters sent with every request. Zero
+// maxTokens and nil topP are omitted.
+type sampling struct {
+	temperature float64
+	maxTokens   int
+	topP        *float64
+}
+
+// validateSampling checks generation parameters against the ranges accepted
+// by OpenAI-compatible APIs.
+func validateSampling(temperature *float64, maxTokens int, topP *float64) error {
+	if temperature != nil && (*temperature < 0 || *temperature > 2) {
+		return errors.New("ai: temperature must be between 0 and 2")
+	}
+	if maxTokens < 0 {
+		return errors.New("ai: max tokens must not be negative")
+	}
+	if topP != nil && (*topP <= 0 || *topP > 1) {
+		return errors.New("ai: top_p must be in (0, 1]")
+	}
+	return nil
+}
+
+// chatConfig holds provider options after defaults were applied.
+type chatConfig struct {
+	APIKey             string
+	BaseURL            string
+	Model              string
+	Timeout            time.Duration
+	SystemPrompt       string
+	SystemHint         string
+	PlainTextSingle    bool
+	IncludeTriggers    bool
+	MaxContextMessages int
+	MaxRetries         int
+	RetryBaseDelay     time.Duration
+	OnUsage            func(Usage)
+	HTTPClient         *http.Client
+	Headers            map[string]string
+	Temperature        *float64
+	MaxTokens          int
+	TopP               *float64
+	PromptByLang       map[string]string
+	StrictParsing      bool
+	MaxPayloadBytes    int
+}
+
+const (
+	defaultMaxContextMessages = 5
+	defaultRetryBaseDelay     = 500 * time.Millisecond
+)
+
+func newChatClient(cfg chatConfig) chatClient {
+	if cfg.Timeout <= 0 && cfg.HTTPClient == nil {
+		cfg.Timeout = 15 * time.Second
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = defaultRetryBaseDelay
+	}
+	if cfg.MaxContextMessages == 0 {
+		cfg.MaxContextMessages = defaultMaxContextMessages
+	}
+	prompt := defaultSystemPromptBase + "\n" + defaultSystemPromptSingleOutput
+	customPrompt := false
+	if strings.TrimSpace(cfg.SystemPrompt) != "" {
+		prompt = cfg.SystemPrompt
+		customPrompt = true
+	} else if strings.TrimSpace(cfg.SystemHint) != "" {
+		prompt = cfg.SystemHint
+		customPrompt = true
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	client := resty.New()
+	if cfg.HTTPClient != nil {
+		// A copy, so Timeout below never changes the caller's client.
+		hc := *cfg.HTTPClient
+		client = resty.NewWithClient(&hc)
+	}
+	if cfg.Timeout > 0 {
+		client.SetTimeout(cfg.Timeout)
+	}
+	client.SetBaseURL(baseURL).
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(cfg.Headers)
+	if cfg.APIKey != "" {
+		client.SetAuthToken(cfg.APIKey)
+	}
+	params := sampling{maxTokens: cfg.MaxTokens, topP: cfg.TopP}
+	if cfg.Temperature != nil {
+		params.temperature = *cfg.Temperature
+	}
+	var byLang map[string]string
+	for lang, block := range cfg.PromptByLang {
+		if byLang == nil {
+			byLang = make(map[string]string, len(cfg.PromptByLang))
+		}
+		byLang[strings.ToLower(strings.TrimSpace(lang))] = block
+	}
+	return chatClient{
+		sampling:       params,
+		promptByLang:   byLang,
+		strict:         cfg.StrictParsing,
+		maxPayload:     cfg.MaxPayloadBytes,
+		baseURL:        baseURL,
+		model:          cfg.Model,
+		endpoint:       buildChatCompletionsURL(baseURL),
+		customPrompt:   customPrompt,
+		plainSingle:    cfg.PlainTextSingle,
+		withTriggers:   cfg.IncludeTriggers,
+		maxContext:     cfg.MaxContextMessages,
+		maxRetries:     cfg.MaxRetries,
+		retryBaseDelay: cfg.RetryBaseDelay,
+		wire:           openAIWire{},
+		usage:          &usageCounter{},
+		onUsage:        cfg.OnUsage,
+		client:         client,
+		prompt:         prompt,
+	}
+}
+
+func (c *chatClient) Analyze(ctx context.Context, message models.Message) (models.AIResult, error) {
+	results, err := c.AnalyzeBatch(ctx, []models.Message{message})
+	if err != nil {
+		return models.AIResult{}, err
+	}
+	if len(results) == 0 {
+		return models.AIResult{}, errors.New("ai: empty response")
+	}
+	return results[0], nil
+}
+
+func (c *chatClient) AnalyzeBatch(ctx context.Context, messages []models.Message) ([]models.AIResult, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	payload, err := c.buildPayload(messages)
+	if err != nil {
+		return nil, err
+	}
+	if c.maxPayload > 0 && len(payload) > c.maxPayload && len(messages) > 1 {
+		return c.analyzeSplit(ctx, messages)
+	}
+	return c.analyzePayload(ctx, messages, payload)
+}
+
+// analyzeSplit analyzes the two halves of messages in separate requests,
+// splitting further while a half is still over maxPayload. Each half is
+// aligned by MessageID, so the concatenation keeps the input order.
+func (c *chatClient) analyzeSplit(ctx context.Context, messages []models.Message) ([]models.AIResult, error) {
+	mid := len(messages) / 2
+	head, err := c.AnalyzeBatch(ctx, messages[:mid])
+	if err != nil {
+		return nil, err
+	}
+	tail, err := c.AnalyzeBatch(ctx, messages[mid:])
+	if err != nil {
+		return nil, err
+	}
+	return append(head, tail...), nil
+}
+
+func (c *chatClient) analyzePayload(ctx context.Context, messages []models.Message, payload []byte) ([]models.AIResult, error) {
+	body, err := c.post(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := c.wire.content(body)
+	if err != nil {
+		return nil, err
+	}
+	c.recordUsage(c.wire.usage(body))
+
+	results, err := c.parseContent(content)
+	if err != nil {
+		return nil, err
+	}
+	// A single verdict for a batch is fanned out only when it cannot be tied to
+	// one message; otherwise the other messages are left missing.
+	if len(results) == 1 && len(messages) > 1 && !matchesMessage(messages, results[0].MessageID) {
+		for i := range messages {
+			copyRes := results[0]
+			copyRes.MessageID = messages[i].ID
+			if copyRes.ViolatorUserID == 0 {
+				copyRes.ViolatorUserID = messages[i].User
+			}
+			results = append(results, copyRes)
+		}
+		results = results[1:]
+	}
+	return alignResults(messages, results), nil
+}
+
+// post sends payload, retrying transient failures with jittered exponential
+// backoff up to maxRetries times.
+func (c *chatClient) post(ctx context.Context, payload []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := c.postOnce(ctx, payload)
+		if err == nil || attempt >= c.maxRetries || !retryable(ctx, err) {
+			return body, err
+		}
+		delay := backoffDelay(c.retryBaseDelay, attempt)
+		var rl *RateLimitError
+		if errors.As(err, &rl) && rl.Wait > delay {
+			delay = rl.Wait
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *chatClient) postOnce(ctx context.Context, payload []byte) ([]byte, error) {
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetBody(payload).
+		Post(c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() == http.StatusTooManyRequests {
+		return nil, &RateLimitError{Wait: parseRetryAfter(resp.Header().Get("Retry-After"), time.Now()), Body: resp.String()}
+	}
+	if resp.StatusCode() >= http.StatusMultipleChoices {
+		return nil, &APIError{StatusCode: resp.StatusCode(), Body: resp.String()}
+	}
+	return resp.Body(), nil
+}
+
+// APIError reports a non-2xx HTTP response other than 429, which is a
+// *RateLimitError. Body is the response body as returned.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string { return fmt.Sprintf("ai: status %d: %s", e.StatusCode, e.Body) }
+
+// retryable reports transient failures: rate limits, 500-504 and transport
+// errors. Client errors and cancellation are final.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return true
+	}
+	var ae *APIError
+	if errors.As(err, &ae) {
+		return ae.StatusCode >= http.StatusInternalServerError && ae.StatusCode <= http.StatusGatewayTimeout
+	}
+	return true
+}
+
+// backoffDelay returns base*2^attempt plus up to 50% jitter.
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	d := base << attempt
+	if d <= 0 || attempt > 16 {
+		d = base << 16
+	}
+	return d + rand.N(d/2+1)
+}
+
+func matchesMessage(messages []models.Message, id int64) bool {
+	if id == 0 {
+		return false
+	}
+	for _, msg := range messages {
+		if msg.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *chatClient) buildPayload(messages []models.Message) ([]byte, error) {
+	type inputMessage struct {
+		ID       int64                   `json:"id"`
+		User     int64                   `json:"user"`
+		Data     string                  `json:"data"`
+		Triggers []string                `json:"triggers,omitempty"`
+		Context  []models.ContextMessage `json:"context,omitempty"`
+		Lang     string                  `json:"lang,omitempty"`
+	}
+	batch := len(messages) > 1
+	withContext := false
+	for _, msg := range messages {
+		if len(c.contextFor(msg)) > 0 {
+			withContext = true
+			break
+		}
+	}
+	var userPayload []byte
+	if c.plainSingle && !batch && !withContext {
+		userPayload = []byte(messages[0].Data)
+	} else {
+		in := make([]inputMessage, 0, len(messages))
+		for _, msg := range messages {
+			item := inputMessage{ID: msg.ID, User: msg.User, Data: msg.Data, Lang: msg.Lang}
+			if c.withTriggers {
+				item.Triggers = msg.Triggers
+			}
+			item.Context = c.contextFor(msg)
+			in = append(in, item)
+		}
+		var err error
+		userPayload, err = json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	system := c.systemPrompt(batch, withContext) + c.langPrompts(messages)
+	return json.Marshal(c.wire.request(c.model, c.sampling, system, string(userPayload)))
+}
+
+// langPrompts returns the PromptByLang blocks for the languages of messages,
+// each once, in order of first appearance.
+func (c *chatClient) langPrompts(messages []models.Message) string {
+	if len(c.promptByLang) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	seen := make(map[string]bool, 2)
+	for _, msg := range messages {
+		lang := strings.ToLower(strings.TrimSpace(msg.Lang))
+		if lang == "" {
+			continue
+		}
+		block, ok := c.promptByLang[lang]
+		if !ok {
+			base, _, _ := strings.Cut(lang, "-")
+			lang = base
+			block, ok = c.promptByLang[lang]
+		}
+		if !ok || seen[lang] {
+			continue
+		}
+		seen[lang] = true
+		b.WriteString("\n")
+		b.WriteString(block)
+	}
+	return b.String()
+}
+
+func (c *chatClient) systemPromptFor(batch bool) string {
+	return c.systemPrompt(batch, false)
+}
+
+func (c *chatClient) systemPrompt(batch, withContext bool) string {
+	if c.customPrompt {
+		return c.prompt
+	}
+	if batch {
+		return c.promptBase(withContext) + "\n" + defaultSystemPromptBatchOutput
+	}
+	if c.plainSingle && !withContext {
+		return defaultSystemPromptBase + "\n" + defaultSystemPromptPlainSingleOutput
+	}
+	return c.promptBase(withContext) + "\n" + defaultSystemPromptSingleOutput
+}
+
+func (c *chatClient) promptBase(withContext bool) string {
+	base := defaultSystemPromptBase
+	if c.withTriggers {
+		base += "\n" + defaultSystemPromptTriggersHint
+	}
+	if withContext {
+		base += "\n" + defaultSystemPromptContextHint
+	}
+	return base
+}
+
+// contextFor returns the latest context turns allowed by maxContext.
+func (c *chatClient) contextFor(msg models.Message) []models.ContextMessage {
+	if c.maxContext <= 0 || len(msg.Context) == 0 {
+		return nil
+	}
+	if len(msg.Context) > c.maxContext {
+		return msg.Context[len(msg.Context)-c.maxContext:]
+	}
+	return msg.Context
+}
+
+// chatWire is the provider-specific request and response encoding.
+type chatWire interface {
+	request(model string, params sampling, system, user string) any
+	content(body []byte) (string, error)
+	// usage reports the token counts of a response; zero when absent.
+	usage(body []byte) Usage
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIWire speaks the OpenAI chat completions format used by DeepSeek and
+// OpenAI.
+type openAIWire struct{}
+
+func (openAIWire) request(model string, params sampling, system, user string) any {
+	type responseFormat struct {
+		Type string `json:"type"`
+	}
+	type requestPayload struct {
+		Model          string         `json:"model"`
+		Messages       []chatMessage  `json:"messages"`
+		Temperature    float64        `json:"temperature"`
+		MaxTokens      int            `json:"max_tokens,omitempty"`
+		TopP           *float64       `json:"top_p,omitempty"`
+		Stream         bool           `json:"stream"`
+		ResponseFormat responseFormat `json:"response_format"`
+	}
+	return requestPayload{
+		Model: model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		Temperature: params.temperature,
+		MaxTokens:   params.maxTokens,
+		TopP:        params.topP,
+		Stream:      false,
+		ResponseFormat: responseFormat{
+			Type: "json_object",
+		},
+	}
+}
+
+func (openAIWire) content(body []byte) (string, error) { return extractContent(body) }
+
+func (openAIWire) usage(body []byte) Usage {
+	var resp struct {
+		Usage struct {
+			PromptTokens     int64 `json:"prompt_tokens"`
+			CompletionTokens int64 `json:"completion_tokens"`
+			TotalTokens      int64 `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+func extractContent(body []byte) (string, error) {
+	var resp chatCompletionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("ai: choices is empty")
+	}
+	return cleanContent(resp.Choices[0].Message.Content)
+}
+
+// cleanContent strips code fences and any prose around the first JSON object
+// or array in a model reply.
+func cleanContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", errors.New("ai: response content is empty")
+	}
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	content = strings.TrimSpace(content)
+	if json.Valid([]byte(content)) {
+		return content, nil
+	}
+	if found, ok := firstJSON(content); ok {
+		return found, nil
+	}
+	return content, nil
+}
+
+// firstJSON returns the first balanced JSON object or array in s.
+func firstJSON(s string) (string, bool) {
+	for start := 0; start < len(s); start++ {
+		if s[start] != '{' && s[start] != '[' {
+			continue
+		}
+		depth, inString, escaped := 0, false, false
+		for i := start; i < len(s); i++ {
+			ch := s[i]
+			if inString {
+				switch {
+				case escaped:
+					escaped = false
+				case ch == '\\':
+					escaped = true
+				case ch == '"':
+					inString = false
+				}
+				continue
+			}
+			switch ch {
+			case '"':
+				inString = true
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			if depth == 0 {
+				if candidate := s[start : i+1]; json.Valid([]byte(candidate)) {
+					return candidate, true
+				}
+				break
+			}
+		}
+	}
+	return "", false
+}
+
+// maxTriggerLength is the per-token limit the prompt asks the model to keep.
+const maxTriggerLength = 255
+
+// parseContent decodes model content, validating it under strict parsing.
+func (c *chatClient) parseContent(content string) ([]models.AIResult, error) {
+	if !c.strict {
+		return parseResults(content)
+	}
+	results, err := decodeResults(content)
+	if err == nil {
+		err = validateResults(results)
+	}
+	if err != nil {
+		return nil, &AIResponseError{Raw: content, Err: err}
+	}
+	return results, nil
+}
+
+// parseResults decodes model content leniently: unknown statuses become
+// human review.
+func parseResults(content string) ([]models.AIResult, error) {
+	results, err := decodeResults(content)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if !results[i].StatusCode.Valid() {
+			results[i].StatusCode = models.StatusHumanReview
+		}
+	}
+	return results, nil
+}
+
+func decodeResults(content string) ([]models.AIResult, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("ai: empty result payload")
+	}
+
+	if strings.HasPrefix(content, "[") {
+		var items []json.RawMessage
+		if err := json.Unmarshal([]byte(content), &items); err != nil {
+			return nil, err
+		}
+		arr := make([]models.AIResult, len(items))
+		for i, item := range items {
+			if err := json.Unmarshal(item, &arr[i]); err != nil {
+				return nil, err
+			}
+			arr[i].Raw = string(item)
+		}
+		return arr, nil
+	}
+
+	var one models.AIResult
+	if err := json.Unmarshal([]byte(content), &one); err != nil {
+		return nil, err
+	}
+	one.Raw = content
+	return []models.AIResult{one}, nil
+}
+
+// validateResults checks decoded results against the output contract.
+func validateResults(results []models.AIResult) error {
+	for _, r := range results {
+		if !r.StatusCode.Valid() {
+			return fmt.Errorf("message %d: status %d out of range", r.MessageID, r.StatusCode)
+		}
+		if r.Confidence < 0 || r.Confidence > 1 {
+			return fmt.Errorf("message %d: confidence %v out of [0, 1]", r.MessageID, r.Confidence)
+		}
+		for _, t := range r.TriggerTokens {
+			if n := utf8.RuneCountInString(t); n > maxTriggerLength {
+				return fmt.Errorf("message %d: trigger token of %d characters exceeds %d", r.MessageID, n, maxTriggerLength)
+			}
+		}
+	}
+	return nil
+}
+
+// duplicateIDs reports whether two messages share an ID.
+func duplicateIDs(messages []models.Message) bool {
+	seen := make(map[int64]struct{}, len(messages))
+	for _, m := range messages {
+		if _, ok := seen[m.ID]; ok {
+			return true
+		}
+		seen[m.ID] = struct{}{}
+	}
+	return false
+}
+
+func alignResults(messages []models.Message, results []models.AIResult) []models.AIResult {
+	if len(results) == 0 {
+		return nil
+	}
+	byID := make(map[int64]models.AIResult, len(results))
+	for _, r := range results {
+		if r.MessageID != 0 {
+			byID[r.MessageID] = r
+		}
+	}
+
+	out := make([]models.AIResult, 0, len(messages))
+	// With duplicate IDs a lookup would hand one verdict to every copy.
+	if len(byID) > 0 && !duplicateIDs(messages) {
+		for _, msg := range messages {
+			res, ok := byID[msg.ID]
+			if !ok {
+				continue
+			}
+			if res.ViolatorUserID == 0 {
+				res.ViolatorUserID = msg.User
+			}
+			if res.MessageID == 0 {
+				res.MessageID = msg.ID
+			}
+			out = append(out, res)
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+
+	for i, msg := range messages {
+		if i >= len(results) {
+			break
+		}
+		res := results[i]
+		if res.ViolatorUserID == 0 {
+			res.ViolatorUserID = msg.User
+		}
+		if res.MessageID == 0 {
+			res.MessageID = msg.ID
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+func buildChatCompletionsURL(base string) string {
+	if base == "" {
+		return "https://api.deepseek.com/chat/completions"
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return strings.TrimRight(base, "/") + "/chat/completions"
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	switch u.Path {
+	case "":
+		u.Path = "/chat/completions"
+	case "/v1":
+		u.Path = "/v1/chat/completions"
+	case "/chat/completions", "/v1/chat/completions":
+		// keep as is
+	default:
+		u.Path = u.Path + "/chat/completions"
+	}
+	return u.String()
+}
@@ -2,11 +2,15 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/elum-utils/censor/models"
 )
@@ -108,3 +112,542 @@ func TestBuildChatCompletionsURL(t *testing.T) {
 		}
 	}
 }
+
+func TestPlainTextSinglePayload(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", PlainTextSingle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userContent := func(msgs []models.Message) (string, string) {
+		raw, err := a.buildPayload(msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Messages[0].Content, body.Messages[1].Content
+	}
+
+	system, user := userContent([]models.Message{{ID: 7, User: 1, Data: "привет"}})
+	if user != "привет" || !strings.Contains(system, "raw text of one message") {
+		t.Fatalf("expected plain single payload, got system=%q user=%q", system[len(system)-80:], user)
+	}
+	_, user = userContent([]models.Message{{ID: 7, User: 1, Data: "a"}, {ID: 8, User: 1, Data: "b"}})
+	if !strings.HasPrefix(user, `[{"id":7`) {
+		t.Fatalf("batches must stay JSON, got %q", user)
+	}
+
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		body := `{"choices":[{"message":{"content":"{\"a\":2,\"c\":0.8,\"d\":[]}"}}]}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	}))
+	res, err := a.Analyze(context.Background(), models.Message{ID: 7, User: 3, Data: "x"})
+	if err != nil || res.MessageID != 7 || res.ViolatorUserID != 3 {
+		t.Fatalf("expected IDs filled from message, got %+v err=%v", res, err)
+	}
+}
+
+func TestAnalyzeBatchSingleResultWithID(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		body := `{"choices":[{"message":{"content":"{\"a\":5,\"f\":2,\"c\":0.9,\"d\":[\"продаю\"]}"}}]}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	}))
+	out, err := a.AnalyzeBatch(context.Background(), []models.Message{{ID: 1, User: 11, Data: "a"}, {ID: 2, User: 22, Data: "b"}, {ID: 3, User: 33, Data: "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].MessageID != 2 || out[0].ViolatorUserID != 22 {
+		t.Fatalf("expected only message 2 to get the verdict, got %+v", out)
+	}
+
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		body := `{"choices":[{"message":{"content":"{\"a\":2,\"f\":99,\"c\":0.8,\"d\":[]}"}}]}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	}))
+	out, err = a.AnalyzeBatch(context.Background(), []models.Message{{ID: 1, User: 11, Data: "a"}, {ID: 2, User: 22, Data: "b"}})
+	if err != nil || len(out) != 2 || out[0].MessageID != 1 || out[1].MessageID != 2 {
+		t.Fatalf("unknown ID must still fan out, got %+v err=%v", out, err)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Retry-After", "3")
+		return &http.Response{StatusCode: 429, Body: io.NopCloser(strings.NewReader("slow down")), Header: h}, nil
+	}))
+	_, err = a.Analyze(context.Background(), models.Message{ID: 1, User: 1, Data: "x"})
+	var rl *RateLimitError
+	if !errors.As(err, &rl) || rl.RetryAfter() != 3*time.Second {
+		t.Fatalf("expected rate limit error with 3s wait, got %v", err)
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := parseRetryAfter(now.Add(5*time.Second).Format(http.TimeFormat), now); got != 5*time.Second {
+		t.Fatalf("expected HTTP-date wait of 5s, got %s", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Fatalf("expected zero wait for malformed header, got %s", got)
+	}
+}
+
+func TestIncludeTriggersPayload(t *testing.T) {
+	msgs := []models.Message{{ID: 1, User: 1, Data: "продаю видео", Triggers: []string{"продаю", "видео"}}}
+	for _, include := range []bool{false, true} {
+		a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", IncludeTriggers: include})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := a.buildPayload(msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload := string(raw)
+		if got := strings.Contains(payload, `\"triggers\":[\"продаю\",\"видео\"]`); got != include {
+			t.Fatalf("include=%v: unexpected triggers in payload %s", include, payload)
+		}
+		if got := strings.Contains(a.systemPromptFor(false), "prefilter matched"); got != include {
+			t.Fatalf("include=%v: unexpected prompt hint", include)
+		}
+	}
+}
+
+func TestContextSerializedIntoRequest(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", MaxContextMessages: 2, PlainTextSingle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent string
+	a.client.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		raw, _ := io.ReadAll(req.Body)
+		sent = string(raw)
+		body := `{"choices":[{"message":{"content":"{\"a\":1,\"c\":0.9,\"d\":[]}"}}]}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	}))
+	msg := models.Message{ID: 1, User: 2, Data: "сколько стоит?", Context: []models.ContextMessage{
+		{User: 3, Data: "dropped-turn"},
+		{User: 3, Data: "продаю фото"},
+		{User: 2, Data: "покажи"},
+	}}
+	if _, err := a.Analyze(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sent, `\"context\":[{\"user\":3,\"data\":\"продаю фото\"},{\"user\":2,\"data\":\"покажи\"}]`) {
+		t.Fatal("expected capped context in request body")
+	}
+	if strings.Contains(sent, "dropped-turn") {
+		t.Fatal("context must be capped to the latest turns")
+	}
+	if !strings.Contains(sent, "earlier dialog turns") {
+		t.Fatalf("expected context hint in prompt")
+	}
+
+	msg.Context = nil
+	if _, err := a.Analyze(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sent, `\"context\"`) || !strings.Contains(sent, `"content":"сколько стоит?"`) {
+		t.Fatal("nil context must keep the plain single payload")
+	}
+}
+
+func TestAnalyzeBatchRetriesTransientFailures(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", MaxRetries: 2, RetryBaseDelay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		switch calls.Add(1) {
+		case 1:
+			return &http.Response{StatusCode: 429, Body: io.NopCloser(strings.NewReader("slow down")), Header: make(http.Header)}, nil
+		case 2:
+			return &http.Response{StatusCode: 503, Body: io.NopCloser(strings.NewReader("busy")), Header: make(http.Header)}, nil
+		}
+		body := `{"choices":[{"message":{"content":"{\"a\":1,\"b\":\"ok\",\"c\":0.9,\"d\":[]}"}}]}`
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	}))
+	res, err := a.Analyze(context.Background(), models.Message{ID: 1, User: 2, Data: "x"})
+	if err != nil || res.StatusCode != models.StatusClean {
+		t.Fatalf("unexpected analyze: res=%+v err=%v", res, err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestAnalyzeBatchDoesNotRetryClientErrors(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", MaxRetries: 3, RetryBaseDelay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return &http.Response{StatusCode: 401, Body: io.NopCloser(strings.NewReader("denied")), Header: make(http.Header)}, nil
+	}))
+	_, err = a.Analyze(context.Background(), models.Message{ID: 1, User: 2, Data: "x"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || apiErr.Body != "denied" {
+		t.Fatalf("expected *APIError 401, got %v", err)
+	}
+	if err.Error() != "ai: status 401: denied" {
+		t.Fatalf("unexpected message %q", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected single attempt, got %d", calls.Load())
+	}
+}
+
+func TestAnalyzeBatchRetryStopsOnCancel(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", MaxRetries: 5, RetryBaseDelay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return &http.Response{StatusCode: 502, Body: io.NopCloser(strings.NewReader("bad gateway")), Header: make(http.Header)}, nil
+	}))
+	_, err = a.Analyze(ctx, models.Message{ID: 1, User: 2, Data: "x"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestUsageAccumulatesAcrossBatches(t *testing.T) {
+	var perRequest []Usage
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", OnUsage: func(u Usage) {
+		perRequest = append(perRequest, u)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int64
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		n := calls.Add(1)
+		body := `{"choices":[{"message":{"content":"[{\"id\":1,\"a\":1,\"b\":\"ok\",\"c\":0.9,\"d\":[]},{\"id\":2,\"a\":1,\"b\":\"ok\",\"c\":0.9,\"d\":[]}]"}}],` +
+			fmt.Sprintf(`"usage":{"prompt_tokens":%d,"completion_tokens":5,"total_tokens":%d}}`, 10*n, 10*n+5)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	}))
+	batch := []models.Message{{ID: 1, User: 1, Data: "a"}, {ID: 2, User: 2, Data: "b"}}
+	for i := 0; i < 2; i++ {
+		if _, err := a.AnalyzeBatch(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Requests report 10+5 and 20+5 tokens.
+	if got := a.Usage(); got != (Usage{PromptTokens: 30, CompletionTokens: 10, TotalTokens: 40}) {
+		t.Fatalf("unexpected usage total: %+v", got)
+	}
+	if len(perRequest) != 2 || perRequest[0].TotalTokens != 15 || perRequest[1].TotalTokens != 25 {
+		t.Fatalf("unexpected per-request usage: %+v", perRequest)
+	}
+
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 500, Body: io.NopCloser(strings.NewReader("boom")), Header: make(http.Header)}, nil
+	}))
+	_, _ = a.AnalyzeBatch(context.Background(), batch)
+	if got := a.Usage(); got.TotalTokens != 40 || len(perRequest) != 2 {
+		t.Fatalf("failed requests must not count: %+v", got)
+	}
+}
+
+func TestCustomHTTPClientAndHeaders(t *testing.T) {
+	var seen atomic.Bool
+	hc := &http.Client{
+		Timeout: 42 * time.Second,
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Header.Get("X-Org-Id") != "org-1" || r.Header.Get("Authorization") != "Bearer k" {
+				t.Errorf("missing headers: %v", r.Header)
+			}
+			seen.Store(true)
+			body := `{"choices":[{"message":{"content":"{\"a\":1,\"b\":\"ok\",\"c\":0.9,\"d\":[]}"}}]}`
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+		}),
+	}
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", HTTPClient: hc, Headers: map[string]string{"X-Org-Id": "org-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Analyze(context.Background(), models.Message{ID: 1, User: 1, Data: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if !seen.Load() {
+		t.Fatalf("custom transport was not used")
+	}
+	if hc.Timeout != 42*time.Second {
+		t.Fatalf("client timeout must be kept when Timeout is unset: %v", hc.Timeout)
+	}
+	b, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", HTTPClient: hc, Timeout: time.Second})
+	if err != nil || b.client.GetClient().Timeout != time.Second {
+		t.Fatalf("explicit Timeout must apply: err=%v", err)
+	}
+	if hc.Timeout != 42*time.Second {
+		t.Fatalf("caller's client must not be changed: %v", hc.Timeout)
+	}
+	o, err := NewOllamaAdapter(OllamaOptions{HTTPClient: hc})
+	if err != nil || o.client.GetClient().Timeout != 42*time.Second {
+		t.Fatalf("ollama must keep the client timeout when Timeout is unset: err=%v", err)
+	}
+}
+
+func TestSamplingParamsInPayload(t *testing.T) {
+	msgs := []models.Message{{ID: 1, User: 1, Data: "x"}}
+	decode := func(t *testing.T, a *DeepSeekAdapter) map[string]any {
+		t.Helper()
+		raw, err := a.buildPayload(msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload map[string]any
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+
+	a, _ := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k"})
+	payload := decode(t, a)
+	if payload["temperature"] != 0.0 {
+		t.Fatalf("temperature must default to 0: %v", payload["temperature"])
+	}
+	if _, ok := payload["max_tokens"]; ok {
+		t.Fatalf("unset max_tokens must be omitted: %v", payload)
+	}
+	if _, ok := payload["top_p"]; ok {
+		t.Fatalf("unset top_p must be omitted: %v", payload)
+	}
+
+	temp, topP := 0.7, 0.9
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", Temperature: &temp, MaxTokens: 256, TopP: &topP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload = decode(t, a)
+	if payload["temperature"] != 0.7 || payload["max_tokens"] != 256.0 || payload["top_p"] != 0.9 {
+		t.Fatalf("unexpected sampling params: %v", payload)
+	}
+
+	bad := 2.5
+	if _, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", Temperature: &bad}); err == nil {
+		t.Fatalf("expected temperature range error")
+	}
+	if _, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", TopP: &bad}); err == nil {
+		t.Fatalf("expected top_p range error")
+	}
+	if _, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", MaxTokens: -1}); err == nil {
+		t.Fatalf("expected max tokens error")
+	}
+}
+
+func TestPromptByLang(t *testing.T) {
+	a, _ := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", PromptByLang: map[string]string{
+		"EN": "English examples: ...",
+		"es": "Ejemplos en español: ...",
+		"ru": "Примеры на русском: ...",
+	}})
+	decode := func(t *testing.T, msgs []models.Message) (system, user string) {
+		t.Helper()
+		raw, err := a.buildPayload(msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload struct {
+			Messages []chatMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			t.Fatal(err)
+		}
+		return payload.Messages[0].Content, payload.Messages[1].Content
+	}
+
+	system, user := decode(t, []models.Message{{ID: 1, User: 1, Data: "buy now", Lang: "en"}})
+	if !strings.Contains(system, "English examples") || strings.Contains(system, "español") || strings.Contains(system, "русском") {
+		t.Fatalf("expected only the english block:\n%s", system)
+	}
+	if !strings.Contains(user, `"lang":"en"`) {
+		t.Fatalf("expected language tag in payload: %s", user)
+	}
+
+	system, user = decode(t, []models.Message{
+		{ID: 1, User: 1, Data: "hola", Lang: "es-MX"},
+		{ID: 2, User: 2, Data: "hi", Lang: "en"},
+		{ID: 3, User: 3, Data: "que tal", Lang: "es"},
+		{ID: 4, User: 4, Data: "bonjour", Lang: "fr"},
+	})
+	if strings.Count(system, "Ejemplos en español") != 1 || !strings.Contains(system, "English examples") || strings.Contains(system, "русском") {
+		t.Fatalf("expected es and en blocks once each:\n%s", system)
+	}
+	if strings.Index(system, "Ejemplos") > strings.Index(system, "English") {
+		t.Fatalf("blocks must follow first appearance order:\n%s", system)
+	}
+	if !strings.Contains(user, `"lang":"es-MX"`) || !strings.Contains(user, `"lang":"fr"`) {
+		t.Fatalf("expected per-message language tags: %s", user)
+	}
+
+	system, user = decode(t, []models.Message{{ID: 1, User: 1, Data: "x"}})
+	if strings.Contains(system, "English examples") || strings.Contains(user, "lang") {
+		t.Fatalf("messages without a language must not get blocks or tags:\n%s\n%s", system, user)
+	}
+}
+
+func TestStrictParsing(t *testing.T) {
+	respond := func(a *DeepSeekAdapter, content string) {
+		body, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": content}}}})
+		a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(string(body))), Header: make(http.Header)}, nil
+		}))
+	}
+	msg := models.Message{ID: 1, User: 1, Data: "x"}
+	long := strings.Repeat("я", maxTriggerLength+1)
+	cases := map[string]string{
+		"confidence": `{"id":1,"a":4,"b":"x","c":1.5,"d":[]}`,
+		"negative":   `{"id":1,"a":4,"b":"x","c":-0.1,"d":[]}`,
+		"token":      `{"id":1,"a":5,"b":"x","c":0.9,"d":["` + long + `"]}`,
+		"status":     `{"id":1,"a":9,"b":"x","c":0.9,"d":[]}`,
+		"malformed":  `{"id":1,"a":`,
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			strict, _ := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", StrictParsing: true})
+			respond(strict, content)
+			_, err := strict.Analyze(context.Background(), msg)
+			var re *AIResponseError
+			if !errors.As(err, &re) || re.Raw != content {
+				t.Fatalf("expected AIResponseError with raw content, got %v", err)
+			}
+		})
+	}
+
+	lenient, _ := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x"})
+	respond(lenient, cases["confidence"])
+	if res, err := lenient.Analyze(context.Background(), msg); err != nil || res.Confidence != 1.5 {
+		t.Fatalf("lenient parsing must keep the result: %+v, %v", res, err)
+	}
+	strict, _ := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", StrictParsing: true})
+	respond(strict, `{"id":1,"a":5,"b":"x","c":0.9,"d":["`+strings.Repeat("я", maxTriggerLength)+`"]}`)
+	if _, err := strict.Analyze(context.Background(), msg); err != nil {
+		t.Fatalf("in-contract response rejected: %v", err)
+	}
+}
+
+func TestMaxPayloadBytesSplitsBatch(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m", MaxPayloadBytes: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests []int
+	a.client.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		raw, _ := io.ReadAll(req.Body)
+		var wire struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal(raw, &wire); err != nil {
+			return nil, err
+		}
+		var in []struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(wire.Messages[len(wire.Messages)-1].Content), &in); err != nil {
+			return nil, err
+		}
+		if len(raw) > 10000 && len(in) > 1 {
+			t.Errorf("request of %d messages is %d bytes, over the limit", len(in), len(raw))
+		}
+		requests = append(requests, len(in))
+		out := make([]map[string]any, 0, len(in))
+		for i := len(in) - 1; i >= 0; i-- {
+			out = append(out, map[string]any{"id": in[i].ID, "a": 2, "b": "x", "c": 0.5, "d": []string{}})
+		}
+		content, _ := json.Marshal(out)
+		body, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": string(content)}}}})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(string(body))), Header: make(http.Header)}, nil
+	}))
+
+	batch := make([]models.Message, 12)
+	for i := range batch {
+		batch[i] = models.Message{ID: int64(i + 1), User: int64(100 + i), Data: strings.Repeat("x", 600)}
+	}
+	out, err := a.AnalyzeBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) < 2 {
+		t.Fatalf("expected the batch to be split, got requests %v", requests)
+	}
+	if len(out) != len(batch) {
+		t.Fatalf("expected %d results, got %d", len(batch), len(out))
+	}
+	for i, r := range out {
+		if r.MessageID != batch[i].ID || r.ViolatorUserID != batch[i].User {
+			t.Fatalf("result %d not aligned: %+v", i, r)
+		}
+	}
+
+	requests = nil
+	huge := models.Message{ID: 1, User: 1, Data: strings.Repeat("y", 20000)}
+	if _, err := a.Analyze(context.Background(), huge); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("single message must be sent as is, got %v", requests)
+	}
+}
+
+func TestRawPreservedPerResult(t *testing.T) {
+	a, err := NewDeepSeekAdapter(DeepSeekOptions{APIKey: "k", BaseURL: "http://x", Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := ""
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		body, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": content}}}})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(string(body))), Header: make(http.Header)}, nil
+	}))
+
+	content = `{"a": 4, "b": "offer", "c": 0.7, "d": ["tg"]}`
+	res, err := a.Analyze(context.Background(), models.Message{ID: 1, User: 2, Data: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Raw != content {
+		t.Fatalf("single raw = %q", res.Raw)
+	}
+
+	content = `[{"f":2,"a":1,"b":"ok","c":0.9,"d":[]}, {"f":1,"a":6,"b":"bad","c":0.95,"d":["x"]}]`
+	batch := []models.Message{{ID: 1, User: 1, Data: "a"}, {ID: 2, User: 2, Data: "b"}}
+	out, err := a.AnalyzeBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Raw != `{"f":1,"a":6,"b":"bad","c":0.95,"d":["x"]}` || out[1].Raw != `{"f":2,"a":1,"b":"ok","c":0.9,"d":[]}` {
+		t.Fatalf("batch raw not kept per message: %+v", out)
+	}
+
+	content = `{"a":2,"b":"abuse","c":0.8,"d":[]}`
+	out, err = a.AnalyzeBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Raw != content || out[1].Raw != content {
+		t.Fatalf("fanned out verdicts must share the raw response: %+v", out)
+	}
+}
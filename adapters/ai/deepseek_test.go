@@ -24,3 +24,15 @@ func TestAlignResultsByMessageID(t *testing.T) {
 		t.Fatalf("unexpected align: %+v", out)
 	}
 }
+
+func TestAlignResultsDuplicateIDsByPosition(t *testing.T) {
+	msgs := []models.Message{{ID: 7, User: 2}, {ID: 7, User: 3}}
+	in := []models.AIResult{{MessageID: 7, StatusCode: models.StatusCritical}, {MessageID: 7, StatusCode: models.StatusClean}}
+	out := alignResults(msgs, in)
+	if len(out) != 2 || out[0].StatusCode != models.StatusCritical || out[1].StatusCode != models.StatusClean {
+		t.Fatalf("unexpected align: %+v", out)
+	}
+	if out[0].ViolatorUserID != 2 || out[1].ViolatorUserID != 3 {
+		t.Fatalf("unexpected users: %+v", out)
+	}
+}
@@ -0,0 +1,165 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GeminiAdapter moderates through the Gemini generateContent API of the
+// Generative Language API, using the same prompt and compact result contract
+// as DeepSeekAdapter.
+type GeminiAdapter struct {
+	chatClient
+}
+
+// GeminiOptions configures adapter.
+type GeminiOptions struct {
+	APIKey string
+	// BaseURL defaults to https://generativelanguage.googleapis.com/v1beta.
+	BaseURL      string
+	Model        string
+	Timeout      time.Duration
+	SystemPrompt string
+	// PlainTextSingle, IncludeTriggers, MaxContextMessages, MaxRetries,
+	// RetryBaseDelay, OnUsage, HTTPClient, Headers, Temperature, MaxTokens
+	// and TopP behave as in DeepSeekOptions.
+	PlainTextSingle    bool
+	IncludeTriggers    bool
+	MaxContextMessages int
+	MaxRetries         int
+	RetryBaseDelay     time.Duration
+	OnUsage            func(Usage)
+	HTTPClient         *http.Client
+	Headers            map[string]string
+	Temperature        *float64
+	MaxTokens          int
+	TopP               *float64
+}
+
+// NewGeminiAdapter creates adapter instance. The key is sent in the
+// x-goog-api-key header.
+func NewGeminiAdapter(opt GeminiOptions) (*GeminiAdapter, error) {
+	if strings.TrimSpace(opt.APIKey) == "" {
+		return nil, errors.New("ai: API key is required")
+	}
+	if strings.TrimSpace(opt.BaseURL) == "" {
+		opt.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+	if strings.TrimSpace(opt.Model) == "" {
+		opt.Model = "gemini-2.0-flash"
+	}
+	if err := validateSampling(opt.Temperature, opt.MaxTokens, opt.TopP); err != nil {
+		return nil, err
+	}
+	client := newChatClient(chatConfig{
+		BaseURL:            opt.BaseURL,
+		Model:              opt.Model,
+		Timeout:            opt.Timeout,
+		SystemPrompt:       opt.SystemPrompt,
+		PlainTextSingle:    opt.PlainTextSingle,
+		IncludeTriggers:    opt.IncludeTriggers,
+		MaxContextMessages: opt.MaxContextMessages,
+		MaxRetries:         opt.MaxRetries,
+		RetryBaseDelay:     opt.RetryBaseDelay,
+		OnUsage:            opt.OnUsage,
+		HTTPClient:         opt.HTTPClient,
+		Headers:            opt.Headers,
+		Temperature:        opt.Temperature,
+		MaxTokens:          opt.MaxTokens,
+		TopP:               opt.TopP,
+	})
+	client.client.SetHeader("x-goog-api-key", opt.APIKey)
+	client.endpoint = client.baseURL + "/models/" + strings.TrimPrefix(opt.Model, "models/") + ":generateContent"
+	client.wire = geminiWire{}
+	return &GeminiAdapter{chatClient: client}, nil
+}
+
+func (g *GeminiAdapter) Name() string { return "gemini" }
+
+// geminiWire speaks the generateContent format: the system prompt goes in
+// systemInstruction and the model answers in candidate parts.
+type geminiWire struct{}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+func (geminiWire) request(_ string, params sampling, system, user string) any {
+	type generationConfig struct {
+		Temperature      float64  `json:"temperature"`
+		MaxOutputTokens  int      `json:"maxOutputTokens,omitempty"`
+		TopP             *float64 `json:"topP,omitempty"`
+		ResponseMimeType string   `json:"responseMimeType"`
+	}
+	type requestPayload struct {
+		SystemInstruction geminiContent    `json:"systemInstruction"`
+		Contents          []geminiContent  `json:"contents"`
+		GenerationConfig  generationConfig `json:"generationConfig"`
+	}
+	return requestPayload{
+		SystemInstruction: geminiContent{Parts: []geminiPart{{Text: system}}},
+		Contents:          []geminiContent{{Role: "user", Parts: []geminiPart{{Text: user}}}},
+		GenerationConfig: generationConfig{
+			Temperature:      params.temperature,
+			MaxOutputTokens:  params.maxTokens,
+			TopP:             params.topP,
+			ResponseMimeType: "application/json",
+		},
+	}
+}
+
+func (geminiWire) content(body []byte) (string, error) { return extractGeminiContent(body) }
+
+// usage maps Gemini's usageMetadata counts.
+func (geminiWire) usage(body []byte) Usage {
+	var resp struct {
+		UsageMetadata struct {
+			PromptTokenCount     int64 `json:"promptTokenCount"`
+			CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+			TotalTokenCount      int64 `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+		CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+	}
+}
+
+// extractGeminiContent joins the text parts of the first candidate. A prompt
+// blocked by Gemini's own safety filters has no candidates and is an error.
+func extractGeminiContent(body []byte) (string, error) {
+	var resp struct {
+		Candidates []struct {
+			Content geminiContent `json:"content"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Candidates) == 0 {
+		if resp.PromptFeedback.BlockReason != "" {
+			return "", errors.New("ai: gemini: prompt blocked: " + resp.PromptFeedback.BlockReason)
+		}
+		return "", errors.New("ai: candidates is empty")
+	}
+	var b strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		b.WriteString(part.Text)
+	}
+	return cleanContent(b.String())
+}
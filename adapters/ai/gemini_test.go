@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+var _ interfaces.BatchAIAnalyzer = (*GeminiAdapter)(nil)
+
+func geminiReply(parts ...string) *http.Response {
+	var ps []map[string]string
+	for _, p := range parts {
+		ps = append(ps, map[string]string{"text": p})
+	}
+	raw, _ := json.Marshal(map[string]any{
+		"candidates": []any{map[string]any{
+			"content":      map[string]any{"role": "model", "parts": ps},
+			"finishReason": "STOP",
+		}},
+		"usageMetadata": map[string]int{"promptTokenCount": 10, "candidatesTokenCount": 4, "totalTokenCount": 14},
+	})
+	return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(string(raw)))}
+}
+
+func TestNewGeminiAdapterValidationAndDefaults(t *testing.T) {
+	if _, err := NewGeminiAdapter(GeminiOptions{}); err == nil {
+		t.Fatalf("expected error")
+	}
+	a, err := NewGeminiAdapter(GeminiOptions{APIKey: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name() != "gemini" || a.endpoint != "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.0-flash:generateContent" {
+		t.Fatalf("unexpected defaults: name=%s endpoint=%s", a.Name(), a.endpoint)
+	}
+}
+
+func TestGeminiAnalyzeSingle(t *testing.T) {
+	var usage Usage
+	a, err := NewGeminiAdapter(GeminiOptions{APIKey: "k", BaseURL: "http://gemini/v1beta/", Model: "models/g", OnUsage: func(u Usage) { usage = u }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1beta/models/g:generateContent" {
+			t.Fatalf("unexpected endpoint: %s", r.URL.Path)
+		}
+		if r.Header.Get("x-goog-api-key") != "k" || r.Header.Get("Authorization") != "" {
+			t.Fatalf("unexpected auth headers: %v", r.Header)
+		}
+		var payload struct {
+			SystemInstruction geminiContent   `json:"systemInstruction"`
+			Contents          []geminiContent `json:"contents"`
+			GenerationConfig  map[string]any  `json:"generationConfig"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if len(payload.SystemInstruction.Parts) != 1 || !strings.Contains(payload.SystemInstruction.Parts[0].Text, "status_code") {
+			t.Fatalf("system prompt not in systemInstruction: %+v", payload.SystemInstruction)
+		}
+		if len(payload.Contents) != 1 || payload.Contents[0].Role != "user" || !strings.Contains(payload.Contents[0].Parts[0].Text, "idiot") {
+			t.Fatalf("unexpected contents: %+v", payload.Contents)
+		}
+		if payload.GenerationConfig["responseMimeType"] != "application/json" {
+			t.Fatalf("unexpected generation config: %v", payload.GenerationConfig)
+		}
+		return geminiReply(`{"a":2,"c":0.7,`, `"d":["idiot"],"f":5}`), nil
+	}))
+	res, err := a.Analyze(context.Background(), models.Message{ID: 5, User: 9, Data: "idiot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != models.StatusNonCriticalAbuse || res.MessageID != 5 || res.ViolatorUserID != 9 || len(res.TriggerTokens) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if usage.PromptTokens != 10 || usage.CompletionTokens != 4 || usage.TotalTokens != 14 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestGeminiAnalyzeBatch(t *testing.T) {
+	a, err := NewGeminiAdapter(GeminiOptions{APIKey: "k", Model: "g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return geminiReply("```json\n[{\"a\":6,\"c\":0.95,\"d\":[\"drugs\"],\"f\":2},{\"a\":1,\"c\":0.9,\"d\":[],\"f\":1}]\n```"), nil
+	}))
+	res, err := a.AnalyzeBatch(context.Background(), []models.Message{{ID: 1, User: 1, Data: "hi"}, {ID: 2, User: 2, Data: "drugs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].StatusCode != models.StatusClean || res[1].StatusCode != models.StatusDangerousIllegal {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestGeminiBlockedPrompt(t *testing.T) {
+	if _, err := extractGeminiContent([]byte(`{"promptFeedback":{"blockReason":"SAFETY"}}`)); err == nil || !strings.Contains(err.Error(), "SAFETY") {
+		t.Fatalf("expected block reason error, got %v", err)
+	}
+	if _, err := extractGeminiContent([]byte(`{"candidates":[]}`)); err == nil {
+		t.Fatalf("expected error on missing candidates")
+	}
+}
@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaAdapter moderates through a local Ollama server's /api/chat endpoint,
+// so message text never leaves the deployment.
+type OllamaAdapter struct {
+	chatClient
+}
+
+// OllamaOptions configures adapter.
+type OllamaOptions struct {
+	BaseURL string
+	Model   string
+	// Timeout defaults to 2 minutes, unless HTTPClient is set; local models
+	// are slow on long batches.
+	Timeout      time.Duration
+	SystemPrompt string
+	// IncludeTriggers, MaxContextMessages, MaxRetries, RetryBaseDelay,
+	// OnUsage, HTTPClient and Headers behave as in DeepSeekOptions.
+	IncludeTriggers    bool
+	MaxContextMessages int
+	MaxRetries         int
+	RetryBaseDelay     time.Duration
+	OnUsage            func(Usage)
+	HTTPClient         *http.Client
+	Headers            map[string]string
+}
+
+const defaultOllamaTimeout = 2 * time.Minute
+
+// NewOllamaAdapter creates adapter instance.
+func NewOllamaAdapter(opt OllamaOptions) (*OllamaAdapter, error) {
+	if strings.TrimSpace(opt.BaseURL) == "" {
+		opt.BaseURL = "http://localhost:11434"
+	}
+	if strings.TrimSpace(opt.Model) == "" {
+		opt.Model = "llama3.1"
+	}
+	if opt.Timeout <= 0 && opt.HTTPClient == nil {
+		opt.Timeout = defaultOllamaTimeout
+	}
+	client := newChatClient(chatConfig{
+		BaseURL:            opt.BaseURL,
+		Model:              opt.Model,
+		Timeout:            opt.Timeout,
+		SystemPrompt:       opt.SystemPrompt,
+		IncludeTriggers:    opt.IncludeTriggers,
+		MaxContextMessages: opt.MaxContextMessages,
+		MaxRetries:         opt.MaxRetries,
+		RetryBaseDelay:     opt.RetryBaseDelay,
+		OnUsage:            opt.OnUsage,
+		HTTPClient:         opt.HTTPClient,
+		Headers:            opt.Headers,
+	})
+	client.endpoint = client.baseURL + "/api/chat"
+	client.wire = ollamaWire{}
+	return &OllamaAdapter{chatClient: client}, nil
+}
+
+func (o *OllamaAdapter) Name() string { return "ollama" }
+
+// ollamaWire speaks Ollama's native chat format.
+type ollamaWire struct{}
+
+func (ollamaWire) request(model string, params sampling, system, user string) any {
+	type requestOptions struct {
+		Temperature float64  `json:"temperature"`
+		NumPredict  int      `json:"num_predict,omitempty"`
+		TopP        *float64 `json:"top_p,omitempty"`
+	}
+	type requestPayload struct {
+		Model    string         `json:"model"`
+		Messages []chatMessage  `json:"messages"`
+		Stream   bool           `json:"stream"`
+		Format   string         `json:"format"`
+		Options  requestOptions `json:"options"`
+	}
+	return requestPayload{
+		Model: model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		Stream: false,
+		Format: "json",
+		Options: requestOptions{
+			Temperature: params.temperature,
+			NumPredict:  params.maxTokens,
+			TopP:        params.topP,
+		},
+	}
+}
+
+// usage maps Ollama's prompt and completion evaluation counts.
+func (ollamaWire) usage(body []byte) Usage {
+	var resp struct {
+		PromptEvalCount int64 `json:"prompt_eval_count"`
+		EvalCount       int64 `json:"eval_count"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+}
+
+func (ollamaWire) content(body []byte) (string, error) {
+	var resp struct {
+		Message *chatMessage `json:"message"`
+		Error   string       `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	if resp.Error != "" {
+		return "", errors.New("ai: ollama: " + resp.Error)
+	}
+	if resp.Message == nil {
+		return "", errors.New("ai: message is empty")
+	}
+	return cleanContent(resp.Message.Content)
+}
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+var _ interfaces.BatchAIAnalyzer = (*OllamaAdapter)(nil)
+
+func ollamaReply(content string) *http.Response {
+	raw, _ := json.Marshal(map[string]any{
+		"model":   "m",
+		"message": map[string]string{"role": "assistant", "content": content},
+		"done":    true,
+	})
+	return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(string(raw)))}
+}
+
+func TestNewOllamaAdapterDefaults(t *testing.T) {
+	a, err := NewOllamaAdapter(OllamaOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name() != "ollama" || a.endpoint != "http://localhost:11434/api/chat" {
+		t.Fatalf("unexpected defaults: name=%s endpoint=%s", a.Name(), a.endpoint)
+	}
+	if a.client.GetClient().Timeout != defaultOllamaTimeout {
+		t.Fatalf("unexpected timeout: %s", a.client.GetClient().Timeout)
+	}
+}
+
+func TestOllamaAnalyzeSingle(t *testing.T) {
+	a, err := NewOllamaAdapter(OllamaOptions{BaseURL: "http://ollama:11434/", Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/api/chat" {
+			t.Fatalf("unexpected endpoint: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Fatalf("unexpected auth header")
+		}
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if payload["format"] != "json" || payload["stream"] != false || payload["model"] != "m" {
+			t.Fatalf("unexpected payload: %v", payload)
+		}
+		return ollamaReply("Sure! Here is the verdict:\n{\"a\":2,\"c\":0.7,\"d\":[\"idiot\"],\"f\":5}\nLet me know."), nil
+	}))
+	res, err := a.Analyze(context.Background(), models.Message{ID: 5, User: 9, Data: "idiot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != models.StatusNonCriticalAbuse || res.MessageID != 5 || res.ViolatorUserID != 9 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestOllamaAnalyzeBatch(t *testing.T) {
+	a, err := NewOllamaAdapter(OllamaOptions{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return ollamaReply("```json\n[{\"a\":1,\"c\":0.9,\"d\":[],\"f\":1},{\"a\":6,\"c\":0.95,\"d\":[\"drugs\"],\"f\":2}]\n```"), nil
+	}))
+	res, err := a.AnalyzeBatch(context.Background(), []models.Message{{ID: 1, User: 1, Data: "hi"}, {ID: 2, User: 2, Data: "drugs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].StatusCode != models.StatusClean || res[1].StatusCode != models.StatusDangerousIllegal {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestOllamaErrorPayload(t *testing.T) {
+	if _, err := (ollamaWire{}).content([]byte(`{"error":"model not found"}`)); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := (ollamaWire{}).content([]byte(`{}`)); err == nil {
+		t.Fatalf("expected error on missing message")
+	}
+}
+
+func TestFirstJSONSkipsProse(t *testing.T) {
+	got, ok := firstJSON(`note {broken and then {"a":1,"d":["}"]} tail`)
+	if !ok || got != `{"a":1,"d":["}"]}` {
+		t.Fatalf("unexpected extraction: %q ok=%v", got, ok)
+	}
+	if _, ok := firstJSON("no json here"); ok {
+		t.Fatalf("expected no match")
+	}
+}
@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIAdapter moderates through the OpenAI chat completions API using the
+// same prompt and compact result contract as DeepSeekAdapter.
+type OpenAIAdapter struct {
+	chatClient
+}
+
+// OpenAIOptions configures adapter.
+type OpenAIOptions struct {
+	APIKey       string
+	BaseURL      string
+	Model        string
+	Timeout      time.Duration
+	SystemPrompt string
+	// PlainTextSingle, IncludeTriggers, MaxContextMessages, MaxRetries,
+	// RetryBaseDelay, OnUsage, HTTPClient, Headers, Temperature, MaxTokens
+	// and TopP behave as in DeepSeekOptions.
+	PlainTextSingle    bool
+	IncludeTriggers    bool
+	MaxContextMessages int
+	MaxRetries         int
+	RetryBaseDelay     time.Duration
+	OnUsage            func(Usage)
+	HTTPClient         *http.Client
+	Headers            map[string]string
+	Temperature        *float64
+	MaxTokens          int
+	TopP               *float64
+}
+
+// NewOpenAIAdapter creates adapter instance.
+func NewOpenAIAdapter(opt OpenAIOptions) (*OpenAIAdapter, error) {
+	if strings.TrimSpace(opt.APIKey) == "" {
+		return nil, errors.New("ai: API key is required")
+	}
+	if strings.TrimSpace(opt.BaseURL) == "" {
+		opt.BaseURL = "https://api.openai.com/v1"
+	}
+	if strings.TrimSpace(opt.Model) == "" {
+		opt.Model = "gpt-4o-mini"
+	}
+	if err := validateSampling(opt.Temperature, opt.MaxTokens, opt.TopP); err != nil {
+		return nil, err
+	}
+	return &OpenAIAdapter{chatClient: newChatClient(chatConfig{
+		APIKey:             opt.APIKey,
+		BaseURL:            opt.BaseURL,
+		Model:              opt.Model,
+		Timeout:            opt.Timeout,
+		SystemPrompt:       opt.SystemPrompt,
+		PlainTextSingle:    opt.PlainTextSingle,
+		IncludeTriggers:    opt.IncludeTriggers,
+		MaxContextMessages: opt.MaxContextMessages,
+		MaxRetries:         opt.MaxRetries,
+		RetryBaseDelay:     opt.RetryBaseDelay,
+		OnUsage:            opt.OnUsage,
+		HTTPClient:         opt.HTTPClient,
+		Headers:            opt.Headers,
+		Temperature:        opt.Temperature,
+		MaxTokens:          opt.MaxTokens,
+		TopP:               opt.TopP,
+	})}, nil
+}
+
+func (o *OpenAIAdapter) Name() string { return "openai" }
@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+var _ interfaces.BatchAIAnalyzer = (*OpenAIAdapter)(nil)
+
+func TestNewOpenAIAdapterValidationAndDefaults(t *testing.T) {
+	if _, err := NewOpenAIAdapter(OpenAIOptions{}); err == nil {
+		t.Fatalf("expected error")
+	}
+	a, err := NewOpenAIAdapter(OpenAIOptions{APIKey: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name() != "openai" || a.model != "gpt-4o-mini" {
+		t.Fatalf("unexpected defaults: name=%s model=%s", a.Name(), a.model)
+	}
+	if a.endpoint != "https://api.openai.com/v1/chat/completions" {
+		t.Fatalf("unexpected endpoint: %s", a.endpoint)
+	}
+}
+
+func TestOpenAIAnalyzeBatchHTTP(t *testing.T) {
+	a, err := NewOpenAIAdapter(OpenAIOptions{APIKey: "k", Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Fatalf("unexpected endpoint: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer k" {
+			t.Fatalf("missing auth header")
+		}
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		rf, ok := payload["response_format"].(map[string]any)
+		if !ok || rf["type"] != "json_object" || payload["model"] != "m" {
+			t.Fatalf("unexpected payload: %v", payload)
+		}
+		body := `{"choices":[{"message":{"content":"[{\"a\":1,\"c\":0.9,\"d\":[],\"f\":2},{\"a\":5,\"c\":0.8,\"d\":[\"card\"],\"f\":1}]"}}]}`
+		return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}, nil
+	}))
+	res, err := a.AnalyzeBatch(context.Background(), []models.Message{{ID: 1, User: 7, Data: "pay to card"}, {ID: 2, User: 8, Data: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].MessageID != 1 || res[0].StatusCode != models.StatusCommercialOffPlatform || res[0].ViolatorUserID != 7 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res[1].MessageID != 2 || res[1].StatusCode != models.StatusClean {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestOpenAIRateLimit(t *testing.T) {
+	a, err := NewOpenAIAdapter(OpenAIOptions{APIKey: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.client.SetTransport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Retry-After", "3")
+		return &http.Response{StatusCode: 429, Header: h, Body: io.NopCloser(strings.NewReader("quota"))}, nil
+	}))
+	_, err = a.Analyze(context.Background(), models.Message{ID: 1, User: 2, Data: "x"})
+	var rl *RateLimitError
+	if !errors.As(err, &rl) || rl.RetryAfter().Seconds() != 3 {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+}
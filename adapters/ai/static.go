@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// StaticAnalyzer answers without calling a model, from a fixed verdict or a
+// rule. It lets a deployment run the trigger filter and callbacks end to end,
+// e.g. to measure trigger hit rates before paying for AI calls.
+type StaticAnalyzer struct {
+	name string
+	rule func(models.Message) models.AIResult
+}
+
+// NewStaticAnalyzer returns result for every message, with MessageID set to
+// the message's and ViolatorUserID defaulting to its User.
+func NewStaticAnalyzer(result models.AIResult) *StaticAnalyzer {
+	return &StaticAnalyzer{name: "static", rule: func(models.Message) models.AIResult {
+		r := result
+		r.TriggerTokens = append([]string(nil), result.TriggerTokens...)
+		return r
+	}}
+}
+
+// NewRuleAnalyzer derives each verdict from the message with rule. IDs are
+// filled in as by NewStaticAnalyzer. A nil rule judges every message clean.
+func NewRuleAnalyzer(rule func(models.Message) models.AIResult) *StaticAnalyzer {
+	if rule == nil {
+		rule = func(models.Message) models.AIResult { return models.AIResult{StatusCode: models.StatusClean} }
+	}
+	return &StaticAnalyzer{name: "rule", rule: rule}
+}
+
+func (s *StaticAnalyzer) Name() string { return s.name }
+
+func (s *StaticAnalyzer) Analyze(ctx context.Context, message models.Message) (models.AIResult, error) {
+	if err := ctx.Err(); err != nil {
+		return models.AIResult{}, err
+	}
+	return s.judge(message), nil
+}
+
+// AnalyzeBatch returns one result per message in input order.
+func (s *StaticAnalyzer) AnalyzeBatch(ctx context.Context, messages []models.Message) ([]models.AIResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	out := make([]models.AIResult, len(messages))
+	for i, msg := range messages {
+		out[i] = s.judge(msg)
+	}
+	return out, nil
+}
+
+func (s *StaticAnalyzer) judge(message models.Message) models.AIResult {
+	r := s.rule(message)
+	r.MessageID = message.ID
+	if r.ViolatorUserID == 0 {
+		r.ViolatorUserID = message.User
+	}
+	return r
+}
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+var _ interfaces.BatchAIAnalyzer = (*StaticAnalyzer)(nil)
+
+func TestStaticAnalyzerFillsIDs(t *testing.T) {
+	a := NewStaticAnalyzer(models.AIResult{StatusCode: models.StatusHumanReview, Confidence: 0.5, TriggerTokens: []string{"x"}, MessageID: 99})
+	if a.Name() != "static" {
+		t.Fatalf("unexpected name %q", a.Name())
+	}
+	res, err := a.AnalyzeBatch(context.Background(), []models.Message{{ID: 1, User: 10}, {ID: 2, User: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("unexpected results: %+v", res)
+	}
+	for i, want := range []struct{ id, user int64 }{{1, 10}, {2, 20}} {
+		r := res[i]
+		if r.MessageID != want.id || r.ViolatorUserID != want.user || r.StatusCode != models.StatusHumanReview || r.Confidence != 0.5 {
+			t.Fatalf("result %d: unexpected %+v", i, r)
+		}
+	}
+	res[0].TriggerTokens[0] = "changed"
+	if res[1].TriggerTokens[0] != "x" {
+		t.Fatal("results must not share trigger slices")
+	}
+
+	single, err := a.Analyze(context.Background(), models.Message{ID: 3, User: 30})
+	if err != nil || single.MessageID != 3 || single.ViolatorUserID != 30 {
+		t.Fatalf("unexpected single result %+v, err=%v", single, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := a.Analyze(ctx, models.Message{ID: 4}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context error, got %v", err)
+	}
+}
+
+func TestRuleAnalyzer(t *testing.T) {
+	a := NewRuleAnalyzer(func(m models.Message) models.AIResult {
+		if len(m.Triggers) > 0 && strings.Contains(m.Data, "pay") {
+			return models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.8, TriggerTokens: m.Triggers, ViolatorUserID: 7}
+		}
+		return models.AIResult{StatusCode: models.StatusClean, Confidence: 1}
+	})
+	if a.Name() != "rule" {
+		t.Fatalf("unexpected name %q", a.Name())
+	}
+	res, err := a.AnalyzeBatch(context.Background(), []models.Message{
+		{ID: 1, User: 1, Data: "pay me offsite", Triggers: []string{"offsite"}},
+		{ID: 2, User: 2, Data: "hello"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].StatusCode != models.StatusCommercialOffPlatform || res[0].MessageID != 1 || res[0].ViolatorUserID != 7 {
+		t.Fatalf("unexpected rule result %+v", res[0])
+	}
+	if res[1].StatusCode != models.StatusClean || res[1].MessageID != 2 || res[1].ViolatorUserID != 2 {
+		t.Fatalf("unexpected rule result %+v", res[1])
+	}
+
+	clean, err := NewRuleAnalyzer(nil).Analyze(context.Background(), models.Message{ID: 5, User: 6})
+	if err != nil || clean.StatusCode != models.StatusClean || clean.MessageID != 5 || clean.ViolatorUserID != 6 {
+		t.Fatalf("nil rule must judge clean, got %+v, err=%v", clean, err)
+	}
+}
@@ -0,0 +1,79 @@
+// Package metrics exports censor counters to Prometheus. It is a module of
+// its own, so the censor module does not depend on the Prometheus client.
+package metrics
+
+import (
+	"time"
+
+	"github.com/elum-utils/censor/core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector exports Core counters. Values are read on every scrape,
+// so the collector holds no state of its own.
+type PrometheusCollector struct {
+	core *core.Core
+
+	processed     *prometheus.Desc
+	aiCalls       *prometheus.Desc
+	aiErrors      *prometheus.Desc
+	cacheHits     *prometheus.Desc
+	cacheMisses   *prometheus.Desc
+	lookups       *prometheus.Desc
+	lastLookup    *prometheus.Desc
+	tokenHits     *prometheus.Desc
+	tokens        *prometheus.Desc
+	reloads       *prometheus.Desc
+	lastReloadDur *prometheus.Desc
+}
+
+// NewPrometheusCollector creates a collector reading from c.
+func NewPrometheusCollector(c *core.Core) prometheus.Collector {
+	return &PrometheusCollector{
+		core:          c,
+		processed:     prometheus.NewDesc("censor_processed_total", "Processed messages by final status.", []string{"status"}, nil),
+		aiCalls:       prometheus.NewDesc("censor_ai_calls_total", "AI analyzer requests.", nil, nil),
+		aiErrors:      prometheus.NewDesc("censor_ai_errors_total", "Failed AI analyzer requests.", nil, nil),
+		cacheHits:     prometheus.NewDesc("censor_cache_hits_total", "Verdict cache hits.", nil, nil),
+		cacheMisses:   prometheus.NewDesc("censor_cache_misses_total", "Verdict cache misses.", nil, nil),
+		lookups:       prometheus.NewDesc("censor_engine_lookups_total", "Trigger engine lookups.", nil, nil),
+		lastLookup:    prometheus.NewDesc("censor_engine_last_lookup_seconds", "Duration of the latest trigger lookup.", nil, nil),
+		tokenHits:     prometheus.NewDesc("censor_engine_token_hits_total", "Trigger tokens matched by the engine.", nil, nil),
+		tokens:        prometheus.NewDesc("censor_tokens", "Trigger tokens loaded in memory.", nil, nil),
+		reloads:       prometheus.NewDesc("censor_engine_reloads_total", "Full token set reloads.", nil, nil),
+		lastReloadDur: prometheus.NewDesc("censor_engine_last_reload_seconds", "Duration of the latest token reload.", nil, nil),
+	}
+}
+
+func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.processed
+	ch <- p.aiCalls
+	ch <- p.aiErrors
+	ch <- p.cacheHits
+	ch <- p.cacheMisses
+	ch <- p.lookups
+	ch <- p.lastLookup
+	ch <- p.tokenHits
+	ch <- p.tokens
+	ch <- p.reloads
+	ch <- p.lastReloadDur
+}
+
+func (p *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	for code, n := range p.core.Metrics() {
+		ch <- prometheus.MustNewConstMetric(p.processed, prometheus.CounterValue, float64(n), code.String())
+	}
+	rt := p.core.RuntimeStats()
+	ch <- prometheus.MustNewConstMetric(p.aiCalls, prometheus.CounterValue, float64(rt.AICalls))
+	ch <- prometheus.MustNewConstMetric(p.aiErrors, prometheus.CounterValue, float64(rt.AIErrors))
+	ch <- prometheus.MustNewConstMetric(p.cacheHits, prometheus.CounterValue, float64(rt.CacheHits))
+	ch <- prometheus.MustNewConstMetric(p.cacheMisses, prometheus.CounterValue, float64(rt.CacheMisses))
+
+	es := p.core.EngineStats()
+	ch <- prometheus.MustNewConstMetric(p.lookups, prometheus.CounterValue, float64(es.TotalLookups))
+	ch <- prometheus.MustNewConstMetric(p.lastLookup, prometheus.GaugeValue, time.Duration(es.LastLookupNanos).Seconds())
+	ch <- prometheus.MustNewConstMetric(p.tokenHits, prometheus.CounterValue, float64(es.TotalTokenHits))
+	ch <- prometheus.MustNewConstMetric(p.tokens, prometheus.GaugeValue, float64(p.core.TokenCount()))
+	ch <- prometheus.MustNewConstMetric(p.reloads, prometheus.CounterValue, float64(es.TotalReloadCount))
+	ch <- prometheus.MustNewConstMetric(p.lastReloadDur, prometheus.GaugeValue, time.Duration(es.LastReloadNanos).Seconds())
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/elum-utils/censor/adapters/storage"
+	"github.com/elum-utils/censor/core"
+	"github.com/elum-utils/censor/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type stubAI struct{}
+
+func (stubAI) Name() string { return "stub" }
+
+func (stubAI) Analyze(_ context.Context, m models.Message) (models.AIResult, error) {
+	return models.AIResult{StatusCode: models.StatusNonCriticalAbuse, Confidence: 0.5, MessageID: m.ID}, nil
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryAdapter()
+	_ = st.AddToken(ctx, "bad")
+	c := core.New(core.Options{AIAnalyzer: stubAI{}, Storage: st})
+	defer c.Close()
+	if err := c.SyncOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []models.Message{{ID: 1, User: 1, Data: "hello"}, {ID: 2, User: 1, Data: "bad"}, {ID: 3, User: 1, Data: "bad"}} {
+		if _, err := c.ProcessMessage(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(NewPrometheusCollector(c)); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP censor_ai_calls_total AI analyzer requests.
+# TYPE censor_ai_calls_total counter
+censor_ai_calls_total 1
+# HELP censor_cache_hits_total Verdict cache hits.
+# TYPE censor_cache_hits_total counter
+censor_cache_hits_total 1
+# HELP censor_cache_misses_total Verdict cache misses.
+# TYPE censor_cache_misses_total counter
+censor_cache_misses_total 1
+# HELP censor_tokens Trigger tokens loaded in memory.
+# TYPE censor_tokens gauge
+censor_tokens 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "censor_ai_calls_total", "censor_cache_hits_total", "censor_cache_misses_total", "censor_tokens"); err != nil {
+		t.Fatal(err)
+	}
+	processed := `
+# HELP censor_processed_total Processed messages by final status.
+# TYPE censor_processed_total counter
+censor_processed_total{status="clean"} 1
+censor_processed_total{status="commercial_off_platform"} 0
+censor_processed_total{status="dangerous_illegal"} 0
+censor_processed_total{status="human_review"} 0
+censor_processed_total{status="non_critical_abuse"} 2
+censor_processed_total{status="suspicious"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(processed), "censor_processed_total"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testutil.GatherAndCount(reg); err != nil || n < 11 {
+		t.Fatalf("unexpected metric count %d: %v", n, err)
+	}
+}
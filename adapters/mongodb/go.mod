@@ -0,0 +1,22 @@
+module github.com/elum-utils/censor/adapters/mongodb
+
+go 1.25.4
+
+require (
+	github.com/elum-utils/censor v0.0.0
+	go.mongodb.org/mongo-driver/v2 v2.9.1
+)
+
+require (
+	github.com/klauspost/compress v1.19.2 // indirect
+	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
+	github.com/xdg-go/scram v1.2.0 // indirect
+	github.com/xdg-go/stringprep v1.0.4 // indirect
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78 // indirect
+	golang.org/x/crypto v0.54.0 // indirect
+	golang.org/x/sync v0.22.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+)
+
+// The adapter is developed against the censor module in this repository.
+replace github.com/elum-utils/censor => ../..
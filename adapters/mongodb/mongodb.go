@@ -0,0 +1,136 @@
+// Package mongodb stores censor tokens in MongoDB. It is a module of its own,
+// so the censor module does not depend on the MongoDB driver.
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// collection is the part of *mongo.Collection the token operations use, so
+// tests can record the filters and updates without a server.
+type collection interface {
+	UpdateOne(ctx context.Context, filter, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error)
+	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...options.Lister[options.BulkWriteOptions]) (*mongo.BulkWriteResult, error)
+	DeleteOne(ctx context.Context, filter any, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error)
+	Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) (*mongo.Cursor, error)
+	CountDocuments(ctx context.Context, filter any, opts ...options.Lister[options.CountOptions]) (int64, error)
+}
+
+// MongoAdapter keeps tokens as {token: <value>} documents in a collection.
+// Run EnsureIndexes once so the token field is unique.
+type MongoAdapter struct {
+	coll *mongo.Collection
+	ops  collection
+}
+
+// NewMongoAdapter creates an adapter over coll.
+func NewMongoAdapter(coll *mongo.Collection) (*MongoAdapter, error) {
+	if coll == nil {
+		return nil, errors.New("mongodb: collection is nil")
+	}
+	return &MongoAdapter{coll: coll, ops: coll}, nil
+}
+
+// Ping checks the connection of the collection's client.
+func (m *MongoAdapter) Ping(ctx context.Context) error {
+	return m.coll.Database().Client().Ping(ctx, nil)
+}
+
+// EnsureIndexes creates the unique index on token if missing.
+func (m *MongoAdapter) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "token", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("token_unique"),
+	})
+	return err
+}
+
+// AddToken upserts token; adding an existing token is a no-op.
+func (m *MongoAdapter) AddToken(ctx context.Context, token string) error {
+	filter := tokenFilter(token)
+	update := bson.D{{Key: "$setOnInsert", Value: filter}}
+	_, err := m.ops.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	return ignoreDuplicateKey(err)
+}
+
+// AddTokens upserts all tokens in one unordered bulk write.
+func (m *MongoAdapter) AddTokens(ctx context.Context, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, len(tokens))
+	for i, token := range tokens {
+		filter := tokenFilter(token)
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.D{{Key: "$setOnInsert", Value: filter}}).
+			SetUpsert(true)
+	}
+	_, err := m.ops.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return ignoreDuplicateKey(err)
+}
+
+func tokenFilter(token string) bson.D { return bson.D{{Key: "token", Value: token}} }
+
+// ignoreDuplicateKey drops the error of an upsert that lost a race with a
+// concurrent insert of the same token.
+func ignoreDuplicateKey(err error) error {
+	if err != nil && mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (m *MongoAdapter) RemoveToken(ctx context.Context, token string) error {
+	_, err := m.ops.DeleteOne(ctx, tokenFilter(token))
+	return err
+}
+
+func (m *MongoAdapter) GetTokens(ctx context.Context) ([]string, error) {
+	var out []string
+	err := m.GetTokensFunc(ctx, func(token string) error {
+		out = append(out, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetTokensFunc walks the collection with a cursor without buffering the
+// whole set.
+func (m *MongoAdapter) GetTokensFunc(ctx context.Context, fn func(token string) error) error {
+	opts := options.Find().SetProjection(bson.D{{Key: "token", Value: 1}, {Key: "_id", Value: 0}})
+	cur, err := m.ops.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var doc struct {
+			Token string `bson:"token"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return err
+		}
+		if err := fn(doc.Token); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (m *MongoAdapter) TokenExists(ctx context.Context, token string) (bool, error) {
+	n, err := m.ops.CountDocuments(ctx, tokenFilter(token), options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
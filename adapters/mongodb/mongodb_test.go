@@ -0,0 +1,189 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/interfaces"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var _ interfaces.StreamStorage = (*MongoAdapter)(nil)
+var _ interfaces.BulkStorage = (*MongoAdapter)(nil)
+var _ interfaces.PingStorage = (*MongoAdapter)(nil)
+
+func TestNewMongoAdapterNilCollection(t *testing.T) {
+	if _, err := NewMongoAdapter(nil); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// stubCollection records the token operations instead of sending them.
+type stubCollection struct {
+	filters []any
+	updates []any
+	upserts []bool
+	bulk    []mongo.WriteModel
+	ordered bool
+	docs    []any
+	err     error
+}
+
+func (s *stubCollection) UpdateOne(_ context.Context, filter, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error) {
+	var o options.UpdateOneOptions
+	for _, opt := range opts {
+		for _, set := range opt.List() {
+			_ = set(&o)
+		}
+	}
+	s.filters = append(s.filters, filter)
+	s.updates = append(s.updates, update)
+	s.upserts = append(s.upserts, o.Upsert != nil && *o.Upsert)
+	return &mongo.UpdateResult{}, s.err
+}
+
+func (s *stubCollection) BulkWrite(_ context.Context, models []mongo.WriteModel, opts ...options.Lister[options.BulkWriteOptions]) (*mongo.BulkWriteResult, error) {
+	o := options.BulkWriteOptions{Ordered: &options.DefaultOrdered}
+	for _, opt := range opts {
+		for _, set := range opt.List() {
+			_ = set(&o)
+		}
+	}
+	s.bulk = append(s.bulk, models...)
+	s.ordered = *o.Ordered
+	return &mongo.BulkWriteResult{}, s.err
+}
+
+func (s *stubCollection) DeleteOne(_ context.Context, filter any, _ ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error) {
+	s.filters = append(s.filters, filter)
+	return &mongo.DeleteResult{}, s.err
+}
+
+func (s *stubCollection) Find(context.Context, any, ...options.Lister[options.FindOptions]) (*mongo.Cursor, error) {
+	return mongo.NewCursorFromDocuments(s.docs, nil, nil)
+}
+
+func (s *stubCollection) CountDocuments(_ context.Context, filter any, _ ...options.Lister[options.CountOptions]) (int64, error) {
+	s.filters = append(s.filters, filter)
+	return int64(len(s.docs)), s.err
+}
+
+func TestMongoAdapterOperations(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubCollection{}
+	a := &MongoAdapter{ops: stub}
+	want := bson.D{{Key: "token", Value: "spam"}}
+
+	if err := a.AddToken(ctx, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(stub.filters[0]) != fmt.Sprint(want) || !stub.upserts[0] {
+		t.Fatalf("AddToken must upsert by token: filter=%v upsert=%v", stub.filters[0], stub.upserts[0])
+	}
+	if got := fmt.Sprint(stub.updates[0]); got != fmt.Sprint(bson.D{{Key: "$setOnInsert", Value: want}}) {
+		t.Fatalf("AddToken must only set the token on insert: %s", got)
+	}
+
+	if err := a.AddTokens(ctx, nil); err != nil || len(stub.bulk) != 0 {
+		t.Fatalf("empty AddTokens must not write: %v", err)
+	}
+	if err := a.AddTokens(ctx, []string{"spam", "scam"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.bulk) != 2 || stub.ordered {
+		t.Fatalf("AddTokens must send one unordered bulk write: %d models, ordered=%v", len(stub.bulk), stub.ordered)
+	}
+	m := stub.bulk[1].(*mongo.UpdateOneModel)
+	if fmt.Sprint(m.Filter) != fmt.Sprint(bson.D{{Key: "token", Value: "scam"}}) || m.Upsert == nil || !*m.Upsert {
+		t.Fatalf("unexpected bulk model: %+v", m)
+	}
+
+	stub.err = mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}
+	if err := a.AddToken(ctx, "spam"); err != nil {
+		t.Fatalf("a lost upsert race must be ignored: %v", err)
+	}
+	stub.err = mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Code: 11000}}}}
+	if err := a.AddTokens(ctx, []string{"spam"}); err != nil {
+		t.Fatalf("a lost bulk upsert race must be ignored: %v", err)
+	}
+	stub.err = mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 2}}}
+	if err := a.AddToken(ctx, "spam"); err == nil {
+		t.Fatalf("other write errors must be returned")
+	}
+	stub.err = nil
+
+	stub.filters = nil
+	if err := a.RemoveToken(ctx, "spam"); err != nil || fmt.Sprint(stub.filters[0]) != fmt.Sprint(want) {
+		t.Fatalf("RemoveToken must delete by token: %v err=%v", stub.filters, err)
+	}
+
+	stub.docs = []any{bson.D{{Key: "token", Value: "spam"}}, bson.D{{Key: "token", Value: "buy now"}}}
+	got, err := a.GetTokens(ctx)
+	if err != nil || strings.Join(got, ",") != "spam,buy now" {
+		t.Fatalf("unexpected tokens %v err=%v", got, err)
+	}
+	if ok, err := a.TokenExists(ctx, "spam"); err != nil || !ok {
+		t.Fatalf("TokenExists(spam) = %v, %v", ok, err)
+	}
+}
+
+// TestMongoAdapter runs against a real server.
+//
+// Required env:
+//
+//	CENSOR_IT_MONGO_URI (e.g. mongodb://localhost:27017)
+func TestMongoAdapter(t *testing.T) {
+	uri := strings.TrimSpace(os.Getenv("CENSOR_IT_MONGO_URI"))
+	if uri == "" {
+		t.Skip("set CENSOR_IT_MONGO_URI to run the mongo adapter test")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	coll := client.Database("censor_test").Collection(fmt.Sprintf("tokens_%d", time.Now().UnixNano()))
+	defer func() { _ = coll.Drop(context.Background()) }()
+
+	a, _ := NewMongoAdapter(coll)
+	if err := a.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("EnsureIndexes must be idempotent: %v", err)
+	}
+	for _, token := range []string{"spam", "spam", "buy now"} {
+		if err := a.AddToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.AddTokens(ctx, []string{"spam", "scam", "scam"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.GetTokens(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "buy now,scam,spam" {
+		t.Fatalf("unexpected tokens %v", got)
+	}
+	if ok, err := a.TokenExists(ctx, "scam"); err != nil || !ok {
+		t.Fatalf("TokenExists(scam) = %v, %v", ok, err)
+	}
+	if err := a.RemoveToken(ctx, "scam"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.TokenExists(ctx, "scam"); err != nil || ok {
+		t.Fatalf("removed token reported: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+const (
+	defaultCachedTTL     = time.Minute
+	defaultCachedEntries = 10000
+)
+
+// CachedStorage caches TokenExists answers of another storage in an LRU with
+// TTL. Writes through it invalidate the affected tokens; writes made to the
+// inner storage directly are seen once their entries expire.
+type CachedStorage struct {
+	inner interfaces.Storage
+	ttl   time.Duration
+	max   int
+	now   func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	// gen changes on every write so a lookup that raced with one is not
+	// cached.
+	gen uint64
+}
+
+type existsEntry struct {
+	token     string
+	exists    bool
+	expiresAt time.Time
+}
+
+// Cached wraps inner with a TokenExists cache of up to maxEntries tokens
+// (default 10000), each kept for ttl (default 1m). Reads, statuses and
+// metadata pass through.
+func Cached(inner interfaces.Storage, ttl time.Duration, maxEntries int) *CachedStorage {
+	if ttl <= 0 {
+		ttl = defaultCachedTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultCachedEntries
+	}
+	return &CachedStorage{
+		inner: inner,
+		ttl:   ttl,
+		max:   maxEntries,
+		now:   time.Now,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+func (c *CachedStorage) AddToken(ctx context.Context, token string) error {
+	defer c.invalidate(token)
+	return c.inner.AddToken(ctx, token)
+}
+
+// AddTokens uses the inner bulk insert when available.
+func (c *CachedStorage) AddTokens(ctx context.Context, tokens []string) error {
+	defer c.invalidate(tokens...)
+	if bs, ok := c.inner.(interfaces.BulkStorage); ok {
+		return bs.AddTokens(ctx, tokens)
+	}
+	for _, token := range tokens {
+		if err := c.inner.AddToken(ctx, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CachedStorage) RemoveToken(ctx context.Context, token string) error {
+	defer c.invalidate(token)
+	return c.inner.RemoveToken(ctx, token)
+}
+
+func (c *CachedStorage) GetTokens(ctx context.Context) ([]string, error) {
+	return c.inner.GetTokens(ctx)
+}
+
+// GetTokensFunc streams from the inner storage when it supports it.
+func (c *CachedStorage) GetTokensFunc(ctx context.Context, fn func(token string) error) error {
+	if ss, ok := c.inner.(interfaces.StreamStorage); ok {
+		return ss.GetTokensFunc(ctx, fn)
+	}
+	tokens, err := c.inner.GetTokens(ctx)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTokenStatuses passes through to the inner storage. It returns nil when
+// the inner storage keeps no statuses.
+func (c *CachedStorage) GetTokenStatuses(ctx context.Context) (map[string]models.StatusCode, error) {
+	if ss, ok := c.inner.(interfaces.StatusStorage); ok {
+		return ss.GetTokenStatuses(ctx)
+	}
+	return nil, nil
+}
+
+// SetTokenStatus passes through to the inner storage.
+func (c *CachedStorage) SetTokenStatus(ctx context.Context, token string, status models.StatusCode) error {
+	if ss, ok := c.inner.(interfaces.StatusStorage); ok {
+		return ss.SetTokenStatus(ctx, token, status)
+	}
+	return errors.New("storage: inner storage does not support token statuses")
+}
+
+// AddTokenMeta passes through to the inner storage. Without metadata support
+// there, only the token value is stored.
+func (c *CachedStorage) AddTokenMeta(ctx context.Context, token models.Token) error {
+	defer c.invalidate(token.Value)
+	if rs, ok := c.inner.(interfaces.RichStorage); ok {
+		return rs.AddTokenMeta(ctx, token)
+	}
+	return c.inner.AddToken(ctx, token.Value)
+}
+
+// AddTokensMeta uses the inner bulk metadata write when available.
+func (c *CachedStorage) AddTokensMeta(ctx context.Context, tokens []models.Token) error {
+	values := make([]string, len(tokens))
+	for i, token := range tokens {
+		values[i] = token.Value
+	}
+	defer c.invalidate(values...)
+	if bs, ok := c.inner.(interfaces.BulkRichStorage); ok {
+		return bs.AddTokensMeta(ctx, tokens)
+	}
+	if rs, ok := c.inner.(interfaces.RichStorage); ok {
+		for _, token := range tokens {
+			if err := rs.AddTokenMeta(ctx, token); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c.AddTokens(ctx, values)
+}
+
+// GetTokensMeta passes through to the inner storage. Without metadata support
+// there, tokens come back with empty metadata.
+func (c *CachedStorage) GetTokensMeta(ctx context.Context) ([]models.Token, error) {
+	if rs, ok := c.inner.(interfaces.RichStorage); ok {
+		return rs.GetTokensMeta(ctx)
+	}
+	tokens, err := c.inner.GetTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.Token, len(tokens))
+	for i, token := range tokens {
+		out[i] = models.Token{Value: token}
+	}
+	return out, nil
+}
+
+// GetTokensMetaFunc streams from the inner storage when it supports it.
+func (c *CachedStorage) GetTokensMetaFunc(ctx context.Context, fn func(token models.Token) error) error {
+	if ss, ok := c.inner.(interfaces.StreamRichStorage); ok {
+		return ss.GetTokensMetaFunc(ctx, fn)
+	}
+	if _, ok := c.inner.(interfaces.RichStorage); !ok {
+		return c.GetTokensFunc(ctx, func(token string) error {
+			return fn(models.Token{Value: token})
+		})
+	}
+	tokens, err := c.GetTokensMeta(ctx)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping pings the inner storage, bypassing the cache.
+func (c *CachedStorage) Ping(ctx context.Context) error {
+	if ps, ok := c.inner.(interfaces.PingStorage); ok {
+		return ps.Ping(ctx)
+	}
+	_, err := c.inner.TokenExists(ctx, "")
+	return err
+}
+
+// TokenExists answers from the cache, asking the inner storage on a miss.
+// Errors are not cached.
+func (c *CachedStorage) TokenExists(ctx context.Context, token string) (bool, error) {
+	now := c.now()
+	c.mu.Lock()
+	if elem, ok := c.items[token]; ok {
+		entry := elem.Value.(*existsEntry)
+		if now.Before(entry.expiresAt) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.exists, nil
+		}
+		c.removeElement(elem)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	exists, err := c.inner.TokenExists(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.setLocked(token, exists, now)
+	}
+	c.mu.Unlock()
+	return exists, nil
+}
+
+// Len returns the number of cached answers, including expired ones not yet
+// evicted.
+func (c *CachedStorage) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *CachedStorage) setLocked(token string, exists bool, now time.Time) {
+	if elem, ok := c.items[token]; ok {
+		c.removeElement(elem)
+	}
+	c.items[token] = c.lru.PushFront(&existsEntry{token: token, exists: exists, expiresAt: now.Add(c.ttl)})
+	for c.lru.Len() > c.max {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *CachedStorage) invalidate(tokens ...string) {
+	c.mu.Lock()
+	c.gen++
+	for _, token := range tokens {
+		if elem, ok := c.items[token]; ok {
+			c.removeElement(elem)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *CachedStorage) removeElement(elem *list.Element) {
+	delete(c.items, elem.Value.(*existsEntry).token)
+	c.lru.Remove(elem)
+}
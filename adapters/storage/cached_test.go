@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+)
+
+type countingExists struct {
+	*MemoryAdapter
+	lookups atomic.Int64
+}
+
+func (c *countingExists) TokenExists(ctx context.Context, token string) (bool, error) {
+	c.lookups.Add(1)
+	return c.MemoryAdapter.TokenExists(ctx, token)
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingExists{MemoryAdapter: NewMemoryAdapter()}
+	_ = inner.AddToken(ctx, "a")
+	c := Cached(inner, time.Minute, 2)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, err := c.TokenExists(ctx, "a"); err != nil || !ok {
+			t.Fatalf("a: ok=%v err=%v", ok, err)
+		}
+		if ok, _ := c.TokenExists(ctx, "b"); ok {
+			t.Fatal("b must not exist")
+		}
+	}
+	if got := inner.lookups.Load(); got != 2 {
+		t.Fatalf("inner lookups = %d, want 2", got)
+	}
+
+	if err := c.AddToken(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.TokenExists(ctx, "b"); !ok {
+		t.Fatal("AddToken must invalidate the cached miss")
+	}
+	if err := c.RemoveToken(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.TokenExists(ctx, "a"); ok {
+		t.Fatal("RemoveToken must invalidate the cached hit")
+	}
+	if got := inner.lookups.Load(); got != 4 {
+		t.Fatalf("inner lookups = %d, want 4", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	_, _ = c.TokenExists(ctx, "b")
+	if got := inner.lookups.Load(); got != 5 {
+		t.Fatalf("expired entry must be refetched, lookups = %d", got)
+	}
+	_, _ = c.TokenExists(ctx, "c")
+	_, _ = c.TokenExists(ctx, "d")
+	if c.Len() != 2 {
+		t.Fatalf("cache size = %d, want bound 2", c.Len())
+	}
+
+	if err := c.AddTokens(ctx, []string{"c", "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.TokenExists(ctx, "d"); !ok {
+		t.Fatal("AddTokens must invalidate")
+	}
+	tokens, err := c.GetTokens(ctx)
+	if err != nil || len(tokens) != 3 {
+		t.Fatalf("GetTokens must pass through: %v err=%v", tokens, err)
+	}
+}
+
+var _ interfaces.BulkStorage = (*CachedStorage)(nil)
+var _ interfaces.StreamStorage = (*CachedStorage)(nil)
+var _ interfaces.PingStorage = (*CachedStorage)(nil)
+var _ interfaces.StatusStorage = (*CachedStorage)(nil)
+var _ interfaces.BulkRichStorage = (*CachedStorage)(nil)
+var _ interfaces.StreamRichStorage = (*CachedStorage)(nil)
+
+func TestCachedStorageForwardsStatusesAndMeta(t *testing.T) {
+	ctx := context.Background()
+	c := Cached(NewMemoryAdapter(), time.Minute, 10)
+	if ok, _ := c.TokenExists(ctx, "casino"); ok {
+		t.Fatal("casino must not exist yet")
+	}
+	if err := c.AddTokenMeta(ctx, models.Token{Value: "casino", Category: "gambling"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.TokenExists(ctx, "casino"); !ok {
+		t.Fatal("AddTokenMeta must invalidate the cached miss")
+	}
+	if err := c.AddTokensMeta(ctx, []models.Token{{Value: "poker", Severity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	var streamed []models.Token
+	_ = c.GetTokensMetaFunc(ctx, func(token models.Token) error {
+		streamed = append(streamed, token)
+		return nil
+	})
+	all, err := c.GetTokensMeta(ctx)
+	if err != nil || len(all) != 2 || len(streamed) != 2 {
+		t.Fatalf("metadata must pass through: %+v streamed=%+v err=%v", all, streamed, err)
+	}
+	if err := c.SetTokenStatus(ctx, "casino", models.StatusSuspicious); err != nil {
+		t.Fatal(err)
+	}
+	if statuses, err := c.GetTokenStatuses(ctx); err != nil || statuses["casino"] != models.StatusSuspicious {
+		t.Fatalf("statuses must pass through: %v err=%v", statuses, err)
+	}
+
+	plain := Cached(struct{ interfaces.Storage }{NewMemoryAdapter()}, time.Minute, 10)
+	if err := plain.SetTokenStatus(ctx, "casino", models.StatusSuspicious); err == nil {
+		t.Fatal("expected unsupported statuses error")
+	}
+	if statuses, err := plain.GetTokenStatuses(ctx); err != nil || statuses != nil {
+		t.Fatalf("plain inner storage has no statuses: %v err=%v", statuses, err)
+	}
+	if err := plain.AddTokensMeta(ctx, []models.Token{{Value: "casino", Category: "gambling"}}); err != nil {
+		t.Fatal(err)
+	}
+	all, err = plain.GetTokensMeta(ctx)
+	if err != nil || len(all) != 1 || all[0] != (models.Token{Value: "casino"}) {
+		t.Fatalf("plain inner storage must keep only values: %+v err=%v", all, err)
+	}
+}
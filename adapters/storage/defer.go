@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// MemoryDeferQueue is an in-memory defer store. Queued messages are lost on
+// restart.
+type MemoryDeferQueue struct {
+	mu       sync.Mutex
+	messages []models.Message
+}
+
+// NewMemoryDeferQueue creates an empty in-memory defer store.
+func NewMemoryDeferQueue() *MemoryDeferQueue {
+	return &MemoryDeferQueue{}
+}
+
+func (q *MemoryDeferQueue) Enqueue(_ context.Context, messages []models.Message) error {
+	q.mu.Lock()
+	q.messages = append(q.messages, messages...)
+	q.mu.Unlock()
+	return nil
+}
+
+func (q *MemoryDeferQueue) Drain(context.Context) ([]models.Message, error) {
+	q.mu.Lock()
+	out := q.messages
+	q.messages = nil
+	q.mu.Unlock()
+	return out, nil
+}
+
+// Len returns the number of queued messages.
+func (q *MemoryDeferQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileAdapter keeps tokens in a JSON array file. Every change rewrites the
+// file atomically through a temp file and rename; reads use an in-memory index.
+type FileAdapter struct {
+	mu     sync.RWMutex
+	path   string
+	tokens map[string]struct{}
+}
+
+// NewFileAdapter loads tokens from path. A missing file starts an empty set
+// and is created on the first change.
+func NewFileAdapter(path string) (*FileAdapter, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, errors.New("storage: file path is empty")
+	}
+	f := &FileAdapter{path: path, tokens: make(map[string]struct{})}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &tokens); err != nil {
+			return nil, err
+		}
+	}
+	for _, token := range tokens {
+		f.tokens[token] = struct{}{}
+	}
+	return f, nil
+}
+
+func (f *FileAdapter) AddToken(ctx context.Context, token string) error {
+	return f.AddTokens(ctx, []string{token})
+}
+
+// AddTokens adds tokens with a single file write. Existing tokens are ignored.
+func (f *FileAdapter) AddTokens(_ context.Context, tokens []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var added []string
+	for _, token := range tokens {
+		if _, ok := f.tokens[token]; ok {
+			continue
+		}
+		f.tokens[token] = struct{}{}
+		added = append(added, token)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	if err := f.saveLocked(); err != nil {
+		for _, token := range added {
+			delete(f.tokens, token)
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *FileAdapter) RemoveToken(_ context.Context, token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.tokens[token]; !ok {
+		return nil
+	}
+	delete(f.tokens, token)
+	if err := f.saveLocked(); err != nil {
+		f.tokens[token] = struct{}{}
+		return err
+	}
+	return nil
+}
+
+func (f *FileAdapter) GetTokens(_ context.Context) ([]string, error) {
+	f.mu.RLock()
+	out := make([]string, 0, len(f.tokens))
+	for token := range f.tokens {
+		out = append(out, token)
+	}
+	f.mu.RUnlock()
+	return out, nil
+}
+
+func (f *FileAdapter) TokenExists(_ context.Context, token string) (bool, error) {
+	f.mu.RLock()
+	_, ok := f.tokens[token]
+	f.mu.RUnlock()
+	return ok, nil
+}
+
+// saveLocked writes the sorted token set next to path and renames it into
+// place, so readers never see a partial file. Caller holds f.mu.
+func (f *FileAdapter) saveLocked() error {
+	tokens := make([]string, 0, len(f.tokens))
+	for token := range f.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
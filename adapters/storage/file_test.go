@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/elum-utils/censor/interfaces"
+)
+
+var _ interfaces.BulkStorage = (*FileAdapter)(nil)
+
+func TestFileAdapterPersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	f, err := NewFileAdapter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := f.GetTokens(ctx); len(all) != 0 {
+		t.Fatalf("missing file must start empty: %v", all)
+	}
+	for _, token := range []string{"b", "a", "a"} {
+		if err := f.AddToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.AddTokens(ctx, []string{"c", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RemoveToken(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RemoveToken(ctx, "missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk []string
+	if err := json.Unmarshal(data, &onDisk); err != nil || len(onDisk) != 2 || onDisk[0] != "a" || onDisk[1] != "b" {
+		t.Fatalf("unexpected file content %s err=%v", data, err)
+	}
+	if leftovers, _ := filepath.Glob(path + ".tmp-*"); len(leftovers) != 0 {
+		t.Fatalf("temp files left behind: %v", leftovers)
+	}
+
+	reopened, err := NewFileAdapter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _ := reopened.GetTokens(ctx)
+	sort.Strings(all)
+	if len(all) != 2 || all[0] != "a" || all[1] != "b" {
+		t.Fatalf("unexpected tokens after reopen: %v", all)
+	}
+	if ok, _ := reopened.TokenExists(ctx, "a"); !ok {
+		t.Fatalf("token must exist after reopen")
+	}
+	if ok, _ := reopened.TokenExists(ctx, "c"); ok {
+		t.Fatalf("removed token must stay removed")
+	}
+}
+
+func TestFileAdapterErrors(t *testing.T) {
+	if _, err := NewFileAdapter(" "); err == nil {
+		t.Fatalf("expected empty path error")
+	}
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileAdapter(path); err == nil {
+		t.Fatalf("expected decode error")
+	}
+	f, err := NewFileAdapter(filepath.Join(t.TempDir(), "missing-dir", "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddToken(context.Background(), "a"); err == nil {
+		t.Fatalf("expected write error")
+	}
+	if ok, _ := f.TokenExists(context.Background(), "a"); ok {
+		t.Fatalf("failed write must roll back the index")
+	}
+}
@@ -2,18 +2,24 @@ package storage
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/elum-utils/censor/models"
 )
 
 // MemoryAdapter is an in-memory storage implementation.
 type MemoryAdapter struct {
-	mu     sync.RWMutex
-	tokens map[string]struct{}
+	mu       sync.RWMutex
+	tokens   map[string]struct{}
+	statuses map[string]models.StatusCode
+	meta     map[string]models.Token
 }
 
 // NewMemoryAdapter creates a memory storage adapter.
 func NewMemoryAdapter() *MemoryAdapter {
-	return &MemoryAdapter{tokens: make(map[string]struct{})}
+	return &MemoryAdapter{tokens: make(map[string]struct{}), statuses: make(map[string]models.StatusCode), meta: make(map[string]models.Token)}
 }
 
 func (m *MemoryAdapter) AddToken(_ context.Context, token string) error {
@@ -23,9 +29,20 @@ func (m *MemoryAdapter) AddToken(_ context.Context, token string) error {
 	return nil
 }
 
+func (m *MemoryAdapter) AddTokens(_ context.Context, tokens []string) error {
+	m.mu.Lock()
+	for _, token := range tokens {
+		m.tokens[token] = struct{}{}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
 func (m *MemoryAdapter) RemoveToken(_ context.Context, token string) error {
 	m.mu.Lock()
 	delete(m.tokens, token)
+	delete(m.meta, token)
+	delete(m.statuses, token)
 	m.mu.Unlock()
 	return nil
 }
@@ -40,9 +57,110 @@ func (m *MemoryAdapter) GetTokens(_ context.Context) ([]string, error) {
 	return out, nil
 }
 
+// GetTokensFunc calls fn for a snapshot of the tokens, so fn may modify the adapter.
+func (m *MemoryAdapter) GetTokensFunc(ctx context.Context, fn func(token string) error) error {
+	tokens, _ := m.GetTokens(ctx)
+	for _, token := range tokens {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTokensAfter calls fn for a sorted snapshot of the tokens after after.
+func (m *MemoryAdapter) GetTokensAfter(ctx context.Context, after string, fn func(token string) error) error {
+	tokens, _ := m.GetTokens(ctx)
+	sort.Strings(tokens)
+	for _, token := range tokens[sort.SearchStrings(tokens, after):] {
+		if token <= after {
+			continue
+		}
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *MemoryAdapter) TokenExists(_ context.Context, token string) (bool, error) {
 	m.mu.RLock()
 	_, ok := m.tokens[token]
 	m.mu.RUnlock()
 	return ok, nil
 }
+
+// SetTokenStatus attaches a direct verdict to token. A zero status detaches it.
+func (m *MemoryAdapter) SetTokenStatus(_ context.Context, token string, status models.StatusCode) error {
+	m.mu.Lock()
+	if status == 0 {
+		delete(m.statuses, token)
+	} else {
+		m.statuses[token] = status
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryAdapter) GetTokenStatuses(_ context.Context) (map[string]models.StatusCode, error) {
+	m.mu.RLock()
+	out := make(map[string]models.StatusCode, len(m.statuses))
+	for token, status := range m.statuses {
+		out[token] = status
+	}
+	m.mu.RUnlock()
+	return out, nil
+}
+
+// AddTokenMeta adds token.Value and replaces its metadata. A zero AddedAt is
+// set to the current time.
+func (m *MemoryAdapter) AddTokenMeta(_ context.Context, token models.Token) error {
+	if token.AddedAt.IsZero() {
+		token.AddedAt = time.Now()
+	}
+	m.mu.Lock()
+	m.tokens[token.Value] = struct{}{}
+	m.meta[token.Value] = token
+	m.mu.Unlock()
+	return nil
+}
+
+// AddTokensMeta is AddTokenMeta for several tokens under one lock.
+func (m *MemoryAdapter) AddTokensMeta(_ context.Context, tokens []models.Token) error {
+	now := time.Now()
+	m.mu.Lock()
+	for _, token := range tokens {
+		if token.AddedAt.IsZero() {
+			token.AddedAt = now
+		}
+		m.tokens[token.Value] = struct{}{}
+		m.meta[token.Value] = token
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// GetTokensMetaFunc calls fn for a snapshot of GetTokensMeta.
+func (m *MemoryAdapter) GetTokensMetaFunc(ctx context.Context, fn func(token models.Token) error) error {
+	tokens, _ := m.GetTokensMeta(ctx)
+	for _, token := range tokens {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryAdapter) GetTokensMeta(_ context.Context) ([]models.Token, error) {
+	m.mu.RLock()
+	out := make([]models.Token, 0, len(m.tokens))
+	for token := range m.tokens {
+		meta, ok := m.meta[token]
+		if !ok {
+			meta = models.Token{Value: token}
+		}
+		out = append(out, meta)
+	}
+	m.mu.RUnlock()
+	return out, nil
+}
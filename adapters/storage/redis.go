@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/elum-utils/censor/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisAdapter keeps tokens in a Redis SET and direct token verdicts in a HASH.
+type RedisAdapter struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+const redisScanCount = 1000
+
+// NewRedisAdapter creates an adapter over client. Keys are prefix+"tokens" and
+// prefix+"token_status"; prefix defaults to "censor:".
+func NewRedisAdapter(client redis.UniversalClient, prefix string) (*RedisAdapter, error) {
+	if client == nil {
+		return nil, errors.New("storage: redis client is nil")
+	}
+	if prefix == "" {
+		prefix = "censor:"
+	}
+	return &RedisAdapter{client: client, prefix: prefix}, nil
+}
+
+// Ping checks the Redis connection.
+func (r *RedisAdapter) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *RedisAdapter) tokensKey() string { return r.prefix + "tokens" }
+
+func (r *RedisAdapter) statusKey() string { return r.prefix + "token_status" }
+
+func (r *RedisAdapter) AddToken(ctx context.Context, token string) error {
+	return r.client.SAdd(ctx, r.tokensKey(), token).Err()
+}
+
+// AddTokens adds all tokens with one SADD.
+func (r *RedisAdapter) AddTokens(ctx context.Context, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	members := make([]any, len(tokens))
+	for i, token := range tokens {
+		members[i] = token
+	}
+	return r.client.SAdd(ctx, r.tokensKey(), members...).Err()
+}
+
+func (r *RedisAdapter) RemoveToken(ctx context.Context, token string) error {
+	return r.client.SRem(ctx, r.tokensKey(), token).Err()
+}
+
+// GetTokens walks the set with SSCAN so large sets do not block the server.
+func (r *RedisAdapter) GetTokens(ctx context.Context) ([]string, error) {
+	var (
+		out    []string
+		cursor uint64
+	)
+	for {
+		keys, next, err := r.client.SScan(ctx, r.tokensKey(), cursor, "", redisScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, keys...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	// SSCAN may return an element more than once.
+	seen := make(map[string]struct{}, len(out))
+	uniq := out[:0]
+	for _, token := range out {
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		uniq = append(uniq, token)
+	}
+	return uniq, nil
+}
+
+func (r *RedisAdapter) TokenExists(ctx context.Context, token string) (bool, error) {
+	return r.client.SIsMember(ctx, r.tokensKey(), token).Result()
+}
+
+// SetTokenStatus attaches a direct verdict to token. A zero status detaches it.
+func (r *RedisAdapter) SetTokenStatus(ctx context.Context, token string, status models.StatusCode) error {
+	if status == 0 {
+		return r.client.HDel(ctx, r.statusKey(), token).Err()
+	}
+	return r.client.HSet(ctx, r.statusKey(), token, int(status)).Err()
+}
+
+func (r *RedisAdapter) GetTokenStatuses(ctx context.Context) (map[string]models.StatusCode, error) {
+	raw, err := r.client.HGetAll(ctx, r.statusKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]models.StatusCode, len(raw))
+	for token, v := range raw {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		out[token] = models.StatusCode(n)
+	}
+	return out, nil
+}
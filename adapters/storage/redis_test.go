@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ interfaces.StatusStorage = (*RedisAdapter)(nil)
+
+func newTestRedis(t *testing.T, prefix string) (*RedisAdapter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	a, err := NewRedisAdapter(client, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, mr
+}
+
+func TestNewRedisAdapterNilClient(t *testing.T) {
+	if _, err := NewRedisAdapter(nil, ""); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestRedisAdapter(t *testing.T) {
+	ctx := context.Background()
+	a, mr := newTestRedis(t, "app:")
+	if err := a.AddToken(ctx, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddToken(ctx, "spam"); err != nil {
+		t.Fatalf("repeated add must be idempotent: %v", err)
+	}
+	_ = a.AddToken(ctx, "scam")
+	if !mr.Exists("app:tokens") {
+		t.Fatalf("expected prefixed key")
+	}
+	ok, err := a.TokenExists(ctx, "spam")
+	if err != nil || !ok {
+		t.Fatalf("expected token: ok=%v err=%v", ok, err)
+	}
+	all, err := a.GetTokens(ctx)
+	sort.Strings(all)
+	if err != nil || len(all) != 2 || all[0] != "scam" || all[1] != "spam" {
+		t.Fatalf("unexpected tokens: %v err=%v", all, err)
+	}
+	if err := a.RemoveToken(ctx, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ = a.TokenExists(ctx, "spam"); ok {
+		t.Fatalf("expected token removed")
+	}
+}
+
+func TestRedisAdapterAddTokens(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestRedis(t, "")
+	_ = a.AddToken(ctx, "a")
+	if err := a.AddTokens(ctx, []string{"a", "b", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddTokens(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := a.GetTokens(ctx); len(all) != 2 {
+		t.Fatalf("unexpected tokens: %v", all)
+	}
+}
+
+func TestRedisAdapterScansLargeSet(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestRedis(t, "")
+	for i := 0; i < 2500; i++ {
+		_ = a.AddToken(ctx, fmt.Sprintf("t%d", i))
+	}
+	all, err := a.GetTokens(ctx)
+	if err != nil || len(all) != 2500 {
+		t.Fatalf("unexpected size: %d err=%v", len(all), err)
+	}
+}
+
+func TestRedisAdapterStatuses(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestRedis(t, "")
+	_ = a.SetTokenStatus(ctx, "scam.example", models.StatusCommercialOffPlatform)
+	st, err := a.GetTokenStatuses(ctx)
+	if err != nil || st["scam.example"] != models.StatusCommercialOffPlatform {
+		t.Fatalf("unexpected statuses: %v err=%v", st, err)
+	}
+	_ = a.SetTokenStatus(ctx, "scam.example", 0)
+	if st, _ = a.GetTokenStatuses(ctx); len(st) != 0 {
+		t.Fatalf("zero status must detach: %v", st)
+	}
+	if tokens, _ := a.GetTokens(ctx); len(tokens) != 0 {
+		t.Fatalf("status writes must not touch token set: %v", tokens)
+	}
+}
@@ -6,35 +6,120 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/elum-utils/censor/models"
 )
 
 // SQLAdapter is a generic SQL storage implementation.
 type SQLAdapter struct {
-	db    *sql.DB
-	table string
+	db      *sql.DB
+	table   string
+	dialect Dialect
+	// statuses enables the status table; see WithTokenStatuses.
+	statuses bool
+	// meta enables the metadata table; see WithTokenMeta.
+	meta bool
+
+	maxRetries int
+	retryDelay time.Duration
+	retryable  func(error) bool
 }
 
-// NewSQLAdapter creates an adapter over *sql.DB.
-func NewSQLAdapter(db *sql.DB, table string) (*SQLAdapter, error) {
+// NewSQLAdapter creates an adapter over *sql.DB. Without WithDialect it uses
+// DialectGeneric.
+func NewSQLAdapter(db *sql.DB, table string, opts ...SQLOption) (*SQLAdapter, error) {
 	if db == nil {
 		return nil, errors.New("storage: db is nil")
 	}
 	if strings.TrimSpace(table) == "" {
 		table = "censor_tokens"
 	}
-	return &SQLAdapter{db: db, table: table}, nil
+	s := &SQLAdapter{db: db, table: table}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// WithMaxOpenConns passes n to sql.DB.SetMaxOpenConns.
+func WithMaxOpenConns(n int) SQLOption {
+	return func(s *SQLAdapter) { s.db.SetMaxOpenConns(n) }
 }
 
-// EnsureSchema creates table if missing.
+// WithMaxIdleConns passes n to sql.DB.SetMaxIdleConns.
+func WithMaxIdleConns(n int) SQLOption {
+	return func(s *SQLAdapter) { s.db.SetMaxIdleConns(n) }
+}
+
+// WithConnMaxLifetime passes d to sql.DB.SetConnMaxLifetime.
+func WithConnMaxLifetime(d time.Duration) SQLOption {
+	return func(s *SQLAdapter) { s.db.SetConnMaxLifetime(d) }
+}
+
+// WithConnMaxIdleTime passes d to sql.DB.SetConnMaxIdleTime.
+func WithConnMaxIdleTime(d time.Duration) SQLOption {
+	return func(s *SQLAdapter) { s.db.SetConnMaxIdleTime(d) }
+}
+
+// Ping checks the database connection.
+func (s *SQLAdapter) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// EnsureSchema creates tables if missing: the token table, plus the status
+// and metadata tables when WithTokenStatuses and WithTokenMeta are set.
 func (s *SQLAdapter) EnsureSchema(ctx context.Context) error {
-	q := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (token TEXT PRIMARY KEY)`, s.table)
-	_, err := s.db.ExecContext(ctx, q)
+	key, text, bigint := s.dialect.keyType(), s.dialect.textType(), s.dialect.bigintType()
+	q := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (token %s PRIMARY KEY)`, s.table, key)
+	if _, err := s.exec(ctx, q); err != nil {
+		return err
+	}
+	if s.statuses {
+		q = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (token %s PRIMARY KEY, status INTEGER NOT NULL)`, s.statusTable(), key)
+		if _, err := s.exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	if !s.meta {
+		return nil
+	}
+	q = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (token %s PRIMARY KEY, category %s NOT NULL DEFAULT '', severity INTEGER NOT NULL DEFAULT 0, source %s NOT NULL DEFAULT '', added_at %s NOT NULL DEFAULT 0)`, s.metaTable(), key, text, text, bigint)
+	_, err := s.exec(ctx, q)
 	return err
 }
 
+// statusTable holds direct per-token verdicts next to the token table.
+func (s *SQLAdapter) statusTable() string {
+	return s.table + "_status"
+}
+
+func (s *SQLAdapter) exec(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.dialect.rebind(q), args...)
+}
+
+func (s *SQLAdapter) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.rebind(q), args...)
+}
+
+func (s *SQLAdapter) queryRow(ctx context.Context, q string, args ...any) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.rebind(q), args...)
+}
+
+// metaTable holds token metadata; added_at is stored as Unix seconds.
+func (s *SQLAdapter) metaTable() string {
+	return s.table + "_meta"
+}
+
+// AddToken inserts token, ignoring an existing one. DialectGeneric recognizes
+// duplicates by the driver error text; other dialects use a native insert.
 func (s *SQLAdapter) AddToken(ctx context.Context, token string) error {
+	if s.dialect != DialectGeneric {
+		_, err := s.exec(ctx, s.dialect.insertIgnore(s.table, "token", "(?)"), token)
+		return err
+	}
 	q := fmt.Sprintf(`INSERT INTO %s (token) VALUES (?)`, s.table)
-	_, err := s.db.ExecContext(ctx, q, token)
+	_, err := s.exec(ctx, q, token)
 	if err == nil {
 		return nil
 	}
@@ -44,38 +129,153 @@ func (s *SQLAdapter) AddToken(ctx context.Context, token string) error {
 	return err
 }
 
+// sqlBulkChunk keeps multi-row inserts under common bind parameter limits.
+const sqlBulkChunk = 500
+
+// AddTokens inserts tokens with multi-row INSERT ... ON CONFLICT DO NOTHING
+// (INSERT IGNORE on MySQL) statements, so existing tokens are skipped.
+// DialectGeneric has no portable form and inserts them one by one.
+func (s *SQLAdapter) AddTokens(ctx context.Context, tokens []string) error {
+	tokens = uniqueTokens(tokens)
+	if s.dialect == DialectGeneric {
+		for _, token := range tokens {
+			if err := s.AddToken(ctx, token); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for start := 0; start < len(tokens); start += sqlBulkChunk {
+		end := min(start+sqlBulkChunk, len(tokens))
+		chunk := tokens[start:end]
+		args := make([]any, len(chunk))
+		for i, token := range chunk {
+			args[i] = token
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("(?), ", len(chunk)), ", ")
+		q := s.dialect.insertIgnore(s.table, "token", placeholders)
+		if _, err := s.exec(ctx, q, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uniqueTokens(tokens []string) []string {
+	seen := make(map[string]struct{}, len(tokens))
+	out := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		out = append(out, token)
+	}
+	return out
+}
+
+// RemoveToken deletes token along with its metadata and status rows, so a
+// later AddToken does not inherit them.
 func (s *SQLAdapter) RemoveToken(ctx context.Context, token string) error {
-	q := fmt.Sprintf(`DELETE FROM %s WHERE token = ?`, s.table)
-	_, err := s.db.ExecContext(ctx, q, token)
-	return err
+	tables := []string{s.table}
+	if s.meta {
+		tables = append(tables, s.metaTable())
+	}
+	if s.statuses {
+		tables = append(tables, s.statusTable())
+	}
+	for _, table := range tables {
+		q := fmt.Sprintf(`DELETE FROM %s WHERE token = ?`, table)
+		if _, err := s.exec(ctx, q, token); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+// GetTokens returns every token. A retryable failure restarts the read.
 func (s *SQLAdapter) GetTokens(ctx context.Context) ([]string, error) {
-	q := fmt.Sprintf(`SELECT token FROM %s`, s.table)
-	rows, err := s.db.QueryContext(ctx, q)
+	out := make([]string, 0, 256)
+	err := s.withRetry(ctx, func() error {
+		out = out[:0]
+		return s.scanTokens(ctx, func(token string) error {
+			out = append(out, token)
+			return nil
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
+	return out, nil
+}
+
+// GetTokensFunc calls fn for every row of the token table without buffering
+// the whole set. Failures are retried only until fn saw the first token.
+func (s *SQLAdapter) GetTokensFunc(ctx context.Context, fn func(token string) error) error {
+	started := false
+	return s.withRetry(ctx, func() error {
+		var fnErr error
+		err := s.scanTokens(ctx, func(token string) error {
+			started = true
+			fnErr = fn(token)
+			return fnErr
+		})
+		if err != nil && (started || fnErr != nil) {
+			return finalError{err}
+		}
+		return err
+	})
+}
+
+// GetTokensAfter calls fn for every token greater than after in ascending
+// order. A retryable failure resumes after the last token fn accepted.
+func (s *SQLAdapter) GetTokensAfter(ctx context.Context, after string, fn func(token string) error) error {
+	q := fmt.Sprintf(`SELECT token FROM %s WHERE token > ? ORDER BY token`, s.table)
+	cursor := after
+	return s.withRetry(ctx, func() error {
+		var fnErr error
+		err := s.scanRows(ctx, q, []any{cursor}, func(token string) error {
+			if fnErr = fn(token); fnErr == nil {
+				cursor = token
+			}
+			return fnErr
+		})
+		if fnErr != nil {
+			return finalError{err}
+		}
+		return err
+	})
+}
+
+func (s *SQLAdapter) scanTokens(ctx context.Context, fn func(token string) error) error {
+	return s.scanRows(ctx, fmt.Sprintf(`SELECT token FROM %s`, s.table), nil, fn)
+}
+
+func (s *SQLAdapter) scanRows(ctx context.Context, q string, args []any, fn func(token string) error) error {
+	rows, err := s.query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	out := make([]string, 0, 256)
 	for rows.Next() {
 		var token string
 		if scanErr := rows.Scan(&token); scanErr != nil {
-			return nil, scanErr
+			return scanErr
+		}
+		if err := fn(token); err != nil {
+			return err
 		}
-		out = append(out, token)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
 	}
-	return out, nil
+	return rows.Err()
 }
 
 func (s *SQLAdapter) TokenExists(ctx context.Context, token string) (bool, error) {
 	q := fmt.Sprintf(`SELECT 1 FROM %s WHERE token = ? LIMIT 1`, s.table)
 	var v int
-	err := s.db.QueryRowContext(ctx, q, token).Scan(&v)
+	err := s.withRetry(ctx, func() error {
+		return s.queryRow(ctx, q, token).Scan(&v)
+	})
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -84,3 +284,234 @@ func (s *SQLAdapter) TokenExists(ctx context.Context, token string) (bool, error
 	}
 	return true, nil
 }
+
+// WithTokenStatuses enables direct per-token verdicts in <table>_status.
+// Without it the adapter reports no statuses and rejects SetTokenStatus, so
+// existing deployments need no extra table.
+func WithTokenStatuses() SQLOption {
+	return func(s *SQLAdapter) { s.statuses = true }
+}
+
+// SetTokenStatus attaches a direct verdict to token. A zero status detaches it.
+func (s *SQLAdapter) SetTokenStatus(ctx context.Context, token string, status models.StatusCode) error {
+	if !s.statuses {
+		return errors.New("storage: token statuses are disabled, see WithTokenStatuses")
+	}
+	if status == 0 {
+		q := fmt.Sprintf(`DELETE FROM %s WHERE token = ?`, s.statusTable())
+		_, err := s.exec(ctx, q, token)
+		return err
+	}
+	if status < 0 {
+		return fmt.Errorf("storage: invalid token status %d", status)
+	}
+	return s.replaceRows(ctx, s.statusTable(), []string{"token", "status"}, [][]any{{token, int64(status)}})
+}
+
+// replaceRows inserts rows keyed by their first column, replacing existing
+// ones, in statements of at most sqlBulkChunk bind parameters. DialectGeneric
+// has no portable upsert and replaces them in one transaction.
+func (s *SQLAdapter) replaceRows(ctx context.Context, table string, columns []string, rows [][]any) error {
+	if s.dialect != DialectGeneric {
+		per := max(1, sqlBulkChunk/len(columns))
+		for start := 0; start < len(rows); start += per {
+			chunk := rows[start:min(start+per, len(rows))]
+			args := make([]any, 0, len(chunk)*len(columns))
+			for _, row := range chunk {
+				args = append(args, row...)
+			}
+			if _, err := s.exec(ctx, s.dialect.upsert(table, columns, len(chunk)), args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	del := fmt.Sprintf(`DELETE FROM %s WHERE %s = ?`, table, columns[0])
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	ins := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, strings.Join(columns, ", "), placeholders)
+	for _, row := range rows {
+		if _, err := tx.ExecContext(ctx, del, row[0]); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, ins, row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetTokenStatuses returns every stored status, or none when statuses are
+// disabled.
+func (s *SQLAdapter) GetTokenStatuses(ctx context.Context) (map[string]models.StatusCode, error) {
+	if !s.statuses {
+		return nil, nil
+	}
+	var out map[string]models.StatusCode
+	err := s.withRetry(ctx, func() error {
+		var err error
+		out, err = s.scanStatuses(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (s *SQLAdapter) scanStatuses(ctx context.Context) (map[string]models.StatusCode, error) {
+	q := fmt.Sprintf(`SELECT token, status FROM %s`, s.statusTable())
+	rows, err := s.query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]models.StatusCode)
+	for rows.Next() {
+		var (
+			token  string
+			status int64
+		)
+		if scanErr := rows.Scan(&token, &status); scanErr != nil {
+			return nil, scanErr
+		}
+		out[token] = models.StatusCode(status)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WithTokenMeta keeps token metadata in <table>_meta. Without it the adapter
+// stores only token values: AddTokenMeta behaves as AddToken and
+// GetTokensMeta returns tokens with only Value set.
+func WithTokenMeta() SQLOption {
+	return func(s *SQLAdapter) { s.meta = true }
+}
+
+var metaColumns = []string{"token", "category", "severity", "source", "added_at"}
+
+// AddTokenMeta adds token.Value and replaces its metadata row. A zero AddedAt
+// is set to the current time.
+func (s *SQLAdapter) AddTokenMeta(ctx context.Context, token models.Token) error {
+	if err := s.AddToken(ctx, token.Value); err != nil {
+		return err
+	}
+	if !s.meta {
+		return nil
+	}
+	if token.AddedAt.IsZero() {
+		token.AddedAt = time.Now()
+	}
+	return s.replaceRows(ctx, s.metaTable(), metaColumns, [][]any{metaRow(token)})
+}
+
+// AddTokensMeta adds every token.Value with AddTokens and replaces their
+// metadata rows in multi-row statements. A zero AddedAt is set to the
+// current time.
+func (s *SQLAdapter) AddTokensMeta(ctx context.Context, tokens []models.Token) error {
+	values := make([]string, len(tokens))
+	for i, token := range tokens {
+		values[i] = token.Value
+	}
+	if err := s.AddTokens(ctx, values); err != nil {
+		return err
+	}
+	if !s.meta {
+		return nil
+	}
+	now := time.Now()
+	seen := make(map[string]struct{}, len(tokens))
+	rows := make([][]any, 0, len(tokens))
+	// The last entry for a repeated token wins, as with AddTokenMeta calls.
+	for i := len(tokens) - 1; i >= 0; i-- {
+		token := tokens[i]
+		if _, ok := seen[token.Value]; ok {
+			continue
+		}
+		seen[token.Value] = struct{}{}
+		if token.AddedAt.IsZero() {
+			token.AddedAt = now
+		}
+		rows = append(rows, metaRow(token))
+	}
+	return s.replaceRows(ctx, s.metaTable(), metaColumns, rows)
+}
+
+func metaRow(token models.Token) []any {
+	return []any{token.Value, token.Category, int64(token.Severity), token.Source, token.AddedAt.Unix()}
+}
+
+// GetTokensMeta returns every token with its metadata; tokens without a
+// metadata row come back with only Value set.
+func (s *SQLAdapter) GetTokensMeta(ctx context.Context) ([]models.Token, error) {
+	out := make([]models.Token, 0, 256)
+	err := s.withRetry(ctx, func() error {
+		out = out[:0]
+		return s.scanTokensMeta(ctx, func(token models.Token) error {
+			out = append(out, token)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetTokensMetaFunc calls fn for every token with its metadata without
+// buffering the whole set. Failures are retried only until fn saw the first
+// token.
+func (s *SQLAdapter) GetTokensMetaFunc(ctx context.Context, fn func(token models.Token) error) error {
+	started := false
+	return s.withRetry(ctx, func() error {
+		var fnErr error
+		err := s.scanTokensMeta(ctx, func(token models.Token) error {
+			started = true
+			fnErr = fn(token)
+			return fnErr
+		})
+		if err != nil && (started || fnErr != nil) {
+			return finalError{err}
+		}
+		return err
+	})
+}
+
+// scanTokensMeta reads tokens joined with their metadata, or plain tokens
+// when metadata is disabled.
+func (s *SQLAdapter) scanTokensMeta(ctx context.Context, fn func(token models.Token) error) error {
+	if !s.meta {
+		return s.scanTokens(ctx, func(token string) error {
+			return fn(models.Token{Value: token})
+		})
+	}
+	q := fmt.Sprintf(`SELECT t.token, m.category, m.severity, m.source, m.added_at FROM %s t LEFT JOIN %s m ON m.token = t.token`, s.table, s.metaTable())
+	rows, err := s.query(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			token            string
+			category, source sql.NullString
+			severity, added  sql.NullInt64
+		)
+		if scanErr := rows.Scan(&token, &category, &severity, &source, &added); scanErr != nil {
+			return scanErr
+		}
+		t := models.Token{Value: token, Category: category.String, Severity: int(severity.Int64), Source: source.String}
+		if added.Valid && added.Int64 > 0 {
+			t.AddedAt = time.Unix(added.Int64, 0)
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
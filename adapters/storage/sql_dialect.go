@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect selects SQL syntax for a specific database.
+type Dialect int
+
+const (
+	// DialectGeneric uses portable SQL with ? placeholders and detects
+	// duplicate inserts from the driver error text.
+	DialectGeneric Dialect = iota
+	DialectPostgres
+	DialectMySQL
+	DialectSQLite
+)
+
+// SQLOption configures SQLAdapter.
+type SQLOption func(*SQLAdapter)
+
+// WithDialect makes the adapter use native idempotent inserts, $n placeholders
+// for Postgres and column types the database accepts in primary keys.
+func WithDialect(d Dialect) SQLOption {
+	return func(s *SQLAdapter) { s.dialect = d }
+}
+
+// insertIgnore builds an insert that skips rows whose key already exists.
+func (d Dialect) insertIgnore(table, columns, values string) string {
+	if d == DialectMySQL {
+		return "INSERT IGNORE INTO " + table + " (" + columns + ") VALUES " + values
+	}
+	return "INSERT INTO " + table + " (" + columns + ") VALUES " + values + " ON CONFLICT DO NOTHING"
+}
+
+// upsert builds an insert of rows keyed by the first column that overwrites
+// the remaining columns of existing rows.
+func (d Dialect) upsert(table string, columns []string, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	q := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES " + strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+	set := make([]string, 0, len(columns)-1)
+	for _, col := range columns[1:] {
+		if d == DialectMySQL {
+			set = append(set, col+" = VALUES("+col+")")
+		} else {
+			set = append(set, col+" = excluded."+col)
+		}
+	}
+	if d == DialectMySQL {
+		return q + " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	}
+	return q + " ON CONFLICT (" + columns[0] + ") DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// keyType is the column type for token keys; MySQL cannot index bare TEXT.
+func (d Dialect) keyType() string {
+	if d == DialectMySQL {
+		return "VARCHAR(255)"
+	}
+	return "TEXT"
+}
+
+// textType is the column type for short text with a default; MySQL TEXT
+// columns cannot have one.
+func (d Dialect) textType() string {
+	if d == DialectMySQL {
+		return "VARCHAR(255)"
+	}
+	return "TEXT"
+}
+
+func (d Dialect) bigintType() string {
+	if d == DialectPostgres || d == DialectMySQL {
+		return "BIGINT"
+	}
+	return "INTEGER"
+}
+
+// rebind rewrites ? placeholders to $1, $2, ... for Postgres.
+func (d Dialect) rebind(q string) string {
+	if d != DialectPostgres || !strings.Contains(q, "?") {
+		return q
+	}
+	var b strings.Builder
+	b.Grow(len(q) + 8)
+	n := 0
+	for i := 0; i < len(q); i++ {
+		if q[i] != '?' {
+			b.WriteByte(q[i])
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// recordingDriver accepts every statement and keeps its text.
+type recordingDriver struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+type recordingConn struct{ d *recordingDriver }
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d: d}, nil }
+
+func (d *recordingDriver) record(q string) {
+	d.mu.Lock()
+	d.queries = append(d.queries, q)
+	d.mu.Unlock()
+}
+
+func (d *recordingDriver) find(t *testing.T, prefix string) string {
+	t.Helper()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, q := range d.queries {
+		if strings.HasPrefix(q, prefix) {
+			return q
+		}
+	}
+	t.Fatalf("no query with prefix %q in %q", prefix, d.queries)
+	return ""
+}
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not used") }
+func (c *recordingConn) Close() error                        { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error)           { return stubTx{}, nil }
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.d.record(query)
+	return stubResult{}, nil
+}
+
+func (c *recordingConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(query)
+	return &stubRows{}, nil
+}
+
+func TestSQLDialects(t *testing.T) {
+	cases := []struct {
+		name      string
+		dialect   Dialect
+		insert    string
+		bulk      string
+		exists    string
+		schema    string
+		metaTypes string
+		status    string
+		meta      string
+	}{
+		{
+			name:      "generic",
+			dialect:   DialectGeneric,
+			insert:    "INSERT INTO tokens (token) VALUES (?)",
+			bulk:      "INSERT INTO tokens (token) VALUES (?)",
+			exists:    "SELECT 1 FROM tokens WHERE token = ? LIMIT 1",
+			schema:    "CREATE TABLE IF NOT EXISTS tokens (token TEXT PRIMARY KEY)",
+			metaTypes: "source TEXT NOT NULL DEFAULT '', added_at INTEGER",
+			status:    "INSERT INTO tokens_status (token, status) VALUES (?, ?)",
+			meta:      "INSERT INTO tokens_meta (token, category, severity, source, added_at) VALUES (?, ?, ?, ?, ?)",
+		},
+		{
+			name:      "postgres",
+			dialect:   DialectPostgres,
+			insert:    "INSERT INTO tokens (token) VALUES ($1) ON CONFLICT DO NOTHING",
+			bulk:      "INSERT INTO tokens (token) VALUES ($1), ($2) ON CONFLICT DO NOTHING",
+			exists:    "SELECT 1 FROM tokens WHERE token = $1 LIMIT 1",
+			schema:    "CREATE TABLE IF NOT EXISTS tokens (token TEXT PRIMARY KEY)",
+			metaTypes: "source TEXT NOT NULL DEFAULT '', added_at BIGINT",
+			status:    "INSERT INTO tokens_status (token, status) VALUES ($1, $2) ON CONFLICT (token) DO UPDATE SET status = excluded.status",
+			meta:      "INSERT INTO tokens_meta (token, category, severity, source, added_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (token) DO UPDATE SET category = excluded.category, severity = excluded.severity, source = excluded.source, added_at = excluded.added_at",
+		},
+		{
+			name:      "mysql",
+			dialect:   DialectMySQL,
+			insert:    "INSERT IGNORE INTO tokens (token) VALUES (?)",
+			bulk:      "INSERT IGNORE INTO tokens (token) VALUES (?), (?)",
+			exists:    "SELECT 1 FROM tokens WHERE token = ? LIMIT 1",
+			schema:    "CREATE TABLE IF NOT EXISTS tokens (token VARCHAR(255) PRIMARY KEY)",
+			metaTypes: "source VARCHAR(255) NOT NULL DEFAULT '', added_at BIGINT",
+			status:    "INSERT INTO tokens_status (token, status) VALUES (?, ?) ON DUPLICATE KEY UPDATE status = VALUES(status)",
+			meta:      "INSERT INTO tokens_meta (token, category, severity, source, added_at) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE category = VALUES(category), severity = VALUES(severity), source = VALUES(source), added_at = VALUES(added_at)",
+		},
+		{
+			name:      "sqlite",
+			dialect:   DialectSQLite,
+			insert:    "INSERT INTO tokens (token) VALUES (?) ON CONFLICT DO NOTHING",
+			bulk:      "INSERT INTO tokens (token) VALUES (?), (?) ON CONFLICT DO NOTHING",
+			exists:    "SELECT 1 FROM tokens WHERE token = ? LIMIT 1",
+			schema:    "CREATE TABLE IF NOT EXISTS tokens (token TEXT PRIMARY KEY)",
+			metaTypes: "source TEXT NOT NULL DEFAULT '', added_at INTEGER",
+			status:    "INSERT INTO tokens_status (token, status) VALUES (?, ?) ON CONFLICT (token) DO UPDATE SET status = excluded.status",
+			meta:      "INSERT INTO tokens_meta (token, category, severity, source, added_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT (token) DO UPDATE SET category = excluded.category, severity = excluded.severity, source = excluded.source, added_at = excluded.added_at",
+		},
+	}
+	ctx := context.Background()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &recordingDriver{}
+			driverName := "censor_recording_sql_" + tc.name
+			sql.Register(driverName, d)
+			db, err := sql.Open(driverName, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			a, _ := NewSQLAdapter(db, "tokens", WithDialect(tc.dialect), WithTokenStatuses(), WithTokenMeta())
+			if err := a.EnsureSchema(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.AddToken(ctx, "a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.AddTokens(ctx, []string{"a", "b"}); err != nil {
+				t.Fatal(err)
+			}
+			_, _ = a.TokenExists(ctx, "a")
+			if err := a.SetTokenStatus(ctx, "a", 2); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.AddTokenMeta(ctx, models.Token{Value: "a", Category: "c"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := d.find(t, "INSERT"); got != tc.insert {
+				t.Fatalf("insert: got %q want %q", got, tc.insert)
+			}
+			if got := d.find(t, tc.bulk); got != tc.bulk {
+				t.Fatalf("bulk: got %q want %q", got, tc.bulk)
+			}
+			if got := d.find(t, "SELECT 1"); got != tc.exists {
+				t.Fatalf("exists: got %q want %q", got, tc.exists)
+			}
+			if got := d.find(t, "CREATE TABLE IF NOT EXISTS tokens "); got != tc.schema {
+				t.Fatalf("schema: got %q want %q", got, tc.schema)
+			}
+			if got := d.find(t, "INSERT INTO tokens_status"); got != tc.status {
+				t.Fatalf("status: got %q want %q", got, tc.status)
+			}
+			if got := d.find(t, "INSERT INTO tokens_meta"); got != tc.meta {
+				t.Fatalf("meta: got %q want %q", got, tc.meta)
+			}
+			if got := d.find(t, "CREATE TABLE IF NOT EXISTS tokens_meta"); !strings.Contains(got, tc.metaTypes) {
+				t.Fatalf("meta schema %q lacks %q", got, tc.metaTypes)
+			}
+		})
+	}
+}
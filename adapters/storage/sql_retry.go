@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+const defaultSQLRetryDelay = 100 * time.Millisecond
+
+// WithRetry retries reads that fail with a retryable error up to maxRetries
+// times, with jittered exponential backoff starting at baseDelay (default
+// 100ms). Writes are never retried. See WithRetryable for the classification.
+func WithRetry(maxRetries int, baseDelay time.Duration) SQLOption {
+	return func(s *SQLAdapter) {
+		s.maxRetries = maxRetries
+		s.retryDelay = baseDelay
+		if s.retryDelay <= 0 {
+			s.retryDelay = defaultSQLRetryDelay
+		}
+	}
+}
+
+// WithRetryable replaces the default classification of retryable read
+// errors: broken connections, network errors and unexpected EOF.
+func WithRetryable(fn func(error) bool) SQLOption {
+	return func(s *SQLAdapter) { s.retryable = fn }
+}
+
+// RetryableSQLError reports transient failures: broken or closed
+// connections, network errors and unexpected EOF. Context errors, missing
+// rows and query errors are fatal.
+func RetryableSQLError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// finalError stops withRetry regardless of the wrapped error.
+type finalError struct{ err error }
+
+func (e finalError) Error() string { return e.err.Error() }
+
+// withRetry runs read until it succeeds, fails fatally or retries run out.
+func (s *SQLAdapter) withRetry(ctx context.Context, read func() error) error {
+	retryable := s.retryable
+	if retryable == nil {
+		retryable = RetryableSQLError
+	}
+	for attempt := 0; ; attempt++ {
+		err := read()
+		if fe, ok := err.(finalError); ok {
+			return fe.err
+		}
+		if err == nil || attempt >= s.maxRetries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		d := s.retryDelay << min(attempt, 16)
+		timer := time.NewTimer(d + rand.N(d/2+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
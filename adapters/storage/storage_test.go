@@ -7,9 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/core"
+	"github.com/elum-utils/censor/interfaces"
+	"github.com/elum-utils/censor/models"
 )
 
 func TestMemoryAdapter(t *testing.T) {
@@ -77,9 +84,444 @@ func TestSQLAdapterWithStubDriver(t *testing.T) {
 	}
 }
 
+func TestTokenStatusStorage(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryAdapter()
+	_ = m.SetTokenStatus(ctx, "scam.example", models.StatusCommercialOffPlatform)
+	st, _ := m.GetTokenStatuses(ctx)
+	if st["scam.example"] != models.StatusCommercialOffPlatform {
+		t.Fatalf("unexpected memory statuses: %v", st)
+	}
+	_ = m.SetTokenStatus(ctx, "scam.example", 0)
+	if st, _ = m.GetTokenStatuses(ctx); len(st) != 0 {
+		t.Fatalf("zero status must detach: %v", st)
+	}
+
+	driverName := "censor_stub_sql_status"
+	sql.Register(driverName, &stubDriver{store: &stubStore{tokens: make(map[string]struct{})}})
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	off, _ := NewSQLAdapter(db, "tokens")
+	if st, err := off.GetTokenStatuses(ctx); err != nil || st != nil {
+		t.Fatalf("disabled statuses must read as none: %v err=%v", st, err)
+	}
+	if err := off.SetTokenStatus(ctx, "scam.example", models.StatusDangerousIllegal); err == nil {
+		t.Fatal("expected error while statuses are disabled")
+	}
+	a, _ := NewSQLAdapter(db, "tokens", WithTokenStatuses())
+	if err := a.EnsureSchema(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetTokenStatus(ctx, "scam.example", models.StatusCode(-1)); err == nil {
+		t.Fatal("expected error for a negative status")
+	}
+	if err := a.SetTokenStatus(ctx, "scam.example", models.StatusDangerousIllegal); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetTokenStatus(ctx, "scam.example", models.StatusCommercialOffPlatform); err != nil {
+		t.Fatal(err)
+	}
+	st, err = a.GetTokenStatuses(ctx)
+	if err != nil || len(st) != 1 || st["scam.example"] != models.StatusCommercialOffPlatform {
+		t.Fatalf("unexpected sql statuses: %v err=%v", st, err)
+	}
+	if tokens, _ := a.GetTokens(ctx); len(tokens) != 0 {
+		t.Fatalf("status writes must not touch token table: %v", tokens)
+	}
+
+	// Codes above 6 are allowed once Core accepts them.
+	c := core.New(core.Options{Storage: a, MaxStatusCode: 8})
+	defer c.Close()
+	if err := c.SetTokenStatus(ctx, "promo", models.StatusCode(7)); err != nil {
+		t.Fatalf("core must accept a status within MaxStatusCode: %v", err)
+	}
+	if err := a.SetTokenStatus(ctx, "refund", models.StatusCode(7)); err != nil {
+		t.Fatal(err)
+	}
+	if st, err = a.GetTokenStatuses(ctx); err != nil || st["promo"] != 7 || st["refund"] != 7 {
+		t.Fatalf("extended statuses must be stored: %v err=%v", st, err)
+	}
+	if err := c.SetTokenStatus(ctx, "promo", models.StatusCode(9)); err == nil {
+		t.Fatal("core must reject a status above MaxStatusCode")
+	}
+}
+
+func TestBulkStorage(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryAdapter()
+	_ = m.AddToken(ctx, "a")
+	if err := m.AddTokens(ctx, []string{"a", "b", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := m.GetTokens(ctx); len(all) != 2 {
+		t.Fatalf("unexpected memory tokens: %v", all)
+	}
+
+	store := &stubStore{tokens: map[string]struct{}{"a": {}}}
+	driverName := "censor_stub_sql_bulk"
+	sql.Register(driverName, &stubDriver{store: store})
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	generic, _ := NewSQLAdapter(db, "tokens")
+	if err := generic.AddTokens(ctx, []string{"a", "g", "g"}); err != nil {
+		t.Fatal(err)
+	}
+	if store.bulkCalls != 0 || len(store.tokens) != 2 {
+		t.Fatalf("generic dialect must insert row by row: bulk=%d tokens=%v", store.bulkCalls, store.tokens)
+	}
+	delete(store.tokens, "g")
+
+	a, _ := NewSQLAdapter(db, "tokens", WithDialect(DialectSQLite))
+	tokens := []string{"a", "b", "b"}
+	for i := 0; i < sqlBulkChunk; i++ {
+		tokens = append(tokens, fmt.Sprintf("t%d", i))
+	}
+	if err := a.AddTokens(ctx, tokens); err != nil {
+		t.Fatal(err)
+	}
+	if store.bulkCalls != 2 {
+		t.Fatalf("expected 2 chunked inserts, got %d", store.bulkCalls)
+	}
+	if all, _ := a.GetTokens(ctx); len(all) != sqlBulkChunk+2 {
+		t.Fatalf("unexpected sql tokens: %d", len(all))
+	}
+}
+
+func TestTokenMetaStorage(t *testing.T) {
+	ctx := context.Background()
+	added := time.Unix(1700000000, 0)
+	check := func(t *testing.T, s interfaces.StreamRichStorage) {
+		t.Helper()
+		if err := s.AddToken(ctx, "plain"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddTokenMeta(ctx, models.Token{Value: "casino", Category: "gambling", Severity: 3, Source: models.TokenSourceImport, AddedAt: added}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddTokenMeta(ctx, models.Token{Value: "casino", Category: "gambling", Severity: 4, Source: models.TokenSourceManual, AddedAt: added}); err != nil {
+			t.Fatal(err)
+		}
+		all, err := s.GetTokensMeta(ctx)
+		if err != nil || len(all) != 2 {
+			t.Fatalf("unexpected tokens: %+v err=%v", all, err)
+		}
+		byValue := map[string]models.Token{}
+		for _, token := range all {
+			byValue[token.Value] = token
+		}
+		if got := byValue["plain"]; got != (models.Token{Value: "plain"}) {
+			t.Fatalf("plain token should have empty metadata: %+v", got)
+		}
+		got := byValue["casino"]
+		if got.Category != "gambling" || got.Severity != 4 || got.Source != models.TokenSourceManual || !got.AddedAt.Equal(added) {
+			t.Fatalf("metadata did not round-trip: %+v", got)
+		}
+		if ok, _ := s.TokenExists(ctx, "casino"); !ok {
+			t.Fatalf("AddTokenMeta must add the token")
+		}
+		streamed := map[string]models.Token{}
+		if err := s.GetTokensMetaFunc(ctx, func(token models.Token) error {
+			streamed[token.Value] = token
+			return nil
+		}); err != nil || len(streamed) != 2 || streamed["casino"] != got || streamed["plain"].HasMeta() {
+			t.Fatalf("streamed metadata differs: %+v err=%v", streamed, err)
+		}
+	}
+
+	t.Run("memory", func(t *testing.T) { check(t, NewMemoryAdapter()) })
+	t.Run("sql", func(t *testing.T) {
+		store := &stubStore{tokens: make(map[string]struct{})}
+		driverName := "censor_stub_sql_meta"
+		sql.Register(driverName, &stubDriver{store: store})
+		db, err := sql.Open(driverName, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		a, _ := NewSQLAdapter(db, "tokens", WithTokenMeta())
+		if err := a.EnsureSchema(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var metaSchema string
+		for _, q := range store.schemas {
+			if strings.Contains(q, "tokens_meta") {
+				metaSchema = q
+			}
+		}
+		for _, col := range []string{"category text", "severity integer", "source text", "added_at integer"} {
+			if !strings.Contains(metaSchema, col) {
+				t.Fatalf("meta schema missing %q: %s", col, metaSchema)
+			}
+		}
+		check(t, a)
+	})
+	t.Run("sql without meta", func(t *testing.T) {
+		store := &stubStore{tokens: make(map[string]struct{})}
+		driverName := "censor_stub_sql_nometa"
+		sql.Register(driverName, &stubDriver{store: store})
+		db, err := sql.Open(driverName, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		a, _ := NewSQLAdapter(db, "tokens")
+		if err := a.EnsureSchema(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range store.schemas {
+			if strings.Contains(q, "tokens_meta") || strings.Contains(q, "tokens_status") {
+				t.Fatalf("optional table created without its option: %s", q)
+			}
+		}
+		if err := a.AddTokenMeta(ctx, models.Token{Value: "casino", Category: "gambling"}); err != nil {
+			t.Fatal(err)
+		}
+		all, err := a.GetTokensMeta(ctx)
+		if err != nil || len(all) != 1 || all[0] != (models.Token{Value: "casino"}) || len(store.meta) != 0 {
+			t.Fatalf("disabled meta must store only values: %+v err=%v", all, err)
+		}
+		var streamed []models.Token
+		if err := a.GetTokensMetaFunc(ctx, func(token models.Token) error {
+			streamed = append(streamed, token)
+			return nil
+		}); err != nil || len(streamed) != 1 || streamed[0].HasMeta() {
+			t.Fatalf("disabled meta must stream only values: %+v err=%v", streamed, err)
+		}
+	})
+}
+
+func TestAddTokensMeta(t *testing.T) {
+	ctx := context.Background()
+	added := time.Unix(1700000000, 0)
+	check := func(t *testing.T, s interfaces.BulkRichStorage) {
+		t.Helper()
+		if err := s.AddTokenMeta(ctx, models.Token{Value: "casino", Category: "old"}); err != nil {
+			t.Fatal(err)
+		}
+		batch := []models.Token{
+			{Value: "casino", Category: "gambling", Severity: 3, AddedAt: added},
+			{Value: "spam", Source: models.TokenSourceAuto, AddedAt: added},
+			{Value: "spam", Source: models.TokenSourceManual, AddedAt: added},
+		}
+		if err := s.AddTokensMeta(ctx, batch); err != nil {
+			t.Fatal(err)
+		}
+		all, err := s.GetTokensMeta(ctx)
+		if err != nil || len(all) != 2 {
+			t.Fatalf("unexpected tokens: %+v err=%v", all, err)
+		}
+		byValue := map[string]models.Token{}
+		for _, token := range all {
+			byValue[token.Value] = token
+		}
+		if got := byValue["casino"]; got.Category != "gambling" || got.Severity != 3 || !got.AddedAt.Equal(added) {
+			t.Fatalf("metadata not replaced: %+v", got)
+		}
+		if got := byValue["spam"]; got.Source != models.TokenSourceManual {
+			t.Fatalf("last entry for a token must win: %+v", got)
+		}
+	}
+
+	t.Run("memory", func(t *testing.T) { check(t, NewMemoryAdapter()) })
+	for name, opts := range map[string][]SQLOption{
+		"generic": {WithTokenMeta()},
+		"sqlite":  {WithTokenMeta(), WithDialect(DialectSQLite)},
+	} {
+		t.Run("sql "+name, func(t *testing.T) {
+			store := &stubStore{tokens: make(map[string]struct{})}
+			driverName := "censor_stub_sql_bulk_meta_" + name
+			sql.Register(driverName, &stubDriver{store: store})
+			db, err := sql.Open(driverName, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			a, _ := NewSQLAdapter(db, "tokens", opts...)
+			check(t, a)
+		})
+	}
+}
+
+func TestSQLRemoveTokenDropsMetaAndStatus(t *testing.T) {
+	ctx := context.Background()
+	store := &stubStore{tokens: make(map[string]struct{})}
+	driverName := "censor_stub_sql_remove_meta"
+	sql.Register(driverName, &stubDriver{store: store})
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	a, _ := NewSQLAdapter(db, "tokens", WithTokenMeta(), WithTokenStatuses())
+	_ = a.AddTokenMeta(ctx, models.Token{Value: "spam", Source: models.TokenSourceAuto, AddedAt: time.Now().Add(-time.Hour)})
+	_ = a.SetTokenStatus(ctx, "spam", models.StatusSuspicious)
+
+	c := core.New(core.Options{Storage: a})
+	defer c.Close()
+	if n, err := c.PurgeLearnedTokens(ctx, time.Now()); err != nil || n != 1 {
+		t.Fatalf("expected the learned token purged, got %d err=%v", n, err)
+	}
+	if len(store.meta) != 0 || len(store.statuses) != 0 {
+		t.Fatalf("removal must drop meta and status rows: meta=%v statuses=%v", store.meta, store.statuses)
+	}
+	if err := c.AddToken(ctx, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.PurgeLearnedTokens(ctx, time.Now().Add(time.Hour)); err != nil || n != 0 {
+		t.Fatalf("a manually re-added token must not be purged, got %d err=%v", n, err)
+	}
+	if ok, _ := a.TokenExists(ctx, "spam"); !ok {
+		t.Fatal("manually re-added token was removed")
+	}
+}
+
+func TestGetTokensFunc(t *testing.T) {
+	ctx := context.Background()
+	stop := errors.New("stop")
+	check := func(t *testing.T, s interfaces.StreamStorage) {
+		t.Helper()
+		for _, token := range []string{"a", "b", "c"} {
+			_ = s.AddToken(ctx, token)
+		}
+		seen := map[string]int{}
+		if err := s.GetTokensFunc(ctx, func(token string) error {
+			seen[token]++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if len(seen) != 3 || seen["a"] != 1 || seen["b"] != 1 || seen["c"] != 1 {
+			t.Fatalf("callback must run once per token: %v", seen)
+		}
+		calls := 0
+		err := s.GetTokensFunc(ctx, func(string) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Fatalf("callback error must abort iteration: err=%v calls=%d", err, calls)
+		}
+	}
+
+	t.Run("memory", func(t *testing.T) { check(t, NewMemoryAdapter()) })
+	t.Run("sql", func(t *testing.T) {
+		driverName := "censor_stub_sql_stream"
+		sql.Register(driverName, &stubDriver{store: &stubStore{tokens: make(map[string]struct{})}})
+		db, err := sql.Open(driverName, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		a, _ := NewSQLAdapter(db, "tokens")
+		check(t, a)
+	})
+}
+
+func TestGetTokensAfter(t *testing.T) {
+	ctx := context.Background()
+	check := func(t *testing.T, s interfaces.ResumableStreamStorage) {
+		t.Helper()
+		for _, token := range []string{"c", "a", "d", "b"} {
+			_ = s.AddToken(ctx, token)
+		}
+		var got []string
+		collect := func(token string) error {
+			got = append(got, token)
+			return nil
+		}
+		if err := s.GetTokensAfter(ctx, "", collect); err != nil || strings.Join(got, ",") != "a,b,c,d" {
+			t.Fatalf("full read = %v err=%v", got, err)
+		}
+		got = nil
+		if err := s.GetTokensAfter(ctx, "b", collect); err != nil || strings.Join(got, ",") != "c,d" {
+			t.Fatalf("resumed read = %v err=%v", got, err)
+		}
+	}
+
+	t.Run("memory", func(t *testing.T) { check(t, NewMemoryAdapter()) })
+	t.Run("sql", func(t *testing.T) {
+		store := &stubStore{tokens: make(map[string]struct{})}
+		driverName := "censor_stub_sql_after"
+		sql.Register(driverName, &stubDriver{store: store})
+		db, err := sql.Open(driverName, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		a, _ := NewSQLAdapter(db, "tokens", WithRetry(2, time.Millisecond))
+		check(t, a)
+
+		// A retried read continues after the last delivered token.
+		store.failRowsAt = 2
+		before := store.queryCalls
+		var got []string
+		err = a.GetTokensAfter(ctx, "", func(token string) error {
+			got = append(got, token)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, ",") != "a,b,c,d" || store.queryCalls-before != 2 {
+			t.Fatalf("unexpected tokens across retry: %v after %d queries", got, store.queryCalls-before)
+		}
+	})
+}
+
+func TestMemoryDeferQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryDeferQueue()
+	_ = q.Enqueue(ctx, []models.Message{{ID: 1}, {ID: 2}})
+	_ = q.Enqueue(ctx, []models.Message{{ID: 3}})
+	if q.Len() != 3 {
+		t.Fatalf("Len = %d", q.Len())
+	}
+	got, err := q.Drain(ctx)
+	if err != nil || len(got) != 3 || got[0].ID != 1 || got[2].ID != 3 {
+		t.Fatalf("Drain = %+v, %v", got, err)
+	}
+	if got, _ := q.Drain(ctx); len(got) != 0 || q.Len() != 0 {
+		t.Fatalf("queue must be empty after drain, got %+v", got)
+	}
+}
+
+var _ interfaces.DeferStore = (*MemoryDeferQueue)(nil)
+var _ interfaces.StreamStorage = (*MemoryAdapter)(nil)
+var _ interfaces.StreamStorage = (*SQLAdapter)(nil)
+var _ interfaces.RichStorage = (*MemoryAdapter)(nil)
+var _ interfaces.RichStorage = (*SQLAdapter)(nil)
+var _ interfaces.BulkStorage = (*MemoryAdapter)(nil)
+var _ interfaces.BulkStorage = (*SQLAdapter)(nil)
+var _ interfaces.BulkStorage = (*RedisAdapter)(nil)
+var _ interfaces.StatusStorage = (*MemoryAdapter)(nil)
+var _ interfaces.StatusStorage = (*SQLAdapter)(nil)
+var _ interfaces.BulkRichStorage = (*MemoryAdapter)(nil)
+var _ interfaces.BulkRichStorage = (*SQLAdapter)(nil)
+var _ interfaces.StreamRichStorage = (*MemoryAdapter)(nil)
+var _ interfaces.StreamRichStorage = (*SQLAdapter)(nil)
+var _ interfaces.PingStorage = (*SQLAdapter)(nil)
+var _ interfaces.PingStorage = (*RedisAdapter)(nil)
+
 type stubStore struct {
-	mu     sync.Mutex
-	tokens map[string]struct{}
+	mu       sync.Mutex
+	tokens   map[string]struct{}
+	statuses map[string]int64
+	meta     map[string][]driver.Value
+	schemas  []string
+	// bulkCalls counts multi-row inserts.
+	bulkCalls int
+	// failQueries makes the next queries fail with queryErr.
+	failQueries int
+	queryErr    error
+	queryCalls  int
+	// failRowsAt breaks the next ordered read before that row.
+	failRowsAt int
 }
 
 type stubDriver struct{ store *stubStore }
@@ -89,15 +531,23 @@ type stubConn struct{ store *stubStore }
 type stubRows struct {
 	data []string
 	idx  int
+	// failAt makes Next fail with ErrBadConn before row failAt; zero disables.
+	failAt int
 }
 
 type stubResult struct{}
 
+// stubTx applies statements immediately; it only lets BeginTx succeed.
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
 func (d *stubDriver) Open(string) (driver.Conn, error) { return &stubConn{store: d.store}, nil }
 
 func (c *stubConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not used") }
 func (c *stubConn) Close() error                        { return nil }
-func (c *stubConn) Begin() (driver.Tx, error)           { return nil, errors.New("not used") }
+func (c *stubConn) Begin() (driver.Tx, error)           { return stubTx{}, nil }
 
 func (c *stubConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	q := strings.ToLower(query)
@@ -105,6 +555,38 @@ func (c *stubConn) ExecContext(_ context.Context, query string, args []driver.Na
 	defer c.store.mu.Unlock()
 	switch {
 	case strings.Contains(q, "create table"):
+		c.store.schemas = append(c.store.schemas, q)
+		return stubResult{}, nil
+	case strings.Contains(q, "_meta") && strings.Contains(q, "insert"):
+		if c.store.meta == nil {
+			c.store.meta = make(map[string][]driver.Value)
+		}
+		// Multi-row upserts carry five columns per row.
+		for start := 0; start < len(args); start += 5 {
+			row := make([]driver.Value, 5)
+			for i, arg := range args[start : start+5] {
+				row[i] = arg.Value
+			}
+			c.store.meta[fmt.Sprint(row[0])] = row
+		}
+		return stubResult{}, nil
+	case strings.Contains(q, "_meta") && strings.Contains(q, "delete"):
+		delete(c.store.meta, fmt.Sprint(args[0].Value))
+		return stubResult{}, nil
+	case strings.Contains(q, "_status") && strings.Contains(q, "insert"):
+		if c.store.statuses == nil {
+			c.store.statuses = make(map[string]int64)
+		}
+		c.store.statuses[fmt.Sprint(args[0].Value)] = args[1].Value.(int64)
+		return stubResult{}, nil
+	case strings.Contains(q, "_status") && strings.Contains(q, "delete"):
+		delete(c.store.statuses, fmt.Sprint(args[0].Value))
+		return stubResult{}, nil
+	case strings.Contains(q, "on conflict do nothing"):
+		c.store.bulkCalls++
+		for _, arg := range args {
+			c.store.tokens[fmt.Sprint(arg.Value)] = struct{}{}
+		}
 		return stubResult{}, nil
 	case strings.Contains(q, "insert"):
 		token := fmt.Sprint(args[0].Value)
@@ -126,6 +608,43 @@ func (c *stubConn) QueryContext(_ context.Context, query string, args []driver.N
 	q := strings.ToLower(query)
 	c.store.mu.Lock()
 	defer c.store.mu.Unlock()
+	c.store.queryCalls++
+	if c.store.failQueries > 0 {
+		c.store.failQueries--
+		return nil, c.store.queryErr
+	}
+	if strings.Contains(q, "left join") {
+		rows := &stubMetaRows{}
+		for token := range c.store.tokens {
+			row, ok := c.store.meta[token]
+			if !ok {
+				row = []driver.Value{token, nil, nil, nil, nil}
+			}
+			rows.rows = append(rows.rows, row)
+		}
+		return rows, nil
+	}
+	if strings.Contains(q, "_status") {
+		rows := &stubStatusRows{}
+		for token, status := range c.store.statuses {
+			rows.tokens = append(rows.tokens, token)
+			rows.statuses = append(rows.statuses, status)
+		}
+		return rows, nil
+	}
+	if strings.Contains(q, "where token >") {
+		after := fmt.Sprint(args[0].Value)
+		out := make([]string, 0, len(c.store.tokens))
+		for token := range c.store.tokens {
+			if token > after {
+				out = append(out, token)
+			}
+		}
+		sort.Strings(out)
+		rows := &stubRows{data: out, failAt: c.store.failRowsAt}
+		c.store.failRowsAt = 0
+		return rows, nil
+	}
 	if strings.Contains(q, "limit 1") {
 		token := fmt.Sprint(args[0].Value)
 		if _, ok := c.store.tokens[token]; !ok {
@@ -143,6 +662,9 @@ func (c *stubConn) QueryContext(_ context.Context, query string, args []driver.N
 func (r *stubRows) Columns() []string { return []string{"token"} }
 func (r *stubRows) Close() error      { return nil }
 func (r *stubRows) Next(dest []driver.Value) error {
+	if r.failAt > 0 && r.idx == r.failAt {
+		return driver.ErrBadConn
+	}
 	if r.idx >= len(r.data) {
 		return io.EOF
 	}
@@ -151,6 +673,42 @@ func (r *stubRows) Next(dest []driver.Value) error {
 	return nil
 }
 
+type stubStatusRows struct {
+	tokens   []string
+	statuses []int64
+	idx      int
+}
+
+func (r *stubStatusRows) Columns() []string { return []string{"token", "status"} }
+func (r *stubStatusRows) Close() error      { return nil }
+func (r *stubStatusRows) Next(dest []driver.Value) error {
+	if r.idx >= len(r.tokens) {
+		return io.EOF
+	}
+	dest[0] = r.tokens[r.idx]
+	dest[1] = r.statuses[r.idx]
+	r.idx++
+	return nil
+}
+
+type stubMetaRows struct {
+	rows [][]driver.Value
+	idx  int
+}
+
+func (r *stubMetaRows) Columns() []string {
+	return []string{"token", "category", "severity", "source", "added_at"}
+}
+func (r *stubMetaRows) Close() error { return nil }
+func (r *stubMetaRows) Next(dest []driver.Value) error {
+	if r.idx >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.idx])
+	r.idx++
+	return nil
+}
+
 func (stubResult) LastInsertId() (int64, error) { return 0, nil }
 func (stubResult) RowsAffected() (int64, error) { return 1, nil }
 
@@ -159,3 +717,63 @@ var _ driver.Conn = (*stubConn)(nil)
 var _ driver.ExecerContext = (*stubConn)(nil)
 var _ driver.QueryerContext = (*stubConn)(nil)
 var _ driver.Rows = (*stubRows)(nil)
+
+func TestSQLAdapterRetriesReads(t *testing.T) {
+	store := &stubStore{tokens: map[string]struct{}{"a": {}, "b": {}}}
+	driverName := "censor_stub_sql_retry"
+	sql.Register(driverName, &stubDriver{store: store})
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	a, err := NewSQLAdapter(db, "tokens", WithRetry(3, time.Millisecond), WithMaxOpenConns(2), WithMaxIdleConns(1), WithConnMaxLifetime(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Stats().MaxOpenConnections; got != 2 {
+		t.Fatalf("max open conns = %d, want 2", got)
+	}
+	ctx := context.Background()
+
+	store.failQueries, store.queryErr = 2, &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	tokens, err := a.GetTokens(ctx)
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("expected recovery after two failures: %v err=%v", tokens, err)
+	}
+	if store.queryCalls != 3 {
+		t.Fatalf("query calls = %d, want 3", store.queryCalls)
+	}
+
+	store.queryCalls = 0
+	store.failQueries, store.queryErr = 1, errors.New("syntax error at or near SELECT")
+	if _, err := a.GetTokens(ctx); err == nil || store.queryCalls != 1 {
+		t.Fatalf("fatal error must not be retried: err=%v calls=%d", err, store.queryCalls)
+	}
+
+	store.queryCalls = 0
+	store.failQueries, store.queryErr = 5, io.ErrUnexpectedEOF
+	if _, err := a.GetTokens(ctx); !errors.Is(err, io.ErrUnexpectedEOF) || store.queryCalls != 4 {
+		t.Fatalf("retries must stop after the limit: err=%v calls=%d", err, store.queryCalls)
+	}
+
+	store.queryCalls = 0
+	store.failQueries, store.queryErr = 0, nil
+	stop := &net.OpError{Op: "read", Err: errors.New("callback failure")}
+	if err := a.GetTokensFunc(ctx, func(string) error { return stop }); !errors.Is(err, stop) || store.queryCalls != 1 {
+		t.Fatalf("callback errors must not be retried: err=%v calls=%d", err, store.queryCalls)
+	}
+}
+
+func TestRetryableSQLError(t *testing.T) {
+	for _, err := range []error{driver.ErrBadConn, sql.ErrConnDone, io.ErrUnexpectedEOF, &net.OpError{Op: "dial", Err: errors.New("refused")}} {
+		if !RetryableSQLError(fmt.Errorf("wrapped: %w", err)) {
+			t.Fatalf("%v must be retryable", err)
+		}
+	}
+	for _, err := range []error{nil, sql.ErrNoRows, context.Canceled, errors.New("relation does not exist")} {
+		if RetryableSQLError(err) {
+			t.Fatalf("%v must be fatal", err)
+		}
+	}
+}
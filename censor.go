@@ -1,6 +1,9 @@
 package censor
 
-import "github.com/elum-utils/censor/core"
+import (
+	"github.com/elum-utils/censor/core"
+	"github.com/elum-utils/censor/models"
+)
 
 // Re-export core API at module root for convenient imports.
 type (
@@ -10,6 +13,33 @@ type (
 	EventName      = core.EventName
 	ViolationEvent = core.ViolationEvent
 	EventHandler   = core.EventHandler
+	HandlerID      = core.HandlerID
+
+	ResultMiddleware  = core.ResultMiddleware
+	ProcessFunc       = core.ProcessFunc
+	ProcessMiddleware = core.ProcessMiddleware
+	Reasons           = core.Reasons
+	ResultCache       = core.ResultCache
+	LearnSkipReason   = core.LearnSkipReason
+	LearnStats        = core.LearnStats
+	RuntimeStats      = core.RuntimeStats
+	AIStats           = core.AIStats
+	AIRateLimit       = core.AIRateLimit
+	UserScoring       = core.UserScoring
+	UserScore         = core.UserScore
+	DeferStore        = core.DeferStore
+	StreamOptions     = core.StreamOptions
+	HealthError       = core.HealthError
+	HealthComponent   = core.HealthComponent
+
+	MissingResultPolicy  = core.MissingResultPolicy
+	MissingAIResultError = core.MissingAIResultError
+
+	TriggerMergePolicy = core.TriggerMergePolicy
+
+	ReprocessOptions = core.ReprocessOptions
+	ReprocessReport  = core.ReprocessReport
+	StatusChange     = core.StatusChange
 )
 
 const (
@@ -19,6 +49,28 @@ const (
 	EventAutoRestrict     = core.EventAutoRestrict
 	EventAutoBanEscalate  = core.EventAutoBanEscalate
 	EventCriticalEscalate = core.EventCriticalEscalate
+	EventTokenLearned     = core.EventTokenLearned
+
+	TriggerMergeAIPreferred     = core.TriggerMergeAIPreferred
+	TriggerMergeUnion           = core.TriggerMergeUnion
+	TriggerMergeEnginePreferred = core.TriggerMergeEnginePreferred
+	TriggerMergeAIOnly          = core.TriggerMergeAIOnly
+
+	LearnSkipBelowStatus     = core.LearnSkipBelowStatus
+	LearnSkipBelowConfidence = core.LearnSkipBelowConfidence
+	LearnSkipEmpty           = core.LearnSkipEmpty
+	LearnSkipTooLong         = core.LearnSkipTooLong
+	LearnSkipKnown           = core.LearnSkipKnown
+	LearnSkipRecent          = core.LearnSkipRecent
+	LearnSkipExists          = core.LearnSkipExists
+
+	HealthAI      = core.HealthAI
+	HealthStorage = core.HealthStorage
+	HealthEngine  = core.HealthEngine
+
+	MissingResultSuspicious = core.MissingResultSuspicious
+	MissingResultClean      = core.MissingResultClean
+	MissingResultError      = core.MissingResultError
 
 	B  = core.B
 	KB = core.KB
@@ -28,7 +80,20 @@ const (
 	PB = core.PB
 )
 
+// Sentinel errors, see the core package.
+var (
+	ErrNoAnalyzer     = core.ErrNoAnalyzer
+	ErrNoStorage      = core.ErrNoStorage
+	ErrInvalidMaxSize = core.ErrInvalidMaxSize
+	ErrEmptyResult    = core.ErrEmptyResult
+)
+
 // New creates a new content safety filter.
 func New(opt Options) *Core {
 	return core.New(opt)
 }
+
+// StatusEvent returns the default bus event for an extended status code.
+func StatusEvent(code models.StatusCode) EventName {
+	return core.StatusEvent(code)
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// aiLimiter bounds concurrent AI calls. Free slots go to priority waiters before
+// normal ones, so interactive requests do not queue behind batch work.
+type aiLimiter struct {
+	mu       sync.Mutex
+	free     int
+	priority list.List
+	normal   list.List
+}
+
+func newAILimiter(slots int) *aiLimiter {
+	if slots <= 0 {
+		return nil
+	}
+	return &aiLimiter{free: slots}
+}
+
+func (l *aiLimiter) acquire(ctx context.Context, priority bool) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if l.free > 0 && (priority || l.priority.Len() == 0) {
+		l.free--
+		l.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	queue := &l.normal
+	if priority {
+		queue = &l.priority
+	}
+	elem := queue.PushBack(ch)
+	l.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		select {
+		case <-ch:
+			// Granted concurrently with cancellation: hand the slot on.
+			l.mu.Unlock()
+			l.release()
+		default:
+			queue.Remove(elem)
+			l.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+func (l *aiLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, queue := range []*list.List{&l.priority, &l.normal} {
+		if elem := queue.Front(); elem != nil {
+			queue.Remove(elem)
+			close(elem.Value.(chan struct{}))
+			return
+		}
+	}
+	l.free++
+}
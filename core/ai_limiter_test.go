@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestAILimiterPriorityFirst(t *testing.T) {
+	l := newAILimiter(1)
+	if err := l.acquire(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 2)
+	go func() {
+		_ = l.acquire(context.Background(), false)
+		order <- "normal"
+		l.release()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		_ = l.acquire(context.Background(), true)
+		order <- "priority"
+		l.release()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	l.release()
+	if first := <-order; first != "priority" {
+		t.Fatalf("expected priority waiter first, got %s", first)
+	}
+	<-order
+}
+
+func TestAILimiterCancelWhileWaiting(t *testing.T) {
+	l := newAILimiter(1)
+	_ = l.acquire(context.Background(), false)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(ctx, true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	l.release()
+	if err := l.acquire(context.Background(), false); err != nil {
+		t.Fatalf("slot must be free after cancelled waiter: %v", err)
+	}
+}
+
+type blockingAI struct {
+	mockAI
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (b *blockingAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	if msgs[0].ID == 1 {
+		b.started <- struct{}{}
+		<-b.unblock
+	}
+	return b.mockAI.AnalyzeBatch(ctx, msgs)
+}
+
+func TestPriorityProcessPreemptsQueuedBatch(t *testing.T) {
+	ai := &blockingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusClean}}, started: make(chan struct{}), unblock: make(chan struct{})}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxConcurrentAI: 1, CacheMaxBytes: 1})
+	_ = c.SyncOnce(context.Background())
+
+	go func() { _, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad 1"}) }()
+	<-ai.started
+
+	done := make(chan string, 2)
+	go func() {
+		_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 1, Data: "bad 2"})
+		done <- "batch"
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		_, _ = c.ProcessMessageWithOptions(context.Background(), models.Message{ID: 3, User: 1, Data: "bad 3"}, ProcessOptions{Priority: true})
+		done <- "priority"
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	close(ai.unblock)
+	if first := <-done; first != "priority" {
+		t.Fatalf("expected priority call to finish first, got %s", first)
+	}
+	<-done
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/elum-utils/censor/interfaces"
+)
+
+// defaultRateLimitPause is used when a rate-limit error carries no wait.
+const defaultRateLimitPause = time.Second
+
+// aiPause holds a global "no AI dispatch until" deadline set by provider rate limits.
+type aiPause struct {
+	until atomic.Int64
+}
+
+// extend moves the deadline to at, never backwards.
+func (p *aiPause) extend(at time.Time) {
+	next := at.UnixNano()
+	for {
+		cur := p.until.Load()
+		if cur >= next || p.until.CompareAndSwap(cur, next) {
+			return
+		}
+	}
+}
+
+// wait blocks until the pause is over or ctx is done.
+func (p *aiPause) wait(ctx context.Context) error {
+	for {
+		d := time.Until(time.Unix(0, p.until.Load()))
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// PausedUntil reports when AI dispatch resumes after a provider rate limit.
+// The bool is false when dispatch is not paused.
+func (c *Core) PausedUntil() (time.Time, bool) {
+	at := time.Unix(0, c.aiPause.until.Load())
+	if !at.After(time.Now()) {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+func (c *Core) backoffOnRateLimit(err error) {
+	var ra interfaces.RetryAfterError
+	if !errors.As(err, &ra) {
+		return
+	}
+	wait := ra.RetryAfter()
+	if wait <= 0 {
+		wait = defaultRateLimitPause
+	}
+	c.aiPause.extend(time.Now().Add(wait))
+	c.logWarn("ai rate limited, pausing dispatch", map[string]any{"retry_after": wait.String()})
+}
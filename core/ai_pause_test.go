@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+type retryAfterErr time.Duration
+
+func (e retryAfterErr) Error() string             { return "rate limited" }
+func (e retryAfterErr) RetryAfter() time.Duration { return time.Duration(e) }
+
+type rateLimitedOnceAI struct {
+	mockAI
+	calls    atomic.Int64
+	secondAt atomic.Int64
+}
+
+func (r *rateLimitedOnceAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	if r.calls.Add(1) == 1 {
+		return nil, retryAfterErr(50 * time.Millisecond)
+	}
+	r.secondAt.Store(time.Now().UnixNano())
+	return r.mockAI.AnalyzeBatch(ctx, msgs)
+}
+
+func TestRateLimitPausesDispatch(t *testing.T) {
+	ai := &rateLimitedOnceAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusClean}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad")})
+	_ = c.SyncOnce(context.Background())
+
+	failedAt := time.Now()
+	_, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad 1"})
+	var ra retryAfterErr
+	if !errors.As(err, &ra) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if _, paused := c.PausedUntil(); !paused {
+		t.Fatal("expected dispatch to be paused")
+	}
+
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 1, Data: "bad 2"}); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Unix(0, ai.secondAt.Load()).Sub(failedAt); waited < 50*time.Millisecond {
+		t.Fatalf("expected next dispatch after the pause, waited %s", waited)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.aiPause.extend(time.Now().Add(time.Hour))
+	cancel()
+	if _, err := c.ProcessMessage(ctx, models.Message{ID: 3, User: 1, Data: "bad 3"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled wait, got %v", err)
+	}
+}
+
+func TestPausedCallsHoldNoLimiterSlot(t *testing.T) {
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage("bad"), MaxConcurrentAI: 1, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	c.aiPause.extend(time.Now().Add(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.ProcessMessage(ctx, models.Message{ID: 1, User: 1, Data: "bad"})
+		done <- err
+	}()
+
+	// The paused call must leave the only slot free for others.
+	acquired := make(chan error, 1)
+	go func() {
+		slotCtx, slotCancel := context.WithTimeout(context.Background(), time.Second)
+		defer slotCancel()
+		acquired <- c.aiLimiter.acquire(slotCtx, false)
+	}()
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected free slot during the pause, got %v", err)
+	}
+	c.aiLimiter.release()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled wait, got %v", err)
+	}
+}
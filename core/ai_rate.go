@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// AIRateLimit caps how often AI adapter calls are dispatched.
+type AIRateLimit struct {
+	// PerSecond is the sustained number of calls per second. Zero disables the limit.
+	PerSecond float64
+	// Burst is how many calls may go out back to back. Defaults to 1.
+	Burst int
+}
+
+func newAIRate(opt AIRateLimit) *rate.Limiter {
+	if opt.PerSecond <= 0 {
+		return nil
+	}
+	burst := opt.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(opt.PerSecond), burst)
+}
+
+// waitRate blocks until the rate limit allows another adapter call. It fails
+// immediately when the wait would outlast ctx's deadline.
+func (c *Core) waitRate(ctx context.Context) error {
+	if c.aiRate == nil {
+		return nil
+	}
+	if err := c.aiRate.Wait(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("core: ai rate limit wait exceeds context deadline: %w", context.DeadlineExceeded)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestAIRateLimitSpreadsBurst(t *testing.T) {
+	ai := &chunkRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusClean}}}
+	c := New(Options{
+		AIAnalyzer:     ai,
+		Storage:        newMockStorage("bad"),
+		MaxAIBatchSize: 1,
+		AIRateLimit:    AIRateLimit{PerSecond: 20, Burst: 1},
+	})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	in := make([]models.Message, 0, 5)
+	for i := 1; i <= 5; i++ {
+		in = append(in, models.Message{ID: int64(i), Data: "bad " + strings.Repeat("z", i)})
+	}
+	start := time.Now()
+	if _, err := c.ProcessBatch(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	// Burst 1 at 20/s: the four calls after the first wait ~50ms each.
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Fatalf("calls not spread out: %v", elapsed)
+	}
+	if got := len(ai.recorded()); got != 5 {
+		t.Fatalf("expected 5 AI calls, got %d", got)
+	}
+}
+
+func TestAIRateLimitBurstIsImmediate(t *testing.T) {
+	c := New(Options{
+		AIAnalyzer:     &chunkRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusClean}}},
+		Storage:        newMockStorage("bad"),
+		MaxAIBatchSize: 1,
+		AIRateLimit:    AIRateLimit{PerSecond: 1, Burst: 3},
+	})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	start := time.Now()
+	_, err := c.ProcessBatch(context.Background(), []models.Message{{ID: 1, Data: "bad a"}, {ID: 2, Data: "bad b"}, {ID: 3, Data: "bad c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("burst should not wait: %v", elapsed)
+	}
+}
+
+func TestAIRateLimitDeadline(t *testing.T) {
+	c := New(Options{
+		AIAnalyzer:     &mockAI{result: models.AIResult{StatusCode: models.StatusClean}},
+		Storage:        newMockStorage("bad"),
+		MaxAIBatchSize: 1,
+		AIRateLimit:    AIRateLimit{PerSecond: 1, Burst: 1},
+	})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.ProcessBatch(ctx, []models.Message{{ID: 1, Data: "bad a"}, {ID: 2, Data: "bad b"}})
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "rate limit") {
+		t.Fatalf("expected rate limit deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Fatalf("should fail without waiting for the deadline: %v", elapsed)
+	}
+}
@@ -0,0 +1,360 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elum-utils/censor/models"
+)
+
+type chunkRecordingAI struct {
+	mockAI
+	mu     sync.Mutex
+	chunks [][]int64
+}
+
+func (r *chunkRecordingAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	ids := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	r.mu.Lock()
+	r.chunks = append(r.chunks, ids)
+	r.mu.Unlock()
+	return r.mockAI.AnalyzeBatch(ctx, msgs)
+}
+
+func (r *chunkRecordingAI) recorded() [][]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]int64(nil), r.chunks...)
+}
+
+func TestMaxAIBatchCharsSplitsRequests(t *testing.T) {
+	ai := &chunkRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxAIBatchChars: 20, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+
+	in := []models.Message{
+		{ID: 1, User: 1, Data: "bad 1"},
+		{ID: 2, User: 2, Data: "bad " + strings.Repeat("x", 40)},
+		{ID: 3, User: 3, Data: "bad 3"},
+		{ID: 4, User: 4, Data: "bad 4"},
+		{ID: 5, User: 5, Data: "bad 5"},
+	}
+	out, err := c.ProcessBatch(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		if out[i].Message.ID != in[i].ID || out[i].AIResult.MessageID != in[i].ID {
+			t.Fatalf("order mismatch at %d: %+v", i, out[i])
+		}
+	}
+
+	got := ai.recorded()
+	want := [][]int64{{1}, {2}, {3, 4, 5}}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected chunks: %v", got)
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Fatalf("unexpected chunk %d: %v", i, got)
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Fatalf("unexpected chunk %d: %v", i, got)
+			}
+		}
+	}
+}
+
+func TestSplitAIBatchesDisabled(t *testing.T) {
+	msgs := []models.Message{{ID: 1, Data: "aaaa"}, {ID: 2, Data: "bbbb"}}
+	if got := splitAIBatches(msgs, 0, 0); len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("expected single chunk, got %v", got)
+	}
+}
+
+func TestSplitAIBatchesCountsRunes(t *testing.T) {
+	// Each text is five Cyrillic letters: ten bytes, five characters.
+	msgs := []models.Message{{ID: 1, Data: "плохо"}, {ID: 2, Data: "дурно"}}
+	if got := splitAIBatches(msgs, 10, 0); len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("ten characters must fit one chunk, got %v", got)
+	}
+	if got := splitAIBatches(msgs, 9, 0); len(got) != 2 {
+		t.Fatalf("expected the cap to split, got %v", got)
+	}
+}
+
+type triggerRecordingAI struct {
+	mockAI
+	seen [][]string
+}
+
+func (r *triggerRecordingAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	for _, msg := range msgs {
+		r.seen = append(r.seen, msg.Triggers)
+	}
+	return r.mockAI.AnalyzeBatch(ctx, msgs)
+}
+
+func TestTriggersPassedToAI(t *testing.T) {
+	ai := &triggerRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusClean, Confidence: 0.9}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad")})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad word"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ai.seen) != 1 || len(ai.seen[0]) != 1 || ai.seen[0][0] != "bad" {
+		t.Fatalf("expected AI to see matched triggers, got %v", ai.seen)
+	}
+	if res.Message.Triggers != nil {
+		t.Fatalf("violation message must stay as submitted, got %v", res.Message.Triggers)
+	}
+}
+
+type contextRecordingAI struct {
+	mockAI
+	seen []models.ContextMessage
+}
+
+func (r *contextRecordingAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	r.seen = msgs[0].Context
+	return r.mockAI.AnalyzeBatch(ctx, msgs)
+}
+
+func TestContextPassedToAI(t *testing.T) {
+	ai := &contextRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusClean, Confidence: 0.9}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad")})
+	_ = c.SyncOnce(context.Background())
+	history := []models.ContextMessage{{User: 5, Data: "hi"}}
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad", Context: history}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ai.seen) != 1 || ai.seen[0] != history[0] {
+		t.Fatalf("expected context passed through, got %v", ai.seen)
+	}
+}
+
+func TestContextBypassesCacheAndDedup(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DisableAutoLearn: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	_, err := c.ProcessBatch(context.Background(), []models.Message{
+		{ID: 1, User: 1, Data: "bad", Context: []models.ContextMessage{{User: 2, Data: "want to buy?"}}},
+		{ID: 2, User: 1, Data: "bad", Context: []models.ContextMessage{{User: 2, Data: "you are bad"}}},
+		{ID: 3, User: 1, Data: "bad", Context: []models.ContextMessage{{User: 2, Data: "you are bad"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ai.callCount.Load(); got != 2 {
+		t.Fatalf("expected one AI request per distinct context, got %d", got)
+	}
+	if c.CacheLen() != 0 {
+		t.Fatalf("verdicts reached under context must not be cached, len=%d", c.CacheLen())
+	}
+	res, _ := c.ProcessMessage(context.Background(), models.Message{ID: 4, User: 1, Data: "bad"})
+	if res.CacheHit || ai.callCount.Load() != 3 {
+		t.Fatalf("plain message must get its own verdict: %+v calls=%d", res, ai.callCount.Load())
+	}
+	res, _ = c.ProcessMessage(context.Background(), models.Message{ID: 5, User: 1, Data: "bad", Context: []models.ContextMessage{{User: 2, Data: "hi"}}})
+	if res.CacheHit || ai.callCount.Load() != 4 {
+		t.Fatalf("message with context must not reuse the plain verdict: %+v calls=%d", res, ai.callCount.Load())
+	}
+}
+
+func TestMaxAIBatchSizeParallelChunks(t *testing.T) {
+	ai := &chunkRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxAIBatchSize: 2, AIConcurrency: 3, DisableAutoLearn: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	in := make([]models.Message, 0, 5)
+	for i := 1; i <= 5; i++ {
+		in = append(in, models.Message{ID: int64(i), User: int64(i), Data: "bad " + strings.Repeat("y", i)})
+	}
+	out, err := c.ProcessBatch(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		if out[i].Message.ID != in[i].ID || out[i].AIResult.MessageID != in[i].ID {
+			t.Fatalf("order mismatch at %d: %+v", i, out[i])
+		}
+	}
+	got := ai.recorded()
+	sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+	if len(got) != 3 || len(got[0]) != 2 || len(got[1]) != 2 || len(got[2]) != 1 || got[2][0] != 5 {
+		t.Fatalf("unexpected chunks: %v", got)
+	}
+}
+
+type failingChunkAI struct {
+	chunkRecordingAI
+	failID int64
+}
+
+func (f *failingChunkAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	for _, m := range msgs {
+		if m.ID == f.failID {
+			return nil, errors.New("chunk failed")
+		}
+	}
+	return f.chunkRecordingAI.AnalyzeBatch(ctx, msgs)
+}
+
+func TestParallelChunkErrorFailsBatch(t *testing.T) {
+	ai := &failingChunkAI{failID: 2}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxAIBatchSize: 1, AIConcurrency: 2})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	_, err := c.ProcessBatch(context.Background(), []models.Message{{ID: 1, Data: "bad a"}, {ID: 2, Data: "bad b"}, {ID: 3, Data: "bad c"}})
+	if err == nil || err.Error() != "chunk failed" {
+		t.Fatalf("expected first chunk error, got %v", err)
+	}
+}
+
+func TestSplitAIBatchesBySize(t *testing.T) {
+	msgs := []models.Message{{ID: 1}, {ID: 2}, {ID: 3}}
+	if got := splitAIBatches(msgs, 0, 2); len(got) != 2 || len(got[0]) != 2 || len(got[1]) != 1 {
+		t.Fatalf("unexpected chunks: %v", got)
+	}
+}
+
+func TestBatchDeduplicatesRepeatedText(t *testing.T) {
+	ai := &chunkRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.9, Reason: "spam"}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DisableAutoLearn: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	in := []models.Message{
+		{ID: 1, User: 10, Data: "bad deal here"},
+		{ID: 2, User: 20, Data: "  bad deal here"},
+		{ID: 3, User: 30, Data: "bad deal here\n"},
+	}
+	out, err := c.ProcessBatch(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ai.recorded(); len(got) != 1 || len(got[0]) != 1 || got[0][0] != 1 {
+		t.Fatalf("expected one AI call for message 1, got %v", got)
+	}
+	for i, v := range out {
+		r := v.AIResult
+		if v.Message.ID != in[i].ID || r.MessageID != in[i].ID || r.ViolatorUserID != in[i].User ||
+			r.StatusCode != models.StatusCommercialOffPlatform || r.Reason != "spam" {
+			t.Fatalf("unexpected result %d: %+v", i, v)
+		}
+	}
+
+	// The shared verdict warms the cache for the repeated text.
+	v, err := c.ProcessMessage(context.Background(), models.Message{ID: 4, User: 40, Data: "bad deal here"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.CacheHit || v.AIResult.MessageID != 4 || v.AIResult.ViolatorUserID != 40 || len(ai.recorded()) != 1 {
+		t.Fatalf("expected cache hit without AI: %+v", v)
+	}
+}
+
+func TestProcessBatchCancelledContextSkipsAI(t *testing.T) {
+	ai := &chunkRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusClean}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad")})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.ProcessBatch(ctx, []models.Message{{ID: 1, Data: "bad"}}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// Cancelled after the pre-filter: the AI stage must still be skipped.
+	ctx, cancel = context.WithCancel(context.Background())
+	exempt := func(string) bool { cancel(); return false }
+	_, err := c.ProcessBatchWithOptions(ctx, []models.Message{{ID: 2, Data: "bad"}}, ProcessOptions{ExemptDialogs: exempt})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if got := ai.recorded(); len(got) != 0 {
+		t.Fatalf("AI must not be called: %v", got)
+	}
+}
+
+func TestChunkLoopStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ai := &cancelAfterFirstAI{cancel: cancel}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxAIBatchSize: 1})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	_, err := c.ProcessBatch(ctx, []models.Message{{ID: 1, Data: "bad a"}, {ID: 2, Data: "bad b"}, {ID: 3, Data: "bad c"}})
+	if !errors.Is(err, context.Canceled) || ai.calls != 1 {
+		t.Fatalf("expected stop after first chunk, err=%v calls=%d", err, ai.calls)
+	}
+}
+
+type cancelAfterFirstAI struct {
+	mockAI
+	cancel context.CancelFunc
+	calls  int
+}
+
+func (a *cancelAfterFirstAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	a.calls++
+	a.cancel()
+	return a.mockAI.AnalyzeBatch(ctx, msgs)
+}
+
+// textAI flags messages containing "scam" and echoes the IDs it received.
+type textAI struct{ mockAI }
+
+func (a *textAI) AnalyzeBatch(_ context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	out := make([]models.AIResult, 0, len(msgs))
+	for _, msg := range msgs {
+		r := models.AIResult{MessageID: msg.ID, StatusCode: models.StatusClean, Confidence: 0.9}
+		if strings.Contains(msg.Data, "scam") {
+			r.StatusCode = models.StatusSuspicious
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func TestDuplicateIDsAlignByPosition(t *testing.T) {
+	log := &testLogger{}
+	c := New(Options{AIAnalyzer: &textAI{}, Storage: newMockStorage("bad"), Logger: log})
+	_ = c.SyncOnce(context.Background())
+
+	got, err := c.ProcessBatch(context.Background(), []models.Message{
+		{ID: 5, User: 1, Data: "bad scam"},
+		{ID: 5, User: 2, Data: "bad hello"},
+		{ID: 6, User: 3, Data: "bad scam"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.StatusCode{models.StatusSuspicious, models.StatusClean, models.StatusSuspicious}
+	for i, v := range got {
+		if v.AIResult.StatusCode != want[i] || v.AIResult.MessageID != v.Message.ID || v.AIResult.ViolatorUserID != v.Message.User {
+			t.Fatalf("message %d: unexpected result %+v", i, v.AIResult)
+		}
+		if v.AIResult.Reason == c.reasons.MissingAIResult {
+			t.Fatalf("message %d got a fabricated missing result", i)
+		}
+	}
+	if log.warned.Load() == 0 {
+		t.Fatal("expected a duplicate ID warning")
+	}
+}
@@ -0,0 +1,33 @@
+package core
+
+import "github.com/elum-utils/censor/engine"
+
+// buyerHeuristic resolves obvious buyer questions to clean before the AI stage.
+// A message qualifies when it contains a buyer phrase and no seller signal.
+type buyerHeuristic struct {
+	buyer  *engine.Engine
+	seller *engine.Engine
+}
+
+func newBuyerHeuristic(buyerPhrases, sellerSignals []string) *buyerHeuristic {
+	if len(buyerPhrases) == 0 {
+		return nil
+	}
+	h := &buyerHeuristic{buyer: engine.New(), seller: engine.New()}
+	h.buyer.ReplaceAll(buyerPhrases)
+	h.seller.ReplaceAll(sellerSignals)
+	if h.buyer.Count() == 0 {
+		return nil
+	}
+	return h
+}
+
+func (h *buyerHeuristic) match(data string) bool {
+	if h == nil {
+		return false
+	}
+	if len(h.buyer.FindTriggers(data)) == 0 {
+		return false
+	}
+	return len(h.seller.FindTriggers(data)) == 0
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestBuyerPhraseResolvesClean(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9}}
+	c := New(Options{
+		AIAnalyzer:       ai,
+		Storage:          newMockStorage("цена", "телеграм"),
+		DisableAutoLearn: true,
+		BuyerPhrases:     []string{"сколько стоит"},
+		SellerSignals:    []string{"телеграм", "продаю"},
+	})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessBatch(context.Background(), []models.Message{
+		{ID: 1, User: 1, Data: "Сколько стоит, какая цена?"},
+		{ID: 2, User: 1, Data: "сколько стоит? пиши в телеграм"},
+		{ID: 3, User: 1, Data: "цена договорная"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].AIResult.StatusCode != models.StatusClean || res[0].AIResult.Reason != "buyer phrase" || !res[0].Triggered {
+		t.Fatalf("expected buyer phrase clean verdict, got %+v", res[0])
+	}
+	if res[1].AIResult.StatusCode != models.StatusSuspicious {
+		t.Fatalf("seller signal must keep AI stage, got %+v", res[1])
+	}
+	if res[2].AIResult.StatusCode != models.StatusSuspicious {
+		t.Fatalf("message without buyer phrase must reach AI, got %+v", res[2])
+	}
+	if got := ai.callCount.Load(); got != 2 {
+		t.Fatalf("expected 2 AI calls, got %d", got)
+	}
+}
+
+func TestBuyerHeuristicDisabledByDefault(t *testing.T) {
+	if h := newBuyerHeuristic(nil, []string{"продаю"}); h != nil {
+		t.Fatal("expected nil heuristic without buyer phrases")
+	}
+	var h *buyerHeuristic
+	if h.match("сколько стоит") {
+		t.Fatal("nil heuristic must not match")
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// cacheRefreshBatchLimit caps how many entries one refresh pass re-analyzes.
+const cacheRefreshBatchLimit = 100
+
+func (c *Core) startCacheRefresher() {
+	if c.negativeCache == nil || c.cacheRefreshAhead <= 0 {
+		return
+	}
+	interval := time.Minute
+	if c.cacheRefreshAhead/2 < interval {
+		interval = c.cacheRefreshAhead / 2
+	}
+	if interval <= 0 {
+		interval = c.cacheRefreshAhead
+	}
+	c.workers.Add(1)
+	go func() {
+		defer c.workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						c.logWarn("cache refresh panic", map[string]any{"panic": fmt.Sprint(r)})
+					}
+				}()
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				defer cancel()
+				// Close must not wait for a slow AI call.
+				go func() {
+					select {
+					case <-c.done:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+				c.refreshCache(ctx, time.Now())
+			}()
+		}
+	}()
+}
+
+// refreshCache re-analyzes entries nearing expiry and extends them with the fresh verdict.
+// It is skipped while AI calls are in flight or the last call failed.
+func (c *Core) refreshCache(ctx context.Context, now time.Time) int {
+	if c.ai == nil || !c.aiHealthy.Load() || c.aiInflight.Load() > 0 {
+		return 0
+	}
+	keys := c.negativeCache.ExpiringWithin(c.cacheRefreshAhead, now, cacheRefreshBatchLimit)
+	if len(keys) == 0 {
+		return 0
+	}
+	messages := make([]models.Message, 0, len(keys))
+	for i, key := range keys {
+		messages = append(messages, models.Message{ID: int64(i + 1), Data: key})
+	}
+	results, _, err := c.analyze(ctx, messages, ProcessOptions{})
+	if err != nil {
+		c.logWarn("cache refresh failed", map[string]any{"error": err.Error()})
+		return 0
+	}
+	refreshed := 0
+	for _, r := range results {
+		idx := int(r.MessageID) - 1
+		if idx < 0 || idx >= len(keys) {
+			continue
+		}
+		r.MessageID, r.ViolatorUserID = 0, 0
+		c.setCachedNegative(keys[idx], r)
+		refreshed++
+	}
+	return refreshed
+}
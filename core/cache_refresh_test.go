@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestRefreshCacheExtendsNearExpiryEntries(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), CacheTTL: time.Hour, CacheRefreshAhead: 10 * time.Minute})
+	_ = c.SyncOnce(context.Background())
+
+	now := time.Now()
+	c.negativeCache.Set("bad soon", models.AIResult{StatusCode: models.StatusClean}, 5*time.Minute, now)
+	c.negativeCache.Set("bad later", models.AIResult{StatusCode: models.StatusClean}, time.Hour, now)
+
+	if n := c.refreshCache(context.Background(), now); n != 1 {
+		t.Fatalf("expected one refreshed entry, got %d", n)
+	}
+	if ai.callCount.Load() != 1 {
+		t.Fatalf("expected only near-expiry entry analyzed, got %d", ai.callCount.Load())
+	}
+	got, ok := c.negativeCache.Peek("bad soon", now.Add(30*time.Minute))
+	if !ok || got.StatusCode != models.StatusSuspicious {
+		t.Fatalf("expected refreshed entry with extended TTL, got %+v ok=%v", got, ok)
+	}
+}
+
+func TestRefreshCacheSkipsWhenAIUnhealthy(t *testing.T) {
+	ai := &mockAI{err: errors.New("down")}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), CacheRefreshAhead: 10 * time.Minute})
+	_ = c.SyncOnce(context.Background())
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad"})
+
+	now := time.Now()
+	c.negativeCache.Set("bad soon", models.AIResult{StatusCode: models.StatusClean}, time.Minute, now)
+	calls := ai.callCount.Load()
+	if n := c.refreshCache(context.Background(), now); n != 0 || ai.callCount.Load() != calls {
+		t.Fatalf("refresh must be skipped while AI is unhealthy")
+	}
+}
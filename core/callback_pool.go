@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	defaultCallbackWorkers = 4
+	// callbackQueueSize is the per-worker backlog; a full queue blocks the
+	// recording call until the worker catches up.
+	callbackQueueSize = 256
+)
+
+// callbackPool runs callbacks and event handlers on a fixed set of workers.
+// Events of one dialog always go to the same worker, so they fire in order.
+type callbackPool struct {
+	mu     sync.RWMutex
+	closed bool
+	queues []chan poolJob
+	wg     sync.WaitGroup
+}
+
+// poolJob is one queued dispatch. An empty event means the status callbacks
+// and event of e.StatusCode.
+type poolJob struct {
+	event EventName
+	e     ViolationEvent
+}
+
+func newCallbackPool(c *Core, workers int) *callbackPool {
+	if workers <= 0 {
+		workers = defaultCallbackWorkers
+	}
+	p := &callbackPool{queues: make([]chan poolJob, workers)}
+	for i := range p.queues {
+		q := make(chan poolJob, callbackQueueSize)
+		p.queues[i] = q
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range q {
+				c.dispatchSafe(job)
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues job and reports false once the pool is closed.
+func (p *callbackPool) submit(job poolJob) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	p.queues[p.worker(job.e)] <- job
+	return true
+}
+
+func (p *callbackPool) worker(e ViolationEvent) int {
+	if e.DialogID == "" {
+		return int(uint64(e.MessageID) % uint64(len(p.queues)))
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(e.DialogID))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// close stops accepting events and waits for the queued ones to fire.
+func (p *callbackPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, q := range p.queues {
+			close(q)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// dispatch fires callbacks and event handlers for e, on the pool when
+// AsyncCallbacks is set and it is still open, inline otherwise.
+func (c *Core) dispatch(e ViolationEvent) {
+	c.run(poolJob{e: e})
+}
+
+// dispatchNamed fires only the handlers of event, the same way dispatch does.
+func (c *Core) dispatchNamed(event EventName, e ViolationEvent) {
+	c.run(poolJob{event: event, e: e})
+}
+
+func (c *Core) run(job poolJob) {
+	if c.callbacks != nil && c.callbacks.submit(job) {
+		return
+	}
+	c.runJob(job)
+}
+
+func (c *Core) runJob(job poolJob) {
+	if job.event != "" {
+		c.fireEvent(context.Background(), job.event, job.e)
+		return
+	}
+	c.dispatchByStatus(context.Background(), job.e)
+	c.dispatchEvent(context.Background(), job.e)
+}
+
+// dispatchSafe is runJob on a pool worker: a panicking handler is logged
+// instead of taking the worker down.
+func (c *Core) dispatchSafe(job poolJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logWarn("callback panic", map[string]any{"panic": fmt.Sprint(r), "message_id": job.e.MessageID})
+		}
+	}()
+	c.runJob(job)
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestAsyncCallbacksDoNotBlockProcessing(t *testing.T) {
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage("bad"), AsyncCallbacks: true, CallbackWorkers: 2})
+	_ = c.SyncOnce(context.Background())
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	order := make(map[string][]int64)
+	_ = c.OnAllowClean(func(_ context.Context, e ViolationEvent) error {
+		<-release
+		mu.Lock()
+		order[e.DialogID] = append(order[e.DialogID], e.MessageID)
+		mu.Unlock()
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := int64(1); i <= 20; i++ {
+			dialog := "a"
+			if i%2 == 0 {
+				dialog = "b"
+			}
+			if _, err := c.ProcessMessage(context.Background(), models.Message{ID: i, DialogID: dialog, User: 1, Data: "hello"}); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow callback blocked ProcessMessage")
+	}
+
+	close(release)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order["a"]) != 10 || len(order["b"]) != 10 {
+		t.Fatalf("not all callbacks fired: %v", order)
+	}
+	for dialog, ids := range order {
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("dialog %s fired out of order: %v", dialog, ids)
+			}
+		}
+	}
+}
+
+func TestAsyncCallbacksInlineAfterClose(t *testing.T) {
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage("bad"), AsyncCallbacks: true})
+	_ = c.SyncOnce(context.Background())
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fired := false
+	_ = c.OnAllowClean(func(context.Context, ViolationEvent) error {
+		fired = true
+		return nil
+	})
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if !fired {
+		t.Fatal("callback must run inline after Close")
+	}
+}
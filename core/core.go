@@ -4,23 +4,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/elum-utils/censor/engine"
 	"github.com/elum-utils/censor/interfaces"
 	"github.com/elum-utils/censor/models"
+	"golang.org/x/time/rate"
 )
 
 const (
 	defaultConfidenceThreshold = 0.7
+	defaultNoTriggerConfidence = 1.0
 	defaultSyncInterval        = 5 * time.Minute
+	defaultSyncBackoffBase     = time.Second
 	defaultMaxMessageSize      = 4 * 1024
 	defaultMaxLearnTokenLength = 255
 	defaultCacheTTL            = 1 * time.Hour
 	defaultCacheMaxBytes       = 32 * MB
+	defaultLearnDedupWindow    = 30 * time.Second
+	defaultLearnDedupEntries   = 4096
 )
 
 // EventName is a callback bus event.
@@ -35,6 +42,12 @@ const (
 	EventCriticalEscalate EventName = "critical_escalate"
 )
 
+// EventTokenLearned fires once for every trigger token auto-learn adds to the
+// engine. It is not tied to a status: the payload carries the token as its
+// only TriggerTokens entry plus the status, confidence and IDs of the result
+// it was learned from.
+const EventTokenLearned EventName = "token_learned"
+
 // ViolationEvent is callback payload.
 type ViolationEvent struct {
 	DialogID        string
@@ -46,15 +59,114 @@ type ViolationEvent struct {
 	StatusCode      models.StatusCode
 	TriggeredByRule bool
 	CacheHit        bool
+	StaleTokens     bool
 }
 
 // EventHandler handles one moderation event.
 type EventHandler func(ctx context.Context, event ViolationEvent) error
 
+// HandlerID identifies a handler registered with Subscribe.
+type HandlerID uint64
+
+type registeredHandler struct {
+	id HandlerID
+	fn EventHandler
+}
+
+// ResultCache is the pluggable verdict cache, see Options.ResultCache.
+type ResultCache = interfaces.ResultCache
+
+// DeferStore is the queue for messages whose analysis failed, see Options.DeferStore.
+type DeferStore = interfaces.DeferStore
+
+// ResultMiddleware transforms a decision before it is learned from and recorded.
+type ResultMiddleware func(ctx context.Context, message models.Message, result models.AIResult) models.AIResult
+
+// ProcessFunc is the batch processing pipeline as seen by ProcessMiddleware.
+type ProcessFunc func(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.Violation, error)
+
+// ProcessMiddleware wraps the pipeline behind ProcessBatchWithOptions. It may
+// change messages before calling next, or return without calling it. Results
+// must stay one per input message, in order.
+type ProcessMiddleware func(next ProcessFunc) ProcessFunc
+
+// TriggerMergePolicy controls how engine-matched triggers are combined with AI triggers.
+type TriggerMergePolicy int
+
+const (
+	// TriggerMergeAIPreferred uses AI triggers and falls back to engine triggers when AI returned none.
+	TriggerMergeAIPreferred TriggerMergePolicy = iota
+	// TriggerMergeUnion uses AI triggers followed by engine triggers not already present.
+	TriggerMergeUnion
+	// TriggerMergeEnginePreferred uses engine triggers and falls back to AI triggers when none matched.
+	TriggerMergeEnginePreferred
+	// TriggerMergeAIOnly uses AI triggers only.
+	TriggerMergeAIOnly
+)
+
+// MissingResultPolicy controls messages the AI left out of a batch response.
+type MissingResultPolicy int
+
+const (
+	// MissingResultSuspicious resolves them to StatusHumanReview with
+	// Reasons.MissingAIResult and zero confidence.
+	MissingResultSuspicious MissingResultPolicy = iota
+	// MissingResultClean resolves them to StatusClean with
+	// Reasons.MissingAIResult and zero confidence.
+	MissingResultClean
+	// MissingResultError fails the call with a *MissingAIResultError before
+	// any AI verdict of the batch is recorded. Messages resolved without AI
+	// (no trigger, cache hit, bypass, exemption, low trigger score) are
+	// recorded already.
+	MissingResultError
+)
+
+// Sentinel errors returned by Core, for use with errors.Is.
+var (
+	ErrNoAnalyzer     = errors.New("core: AI analyzer is nil")
+	ErrNoStorage      = errors.New("core: storage is nil")
+	ErrInvalidMaxSize = errors.New("core: invalid max message size")
+	ErrEmptyResult    = errors.New("core: empty result")
+)
+
+// MissingAIResultError lists the messages the AI returned no verdict for.
+type MissingAIResultError struct {
+	MessageIDs []int64
+}
+
+func (e *MissingAIResultError) Error() string {
+	return fmt.Sprintf("core: missing AI result for messages %v", e.MessageIDs)
+}
+
 // ProcessOptions controls behavior of message checks.
 type ProcessOptions struct {
 	// SkipTriggerFilter forces AI analysis without in-memory trigger pre-filter.
 	SkipTriggerFilter bool
+	// SkipCache ignores cached verdicts so every analyzed message gets a fresh AI decision.
+	SkipCache bool
+	// ShadowMode computes decisions without side effects: no callbacks, events,
+	// metrics, cache writes or token learning.
+	ShadowMode bool
+	// Priority lets this call's AI requests take free concurrency slots ahead of
+	// queued non-priority work. Only meaningful with Options.MaxConcurrentAI.
+	Priority bool
+	// PerMessageTimeout bounds each AI request of this call. Messages of a
+	// request that times out resolve to StatusSuspicious with Reasons.AITimeout
+	// while the rest of the batch completes. Zero disables the bound.
+	PerMessageTimeout time.Duration
+	// FailFast keeps the whole call failing when a PerMessageTimeout expires.
+	FailFast bool
+	// ExemptDialogs overrides Options.ExemptDialogs for this call.
+	ExemptDialogs func(dialogID string) bool
+	// Explain attaches a ProcessTrace to every decision. Decisions are unchanged.
+	Explain bool
+
+	// dryRun is set by Evaluate: triggers are matched and the cache is read
+	// without updating engine lookup stats or cache recency.
+	dryRun bool
+	// replay is set by ProcessDeferred: the messages entered dialog context
+	// when they were first processed.
+	replay bool
 }
 
 // Options configure core filter.
@@ -64,15 +176,197 @@ type Options struct {
 	CallbackHandler interfaces.CallbackHandler
 	Processed       interfaces.ProcessedHandler
 	Logger          interfaces.Logger
+	// Engine replaces the default in-memory trigger engine.
+	Engine interfaces.Engine
+	// ResultCache replaces the in-process verdict cache, e.g. with one shared
+	// across instances. CacheMaxBytes and CacheRefreshAhead then do not apply.
+	ResultCache interfaces.ResultCache
 
 	ConfidenceThreshold float64
+	// ThresholdByStatus overrides ConfidenceThreshold per status code. A code
+	// present in the map uses its own value, even when lower than the global
+	// one; other codes fall back to ConfidenceThreshold. Non-positive values
+	// are ignored.
+	ThresholdByStatus map[models.StatusCode]float64
+	// MaxStatusCode extends the accepted status range beyond 1..6 for custom
+	// analyzers, e.g. 7 for a spam category. Codes up to it are counted and
+	// dispatched as is; larger ones are recorded as StatusSuspicious. Defaults
+	// to 6; lower values are ignored.
+	MaxStatusCode models.StatusCode
+	// StatusEvents names the bus event for extended codes. Extended codes
+	// without an entry dispatch StatusEvent(code).
+	StatusEvents map[models.StatusCode]EventName
+	// NoTriggerConfidence is assigned to the clean verdict synthesized for messages
+	// without trigger matches. Defaults to 1.
+	NoTriggerConfidence float64
 	SyncInterval        time.Duration
+	// SyncBackoffBase is the first retry delay after a failed periodic sync. It
+	// doubles per consecutive failure, capped at SyncInterval. Defaults to 1s.
+	SyncBackoffBase time.Duration
+	// StaleAfter marks decisions with StaleTokens once the last successful sync
+	// is older than this. Zero disables staleness flagging.
+	StaleAfter          time.Duration
 	MaxMessageSize      int
 	MaxLearnTokenLength int
 	CacheTTL            time.Duration
 	CacheMaxBytes       int
-	AutoLearn           bool
-	DisableAutoLearn    bool
+	// CacheRefreshAhead enables background re-analysis of cached verdicts that expire
+	// within this window, while the AI is healthy and idle. Zero disables refreshing.
+	CacheRefreshAhead time.Duration
+	// LearnDedupWindow skips storage writes for learned tokens this instance
+	// persisted within the window. Defaults to 30s.
+	LearnDedupWindow time.Duration
+	// MaxConcurrentAI bounds concurrent AI requests across all callers. Zero means unlimited.
+	MaxConcurrentAI int
+	// AIRateLimit spaces out AI adapter calls across all callers. Zero PerSecond disables it.
+	AIRateLimit AIRateLimit
+	// UserScoring keeps a decaying risk score per violator, see UserScore and
+	// TopOffenders. Zero HalfLife disables it.
+	UserScoring UserScoring
+	// MaxAIBatchChars caps the summed message length of one AI request, in
+	// characters (runes), not bytes.
+	// A message longer than the cap is sent in its own request. Zero disables the cap.
+	MaxAIBatchChars int
+	// MaxAIBatchSize caps how many messages one AI request carries. Zero disables the cap.
+	MaxAIBatchSize int
+	// AIConcurrency sends up to this many chunks of one call in parallel.
+	// Defaults to 1 (sequential). MaxConcurrentAI still bounds the total.
+	AIConcurrency    int
+	AutoLearn        bool
+	DisableAutoLearn bool
+	// SyncLearn persists learned tokens before the processing call returns
+	// instead of in the background. Failures are logged and counted in LearnStats.
+	SyncLearn bool
+	// SkipLearnIfExists checks Storage.TokenExists before persisting a learned
+	// token and skips the write when it is already stored, e.g. after a restart
+	// with an empty engine. A failed check still writes.
+	SkipLearnIfExists bool
+	// TriggerMergePolicy selects how final trigger tokens are assembled.
+	TriggerMergePolicy TriggerMergePolicy
+	// ExemptDialogs reports dialogs that are not moderated: their messages resolve
+	// to clean without trigger matching or AI.
+	ExemptDialogs func(dialogID string) bool
+	// ResultMiddleware is applied in order to every AI, cached or synthesized decision
+	// before learning and recording. Exempt dialogs bypass it.
+	ResultMiddleware []ResultMiddleware
+	// Middleware wraps every ProcessBatchWithOptions call; the first entry is
+	// the outermost. ProcessMessage, Evaluate and Reprocess go through it too.
+	Middleware []ProcessMiddleware
+	// BypassUsers reports trusted users, e.g. staff, whose messages resolve to
+	// clean without trigger matching or AI. Unlike exempt dialogs, bypassed
+	// decisions are always recorded.
+	BypassUsers func(userID int64) bool
+	// RecordExempt still records exempt decisions (metrics, callbacks and events).
+	RecordExempt bool
+	// ZeroConfidenceReview routes AI verdicts with confidence 0 to human review.
+	// Such verdicts are never cached or learned from either way. Placeholders
+	// for messages the AI did not answer keep the status OnMissingAIResult,
+	// a timeout or a deferral gave them.
+	ZeroConfidenceReview bool
+	// BuyerPhrases resolves triggered messages containing one of these phrases to
+	// clean without AI, unless a SellerSignals entry is also present. Opt-in.
+	BuyerPhrases  []string
+	SellerSignals []string
+	// Reasons overrides the reason strings of synthesized decisions.
+	Reasons Reasons
+	// OnAIError is called synchronously with the chunk of messages whose AI call
+	// failed, before the error propagates, e.g. to alert or feed a dead-letter
+	// queue. Its panics are recovered and logged.
+	OnAIError func(ctx context.Context, messages []models.Message, err error)
+	// DeferStore, when set, turns AI errors into deferred decisions: the
+	// affected messages are enqueued and resolve to StatusSuspicious with
+	// Reasons.Deferred instead of failing the batch. ProcessDeferred retries them.
+	DeferStore interfaces.DeferStore
+	// DialogContextSize keeps the last this many messages of every DialogID
+	// and attaches them as Message.Context to AI requests whose caller left
+	// Context empty. Shadow calls read but do not extend it. As with caller
+	// context, such verdicts skip the result cache. Zero disables it.
+	DialogContextSize int
+	// DialogContextTTL drops dialogs without messages for this long. Defaults
+	// to 30m.
+	DialogContextTTL time.Duration
+	// AsyncCallbacks runs callbacks, Processed and event handlers on a pool of
+	// CallbackWorkers goroutines (default 4) instead of inside the processing
+	// call. Decisions of one dialog fire in order; a full queue blocks the
+	// call. Close waits for queued callbacks, after which they run inline.
+	AsyncCallbacks  bool
+	CallbackWorkers int
+	// HealthMinTokens makes HealthCheck fail while the engine holds fewer
+	// tokens, e.g. before the first sync. Zero skips the check.
+	HealthMinTokens int
+	// OnMissingAIResult selects how messages omitted from an AI response are
+	// resolved. Defaults to MissingResultSuspicious.
+	OnMissingAIResult MissingResultPolicy
+	// MinTriggerScore sends a triggered message to the AI only when the summed
+	// Token.Severity of its triggers reaches it; tokens without a severity
+	// count 1. Below it the message resolves to LowScoreStatus with
+	// Reasons.LowTriggerScore. Zero disables the check.
+	MinTriggerScore float64
+	// LowScoreStatus is the status of messages under MinTriggerScore.
+	// Defaults to StatusClean.
+	LowScoreStatus models.StatusCode
+	// SplitOversizeMessages analyzes messages longer than MaxMessageSize in
+	// overlapping windows of that size instead of truncating them. The most
+	// severe window decision, by status then confidence, becomes the
+	// message's decision. Each window is matched and analyzed on its own, and
+	// the combined decision is not cached.
+	SplitOversizeMessages bool
+	// CalibrateConfidence remaps the confidence of every AI verdict before it
+	// is checked, cached, learned from or recorded, e.g. to clamp a model's
+	// scale or downweight a status. Nil keeps the reported confidence.
+	CalibrateConfidence func(models.AIResult) float64
+	// SyncResumeWindow keeps a sync that was cancelled or failed part way
+	// through, when storage implements ResumableStreamStorage, so the next
+	// SyncOnce within the window reads only the tokens after the last one
+	// loaded. Tokens loaded before the interruption are not read again; edits
+	// to them in between show up on the following sync. Zero means 10
+	// minutes; negative disables resuming.
+	SyncResumeWindow time.Duration
+}
+
+// Reasons holds the Reason strings assigned to decisions the core synthesizes
+// without an AI verdict. Empty fields keep the defaults.
+type Reasons struct {
+	NoTrigger       string // default "no trigger"
+	MissingAIResult string // default "missing AI result"
+	ExemptDialog    string // default "exempt dialog"
+	Bypassed        string // default "bypassed"
+	TokenStatus     string // default "token status"
+	BuyerPhrase     string // default "buyer phrase"
+	AITimeout       string // default "ai timeout"
+	Deferred        string // default "deferred"
+	LowTriggerScore string // default "low trigger score"
+}
+
+func (r Reasons) withDefaults() Reasons {
+	if r.NoTrigger == "" {
+		r.NoTrigger = "no trigger"
+	}
+	if r.MissingAIResult == "" {
+		r.MissingAIResult = "missing AI result"
+	}
+	if r.ExemptDialog == "" {
+		r.ExemptDialog = "exempt dialog"
+	}
+	if r.Bypassed == "" {
+		r.Bypassed = "bypassed"
+	}
+	if r.TokenStatus == "" {
+		r.TokenStatus = "token status"
+	}
+	if r.BuyerPhrase == "" {
+		r.BuyerPhrase = "buyer phrase"
+	}
+	if r.AITimeout == "" {
+		r.AITimeout = "ai timeout"
+	}
+	if r.Deferred == "" {
+		r.Deferred = "deferred"
+	}
+	if r.LowTriggerScore == "" {
+		r.LowTriggerScore = "low trigger score"
+	}
+	return r
 }
 
 // Core is a two-level content filter.
@@ -82,20 +376,83 @@ type Core struct {
 	cb      interfaces.CallbackHandler
 	allCb   interfaces.ProcessedHandler
 	logger  interfaces.Logger
-	engine  *engine.Engine
+	engine  interfaces.Engine
 
 	confidenceThreshold float64
+	thresholdByStatus   map[models.StatusCode]float64
+	noTriggerConfidence float64
 	syncInterval        time.Duration
+	syncBackoffBase     time.Duration
+	staleAfter          time.Duration
+	lastSync            atomic.Int64
+	// syncMu serializes SyncOnce with manual token edits so a reload cannot
+	// drop an edit made between its storage read and engine swap.
+	syncMu              sync.Mutex
+	reload              reloadState
+	staleWarned         atomic.Bool
 	maxMessageSize      int
+	splitOversize       bool
 	maxLearnTokenLength int
 	negativeCacheTTL    time.Duration
+	maxAIBatchChars     int
+	maxAIBatchSize      int
+	aiConcurrency       int
 	autoLearn           bool
+	syncLearn           bool
+	skipLearnIfExists   bool
+	triggerMerge        TriggerMergePolicy
+	recentlyPersisted   *recentTokens
+	exemptDialogs       func(dialogID string) bool
+	bypassUsers         func(userID int64) bool
+	recordExempt        bool
+	zeroConfReview      bool
+	resultMiddleware    []ResultMiddleware
+	onAIError           func(ctx context.Context, messages []models.Message, err error)
+	deferStore          interfaces.DeferStore
+	userScores          *userScores
+	dialogs             *dialogContexts
+	callbacks           *callbackPool
+	healthMinTokens     int
+	onMissing           MissingResultPolicy
+	calibrate           func(models.AIResult) float64
+	minTriggerScore     float64
+	lowScoreStatus      models.StatusCode
+	process             ProcessFunc
+	reasons             Reasons
+	buyer               *buyerHeuristic
 	negativeCache       *negativeResultCache
+	resultCache         interfaces.ResultCache
+	cacheRefreshAhead   time.Duration
+
+	aiLimiter  *aiLimiter
+	aiPause    aiPause
+	aiRate     *rate.Limiter
+	aiHealthy  atomic.Bool
+	aiInflight atomic.Int64
 
 	eventsMu sync.RWMutex
-	events   map[EventName][]EventHandler
+	events   map[EventName][]registeredHandler
+	nextID   HandlerID
+
+	// done stops background workers; workers tracks them for Close.
+	done      chan struct{}
+	closeOnce sync.Once
+	workers   sync.WaitGroup
+
+	maxStatus       models.StatusCode
+	statusEvents    map[models.StatusCode]EventName
+	processed       []atomic.Int64
+	processedByRule []atomic.Int64
+	learnSkips      learnSkipCounters
+	learnPersisted  atomic.Int64
+	learnFailed     atomic.Int64
+	counters        runtimeCounters
+	aiLatency       aiLatency
 
-	processed [7]atomic.Int64
+	// checkpoint is an interrupted sync to resume, guarded by syncMu. Manual
+	// edits discard it, so a resumed reload cannot drop them.
+	checkpoint       *syncCheckpoint
+	syncResumeWindow time.Duration
 }
 
 // New creates filter instance. Configuration errors are returned on Run/Process methods.
@@ -103,21 +460,62 @@ func New(opt Options) *Core {
 	c := &Core{
 		cb:                  noopCallbacks{},
 		engine:              engine.New(),
-		events:              make(map[EventName][]EventHandler, 6),
+		events:              make(map[EventName][]registeredHandler, 6),
 		confidenceThreshold: defaultConfidenceThreshold,
+		noTriggerConfidence: defaultNoTriggerConfidence,
 		syncInterval:        defaultSyncInterval,
+		syncBackoffBase:     defaultSyncBackoffBase,
+		syncResumeWindow:    defaultSyncResumeWindow,
 		maxMessageSize:      defaultMaxMessageSize,
 		maxLearnTokenLength: defaultMaxLearnTokenLength,
 		negativeCacheTTL:    defaultCacheTTL,
 		autoLearn:           true,
+		done:                make(chan struct{}),
+		reload:              reloadState{run: make(chan struct{}, 1), wake: make(chan struct{}, 1)},
+		maxStatus:           models.StatusCritical,
 	}
 
 	if opt.ConfidenceThreshold > 0 {
 		c.confidenceThreshold = opt.ConfidenceThreshold
 	}
+	if opt.MaxStatusCode > c.maxStatus {
+		c.maxStatus = opt.MaxStatusCode
+	}
+	c.processed = make([]atomic.Int64, c.maxStatus+1)
+	c.processedByRule = make([]atomic.Int64, c.maxStatus+1)
+	for code, name := range opt.StatusEvents {
+		if code <= models.StatusCritical || code > c.maxStatus || name == "" {
+			continue
+		}
+		if c.statusEvents == nil {
+			c.statusEvents = make(map[models.StatusCode]EventName, len(opt.StatusEvents))
+		}
+		c.statusEvents[code] = name
+	}
+	for code, threshold := range opt.ThresholdByStatus {
+		if threshold <= 0 {
+			continue
+		}
+		if c.thresholdByStatus == nil {
+			c.thresholdByStatus = make(map[models.StatusCode]float64, len(opt.ThresholdByStatus))
+		}
+		c.thresholdByStatus[code] = threshold
+	}
+	if opt.NoTriggerConfidence > 0 {
+		c.noTriggerConfidence = opt.NoTriggerConfidence
+	}
 	if opt.SyncInterval > 0 {
 		c.syncInterval = opt.SyncInterval
 	}
+	if opt.SyncResumeWindow != 0 {
+		c.syncResumeWindow = opt.SyncResumeWindow
+	}
+	if opt.SyncBackoffBase > 0 {
+		c.syncBackoffBase = opt.SyncBackoffBase
+	}
+	if opt.StaleAfter > 0 {
+		c.staleAfter = opt.StaleAfter
+	}
 	if opt.MaxMessageSize > 0 {
 		c.maxMessageSize = opt.MaxMessageSize
 	}
@@ -127,6 +525,39 @@ func New(opt Options) *Core {
 	if opt.CacheTTL > 0 {
 		c.negativeCacheTTL = opt.CacheTTL
 	}
+	learnDedupWindow := defaultLearnDedupWindow
+	if opt.LearnDedupWindow > 0 {
+		learnDedupWindow = opt.LearnDedupWindow
+	}
+	c.recentlyPersisted = newRecentTokens(learnDedupWindow, defaultLearnDedupEntries)
+	c.aiLimiter = newAILimiter(opt.MaxConcurrentAI)
+	c.aiRate = newAIRate(opt.AIRateLimit)
+	c.userScores = newUserScores(opt.UserScoring)
+	c.healthMinTokens = opt.HealthMinTokens
+	c.onMissing = opt.OnMissingAIResult
+	c.calibrate = opt.CalibrateConfidence
+	c.minTriggerScore = opt.MinTriggerScore
+	c.splitOversize = opt.SplitOversizeMessages
+	c.lowScoreStatus = models.StatusClean
+	if opt.LowScoreStatus != 0 {
+		c.lowScoreStatus = opt.LowScoreStatus
+	}
+	if opt.AsyncCallbacks {
+		c.callbacks = newCallbackPool(c, opt.CallbackWorkers)
+	}
+	c.dialogs = newDialogContexts(opt.DialogContextSize, opt.DialogContextTTL, defaultDialogContexts)
+	if opt.MaxAIBatchChars > 0 {
+		c.maxAIBatchChars = opt.MaxAIBatchChars
+	}
+	if opt.MaxAIBatchSize > 0 {
+		c.maxAIBatchSize = opt.MaxAIBatchSize
+	}
+	if opt.AIConcurrency > 0 {
+		c.aiConcurrency = opt.AIConcurrency
+	}
+	if opt.CacheRefreshAhead > 0 {
+		c.cacheRefreshAhead = opt.CacheRefreshAhead
+	}
 	cacheMaxBytes := defaultCacheMaxBytes
 	if opt.CacheMaxBytes > 0 {
 		cacheMaxBytes = opt.CacheMaxBytes
@@ -137,6 +568,8 @@ func New(opt Options) *Core {
 	if opt.DisableAutoLearn {
 		c.autoLearn = false
 	}
+	c.syncLearn = opt.SyncLearn
+	c.skipLearnIfExists = opt.SkipLearnIfExists
 	if opt.Logger != nil {
 		c.logger = opt.Logger
 	}
@@ -146,24 +579,81 @@ func New(opt Options) *Core {
 	if opt.Processed != nil {
 		c.allCb = opt.Processed
 	}
+	if opt.Engine != nil {
+		c.engine = opt.Engine
+	}
 
+	c.triggerMerge = opt.TriggerMergePolicy
+	c.exemptDialogs = opt.ExemptDialogs
+	c.bypassUsers = opt.BypassUsers
+	c.recordExempt = opt.RecordExempt
+	c.zeroConfReview = opt.ZeroConfidenceReview
+	c.resultMiddleware = append([]ResultMiddleware(nil), opt.ResultMiddleware...)
+	c.onAIError = opt.OnAIError
+	c.deferStore = opt.DeferStore
+	c.process = c.processBatch
+	for i := len(opt.Middleware) - 1; i >= 0; i-- {
+		if opt.Middleware[i] != nil {
+			c.process = opt.Middleware[i](c.process)
+		}
+	}
+	c.reasons = opt.Reasons.withDefaults()
+	c.buyer = newBuyerHeuristic(opt.BuyerPhrases, opt.SellerSignals)
 	c.ai = opt.AIAnalyzer
 	c.storage = opt.Storage
-	c.negativeCache = newNegativeResultCache(int64(cacheMaxBytes))
+	if opt.ResultCache != nil {
+		c.resultCache = opt.ResultCache
+	} else {
+		c.negativeCache = newNegativeResultCache(int64(cacheMaxBytes))
+	}
+	c.aiHealthy.Store(true)
 	c.startNegativeCacheJanitor()
+	c.startCacheRefresher()
 
 	return c
 }
 
 // On registers event handlers.
 func (c *Core) On(event EventName, handler EventHandler) error {
+	_, err := c.Subscribe(event, handler)
+	return err
+}
+
+// Subscribe registers handler like On and returns an id for Off.
+func (c *Core) Subscribe(event EventName, handler EventHandler) (HandlerID, error) {
 	if handler == nil {
-		return errors.New("core: handler is nil")
+		return 0, errors.New("core: handler is nil")
 	}
 	c.eventsMu.Lock()
-	c.events[event] = append(c.events[event], handler)
+	c.nextID++
+	id := c.nextID
+	c.events[event] = append(c.events[event], registeredHandler{id: id, fn: handler})
 	c.eventsMu.Unlock()
-	return nil
+	return id, nil
+}
+
+// Off unregisters the handler with id from event and reports whether it was
+// found. A dispatch already in progress may still call it once.
+func (c *Core) Off(event EventName, id HandlerID) bool {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+	handlers := c.events[event]
+	for i, h := range handlers {
+		if h.id != id {
+			continue
+		}
+		// Copy so snapshots taken by dispatchEvent stay intact.
+		next := make([]registeredHandler, 0, len(handlers)-1)
+		next = append(next, handlers[:i]...)
+		next = append(next, handlers[i+1:]...)
+		if len(next) == 0 {
+			delete(c.events, event)
+		} else {
+			c.events[event] = next
+		}
+		return true
+	}
+	return false
 }
 
 // OnAllowClean registers handler for status code 1 (clean).
@@ -196,6 +686,11 @@ func (c *Core) OnCriticalEscalate(handler EventHandler) error {
 	return c.On(EventCriticalEscalate, handler)
 }
 
+// OnTokenLearned registers handler for EventTokenLearned.
+func (c *Core) OnTokenLearned(handler EventHandler) error {
+	return c.On(EventTokenLearned, handler)
+}
+
 // Run loads initial tokens and starts periodic sync until context cancellation.
 func (c *Core) Run(ctx context.Context) error {
 	if err := c.validate(); err != nil {
@@ -205,33 +700,339 @@ func (c *Core) Run(ctx context.Context) error {
 		return err
 	}
 
-	ticker := time.NewTicker(c.syncInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(c.syncInterval)
+	defer timer.Stop()
+	failures := 0
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-ticker.C:
-			if err := c.SyncOnce(ctx); err != nil {
-				c.logWarn("sync failed", map[string]any{"error": err.Error()})
+		case <-c.reload.wake:
+			if err := c.Reload(ctx); err != nil {
+				c.logWarn("triggered reload failed", map[string]any{"error": err.Error()})
 			}
+		case <-timer.C:
+			delay := c.syncInterval
+			if err := c.Reload(ctx); err != nil {
+				failures++
+				delay = c.syncRetryDelay(failures)
+				c.logWarn("sync failed", map[string]any{"error": err.Error(), "failures": failures, "retry_in": delay.String()})
+			} else {
+				failures = 0
+			}
+			timer.Reset(delay)
 		}
 	}
 }
 
-// SyncOnce reloads token set from storage.
+// syncRetryDelay returns the delay before the next sync after n consecutive
+// failures: syncBackoffBase doubled per failure, capped at syncInterval.
+func (c *Core) syncRetryDelay(n int) time.Duration {
+	delay := c.syncBackoffBase
+	for i := 1; i < n && delay < c.syncInterval; i++ {
+		delay *= 2
+	}
+	if delay > c.syncInterval {
+		delay = c.syncInterval
+	}
+	return delay
+}
+
+// SyncOnce reloads token set from storage. A cancelled ctx aborts the sync
+// promptly and leaves the current token set in place.
 func (c *Core) SyncOnce(ctx context.Context) error {
 	if c.storage == nil {
-		return errors.New("core: storage is nil")
+		return ErrNoStorage
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	if err := c.reloadTokens(ctx); err != nil {
+		return err
+	}
+	if err := c.syncTokenStatuses(ctx); err != nil {
+		return err
+	}
+	c.lastSync.Store(time.Now().UnixNano())
+	c.staleWarned.Store(false)
+	return nil
+}
+
+// reloadTokens replaces the engine token set from storage. Streaming storage
+// feeds the engine token by token, in one pass with metadata when storage
+// streams it too; otherwise metadata is loaded separately.
+func (c *Core) reloadTokens(ctx context.Context) error {
+	if rs, ok := c.storage.(interfaces.ResumableStreamStorage); ok && c.syncResumeWindow > 0 {
+		if rl, ok := c.engine.(reloader); ok {
+			return c.resumableReload(ctx, rs, rl)
+		}
+	}
+	ss, streams := c.storage.(interfaces.StreamStorage)
+	sr, replaces := c.engine.(streamReplacer)
+	if mi, ms, ok := c.metaStream(); ok && replaces {
+		// Only tokens that carry metadata are held until the swap.
+		var meta []models.Token
+		err := sr.ReplaceAllFunc(ctx, func(add func(token string) error) error {
+			return ms.GetTokensMetaFunc(ctx, func(token models.Token) error {
+				if token.HasMeta() {
+					meta = append(meta, token)
+				}
+				return add(token.Value)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		mi.ReplaceTokenMeta(meta)
+		return nil
+	}
+	if streams && replaces {
+		err := sr.ReplaceAllFunc(ctx, func(add func(token string) error) error {
+			return ss.GetTokensFunc(ctx, add)
+		})
+		if err != nil {
+			return err
+		}
+		return c.reloadMeta(ctx)
+	}
+
+	tokens, meta, err := c.loadTokens(ctx)
+	if err != nil {
+		return err
+	}
+	if r, ok := c.engine.(contextReplacer); ok {
+		if err := r.ReplaceAllContext(ctx, tokens); err != nil {
+			return err
+		}
+	} else {
+		c.engine.ReplaceAll(tokens)
+	}
+	if mi, ok := c.engine.(metaIndexer); ok && meta != nil {
+		mi.ReplaceTokenMeta(meta)
+	}
+	return nil
+}
+
+// reloadMeta loads token metadata after a streaming reload.
+func (c *Core) reloadMeta(ctx context.Context) error {
+	if mi, ms, ok := c.metaStream(); ok {
+		var meta []models.Token
+		err := ms.GetTokensMetaFunc(ctx, func(token models.Token) error {
+			if token.HasMeta() {
+				meta = append(meta, token)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		mi.ReplaceTokenMeta(meta)
+		return nil
+	}
+	mi, rs, ok := c.metaSource()
+	if !ok {
+		return nil
+	}
+	meta, err := rs.GetTokensMeta(ctx)
+	if err != nil {
+		return err
+	}
+	mi.ReplaceTokenMeta(meta)
+	return nil
+}
+
+func (c *Core) metaStream() (metaIndexer, interfaces.StreamRichStorage, bool) {
+	ms, ok := c.storage.(interfaces.StreamRichStorage)
+	if !ok {
+		return nil, nil, false
+	}
+	mi, ok := c.engine.(metaIndexer)
+	return mi, ms, ok
+}
+
+func (c *Core) metaSource() (metaIndexer, interfaces.RichStorage, bool) {
+	rs, ok := c.storage.(interfaces.RichStorage)
+	if !ok {
+		return nil, nil, false
+	}
+	mi, ok := c.engine.(metaIndexer)
+	return mi, rs, ok
+}
+
+// loadTokens reads the token set, with metadata when both storage and engine
+// support it. meta is nil otherwise.
+func (c *Core) loadTokens(ctx context.Context) ([]string, []models.Token, error) {
+	_, rs, ok := c.metaSource()
+	if !ok {
+		tokens, err := c.storage.GetTokens(ctx)
+		return tokens, nil, err
+	}
+	meta, err := rs.GetTokensMeta(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens := make([]string, len(meta))
+	for i, token := range meta {
+		tokens[i] = token.Value
+	}
+	return tokens, meta, nil
+}
+
+func (c *Core) syncTokenStatuses(ctx context.Context) error {
+	ss, ok := c.storage.(interfaces.StatusStorage)
+	if !ok {
+		return nil
+	}
+	sr, ok := c.engine.(statusResolver)
+	if !ok {
+		return nil
+	}
+	statuses, err := ss.GetTokenStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	sr.ReplaceTokenStatuses(statuses)
+	return nil
+}
+
+// SetTokenStatus attaches a direct verdict to a token in storage and memory, so a
+// message consisting of exactly that token resolves without AI. A zero status detaches it;
+// others must be within 1..MaxStatusCode.
+func (c *Core) SetTokenStatus(ctx context.Context, token string, status models.StatusCode) error {
+	ss, ok := c.storage.(interfaces.StatusStorage)
+	if !ok {
+		return errors.New("core: storage does not support token statuses")
+	}
+	sr, ok := c.engine.(statusResolver)
+	if !ok {
+		return errors.New("core: engine does not support token statuses")
+	}
+	normalized := c.normalizeToken(token)
+	if normalized == "" {
+		return errors.New("core: token is empty or invalid")
+	}
+	if status != 0 && !c.validStatus(status) {
+		return fmt.Errorf("core: invalid token status %d", status)
+	}
+	if err := ss.SetTokenStatus(ctx, normalized, status); err != nil {
+		return err
+	}
+	sr.SetTokenStatus(normalized, status)
+	return nil
+}
+
+// AddToken adds a trigger token to storage and memory, normalized the same
+// way as learned tokens.
+func (c *Core) AddToken(ctx context.Context, token string) error {
+	normalized, err := c.manualToken(token)
+	if err != nil {
+		return err
 	}
-	tokens, err := c.storage.GetTokens(ctx)
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	c.checkpoint = nil
+	if err := c.storage.AddToken(ctx, normalized); err != nil {
+		return err
+	}
+	c.engine.AddToken(normalized)
+	return nil
+}
+
+// AddTokenMeta adds a trigger token with metadata to storage and memory. A
+// zero AddedAt is set to the current time and an empty Source to manual.
+func (c *Core) AddTokenMeta(ctx context.Context, token models.Token) error {
+	normalized, err := c.manualToken(token.Value)
+	if err != nil {
+		return err
+	}
+	rs, ok := c.storage.(interfaces.RichStorage)
+	if !ok {
+		return errors.New("core: storage does not support token metadata")
+	}
+	token.Value = normalized
+	if token.AddedAt.IsZero() {
+		token.AddedAt = time.Now()
+	}
+	if token.Source == "" {
+		token.Source = models.TokenSourceManual
+	}
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	c.checkpoint = nil
+	if err := rs.AddTokenMeta(ctx, token); err != nil {
+		return err
+	}
+	c.engine.AddToken(normalized)
+	if mi, ok := c.engine.(metaIndexer); ok {
+		mi.SetTokenMeta(token)
+	}
+	return nil
+}
+
+// RemoveToken removes a trigger token from storage and memory, e.g. to undo a
+// bad auto-learned token.
+func (c *Core) RemoveToken(ctx context.Context, token string) error {
+	normalized, err := c.manualToken(token)
 	if err != nil {
 		return err
 	}
-	c.engine.ReplaceAll(tokens)
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	c.checkpoint = nil
+	if err := c.storage.RemoveToken(ctx, normalized); err != nil {
+		return err
+	}
+	c.engine.RemoveToken(normalized)
+	c.recentlyPersisted.release(normalized)
 	return nil
 }
 
+// PurgeLearnedTokens removes auto-learned tokens added before the given time
+// from storage and memory and returns how many were removed. It requires
+// storage with token metadata; tokens learned without it cannot be told apart.
+func (c *Core) PurgeLearnedTokens(ctx context.Context, before time.Time) (int, error) {
+	if c.storage == nil {
+		return 0, ErrNoStorage
+	}
+	rs, ok := c.storage.(interfaces.RichStorage)
+	if !ok {
+		return 0, errors.New("core: storage does not support token metadata")
+	}
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	c.checkpoint = nil
+	tokens, err := rs.GetTokensMeta(ctx)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, token := range tokens {
+		if token.Source != models.TokenSourceAuto || !token.AddedAt.Before(before) {
+			continue
+		}
+		if err := c.storage.RemoveToken(ctx, token.Value); err != nil {
+			return removed, err
+		}
+		c.engine.RemoveToken(token.Value)
+		c.recentlyPersisted.release(token.Value)
+		removed++
+	}
+	return removed, nil
+}
+
+func (c *Core) manualToken(token string) (string, error) {
+	if c.storage == nil {
+		return "", ErrNoStorage
+	}
+	normalized := c.normalizeToken(token)
+	if normalized == "" {
+		return "", errors.New("core: token is empty or invalid")
+	}
+	return normalized, nil
+}
+
 // ProcessMessage processes one message.
 func (c *Core) ProcessMessage(ctx context.Context, message models.Message) (models.Violation, error) {
 	return c.ProcessMessageWithOptions(ctx, message, ProcessOptions{})
@@ -244,18 +1045,35 @@ func (c *Core) ProcessMessageWithOptions(ctx context.Context, message models.Mes
 		return models.Violation{}, err
 	}
 	if len(res) == 0 {
-		return models.Violation{}, errors.New("core: empty result")
+		return models.Violation{}, ErrEmptyResult
 	}
 	return res[0], nil
 }
 
+// Evaluate returns the decision for one message without side effects: nothing is
+// cached, learned, counted or dispatched to callbacks, and neither engine hit
+// stats nor cache recency change.
+func (c *Core) Evaluate(ctx context.Context, message models.Message, opt ProcessOptions) (models.Violation, error) {
+	opt.ShadowMode = true
+	opt.dryRun = true
+	return c.ProcessMessageWithOptions(ctx, message, opt)
+}
+
 // ProcessBatch processes multiple messages with trigger pre-filter and AI stage.
 func (c *Core) ProcessBatch(ctx context.Context, messages []models.Message) ([]models.Violation, error) {
 	return c.ProcessBatchWithOptions(ctx, messages, ProcessOptions{})
 }
 
 // ProcessBatchWithOptions processes multiple messages with custom process behavior.
+// A cancelled ctx fails the call with the context error before any AI request.
 func (c *Core) ProcessBatchWithOptions(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.Violation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.process(ctx, messages, opt)
+}
+
+func (c *Core) processBatch(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.Violation, error) {
 	if err := c.validate(); err != nil {
 		return nil, err
 	}
@@ -267,80 +1085,272 @@ func (c *Core) ProcessBatchWithOptions(ctx context.Context, messages []models.Me
 		index    int
 		message  models.Message
 		triggers []string
+		// context is sent with the message: the caller's own, else the
+		// accumulated dialog context.
+		context []models.ContextMessage
+		// aiID is the message whose AI result this one reuses: itself, or the
+		// first message in the batch with the same trimmed text.
+		aiID int64
+		// shared marks a message reusing another message's result.
+		shared bool
 	}
 
 	out := make([]models.Violation, len(messages))
 	filled := make([]bool, len(messages))
 	toAnalyze := make([]pendingAnalyze, 0, len(messages))
 
+	// Staleness is resolved once per call from the last successful sync.
+	stale := c.TokensStale()
+	start := time.Now()
+	exempt := c.exemptDialogs
+	if opt.ExemptDialogs != nil {
+		exempt = opt.ExemptDialogs
+	}
+
 	for i, msg := range messages {
 		prepared := msg
-		if len(prepared.Data) > c.maxMessageSize {
+		if len(prepared.Data) > c.maxMessageSize && !c.splitOversize {
 			prepared.Data = prepared.Data[:c.maxMessageSize]
 		}
+		turn := prepared
+		if len(turn.Data) > c.maxMessageSize {
+			// A split message enters dialog context truncated, as it would
+			// without splitting.
+			turn.Data = turn.Data[:c.maxMessageSize]
+		}
+		dialogContext := c.dialogs.observe(turn, !opt.ShadowMode && !opt.replay, start)
+		aiContext := prepared.Context
+		if len(aiContext) == 0 {
+			aiContext = dialogContext
+		}
+		if c.bypassUsers != nil && c.bypassUsers(prepared.User) {
+			v := c.stamp(models.Violation{Message: prepared, Triggered: false, AIResult: models.AIResult{
+				StatusCode:     models.StatusClean,
+				Reason:         c.reasons.Bypassed,
+				Confidence:     1,
+				ViolatorUserID: prepared.User,
+				MessageID:      prepared.ID,
+			}}, stale)
+			v.Trace = newTrace(opt, start, nil, false, false, "")
+			c.recordFor(v, opt)
+			out[i] = v
+			filled[i] = true
+			continue
+		}
+		if exempt != nil && exempt(prepared.DialogID) {
+			v := c.stamp(models.Violation{Message: prepared, Triggered: false, AIResult: models.AIResult{
+				StatusCode:     models.StatusClean,
+				Reason:         c.reasons.ExemptDialog,
+				Confidence:     1,
+				ViolatorUserID: prepared.User,
+				MessageID:      prepared.ID,
+			}}, stale)
+			v.Trace = newTrace(opt, start, nil, false, false, "")
+			if c.recordExempt {
+				c.recordFor(v, opt)
+			}
+			out[i] = v
+			filled[i] = true
+			continue
+		}
+		if len(prepared.Data) > c.maxMessageSize {
+			v, err := c.processOversize(ctx, prepared, dialogContext, opt, stale, start)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+			filled[i] = true
+			continue
+		}
+		if token, status, ok := c.exactStatus(prepared.Data); ok {
+			v := models.Violation{Message: prepared, Triggered: true, AIResult: models.AIResult{
+				StatusCode:     status,
+				Reason:         c.reasons.TokenStatus,
+				Confidence:     1,
+				TriggerTokens:  []string{token},
+				ViolatorUserID: prepared.User,
+				MessageID:      prepared.ID,
+			}}
+			v.Trace = newTrace(opt, start, []string{token}, false, false, "")
+			v = c.finish(ctx, v, opt, stale)
+			out[i] = v
+			filled[i] = true
+			continue
+		}
 		cacheKey := prepared.Data
 		if opt.SkipTriggerFilter {
-			if cached, ok := c.getCachedNegative(cacheKey, prepared); ok {
+			if cached, ok := c.cachedFor(cacheKey, prepared, aiContext, opt); ok {
 				v := models.Violation{Message: prepared, Triggered: false, CacheHit: true, AIResult: cached}
-				c.record(v)
+				v.Trace = newTrace(opt, start, nil, true, false, "")
+				v = c.finish(ctx, v, opt, stale)
 				out[i] = v
 				filled[i] = true
 				continue
 			}
-			toAnalyze = append(toAnalyze, pendingAnalyze{index: i, message: prepared, triggers: nil})
+			toAnalyze = append(toAnalyze, pendingAnalyze{index: i, message: prepared, triggers: nil, context: aiContext})
 			continue
 		}
-		triggers := c.engine.FindTriggers(prepared.Data)
+		triggers := c.findTriggers(prepared.Data, opt)
 		if len(triggers) == 0 {
 			v := models.Violation{Message: prepared, Triggered: false, AIResult: models.AIResult{
 				StatusCode:     models.StatusClean,
-				Reason:         "no trigger",
+				Reason:         c.reasons.NoTrigger,
+				Confidence:     c.noTriggerConfidence,
+				ViolatorUserID: prepared.User,
+				MessageID:      prepared.ID,
+			}}
+			v.Trace = newTrace(opt, start, nil, false, false, "")
+			v = c.finish(ctx, v, opt, stale)
+			out[i] = v
+			filled[i] = true
+			continue
+		}
+		if c.buyer.match(prepared.Data) {
+			v := models.Violation{Message: prepared, Triggered: true, AIResult: models.AIResult{
+				StatusCode:     models.StatusClean,
+				Reason:         c.reasons.BuyerPhrase,
 				Confidence:     1,
+				TriggerTokens:  triggers,
 				ViolatorUserID: prepared.User,
 				MessageID:      prepared.ID,
 			}}
-			c.record(v)
+			v.Trace = newTrace(opt, start, triggers, false, false, "")
+			v = c.finish(ctx, v, opt, stale)
 			out[i] = v
 			filled[i] = true
 			continue
 		}
-		if cached, ok := c.getCachedNegative(cacheKey, prepared); ok {
-			if len(cached.TriggerTokens) == 0 {
-				cached.TriggerTokens = triggers
-			}
+		if cached, ok := c.cachedFor(cacheKey, prepared, aiContext, opt); ok {
+			cached.TriggerTokens = mergeTriggers(c.triggerMerge, cached.TriggerTokens, triggers)
 			v := models.Violation{Message: prepared, Triggered: true, CacheHit: true, AIResult: cached}
-			c.record(v)
+			v.Trace = newTrace(opt, start, triggers, true, false, "")
+			v = c.finish(ctx, v, opt, stale)
 			out[i] = v
 			filled[i] = true
 			continue
 		}
-		toAnalyze = append(toAnalyze, pendingAnalyze{index: i, message: prepared, triggers: triggers})
+		if c.minTriggerScore > 0 && c.triggerScore(triggers) < c.minTriggerScore {
+			v := models.Violation{Message: prepared, Triggered: true, AIResult: models.AIResult{
+				StatusCode:     c.lowScoreStatus,
+				Reason:         c.reasons.LowTriggerScore,
+				Confidence:     c.noTriggerConfidence,
+				TriggerTokens:  triggers,
+				ViolatorUserID: prepared.User,
+				MessageID:      prepared.ID,
+			}}
+			v.Trace = newTrace(opt, start, triggers, false, false, "")
+			v = c.finish(ctx, v, opt, stale)
+			out[i] = v
+			filled[i] = true
+			continue
+		}
+		toAnalyze = append(toAnalyze, pendingAnalyze{index: i, message: prepared, triggers: triggers, context: aiContext})
 	}
 
 	if len(toAnalyze) == 0 {
 		return out, nil
 	}
 
+	// Results are matched back by ID. When the batch reuses IDs, its
+	// positions stand in for them so every message keeps its own verdict.
+	positional := duplicateIDs(toAnalyze, func(p pendingAnalyze) int64 { return p.message.ID })
+	if positional {
+		c.logWarn("duplicate message IDs in batch, aligning AI results by position", map[string]any{"messages": len(toAnalyze)})
+	}
+
+	// Repeated texts (e.g. forwarded spam) are analyzed once, unless they come
+	// with different context.
 	aiMessages := make([]models.Message, 0, len(toAnalyze))
-	for _, p := range toAnalyze {
-		aiMessages = append(aiMessages, p.message)
+	firstByText := make(map[string]int64, len(toAnalyze))
+	for i := range toAnalyze {
+		p := &toAnalyze[i]
+		text := dedupKey(p.message.Data, p.context)
+		if id, ok := firstByText[text]; ok {
+			p.aiID = id
+			p.shared = true
+			continue
+		}
+		p.aiID = p.message.ID
+		if positional {
+			p.aiID = int64(i + 1)
+		}
+		firstByText[text] = p.aiID
+		m := p.message
+		m.ID = p.aiID
+		m.Triggers = p.triggers
+		m.Context = p.context
+		aiMessages = append(aiMessages, m)
 	}
-	results, err := c.analyze(ctx, aiMessages)
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	results, timedOut, err := c.analyze(ctx, aiMessages, opt)
+	deferred := false
+	if err != nil {
+		pending := make([]models.Message, len(toAnalyze))
+		for i, p := range toAnalyze {
+			pending[i] = messages[p.index]
+		}
+		if !c.deferFailed(ctx, pending, err, opt) {
+			return nil, err
+		}
+		results, timedOut, deferred = nil, nil, true
+	}
 
 	byID := make(map[int64]models.AIResult, len(results))
 	for _, r := range results {
 		byID[r.MessageID] = r
 	}
+	if c.onMissing == MissingResultError && !deferred {
+		var missing []int64
+		for _, p := range toAnalyze {
+			if _, ok := byID[p.aiID]; !ok && !timedOut[p.aiID] {
+				missing = append(missing, p.message.ID)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, &MissingAIResultError{MessageIDs: missing}
+		}
+	}
 	for _, p := range toAnalyze {
 		msg := p.message
-		r, ok := byID[msg.ID]
-		if !ok {
+		r, ok := byID[p.aiID]
+		raw := r.Raw
+		if ok {
+			r.MessageID = msg.ID
+		}
+		if ok && p.shared {
+			r.ViolatorUserID = msg.User
+			r.TriggerTokens = append([]string(nil), r.TriggerTokens...)
+		}
+		switch {
+		case ok:
+		case deferred:
+			r = models.AIResult{
+				StatusCode:     models.StatusSuspicious,
+				Reason:         c.reasons.Deferred,
+				Confidence:     0,
+				TriggerTokens:  p.triggers,
+				ViolatorUserID: msg.User,
+				MessageID:      msg.ID,
+			}
+		case timedOut[p.aiID]:
+			r = models.AIResult{
+				StatusCode:     models.StatusSuspicious,
+				Reason:         c.reasons.AITimeout,
+				Confidence:     0,
+				TriggerTokens:  p.triggers,
+				ViolatorUserID: msg.User,
+				MessageID:      msg.ID,
+			}
+		default:
+			status := models.StatusHumanReview
+			if c.onMissing == MissingResultClean {
+				status = models.StatusClean
+			}
 			r = models.AIResult{
-				StatusCode:     models.StatusHumanReview,
-				Reason:         "missing AI result",
+				StatusCode:     status,
+				Reason:         c.reasons.MissingAIResult,
 				Confidence:     0,
 				TriggerTokens:  p.triggers,
 				ViolatorUserID: msg.User,
@@ -353,13 +1363,21 @@ func (c *Core) ProcessBatchWithOptions(ctx context.Context, messages []models.Me
 		if r.MessageID == 0 {
 			r.MessageID = msg.ID
 		}
-		if len(r.TriggerTokens) == 0 {
-			r.TriggerTokens = p.triggers
+		if c.zeroConfReview && ok && r.Confidence <= 0 {
+			r.StatusCode = models.StatusHumanReview
 		}
-		v := models.Violation{Message: msg, Triggered: len(p.triggers) > 0, AIResult: r}
-		c.setCachedNegative(msg.Data, r)
-		c.learn(r)
-		c.record(v)
+		r.TriggerTokens = mergeTriggers(c.triggerMerge, r.TriggerTokens, p.triggers)
+		v := c.stamp(models.Violation{Message: msg, Triggered: len(p.triggers) > 0, AIResult: r, Deferred: deferred}, stale)
+		v.Trace = newTrace(opt, start, p.triggers, false, true, raw)
+		if !opt.ShadowMode && len(p.context) == 0 {
+			// Cache the raw verdict: middleware runs again on every cache hit.
+			c.setCachedNegative(msg.Data, r)
+		}
+		v = c.applyResultMiddleware(ctx, v)
+		if !opt.ShadowMode {
+			c.learn(v.AIResult)
+		}
+		c.recordFor(v, opt)
 		out[p.index] = v
 		filled[p.index] = true
 	}
@@ -372,13 +1390,287 @@ func (c *Core) ProcessBatchWithOptions(ctx context.Context, messages []models.Me
 	return out, nil
 }
 
-func (c *Core) analyze(ctx context.Context, messages []models.Message) ([]models.AIResult, error) {
+// dedupKey identifies an AI request by trimmed text and context, so equal
+// texts share a verdict only when it was reached under the same context.
+func dedupKey(data string, history []models.ContextMessage) string {
+	text := strings.TrimSpace(data)
+	if len(history) == 0 {
+		return text
+	}
+	var b strings.Builder
+	b.WriteString(text)
+	for _, m := range history {
+		b.WriteByte(0)
+		b.WriteString(strconv.FormatInt(m.User, 10))
+		b.WriteByte(0)
+		b.WriteString(m.Data)
+	}
+	return b.String()
+}
+
+// triggerScore sums the severities of triggers, counting 1 for tokens
+// without one.
+func (c *Core) triggerScore(triggers []string) float64 {
+	sr, _ := c.engine.(severityResolver)
+	score := 0.0
+	for _, t := range triggers {
+		if sr != nil {
+			if severity, ok := sr.Severity(t); ok {
+				score += float64(severity)
+				continue
+			}
+		}
+		score++
+	}
+	return score
+}
+
+// duplicateIDs reports whether two items share the ID returned by id.
+func duplicateIDs[T any](items []T, id func(T) int64) bool {
+	seen := make(map[int64]struct{}, len(items))
+	for _, it := range items {
+		k := id(it)
+		if _, ok := seen[k]; ok {
+			return true
+		}
+		seen[k] = struct{}{}
+	}
+	return false
+}
+
+func mergeTriggers(policy TriggerMergePolicy, fromAI, fromEngine []string) []string {
+	switch policy {
+	case TriggerMergeUnion:
+		if len(fromEngine) == 0 {
+			return fromAI
+		}
+		out := make([]string, 0, len(fromAI)+len(fromEngine))
+		seen := make(map[string]struct{}, len(fromAI)+len(fromEngine))
+		for _, list := range [][]string{fromAI, fromEngine} {
+			for _, t := range list {
+				if _, ok := seen[t]; ok {
+					continue
+				}
+				seen[t] = struct{}{}
+				out = append(out, t)
+			}
+		}
+		return out
+	case TriggerMergeEnginePreferred:
+		if len(fromEngine) > 0 {
+			return fromEngine
+		}
+		return fromAI
+	case TriggerMergeAIOnly:
+		return fromAI
+	default:
+		if len(fromAI) > 0 {
+			return fromAI
+		}
+		return fromEngine
+	}
+}
+
+func (c *Core) analyze(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.AIResult, map[int64]bool, error) {
+	c.aiInflight.Add(1)
+	defer c.aiInflight.Add(-1)
+	res, timedOut, err := c.analyzeChunks(ctx, messages, opt)
+	c.aiHealthy.Store(err == nil && len(timedOut) == 0)
+	if c.calibrate != nil {
+		for i := range res {
+			res[i].Confidence = c.calibrate(res[i])
+		}
+	}
+	return res, timedOut, err
+}
+
+// analyzeChunks sends messages in chunks, up to aiConcurrency at a time. With
+// PerMessageTimeout and without FailFast, a chunk that times out is skipped and
+// its message IDs returned in timedOut instead of failing the batch. Any other
+// error cancels the remaining chunks.
+func (c *Core) analyzeChunks(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.AIResult, map[int64]bool, error) {
+	chunks := splitAIBatches(messages, c.maxAIBatchChars, c.maxAIBatchSize)
+	results := make([][]models.AIResult, len(chunks))
+	skipped := make([]bool, len(chunks))
+	if len(chunks) == 1 || c.aiConcurrency <= 1 {
+		for i, chunk := range chunks {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+			res, err := c.analyzeChunkTimed(ctx, chunk, opt)
+			if err != nil {
+				if !c.partialTimeout(ctx, err, opt) {
+					return nil, nil, err
+				}
+				skipped[i] = true
+				c.logWarn("ai chunk timed out", map[string]any{"messages": len(chunk), "timeout": opt.PerMessageTimeout.String()})
+				continue
+			}
+			results[i] = res
+		}
+	} else {
+		if err := c.analyzeChunksParallel(ctx, chunks, opt, results, skipped); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	out := make([]models.AIResult, 0, len(messages))
+	var timedOut map[int64]bool
+	for i, chunk := range chunks {
+		if !skipped[i] {
+			out = append(out, results[i]...)
+			continue
+		}
+		if timedOut == nil {
+			timedOut = make(map[int64]bool, len(chunk))
+		}
+		for _, m := range chunk {
+			timedOut[m.ID] = true
+		}
+	}
+	return out, timedOut, nil
+}
+
+func (c *Core) analyzeChunksParallel(ctx context.Context, chunks [][]models.Message, opt ProcessOptions, results [][]models.AIResult, skipped []bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, c.aiConcurrency)
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, chunk []models.Message) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, err := c.analyzeChunkTimed(ctx, chunk, opt)
+			if err == nil {
+				results[i] = res
+				return
+			}
+			if c.partialTimeout(ctx, err, opt) {
+				skipped[i] = true
+				c.logWarn("ai chunk timed out", map[string]any{"messages": len(chunk), "timeout": opt.PerMessageTimeout.String()})
+				return
+			}
+			errOnce.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}(i, chunk)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// partialTimeout reports whether err is a chunk timeout to be turned into
+// placeholder verdicts rather than failing the call.
+func (c *Core) partialTimeout(ctx context.Context, err error, opt ProcessOptions) bool {
+	return !opt.FailFast && opt.PerMessageTimeout > 0 && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+}
+
+func (c *Core) analyzeChunkTimed(ctx context.Context, chunk []models.Message, opt ProcessOptions) ([]models.AIResult, error) {
+	if opt.PerMessageTimeout <= 0 {
+		return c.analyzeChunk(ctx, chunk, opt)
+	}
+	chunkCtx, cancel := context.WithTimeout(ctx, opt.PerMessageTimeout)
+	defer cancel()
+	res, err := c.analyzeChunk(chunkCtx, chunk, opt)
+	if err != nil && chunkCtx.Err() == context.DeadlineExceeded && !errors.Is(err, context.DeadlineExceeded) {
+		// Adapters may wrap the deadline in their own transport error.
+		err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	return res, err
+}
+
+// splitAIBatches packs messages in input order into chunks whose summed
+// data length in runes stays within maxChars and whose size stays within maxSize.
+// Zero disables either limit.
+func splitAIBatches(messages []models.Message, maxChars, maxSize int) [][]models.Message {
+	if (maxChars <= 0 && maxSize <= 0) || len(messages) <= 1 {
+		return [][]models.Message{messages}
+	}
+	chunks := make([][]models.Message, 0, 1)
+	start, size := 0, 0
+	for i, msg := range messages {
+		n := utf8.RuneCountInString(msg.Data)
+		if i > start && ((maxChars > 0 && size+n > maxChars) || (maxSize > 0 && i-start >= maxSize)) {
+			chunks = append(chunks, messages[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	return append(chunks, messages[start:])
+}
+
+func (c *Core) analyzeChunk(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.AIResult, error) {
+	// Wait out a rate-limit pause before taking a slot, and hand the slot
+	// back if a pause started while queued for it.
+	for {
+		if err := c.aiPause.wait(ctx); err != nil {
+			return nil, err
+		}
+		if err := c.aiLimiter.acquire(ctx, opt.Priority); err != nil {
+			return nil, err
+		}
+		if _, paused := c.PausedUntil(); !paused {
+			break
+		}
+		c.aiLimiter.release()
+	}
+	defer c.aiLimiter.release()
+	c.counters.aiCalls.Add(1)
+	res, err := c.callAI(ctx, messages)
+	if err != nil {
+		c.counters.aiErrors.Add(1)
+		c.backoffOnRateLimit(err)
+		c.notifyAIError(ctx, messages, err)
+	}
+	return res, err
+}
+
+func (c *Core) notifyAIError(ctx context.Context, messages []models.Message, err error) {
+	if c.onAIError == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.logWarn("ai error hook panic", map[string]any{"panic": fmt.Sprint(r)})
+		}
+	}()
+	c.onAIError(ctx, messages, err)
+}
+
+func (c *Core) callAI(ctx context.Context, messages []models.Message) ([]models.AIResult, error) {
 	if batch, ok := c.ai.(interfaces.BatchAIAnalyzer); ok {
-		return batch.AnalyzeBatch(ctx, messages)
+		if err := c.waitRate(ctx); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		res, err := batch.AnalyzeBatch(ctx, messages)
+		c.timeAI(start, len(messages), err)
+		return res, err
 	}
 	out := make([]models.AIResult, 0, len(messages))
 	for _, message := range messages {
+		if err := c.waitRate(ctx); err != nil {
+			return nil, err
+		}
+		start := time.Now()
 		res, err := c.ai.Analyze(ctx, message)
+		c.timeAI(start, 1, err)
 		if err != nil {
 			return nil, err
 		}
@@ -399,17 +1691,26 @@ func (c *Core) learn(result models.AIResult) {
 	}
 	// Learn only from higher-risk classes (4..6).
 	if result.StatusCode < models.StatusSuspicious {
+		c.learnSkips.add(LearnSkipBelowStatus, len(result.TriggerTokens))
 		return
 	}
-	if result.Confidence < c.confidenceThreshold {
+	if !c.Confident(result) {
+		c.learnSkips.add(LearnSkipBelowConfidence, len(result.TriggerTokens))
 		return
 	}
+	var fresh []string
 	for _, token := range result.TriggerTokens {
-		normalized := strings.ToLower(strings.TrimSpace(token))
+		normalized := c.normalizeToken(token)
 		if normalized == "" {
+			c.learnSkips.add(LearnSkipEmpty, 1)
+			continue
+		}
+		if specialToken(normalized) {
+			c.learnSkips.add(LearnSkipSpecial, 1)
 			continue
 		}
 		if len(normalized) > c.maxLearnTokenLength {
+			c.learnSkips.add(LearnSkipTooLong, 1)
 			c.logWarn("token exceeds max learn length", map[string]any{
 				"token":      normalized,
 				"length":     len(normalized),
@@ -418,38 +1719,260 @@ func (c *Core) learn(result models.AIResult) {
 			continue
 		}
 		if !c.engine.AddToken(normalized) {
+			c.learnSkips.add(LearnSkipKnown, 1)
 			continue
 		}
-		go func(tok string) {
-			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-			defer cancel()
-			if err := c.storage.AddToken(ctx, tok); err != nil {
-				c.logWarn("token persist failed", map[string]any{"error": err.Error(), "token": tok})
-			}
-		}(normalized)
+		c.dispatchNamed(EventTokenLearned, ViolationEvent{
+			MessageID:      result.MessageID,
+			ViolatorUserID: result.ViolatorUserID,
+			Reason:         result.Reason,
+			Confidence:     result.Confidence,
+			TriggerTokens:  []string{normalized},
+			StatusCode:     result.StatusCode,
+		})
+		if !c.recentlyPersisted.reserve(normalized, time.Now()) {
+			c.learnSkips.add(LearnSkipRecent, 1)
+			continue
+		}
+		fresh = append(fresh, normalized)
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	if c.syncLearn {
+		c.persistLearned(fresh)
+		return
+	}
+	go c.persistLearned(fresh)
+}
+
+// persistLearned writes learned tokens with auto source metadata when storage
+// keeps metadata, in one call when storage supports batched writes, else
+// token by token.
+func (c *Core) persistLearned(tokens []string) {
+	if c.skipLearnIfExists {
+		if tokens = c.unstoredTokens(tokens); len(tokens) == 0 {
+			return
+		}
+	}
+	// Record the source so PurgeLearnedTokens can roll learned tokens back.
+	now := time.Now()
+	if brs, ok := c.storage.(interfaces.BulkRichStorage); ok {
+		meta := make([]models.Token, len(tokens))
+		for i, tok := range tokens {
+			meta[i] = models.Token{Value: tok, Source: models.TokenSourceAuto, AddedAt: now}
+		}
+		c.persistBatch(tokens, func(ctx context.Context) error { return brs.AddTokensMeta(ctx, meta) })
+		return
+	}
+	if rs, ok := c.storage.(interfaces.RichStorage); ok {
+		c.persistEach(tokens, func(ctx context.Context, tok string) error {
+			return rs.AddTokenMeta(ctx, models.Token{Value: tok, Source: models.TokenSourceAuto, AddedAt: now})
+		})
+		return
+	}
+	if bs, ok := c.storage.(interfaces.BulkStorage); ok {
+		c.persistBatch(tokens, func(ctx context.Context) error { return bs.AddTokens(ctx, tokens) })
+		return
+	}
+	c.persistEach(tokens, c.storage.AddToken)
+}
+
+// persistBatch writes tokens in one add call; a failure counts them all.
+func (c *Core) persistBatch(tokens []string, add func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := add(ctx); err != nil {
+		for _, tok := range tokens {
+			c.recentlyPersisted.release(tok)
+		}
+		c.learnFailed.Add(int64(len(tokens)))
+		c.logWarn("token persist failed", map[string]any{"error": err.Error(), "tokens": tokens})
+		return
+	}
+	c.learnPersisted.Add(int64(len(tokens)))
+}
+
+// unstoredTokens drops tokens storage already has. Their dedup reservation is
+// kept, so they are not checked again within the window.
+func (c *Core) unstoredTokens(tokens []string) []string {
+	out := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		exists, err := c.storage.TokenExists(ctx, tok)
+		cancel()
+		if err == nil && exists {
+			c.learnSkips.add(LearnSkipExists, 1)
+			continue
+		}
+		out = append(out, tok)
+	}
+	return out
+}
+
+func (c *Core) persistEach(tokens []string, add func(ctx context.Context, token string) error) {
+	for _, tok := range tokens {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := add(ctx, tok)
+		cancel()
+		if err != nil {
+			c.recentlyPersisted.release(tok)
+			c.learnFailed.Add(1)
+			c.logWarn("token persist failed", map[string]any{"error": err.Error(), "token": tok})
+			continue
+		}
+		c.learnPersisted.Add(1)
+	}
+}
+
+// ThresholdFor returns the confidence a result with code must reach: the
+// ThresholdByStatus entry when present, otherwise ConfidenceThreshold.
+func (c *Core) ThresholdFor(code models.StatusCode) float64 {
+	if threshold, ok := c.thresholdByStatus[code]; ok {
+		return threshold
 	}
+	return c.confidenceThreshold
 }
 
-// Metrics returns count of processed messages by status code 1..6.
+// Confident reports whether result reaches the threshold for its status code.
+// Auto-learn uses it; callbacks can use it to gate automatic sanctions.
+func (c *Core) Confident(result models.AIResult) bool {
+	return result.Confidence > 0 && result.Confidence >= c.ThresholdFor(result.StatusCode)
+}
+
+// Metrics returns count of processed messages by status code 1..MaxStatusCode.
 func (c *Core) Metrics() map[models.StatusCode]int64 {
-	out := make(map[models.StatusCode]int64, 6)
-	for i := 1; i <= 6; i++ {
+	out := make(map[models.StatusCode]int64, c.maxStatus)
+	for i := 1; i <= int(c.maxStatus); i++ {
 		out[models.StatusCode(i)] = c.processed[i].Load()
 	}
 	return out
 }
 
+// MetricsByTrigger splits Metrics by whether the message matched a trigger token
+// (triggered) or reached the decision without one (untriggered).
+func (c *Core) MetricsByTrigger() (triggered, untriggered map[models.StatusCode]int64) {
+	triggered = make(map[models.StatusCode]int64, c.maxStatus)
+	untriggered = make(map[models.StatusCode]int64, c.maxStatus)
+	for i := 1; i <= int(c.maxStatus); i++ {
+		byRule := c.processedByRule[i].Load()
+		triggered[models.StatusCode(i)] = byRule
+		untriggered[models.StatusCode(i)] = c.processed[i].Load() - byRule
+	}
+	return triggered, untriggered
+}
+
 // TokenCount returns number of in-memory tokens.
 func (c *Core) TokenCount() int {
 	return c.engine.Count()
 }
 
+func (c *Core) exactStatus(data string) (string, models.StatusCode, bool) {
+	sr, ok := c.engine.(statusResolver)
+	if !ok {
+		return "", 0, false
+	}
+	return sr.ExactStatus(data)
+}
+
+// findTriggers returns the distinct trigger tokens of data. Dry runs use the
+// engine's stat-free match path when it has one.
+func (c *Core) findTriggers(data string, opt ProcessOptions) []string {
+	mf, ok := c.engine.(matchFinder)
+	if !opt.dryRun || !ok {
+		return c.engine.FindTriggers(data)
+	}
+	matches := mf.FindTriggerMatches(data)
+	if len(matches) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(matches))
+	out := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if _, dup := seen[m.Token]; dup {
+			continue
+		}
+		seen[m.Token] = struct{}{}
+		out = append(out, m.Token)
+	}
+	return out
+}
+
+// cachedFor looks up the cached verdict for key. Messages sent with context
+// bypass the cache: it is keyed on text alone, and the verdict depends on it.
+func (c *Core) cachedFor(key string, message models.Message, aiContext []models.ContextMessage, opt ProcessOptions) (models.AIResult, bool) {
+	if opt.SkipCache || len(aiContext) > 0 {
+		return models.AIResult{}, false
+	}
+	res, ok := c.getCachedNegative(key, message, opt.dryRun)
+	switch {
+	case opt.ShadowMode:
+	case ok:
+		c.counters.cacheHits.Add(1)
+	default:
+		c.counters.cacheMisses.Add(1)
+	}
+	return res, ok
+}
+
+// stamp sets per-decision metadata: staleness and decision time.
+func (c *Core) stamp(v models.Violation, stale bool) models.Violation {
+	v.StaleTokens = stale
+	v.ProcessedAt = time.Now()
+	return v
+}
+
+// finish stamps v, applies result middleware and records the decision.
+func (c *Core) finish(ctx context.Context, v models.Violation, opt ProcessOptions, stale bool) models.Violation {
+	v = c.stamp(v, stale)
+	v = c.applyResultMiddleware(ctx, v)
+	c.recordFor(v, opt)
+	return v
+}
+
+func (c *Core) applyResultMiddleware(ctx context.Context, v models.Violation) models.Violation {
+	for _, mw := range c.resultMiddleware {
+		v.AIResult = mw(ctx, v.Message, v.AIResult)
+	}
+	return v
+}
+
+func (c *Core) recordFor(v models.Violation, opt ProcessOptions) {
+	if opt.ShadowMode {
+		return
+	}
+	c.record(v)
+}
+
+// TokenBreakdown returns the number of in-memory single-word tokens and phrases.
+// Engines without a breakdown report every token as a word.
+func (c *Core) TokenBreakdown() (words, phrases int) {
+	if b, ok := c.engine.(breakdowner); ok {
+		return b.Breakdown()
+	}
+	return c.engine.Count(), 0
+}
+
+// IdleTokens returns in-memory tokens that have not matched any message for maxIdle.
+// Actively matching tokens are never reported, so expiry jobs can prune only dead ones.
+// Engines without hit tracking report no idle tokens.
+func (c *Core) IdleTokens(maxIdle time.Duration) []string {
+	if it, ok := c.engine.(idleTracker); ok {
+		return it.IdleTokens(time.Now().Add(-maxIdle))
+	}
+	return nil
+}
+
 func (c *Core) record(v models.Violation) {
 	code := v.AIResult.StatusCode
-	if !code.Valid() {
+	if !c.validStatus(code) {
 		code = models.StatusSuspicious
 	}
 	c.processed[code].Add(1)
+	if v.Triggered {
+		c.processedByRule[code].Add(1)
+	}
+	c.scoreDecision(v, code)
 	e := ViolationEvent{
 		DialogID:        v.Message.DialogID,
 		MessageID:       v.Message.ID,
@@ -460,9 +1983,9 @@ func (c *Core) record(v models.Violation) {
 		StatusCode:      code,
 		TriggeredByRule: v.Triggered,
 		CacheHit:        v.CacheHit,
+		StaleTokens:     v.StaleTokens,
 	}
-	c.dispatchByStatus(context.Background(), e)
-	c.dispatchEvent(context.Background(), e)
+	c.dispatch(e)
 }
 
 func (c *Core) dispatchByStatus(ctx context.Context, e ViolationEvent) {
@@ -491,13 +2014,37 @@ func (c *Core) dispatchByStatus(ctx context.Context, e ViolationEvent) {
 	}
 }
 
+// validStatus reports whether code is within 1..MaxStatusCode.
+func (c *Core) validStatus(code models.StatusCode) bool {
+	return code >= models.StatusClean && code <= c.maxStatus
+}
+
+// StatusEvent returns the default bus event for an extended status code.
+func StatusEvent(code models.StatusCode) EventName {
+	return EventName(fmt.Sprintf("status_%d", int(code)))
+}
+
+// eventFor maps code to its bus event, including extended codes.
+func (c *Core) eventFor(code models.StatusCode) EventName {
+	if code <= models.StatusCritical {
+		return eventNameFromCode(code)
+	}
+	if name, ok := c.statusEvents[code]; ok {
+		return name
+	}
+	return StatusEvent(code)
+}
+
 func (c *Core) dispatchEvent(ctx context.Context, e ViolationEvent) {
-	event := eventNameFromCode(e.StatusCode)
+	c.fireEvent(ctx, c.eventFor(e.StatusCode), e)
+}
+
+func (c *Core) fireEvent(ctx context.Context, event EventName, e ViolationEvent) {
 	c.eventsMu.RLock()
-	handlers := append([]EventHandler(nil), c.events[event]...)
+	handlers := c.events[event]
 	c.eventsMu.RUnlock()
 	for _, h := range handlers {
-		if err := h(ctx, e); err != nil {
+		if err := h.fn(ctx, e); err != nil {
 			c.logWarn("event handler failed", map[string]any{"error": err.Error(), "event": event})
 		}
 	}
@@ -524,13 +2071,13 @@ func eventNameFromCode(code models.StatusCode) EventName {
 
 func (c *Core) validate() error {
 	if c.ai == nil {
-		return errors.New("core: AI analyzer is nil")
+		return ErrNoAnalyzer
 	}
 	if c.storage == nil {
-		return errors.New("core: storage is nil")
+		return ErrNoStorage
 	}
 	if c.maxMessageSize <= 0 {
-		return fmt.Errorf("core: invalid max message size: %d", c.maxMessageSize)
+		return fmt.Errorf("%w: %d", ErrInvalidMaxSize, c.maxMessageSize)
 	}
 	return nil
 }
@@ -557,11 +2104,21 @@ func (c *Core) logWarn(msg string, fields map[string]any) {
 	}
 }
 
-func (c *Core) getCachedNegative(key string, message models.Message) (models.AIResult, bool) {
-	if c.negativeCache == nil {
-		return models.AIResult{}, false
+// getCachedNegative reads the cached verdict for key. With peek the LRU order
+// is left as it is.
+func (c *Core) getCachedNegative(key string, message models.Message, peek bool) (models.AIResult, bool) {
+	var (
+		res models.AIResult
+		ok  bool
+	)
+	switch {
+	case c.resultCache != nil:
+		res, ok = c.resultCache.Get(key)
+	case c.negativeCache != nil && peek:
+		res, ok = c.negativeCache.Peek(key, time.Now())
+	case c.negativeCache != nil:
+		res, ok = c.negativeCache.Get(key, time.Now())
 	}
-	res, ok := c.negativeCache.Get(key, time.Now())
 	if !ok {
 		return models.AIResult{}, false
 	}
@@ -570,11 +2127,54 @@ func (c *Core) getCachedNegative(key string, message models.Message) (models.AIR
 	return res, true
 }
 
+// PeekCache returns the cached verdict for message text without changing cache state.
+// An external ResultCache is read with Get.
+func (c *Core) PeekCache(messageData string) (models.AIResult, bool) {
+	messageData = c.cacheKeyFor(messageData)
+	if c.resultCache != nil {
+		return c.resultCache.Get(messageData)
+	}
+	if c.negativeCache == nil {
+		return models.AIResult{}, false
+	}
+	return c.negativeCache.Peek(messageData, time.Now())
+}
+
+// InvalidateCache drops the cached verdict for message text.
+func (c *Core) InvalidateCache(messageData string) {
+	messageData = c.cacheKeyFor(messageData)
+	if c.resultCache != nil {
+		c.resultCache.Remove(messageData)
+		return
+	}
+	c.negativeCache.Remove(messageData)
+}
+
+// CacheLen returns the number of cached verdicts.
+func (c *Core) CacheLen() int {
+	if c.resultCache != nil {
+		return c.resultCache.Len()
+	}
+	return c.negativeCache.Len()
+}
+
+func (c *Core) cacheKeyFor(messageData string) string {
+	if len(messageData) > c.maxMessageSize && c.maxMessageSize > 0 {
+		return messageData[:c.maxMessageSize]
+	}
+	return messageData
+}
+
 func (c *Core) setCachedNegative(key string, result models.AIResult) {
-	if c.negativeCache == nil || key == "" {
+	if key == "" {
+		return
+	}
+	// Zero confidence marks placeholders and non-answers, not real verdicts.
+	if !c.validStatus(result.StatusCode) || result.Confidence <= 0 {
 		return
 	}
-	if !result.StatusCode.Valid() {
+	if c.resultCache != nil {
+		c.resultCache.Set(key, result, c.negativeCacheTTL)
 		return
 	}
 	c.negativeCache.Set(key, result, c.negativeCacheTTL, time.Now())
@@ -588,7 +2188,9 @@ func (c *Core) startNegativeCacheJanitor() {
 	if c.negativeCacheTTL > 0 && c.negativeCacheTTL < interval {
 		interval = c.negativeCacheTTL
 	}
+	c.workers.Add(1)
 	go func() {
+		defer c.workers.Done()
 		defer func() {
 			if r := recover(); r != nil {
 				c.logWarn("negative cache janitor panic", map[string]any{"panic": fmt.Sprint(r)})
@@ -597,7 +2199,12 @@ func (c *Core) startNegativeCacheJanitor() {
 
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
-		for range ticker.C {
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
 			func() {
 				defer func() {
 					if r := recover(); r != nil {
@@ -610,6 +2217,79 @@ func (c *Core) startNegativeCacheJanitor() {
 	}()
 }
 
+// Close stops background workers started by New and waits for them to exit,
+// including queued async callbacks. It is safe to call more than once.
+// Process calls still work after Close, but cached verdicts are no longer
+// swept or refreshed and callbacks run inline.
+func (c *Core) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	c.workers.Wait()
+	if c.callbacks != nil {
+		c.callbacks.close()
+	}
+	return nil
+}
+
+// Optional engine capabilities implemented by *engine.Engine.
+type (
+	contextReplacer interface {
+		ReplaceAllContext(ctx context.Context, tokens []string) error
+	}
+	streamReplacer interface {
+		ReplaceAllFunc(ctx context.Context, fill func(add func(token string) error) error) error
+	}
+	reloader interface {
+		BeginReload() *engine.Reload
+	}
+	exporter interface {
+		Export() []string
+	}
+	breakdowner interface {
+		Breakdown() (words, phrases int)
+	}
+	idleTracker interface {
+		IdleTokens(cutoff time.Time) []string
+	}
+	statsResetter interface {
+		ResetStats()
+	}
+	metaIndexer interface {
+		SetTokenMeta(token models.Token)
+		ReplaceTokenMeta(tokens []models.Token)
+	}
+	statusResolver interface {
+		SetTokenStatus(token string, status models.StatusCode)
+		ReplaceTokenStatuses(statuses map[string]models.StatusCode)
+		ExactStatus(message string) (string, models.StatusCode, bool)
+	}
+	tokenNormalizer interface {
+		NormalizeToken(token string) string
+	}
+	severityResolver interface {
+		Severity(token string) (int, bool)
+	}
+	matchFinder interface {
+		FindTriggerMatches(message string) []engine.Match
+	}
+)
+
+// specialToken reports whether a normalized token carries a regex, wildcard
+// or category prefix.
+func specialToken(token string) bool {
+	return strings.HasPrefix(token, engine.RegexPrefix) ||
+		strings.HasPrefix(token, engine.WildcardPrefix) ||
+		strings.HasPrefix(token, engine.CategoryPrefix)
+}
+
+// normalizeToken canonicalizes a token with the engine's normalization
+// policy when it has one, otherwise with engine.NormalizeToken.
+func (c *Core) normalizeToken(token string) string {
+	if n, ok := c.engine.(tokenNormalizer); ok {
+		return n.NormalizeToken(token)
+	}
+	return engine.NormalizeToken(token)
+}
+
 type noopCallbacks struct{}
 
 func (noopCallbacks) OnClean(context.Context, models.Violation) error                 { return nil }
@@ -3,10 +3,12 @@ package core
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/elum-utils/censor/engine"
 	"github.com/elum-utils/censor/models"
 )
 
@@ -56,23 +58,41 @@ func (n *noopProcessed) OnProcessed(context.Context, models.Violation) error {
 	return nil
 }
 
-type testLogger struct{ warned atomic.Int64 }
+type testLogger struct{ warned, debugged atomic.Int64 }
 
-func (l *testLogger) Debug(string, map[string]any) {}
+func (l *testLogger) Debug(string, map[string]any) { l.debugged.Add(1) }
 func (l *testLogger) Info(string, map[string]any)  {}
 func (l *testLogger) Warn(string, map[string]any)  { l.warned.Add(1) }
 func (l *testLogger) Error(string, map[string]any) {}
 
 func TestValidateErrors(t *testing.T) {
 	_, err := New(Options{}).ProcessBatch(context.Background(), []models.Message{{ID: 1, User: 1, Data: "x"}})
-	if err == nil {
-		t.Fatalf("expected validation error")
+	if !errors.Is(err, ErrNoAnalyzer) {
+		t.Fatalf("expected ErrNoAnalyzer, got %v", err)
 	}
 
 	c := New(Options{AIAnalyzer: singleAI{}})
 	_, err = c.ProcessBatch(context.Background(), []models.Message{{ID: 1, User: 1, Data: "x"}})
-	if err == nil {
-		t.Fatalf("expected storage validation error")
+	if !errors.Is(err, ErrNoStorage) {
+		t.Fatalf("expected ErrNoStorage, got %v", err)
+	}
+	if err := c.AddToken(context.Background(), "x"); !errors.Is(err, ErrNoStorage) {
+		t.Fatalf("expected ErrNoStorage from AddToken, got %v", err)
+	}
+
+	c = New(Options{AIAnalyzer: singleAI{}, Storage: newMockStorage()})
+	c.maxMessageSize = -1
+	_, err = c.ProcessBatch(context.Background(), []models.Message{{ID: 1, User: 1, Data: "x"}})
+	if !errors.Is(err, ErrInvalidMaxSize) || err.Error() != "core: invalid max message size: -1" {
+		t.Fatalf("expected ErrInvalidMaxSize, got %v", err)
+	}
+
+	empty := func(ProcessFunc) ProcessFunc {
+		return func(context.Context, []models.Message, ProcessOptions) ([]models.Violation, error) { return nil, nil }
+	}
+	c = New(Options{AIAnalyzer: singleAI{}, Storage: newMockStorage(), Middleware: []ProcessMiddleware{empty}})
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "x"}); !errors.Is(err, ErrEmptyResult) {
+		t.Fatalf("expected ErrEmptyResult, got %v", err)
 	}
 }
 
@@ -152,3 +172,312 @@ func TestEventMappingDefault(t *testing.T) {
 		t.Fatalf("unexpected mapping")
 	}
 }
+
+func TestMergeTriggersPolicies(t *testing.T) {
+	ai := []string{"a", "b"}
+	eng := []string{"b", "c"}
+	cases := []struct {
+		policy TriggerMergePolicy
+		ai     []string
+		eng    []string
+		want   []string
+	}{
+		{TriggerMergeAIPreferred, ai, eng, []string{"a", "b"}},
+		{TriggerMergeAIPreferred, nil, eng, []string{"b", "c"}},
+		{TriggerMergeUnion, ai, eng, []string{"a", "b", "c"}},
+		{TriggerMergeEnginePreferred, ai, eng, []string{"b", "c"}},
+		{TriggerMergeEnginePreferred, ai, nil, []string{"a", "b"}},
+		{TriggerMergeAIOnly, nil, eng, nil},
+	}
+	for i, tc := range cases {
+		got := mergeTriggers(tc.policy, tc.ai, tc.eng)
+		if len(got) != len(tc.want) {
+			t.Fatalf("case %d: got %v want %v", i, got, tc.want)
+		}
+		for j := range got {
+			if got[j] != tc.want[j] {
+				t.Fatalf("case %d: got %v want %v", i, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestTriggerMergeUnionInProcess(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5, TriggerTokens: []string{"from-ai"}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), TriggerMergePolicy: TriggerMergeUnion, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.AIResult.TriggerTokens; len(got) != 2 || got[0] != "from-ai" || got[1] != "bad" {
+		t.Fatalf("unexpected triggers: %v", got)
+	}
+}
+
+func TestExemptDialogsShortCircuit(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCritical, Confidence: 1}}
+	c := New(Options{
+		AIAnalyzer:    ai,
+		Storage:       newMockStorage("bad"),
+		ExemptDialogs: func(id string) bool { return id == "staff" },
+	})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessBatch(context.Background(), []models.Message{
+		{ID: 1, User: 1, DialogID: "staff", Data: "bad"},
+		{ID: 2, User: 2, DialogID: "public", Data: "bad"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].AIResult.StatusCode != models.StatusClean || res[0].Triggered {
+		t.Fatalf("exempt dialog must resolve clean: %+v", res[0])
+	}
+	if res[1].AIResult.StatusCode != models.StatusCritical || ai.callCount.Load() != 1 {
+		t.Fatalf("non-exempt dialog must be analyzed: %+v calls=%d", res[1], ai.callCount.Load())
+	}
+	if c.Metrics()[models.StatusClean] != 0 {
+		t.Fatalf("exempt decisions are not recorded by default")
+	}
+
+	// Per-call override disables the exemption.
+	res, err = c.ProcessBatchWithOptions(context.Background(), []models.Message{{ID: 3, User: 1, DialogID: "staff", Data: "bad"}},
+		ProcessOptions{ExemptDialogs: func(string) bool { return false }})
+	if err != nil || res[0].AIResult.StatusCode != models.StatusCritical {
+		t.Fatalf("override must disable exemption: %+v err=%v", res, err)
+	}
+}
+
+func TestExemptDialogsRecordMetric(t *testing.T) {
+	c := New(Options{
+		AIAnalyzer:    &mockAI{},
+		Storage:       newMockStorage("bad"),
+		ExemptDialogs: func(string) bool { return true },
+		RecordExempt:  true,
+	})
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, DialogID: "x", Data: "bad"})
+	if c.Metrics()[models.StatusClean] != 1 {
+		t.Fatalf("expected exempt decision to be recorded")
+	}
+}
+
+type findCountingEngine struct {
+	*engine.Engine
+	finds atomic.Int64
+}
+
+func (e *findCountingEngine) FindTriggers(message string) []string {
+	e.finds.Add(1)
+	return e.Engine.FindTriggers(message)
+}
+
+func TestBypassUsersSkipEngineAndAI(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCritical, Confidence: 1}}
+	eng := &findCountingEngine{Engine: engine.New()}
+	var callbacks atomic.Int64
+	c := New(Options{
+		AIAnalyzer:  ai,
+		Storage:     newMockStorage("bad"),
+		Engine:      eng,
+		BypassUsers: func(user int64) bool { return user == 1 },
+	})
+	defer c.Close()
+	_ = c.OnAllowClean(func(context.Context, ViolationEvent) error {
+		callbacks.Add(1)
+		return nil
+	})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AIResult.StatusCode != models.StatusClean || res.AIResult.Reason != "bypassed" || res.Triggered {
+		t.Fatalf("bypassed user must resolve clean: %+v", res)
+	}
+	if eng.finds.Load() != 0 || ai.callCount.Load() != 0 {
+		t.Fatalf("bypassed user hit engine %d times and AI %d times", eng.finds.Load(), ai.callCount.Load())
+	}
+	if c.Metrics()[models.StatusClean] != 1 || callbacks.Load() != 1 {
+		t.Fatalf("bypassed decision must be recorded: metrics=%v callbacks=%d", c.Metrics(), callbacks.Load())
+	}
+
+	if res, _ := c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 2, Data: "bad"}); res.AIResult.StatusCode != models.StatusCritical || eng.finds.Load() == 0 {
+		t.Fatalf("other users must be moderated: %+v", res)
+	}
+}
+
+func TestMetricsByTrigger(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.5}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("buy"), DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "buy now"})
+	_, _ = c.ProcessMessageWithOptions(context.Background(), models.Message{ID: 2, User: 1, Data: "selling stuff"}, ProcessOptions{SkipTriggerFilter: true})
+	_, _ = c.ProcessMessageWithOptions(context.Background(), models.Message{ID: 3, User: 1, Data: "selling more"}, ProcessOptions{SkipTriggerFilter: true})
+
+	triggered, untriggered := c.MetricsByTrigger()
+	if triggered[models.StatusCommercialOffPlatform] != 1 || untriggered[models.StatusCommercialOffPlatform] != 2 {
+		t.Fatalf("unexpected split: triggered=%v untriggered=%v", triggered, untriggered)
+	}
+}
+
+func TestResultMiddlewareOrderAndLearn(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCritical, Confidence: 0.9, TriggerTokens: []string{"learned"}}}
+	st := newMockStorage("bad")
+	var order []string
+	c := New(Options{
+		AIAnalyzer: ai,
+		Storage:    st,
+		ResultMiddleware: []ResultMiddleware{
+			func(_ context.Context, msg models.Message, r models.AIResult) models.AIResult {
+				order = append(order, "clamp")
+				if msg.DialogID == "kids" {
+					r.StatusCode = models.StatusHumanReview
+				}
+				return r
+			},
+			func(_ context.Context, _ models.Message, r models.AIResult) models.AIResult {
+				order = append(order, "tag")
+				r.Reason += "|tagged"
+				return r
+			},
+		},
+	})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, DialogID: "kids", Data: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AIResult.StatusCode != models.StatusHumanReview || res.AIResult.Reason != "|tagged" {
+		t.Fatalf("unexpected result: %+v", res.AIResult)
+	}
+	if len(order) != 2 || order[0] != "clamp" || order[1] != "tag" {
+		t.Fatalf("unexpected middleware order: %v", order)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if st.hasToken("learned") {
+		t.Fatalf("learn must use the post-middleware status")
+	}
+
+	// Cached raw verdict is transformed again on hit.
+	res, _ = c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 1, DialogID: "adults", Data: "bad"})
+	if !res.CacheHit || res.AIResult.StatusCode != models.StatusCritical || res.AIResult.Reason != "|tagged" {
+		t.Fatalf("unexpected cached result: %+v", res)
+	}
+}
+
+func TestExtendedStatusCode(t *testing.T) {
+	const spam models.StatusCode = 7
+	ai := &mockAI{result: models.AIResult{StatusCode: spam, Confidence: 0.9, Reason: "spam"}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("promo"), MaxStatusCode: 7})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	var got []models.StatusCode
+	_ = c.On(StatusEvent(spam), func(_ context.Context, e ViolationEvent) error {
+		got = append(got, e.StatusCode)
+		return nil
+	})
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "promo"})
+	if err != nil || res.AIResult.StatusCode != spam {
+		t.Fatalf("extended code must not be downgraded: %+v err=%v", res, err)
+	}
+	if m := c.Metrics(); m[spam] != 1 || m[models.StatusSuspicious] != 0 || len(m) != 7 {
+		t.Fatalf("unexpected metrics: %v", m)
+	}
+	if len(got) != 1 || got[0] != spam {
+		t.Fatalf("expected status_7 event, got %v", got)
+	}
+
+	ai.result.StatusCode = 8
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 2, Data: "promo again"})
+	if m := c.Metrics(); m[models.StatusSuspicious] != 1 {
+		t.Fatalf("codes above MaxStatusCode must be recorded as suspicious: %v", m)
+	}
+}
+
+func TestExtendedStatusEventMapping(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: 7, Confidence: 0.9}}
+	c := New(Options{
+		AIAnalyzer:    ai,
+		Storage:       newMockStorage("promo"),
+		MaxStatusCode: 7,
+		StatusEvents:  map[models.StatusCode]EventName{7: "spam"},
+	})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	fired := 0
+	_ = c.On("spam", func(context.Context, ViolationEvent) error { fired++; return nil })
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "promo"})
+	if fired != 1 {
+		t.Fatalf("expected mapped event, fired=%d", fired)
+	}
+	if def := New(Options{}); len(def.Metrics()) != 6 {
+		t.Fatalf("default range must stay 1..6")
+	}
+}
+
+func TestProcessMiddleware(t *testing.T) {
+	var seen []string
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusClean, Confidence: 1}}
+	var calls atomic.Int32
+	counter := func(next ProcessFunc) ProcessFunc {
+		return func(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.Violation, error) {
+			calls.Add(1)
+			return next(ctx, messages, opt)
+		}
+	}
+	redact := func(next ProcessFunc) ProcessFunc {
+		return func(ctx context.Context, messages []models.Message, opt ProcessOptions) ([]models.Violation, error) {
+			scrubbed := make([]models.Message, len(messages))
+			for i, m := range messages {
+				m.Data = strings.ReplaceAll(m.Data, "+1-555-0100", "[phone]")
+				scrubbed[i] = m
+			}
+			return next(ctx, scrubbed, opt)
+		}
+	}
+	c := New(Options{
+		AIAnalyzer: ai,
+		Storage:    newMockStorage("call"),
+		Middleware: []ProcessMiddleware{counter, nil, redact},
+		ResultMiddleware: []ResultMiddleware{func(_ context.Context, m models.Message, r models.AIResult) models.AIResult {
+			seen = append(seen, m.Data)
+			return r
+		}},
+	})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "call +1-555-0100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Message.Data != "call [phone]" || len(seen) != 1 || seen[0] != "call [phone]" {
+		t.Fatalf("expected redacted data before analysis: %+v seen=%v", res.Message, seen)
+	}
+	_, _ = c.ProcessBatch(context.Background(), []models.Message{{ID: 2, User: 2, Data: "hi"}})
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 middleware invocations, got %d", calls.Load())
+	}
+}
+
+func TestProcessMiddlewareShortCircuit(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusClean}}
+	block := func(ProcessFunc) ProcessFunc {
+		return func(_ context.Context, messages []models.Message, _ ProcessOptions) ([]models.Violation, error) {
+			out := make([]models.Violation, len(messages))
+			for i, m := range messages {
+				out[i] = models.Violation{Message: m, AIResult: models.AIResult{StatusCode: models.StatusDangerousIllegal, Reason: "blocked tenant"}}
+			}
+			return out, nil
+		}
+	}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("x"), Middleware: []ProcessMiddleware{block}})
+	defer c.Close()
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, Data: "x"})
+	if err != nil || res.AIResult.Reason != "blocked tenant" || ai.callCount.Load() != 0 {
+		t.Fatalf("expected short circuit: %+v err=%v calls=%d", res, err, ai.callCount.Load())
+	}
+}
@@ -3,8 +3,15 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/elum-utils/censor/engine"
 	"github.com/elum-utils/censor/models"
 )
 
@@ -74,3 +81,686 @@ func TestDispatchAllStatusesAndNoopMethods(t *testing.T) {
 		t.Fatalf("not all callbacks were called")
 	}
 }
+
+func TestSyncOnceCancelledContext(t *testing.T) {
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: newMockStorage("bad")})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.SyncOnce(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if c.TokenCount() != 0 {
+		t.Fatalf("cancelled sync must not load tokens")
+	}
+}
+
+type fixedEngine struct {
+	replaced []string
+}
+
+func (f *fixedEngine) AddToken(string) bool         { return true }
+func (f *fixedEngine) RemoveToken(string) bool      { return true }
+func (f *fixedEngine) ReplaceAll(tokens []string)   { f.replaced = tokens }
+func (f *fixedEngine) FindTriggers(string) []string { return []string{"external"} }
+func (f *fixedEngine) Count() int                   { return len(f.replaced) }
+func (f *fixedEngine) Stats() engine.Stats          { return engine.Stats{} }
+
+func TestCustomEngineOption(t *testing.T) {
+	eng := &fixedEngine{}
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("a", "b"), Engine: eng})
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.TokenCount() != 2 {
+		t.Fatalf("expected tokens loaded into custom engine, got %d", c.TokenCount())
+	}
+	if words, phrases := c.TokenBreakdown(); words != 2 || phrases != 0 {
+		t.Fatalf("unexpected fallback breakdown: %d/%d", words, phrases)
+	}
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "anything"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Triggered || res.AIResult.TriggerTokens[0] != "external" || ai.callCount.Load() != 1 {
+		t.Fatalf("expected custom engine triggers to drive AI stage: %+v", res)
+	}
+}
+
+type statusStorage struct {
+	*mockStorage
+	statuses map[string]models.StatusCode
+}
+
+func (s *statusStorage) SetTokenStatus(_ context.Context, token string, status models.StatusCode) error {
+	s.statuses[token] = status
+	return nil
+}
+
+func (s *statusStorage) GetTokenStatuses(context.Context) (map[string]models.StatusCode, error) {
+	return s.statuses, nil
+}
+
+func TestTokenStatusResolvesWithoutAI(t *testing.T) {
+	st := &statusStorage{mockStorage: newMockStorage("scam.example", "bad"), statuses: map[string]models.StatusCode{
+		"scam.example": models.StatusCommercialOffPlatform,
+	}}
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusClean}}
+	c := New(Options{AIAnalyzer: ai, Storage: st})
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "scam.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AIResult.StatusCode != models.StatusCommercialOffPlatform || ai.callCount.Load() != 0 {
+		t.Fatalf("expected direct status without AI: %+v calls=%d", res.AIResult, ai.callCount.Load())
+	}
+
+	if err := c.SetTokenStatus(context.Background(), "BAD", models.StatusNonCriticalAbuse); err != nil {
+		t.Fatal(err)
+	}
+	if st.statuses["bad"] != models.StatusNonCriticalAbuse {
+		t.Fatalf("expected status persisted")
+	}
+	if err := c.SetTokenStatus(context.Background(), ` re:SCAM\d+ `, models.StatusSuspicious); err != nil || st.statuses[`re:SCAM\d+`] != models.StatusSuspicious {
+		t.Fatalf("status must be keyed by the normalized token, keeping regex case: %v err=%v", st.statuses, err)
+	}
+	if err := c.SetTokenStatus(context.Background(), "re:(", models.StatusSuspicious); err == nil {
+		t.Fatalf("invalid token must be rejected")
+	}
+	if err := c.SetTokenStatus(context.Background(), "bad", models.StatusCode(42)); err == nil || st.statuses["bad"] != models.StatusNonCriticalAbuse {
+		t.Fatalf("out-of-range status must be rejected before writing: err=%v", err)
+	}
+	res, _ = c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 2, Data: "bad"})
+	if res.AIResult.StatusCode != models.StatusNonCriticalAbuse || ai.callCount.Load() != 0 {
+		t.Fatalf("expected direct status for bad: %+v", res.AIResult)
+	}
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 3, User: 2, Data: "bad words"})
+	if ai.callCount.Load() != 1 {
+		t.Fatalf("non-exact match must still go to AI")
+	}
+
+	plain := New(Options{AIAnalyzer: ai, Storage: newMockStorage()})
+	if err := plain.SetTokenStatus(context.Background(), "x", models.StatusClean); err == nil {
+		t.Fatalf("expected unsupported storage error")
+	}
+}
+
+func TestSyncKeepsSpecialTokens(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage(`re:\+7\d{10}`, "wild:прода*"), DisableAutoLearn: true})
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "продаю, звони +79991234567"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Triggered || len(res.AIResult.TriggerTokens) != 2 {
+		t.Fatalf("expected both special tokens to trigger, got %+v", res)
+	}
+}
+
+func TestSyncRetryDelay(t *testing.T) {
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: errStorage{}, SyncInterval: time.Minute, SyncBackoffBase: 5 * time.Second})
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, w := range want {
+		if got := c.syncRetryDelay(i + 1); got != w {
+			t.Fatalf("failure %d: expected %s, got %s", i+1, w, got)
+		}
+	}
+}
+
+type flakyStorage struct {
+	errStorage
+	calls atomic.Int64
+}
+
+func (f *flakyStorage) GetTokens(context.Context) ([]string, error) {
+	if f.calls.Add(1) == 1 {
+		return []string{"bad"}, nil
+	}
+	return nil, errors.New("db down")
+}
+
+func TestRunBacksOffAfterSyncFailure(t *testing.T) {
+	st := &flakyStorage{}
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: st, SyncInterval: 40 * time.Millisecond, SyncBackoffBase: 5 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	_ = c.Run(ctx)
+	// Initial sync, first periodic failure at 40ms, then retries at +5, +10, +20,
+	// +40ms instead of only every 40ms.
+	if got := st.calls.Load(); got < 5 {
+		t.Fatalf("expected backoff retries between intervals, got %d sync calls", got)
+	}
+}
+
+func TestManualAddRemoveToken(t *testing.T) {
+	st := newMockStorage("keep")
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: st})
+	_ = c.SyncOnce(context.Background())
+
+	if err := c.AddToken(context.Background(), "  Продаю "); err != nil {
+		t.Fatal(err)
+	}
+	if !st.hasToken("продаю") || len(c.engine.FindTriggers("продаю")) != 1 {
+		t.Fatal("expected token in storage and memory")
+	}
+	if err := c.RemoveToken(context.Background(), "ПРОДАЮ"); err != nil {
+		t.Fatal(err)
+	}
+	if st.hasToken("продаю") || len(c.engine.FindTriggers("продаю")) != 0 {
+		t.Fatal("expected token removed from storage and memory")
+	}
+	if err := c.AddToken(context.Background(), " "); err == nil {
+		t.Fatal("expected empty token error")
+	}
+
+	failing := New(Options{AIAnalyzer: singleAI{}, Storage: errStorage{}})
+	if err := failing.AddToken(context.Background(), "x"); err == nil || failing.TokenCount() != 0 {
+		t.Fatalf("storage failure must leave memory unchanged, err=%v", err)
+	}
+}
+
+func TestManualTokensConcurrentWithSync(t *testing.T) {
+	st := newMockStorage("base")
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: st})
+	_ = c.SyncOnce(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = c.AddToken(context.Background(), fmt.Sprintf("tok%d", i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			_ = c.SyncOnce(context.Background())
+		}()
+	}
+	wg.Wait()
+	if got := c.TokenCount(); got != 51 {
+		t.Fatalf("memory diverged from storage: %d tokens", got)
+	}
+}
+
+type bulkStorage struct {
+	*mockStorage
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (b *bulkStorage) AddTokens(ctx context.Context, tokens []string) error {
+	b.mu.Lock()
+	b.calls = append(b.calls, append([]string(nil), tokens...))
+	b.mu.Unlock()
+	for _, token := range tokens {
+		_ = b.mockStorage.AddToken(ctx, token)
+	}
+	return nil
+}
+
+func (b *bulkStorage) AddToken(context.Context, string) error {
+	return errors.New("per-token add must not be used")
+}
+
+func TestLearnUsesSingleBulkCall(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.9, TriggerTokens: []string{"Spam", "spam", "bad", "other"}}}
+	st := &bulkStorage{mockStorage: newMockStorage("bad")}
+	c := New(Options{AIAnalyzer: ai, Storage: st, ConfidenceThreshold: 0.7, AutoLearn: true, SyncLearn: true})
+	_ = c.SyncOnce(context.Background())
+	_, _ = c.ProcessBatch(context.Background(), []models.Message{{ID: 1, User: 2, Data: "bad"}})
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.calls) != 1 || len(st.calls[0]) != 2 || st.calls[0][0] != "spam" || st.calls[0][1] != "other" {
+		t.Fatalf("expected one deduplicated bulk call, got %v", st.calls)
+	}
+	if !st.hasToken("spam") || !st.hasToken("other") {
+		t.Fatalf("expected learned tokens persisted")
+	}
+}
+
+type bulkMetaStorage struct {
+	*metaStorage
+	calls [][]models.Token
+}
+
+func (b *bulkMetaStorage) AddTokensMeta(ctx context.Context, tokens []models.Token) error {
+	b.calls = append(b.calls, append([]models.Token(nil), tokens...))
+	for _, token := range tokens {
+		_ = b.metaStorage.AddTokenMeta(ctx, token)
+	}
+	return nil
+}
+
+func (b *bulkMetaStorage) AddTokenMeta(context.Context, models.Token) error {
+	return errors.New("per-token meta add must not be used")
+}
+
+func TestLearnUsesSingleBulkMetaCall(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.9, TriggerTokens: []string{"spam", "other"}}}
+	st := &bulkMetaStorage{metaStorage: &metaStorage{mockStorage: newMockStorage("bad"), meta: map[string]models.Token{}}}
+	c := New(Options{AIAnalyzer: ai, Storage: st, AutoLearn: true, SyncLearn: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	_, _ = c.ProcessBatch(context.Background(), []models.Message{{ID: 1, User: 2, Data: "bad"}})
+	if len(st.calls) != 1 || len(st.calls[0]) != 2 || st.calls[0][0].Source != models.TokenSourceAuto {
+		t.Fatalf("expected one bulk meta call with auto source, got %+v", st.calls)
+	}
+	if st.meta["spam"].AddedAt.IsZero() || !st.hasToken("other") {
+		t.Fatalf("expected learned tokens persisted with metadata: %+v", st.meta)
+	}
+}
+
+func TestCloseStopsBackgroundWorkers(t *testing.T) {
+	before := runtime.NumGoroutine()
+	cores := make([]*Core, 0, 20)
+	for i := 0; i < 20; i++ {
+		cores = append(cores, New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage(), CacheRefreshAhead: time.Minute}))
+	}
+	if runtime.NumGoroutine() < before+40 {
+		t.Fatalf("expected janitor and refresher per core: before=%d now=%d", before, runtime.NumGoroutine())
+	}
+	for _, c := range cores {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatalf("second close: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("goroutines leaked: before=%d after=%d", before, n)
+	}
+}
+
+func TestEngineStatsThroughCore(t *testing.T) {
+	c := New(Options{AIAnalyzer: &mockAI{result: models.AIResult{StatusCode: models.StatusClean}}, Storage: newMockStorage("bad")})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad"})
+	if st := c.EngineStats(); st.TotalLookups != 1 || st.TotalTokenHits != 1 {
+		t.Fatalf("unexpected engine stats: %+v", st)
+	}
+	c.ResetEngineStats()
+	if st := c.EngineStats(); st.TotalLookups != 0 || st.TokenCount != 1 {
+		t.Fatalf("unexpected stats after reset: %+v", st)
+	}
+}
+
+// slowAI blocks on messages containing "slow" until the context ends.
+type slowAI struct{}
+
+func (slowAI) Name() string { return "slow" }
+
+func (a slowAI) Analyze(ctx context.Context, m models.Message) (models.AIResult, error) {
+	res, err := a.AnalyzeBatch(ctx, []models.Message{m})
+	if err != nil {
+		return models.AIResult{}, err
+	}
+	return res[0], nil
+}
+
+func (slowAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	out := make([]models.AIResult, 0, len(msgs))
+	for _, m := range msgs {
+		if strings.Contains(m.Data, "slow") {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		out = append(out, models.AIResult{StatusCode: models.StatusNonCriticalAbuse, Confidence: 0.9, MessageID: m.ID})
+	}
+	return out, nil
+}
+
+func TestPerMessageTimeoutPartialResults(t *testing.T) {
+	c := New(Options{AIAnalyzer: slowAI{}, Storage: newMockStorage("bad"), MaxAIBatchChars: 8, ZeroConfidenceReview: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	msgs := []models.Message{{ID: 1, User: 1, Data: "bad one"}, {ID: 2, User: 2, Data: "bad slow"}, {ID: 3, User: 3, Data: "bad two"}}
+	start := time.Now()
+	res, err := c.ProcessBatchWithOptions(context.Background(), msgs, ProcessOptions{PerMessageTimeout: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("batch must not wait beyond the per-message timeout")
+	}
+	if res[0].AIResult.StatusCode != models.StatusNonCriticalAbuse || res[2].AIResult.StatusCode != models.StatusNonCriticalAbuse {
+		t.Fatalf("unaffected messages must keep AI verdicts: %+v", res)
+	}
+	if res[1].AIResult.StatusCode != models.StatusSuspicious || res[1].AIResult.Reason != "ai timeout" {
+		t.Fatalf("timed out message must be suspicious: %+v", res[1].AIResult)
+	}
+	if _, ok := c.PeekCache("bad slow"); ok {
+		t.Fatalf("timeout placeholder must not be cached")
+	}
+}
+
+func TestPerMessageTimeoutFailFast(t *testing.T) {
+	c := New(Options{AIAnalyzer: slowAI{}, Storage: newMockStorage("bad")})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	_, err := c.ProcessBatchWithOptions(context.Background(), []models.Message{{ID: 1, Data: "bad slow"}}, ProcessOptions{PerMessageTimeout: 10 * time.Millisecond, FailFast: true})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
+
+type metaStorage struct {
+	*mockStorage
+	meta map[string]models.Token
+}
+
+func (m *metaStorage) AddTokenMeta(ctx context.Context, token models.Token) error {
+	m.meta[token.Value] = token
+	return m.AddToken(ctx, token.Value)
+}
+
+func (m *metaStorage) GetTokensMeta(ctx context.Context) ([]models.Token, error) {
+	tokens, _ := m.GetTokens(ctx)
+	out := make([]models.Token, 0, len(tokens))
+	for _, token := range tokens {
+		meta, ok := m.meta[token]
+		if !ok {
+			meta = models.Token{Value: token}
+		}
+		out = append(out, meta)
+	}
+	return out, nil
+}
+
+func TestTokenMetaSyncAndAdd(t *testing.T) {
+	st := &metaStorage{mockStorage: newMockStorage("plain"), meta: map[string]models.Token{}}
+	st.meta["casino"] = models.Token{Value: "casino", Category: "gambling", Severity: 2}
+	st.tokens["casino"] = struct{}{}
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st})
+	defer c.Close()
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	e := c.engine.(*engine.Engine)
+	if got := e.FindTriggersMeta("casino"); len(got) != 1 || got[0].Category != "gambling" {
+		t.Fatalf("sync must load metadata: %+v", got)
+	}
+
+	if err := c.AddTokenMeta(context.Background(), models.Token{Value: " Poker ", Severity: 5}); err != nil {
+		t.Fatal(err)
+	}
+	stored := st.meta["poker"]
+	if stored.Source != models.TokenSourceManual || stored.AddedAt.IsZero() || !st.hasToken("poker") {
+		t.Fatalf("unexpected stored token: %+v", stored)
+	}
+	if got := e.FindTriggersMeta("poker"); len(got) != 1 || got[0].Severity != 5 {
+		t.Fatalf("engine must see new metadata: %+v", got)
+	}
+
+	plain := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage()})
+	defer plain.Close()
+	if err := plain.AddTokenMeta(context.Background(), models.Token{Value: "x"}); err == nil {
+		t.Fatalf("expected unsupported error")
+	}
+}
+
+func (m *metaStorage) RemoveToken(ctx context.Context, token string) error {
+	delete(m.meta, token)
+	return m.mockStorage.RemoveToken(ctx, token)
+}
+
+func TestPurgeLearnedTokens(t *testing.T) {
+	now := time.Now()
+	st := &metaStorage{mockStorage: newMockStorage(), meta: map[string]models.Token{}}
+	_ = st.AddTokenMeta(context.Background(), models.Token{Value: "bad", Source: models.TokenSourceManual, AddedAt: now.Add(-72 * time.Hour)})
+	_ = st.AddTokenMeta(context.Background(), models.Token{Value: "oldauto", Source: models.TokenSourceAuto, AddedAt: now.Add(-48 * time.Hour)})
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.95, TriggerTokens: []string{"fresh"}}}
+	c := New(Options{AIAnalyzer: ai, Storage: st, AutoLearn: true, SyncLearn: true})
+	defer c.Close()
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad offer"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := st.meta["fresh"]; got.Source != models.TokenSourceAuto || got.AddedAt.IsZero() {
+		t.Fatalf("learned token must carry auto source: %+v", got)
+	}
+
+	n, err := c.PurgeLearnedTokens(context.Background(), now.Add(-24*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 purged, got %d err=%v", n, err)
+	}
+	if st.hasToken("oldauto") || len(c.engine.FindTriggers("oldauto")) != 0 {
+		t.Fatalf("old learned token must be removed from storage and engine")
+	}
+	if !st.hasToken("fresh") || !st.hasToken("bad") {
+		t.Fatalf("newer learned and manual tokens must stay")
+	}
+
+	if n, err = c.PurgeLearnedTokens(context.Background(), time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("expected fresh token purged, got %d err=%v", n, err)
+	}
+	if !st.hasToken("bad") || len(c.engine.FindTriggers("bad")) != 1 {
+		t.Fatalf("manual token must never be purged")
+	}
+
+	plain := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage()})
+	defer plain.Close()
+	if _, err := plain.PurgeLearnedTokens(context.Background(), now); err == nil {
+		t.Fatalf("expected unsupported error")
+	}
+}
+
+// streamOnlyStorage fails GetTokens so tests prove SyncOnce streams.
+type streamOnlyStorage struct {
+	*mockStorage
+	streamed int
+}
+
+func (s *streamOnlyStorage) GetTokens(context.Context) ([]string, error) {
+	return nil, errors.New("GetTokens must not be used")
+}
+
+func (s *streamOnlyStorage) GetTokensFunc(ctx context.Context, fn func(token string) error) error {
+	tokens, _ := s.mockStorage.GetTokens(ctx)
+	for _, token := range tokens {
+		s.streamed++
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSyncOnceStreamsTokens(t *testing.T) {
+	st := &streamOnlyStorage{mockStorage: newMockStorage("bad", "worse")}
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st})
+	defer c.Close()
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st.streamed != 2 || c.engine.Count() != 2 {
+		t.Fatalf("expected streamed sync, streamed=%d count=%d", st.streamed, c.engine.Count())
+	}
+}
+
+// streamMetaStorage fails the buffered reads so tests prove SyncOnce streams
+// tokens and metadata in one pass.
+type streamMetaStorage struct {
+	*metaStorage
+	streamed int
+}
+
+func (s *streamMetaStorage) GetTokens(context.Context) ([]string, error) {
+	return nil, errors.New("GetTokens must not be used")
+}
+
+func (s *streamMetaStorage) GetTokensMeta(context.Context) ([]models.Token, error) {
+	return nil, errors.New("GetTokensMeta must not be used")
+}
+
+func (s *streamMetaStorage) GetTokensMetaFunc(ctx context.Context, fn func(token models.Token) error) error {
+	tokens, _ := s.metaStorage.GetTokensMeta(ctx)
+	for _, token := range tokens {
+		s.streamed++
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSyncOnceStreamsTokenMeta(t *testing.T) {
+	st := &streamMetaStorage{metaStorage: &metaStorage{mockStorage: newMockStorage("plain"), meta: map[string]models.Token{}}}
+	_ = st.AddTokenMeta(context.Background(), models.Token{Value: "casino", Category: "gambling"})
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st})
+	defer c.Close()
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st.streamed != 2 || c.engine.Count() != 2 {
+		t.Fatalf("expected one streamed pass, streamed=%d count=%d", st.streamed, c.engine.Count())
+	}
+	e := c.engine.(*engine.Engine)
+	if got := e.FindTriggersMeta("casino"); len(got) != 1 || got[0].Category != "gambling" {
+		t.Fatalf("streamed sync must load metadata: %+v", got)
+	}
+}
+
+func TestOnAIErrorHook(t *testing.T) {
+	aiErr := errors.New("provider down")
+	var (
+		got    []models.Message
+		gotErr error
+	)
+	c := New(Options{
+		AIAnalyzer:       &mockAI{err: aiErr},
+		Storage:          newMockStorage("bad"),
+		DisableAutoLearn: true,
+		OnAIError: func(_ context.Context, messages []models.Message, err error) {
+			got = append(got, messages...)
+			gotErr = err
+			panic("hook bug")
+		},
+	})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	msgs := []models.Message{{ID: 1, User: 1, Data: "bad offer"}, {ID: 2, User: 2, Data: "hello"}}
+	if _, err := c.ProcessBatch(context.Background(), msgs); !errors.Is(err, aiErr) {
+		t.Fatalf("expected analyzer error to propagate, got %v", err)
+	}
+	if !errors.Is(gotErr, aiErr) || len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("hook got messages %+v, err %v", got, gotErr)
+	}
+}
+
+func TestAddTokenUsesEngineNormalizer(t *testing.T) {
+	st := newMockStorage()
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st, Engine: engine.NewWithOptions(engine.Options{Normalizer: strings.TrimSpace})})
+	if err := c.AddToken(context.Background(), " SKU42A "); err != nil {
+		t.Fatal(err)
+	}
+	if !st.hasToken("SKU42A") || st.hasToken("sku42a") {
+		t.Fatal("stored token must keep its case")
+	}
+	if got := c.engine.FindTriggers("order SKU42A"); len(got) != 1 || got[0] != "SKU42A" {
+		t.Fatalf("unexpected triggers: %v", got)
+	}
+}
+
+// droppingAI answers every message but drop.
+type droppingAI struct {
+	mockAI
+	drop int64
+}
+
+func (d *droppingAI) AnalyzeBatch(_ context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	out := make([]models.AIResult, 0, len(msgs))
+	for _, m := range msgs {
+		if m.ID != d.drop {
+			out = append(out, models.AIResult{MessageID: m.ID, StatusCode: models.StatusSuspicious, Confidence: 0.9})
+		}
+	}
+	return out, nil
+}
+
+func TestOnMissingAIResult(t *testing.T) {
+	batch := []models.Message{{ID: 1, User: 1, Data: "bad one"}, {ID: 2, User: 2, Data: "bad two"}, {ID: 3, User: 3, Data: "bad three"}}
+	run := func(policy MissingResultPolicy, zeroConfReview bool) ([]models.Violation, *countCallbacks, error) {
+		cb := &countCallbacks{}
+		c := New(Options{AIAnalyzer: &droppingAI{drop: 2}, Storage: newMockStorage("bad"), CallbackHandler: cb, OnMissingAIResult: policy, ZeroConfidenceReview: zeroConfReview, DisableAutoLearn: true})
+		_ = c.SyncOnce(context.Background())
+		out, err := c.ProcessBatch(context.Background(), batch)
+		return out, cb, err
+	}
+
+	out, _, err := run(MissingResultSuspicious, false)
+	if err != nil || out[1].AIResult.StatusCode != models.StatusHumanReview || out[1].AIResult.Reason != "missing AI result" {
+		t.Fatalf("suspicious mode: %+v err=%v", out, err)
+	}
+	if out[0].AIResult.StatusCode != models.StatusSuspicious || out[2].AIResult.StatusCode != models.StatusSuspicious {
+		t.Fatalf("answered messages changed: %+v", out)
+	}
+
+	out, _, err = run(MissingResultClean, false)
+	if err != nil || out[1].AIResult.StatusCode != models.StatusClean || out[1].AIResult.Confidence != 0 {
+		t.Fatalf("clean mode: %+v err=%v", out, err)
+	}
+	out, _, err = run(MissingResultClean, true)
+	if err != nil || out[1].AIResult.StatusCode != models.StatusClean {
+		t.Fatalf("zero-confidence review must leave the clean placeholder: %+v err=%v", out, err)
+	}
+
+	out, cb, err := run(MissingResultError, false)
+	var me *MissingAIResultError
+	if !errors.As(err, &me) || len(me.MessageIDs) != 1 || me.MessageIDs[0] != 2 || out != nil {
+		t.Fatalf("error mode: out=%+v err=%v", out, err)
+	}
+	if n := cb.clean.Load() + cb.suspicious.Load() + cb.commercial.Load(); n != 0 {
+		t.Fatalf("error mode must not record AI decisions, got %d callbacks", n)
+	}
+}
+
+func TestMinTriggerScore(t *testing.T) {
+	eng := engine.New()
+	eng.SetTokenMeta(models.Token{Value: "drugs", Severity: 5})
+	eng.SetTokenMeta(models.Token{Value: "damn", Severity: 1})
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("drugs", "damn", "heck", "darn"), Engine: eng, MinTriggerScore: 3, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "damn it"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Triggered || res.AIResult.StatusCode != models.StatusClean || res.AIResult.Reason != "low trigger score" || ai.callCount.Load() != 0 {
+		t.Fatalf("low-score message must skip AI: %+v calls=%d", res, ai.callCount.Load())
+	}
+
+	res, err = c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 1, Data: "selling drugs"})
+	if err != nil || res.AIResult.StatusCode != models.StatusSuspicious || ai.callCount.Load() != 1 {
+		t.Fatalf("high-severity token must reach AI: %+v calls=%d err=%v", res, ai.callCount.Load(), err)
+	}
+
+	// Untagged tokens count 1 each: damn + heck + darn = 3.
+	res, err = c.ProcessMessage(context.Background(), models.Message{ID: 3, User: 1, Data: "damn heck darn"})
+	if err != nil || res.AIResult.StatusCode != models.StatusSuspicious || ai.callCount.Load() != 2 {
+		t.Fatalf("summed score must reach AI: %+v calls=%d err=%v", res, ai.callCount.Load(), err)
+	}
+
+	c = New(Options{AIAnalyzer: ai, Storage: newMockStorage("damn"), Engine: eng, MinTriggerScore: 3, LowScoreStatus: models.StatusNonCriticalAbuse, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	res, _ = c.ProcessMessage(context.Background(), models.Message{ID: 4, User: 1, Data: "damn"})
+	if res.AIResult.StatusCode != models.StatusNonCriticalAbuse {
+		t.Fatalf("expected configured low-score status, got %+v", res.AIResult)
+	}
+}
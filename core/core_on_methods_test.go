@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/elum-utils/censor/models"
@@ -40,3 +41,53 @@ func TestOnConvenienceMethods(t *testing.T) {
 		c.record(models.Violation{Message: models.Message{ID: int64(code), User: 1}, AIResult: models.AIResult{StatusCode: code, ViolatorUserID: 1}})
 	}
 }
+
+func TestTokenBreakdown(t *testing.T) {
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: newMockStorage("bad", "buy now")})
+	_ = c.SyncOnce(context.Background())
+	if words, phrases := c.TokenBreakdown(); words != 1 || phrases != 1 {
+		t.Fatalf("unexpected breakdown: words=%d phrases=%d", words, phrases)
+	}
+}
+
+func TestOffRemovesOnlyThatHandler(t *testing.T) {
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: newMockStorage()})
+	defer c.Close()
+	var first, second int
+	id1, err := c.Subscribe(EventMarkAbuse, func(context.Context, ViolationEvent) error { first++; return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, _ := c.Subscribe(EventMarkAbuse, func(context.Context, ViolationEvent) error { second++; return nil })
+	if id1 == id2 {
+		t.Fatalf("handler ids must be unique")
+	}
+	if !c.Off(EventMarkAbuse, id1) {
+		t.Fatalf("expected handler removed")
+	}
+	if c.Off(EventMarkAbuse, id1) || c.Off(EventAllowClean, id2) {
+		t.Fatalf("unknown handler must not be reported removed")
+	}
+	c.record(models.Violation{AIResult: models.AIResult{StatusCode: models.StatusNonCriticalAbuse}})
+	if first != 0 || second != 1 {
+		t.Fatalf("only the remaining handler must fire: first=%d second=%d", first, second)
+	}
+}
+
+func TestOffDuringDispatch(t *testing.T) {
+	c := New(Options{AIAnalyzer: singleAI{}, Storage: newMockStorage()})
+	defer c.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				id, _ := c.Subscribe(EventAllowClean, func(context.Context, ViolationEvent) error { return nil })
+				c.record(models.Violation{AIResult: models.AIResult{StatusCode: models.StatusClean}})
+				c.Off(EventAllowClean, id)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -500,3 +500,159 @@ func TestViolationEventCacheHitFlag(t *testing.T) {
 		t.Fatalf("expected current message/user in second event, got %+v", secondEvent)
 	}
 }
+
+func TestPeekCache(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Reason: "promo", Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("buy")})
+	_ = c.SyncOnce(context.Background())
+
+	if _, ok := c.PeekCache("buy now"); ok {
+		t.Fatalf("expected empty cache")
+	}
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "buy now"})
+	got, ok := c.PeekCache("buy now")
+	if !ok || got.StatusCode != models.StatusCommercialOffPlatform || got.Reason != "promo" {
+		t.Fatalf("unexpected cached verdict: %+v ok=%v", got, ok)
+	}
+}
+
+func TestNoTriggerConfidence(t *testing.T) {
+	ai := &mockAI{}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad")})
+	_ = c.SyncOnce(context.Background())
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "hello"})
+	if err != nil || res.AIResult.Confidence != 1 {
+		t.Fatalf("expected default confidence 1, got %+v err=%v", res.AIResult, err)
+	}
+
+	c = New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), NoTriggerConfidence: 0.5})
+	_ = c.SyncOnce(context.Background())
+	res, err = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "hello"})
+	if err != nil || res.AIResult.Confidence != 0.5 {
+		t.Fatalf("expected configured confidence 0.5, got %+v err=%v", res.AIResult, err)
+	}
+}
+
+func TestConfiguredReasons(t *testing.T) {
+	ai := singleAI{res: models.AIResult{MessageID: 99, StatusCode: models.StatusClean}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), Reasons: Reasons{NoTrigger: "нет триггеров"}})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessBatch(context.Background(), []models.Message{
+		{ID: 1, User: 2, Data: "hello"},
+		{ID: 2, User: 2, Data: "bad word"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].AIResult.Reason != "нет триггеров" {
+		t.Fatalf("expected configured no-trigger reason, got %q", res[0].AIResult.Reason)
+	}
+	if res[1].AIResult.Reason != "missing AI result" {
+		t.Fatalf("expected default missing-result reason, got %q", res[1].AIResult.Reason)
+	}
+}
+
+func TestZeroConfidenceNotCached(t *testing.T) {
+	ai := singleAI{res: models.AIResult{MessageID: 99, StatusCode: models.StatusClean}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad")})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "bad word"})
+	if err != nil || res.AIResult.Reason != "missing AI result" {
+		t.Fatalf("expected missing result, got %+v err=%v", res, err)
+	}
+	if _, ok := c.PeekCache("bad word"); ok {
+		t.Fatal("missing AI result must not be cached")
+	}
+}
+
+func TestZeroConfidenceReview(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, TriggerTokens: []string{"new"}}}
+	st := newMockStorage("bad")
+	c := New(Options{AIAnalyzer: ai, Storage: st, ZeroConfidenceReview: true})
+	_ = c.SyncOnce(context.Background())
+
+	res, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "bad word"})
+	if err != nil || res.AIResult.StatusCode != models.StatusHumanReview {
+		t.Fatalf("expected zero-confidence verdict routed to review, got %+v err=%v", res, err)
+	}
+	if _, ok := c.PeekCache("bad word"); ok {
+		t.Fatal("zero-confidence verdict must not be cached")
+	}
+}
+
+func TestThresholdByStatusGatesLearn(t *testing.T) {
+	st := newMockStorage()
+	c := New(Options{
+		AIAnalyzer:          &mockAI{},
+		Storage:             st,
+		ConfidenceThreshold: 0.85,
+		ThresholdByStatus: map[models.StatusCode]float64{
+			models.StatusCommercialOffPlatform: 0.75,
+			models.StatusDangerousIllegal:      0.9,
+		},
+		AutoLearn: true,
+		SyncLearn: true,
+	})
+	c.learn(models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.8, TriggerTokens: []string{"card"}})
+	c.learn(models.AIResult{StatusCode: models.StatusDangerousIllegal, Confidence: 0.8, TriggerTokens: []string{"drugs"}})
+	c.learn(models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.8, TriggerTokens: []string{"maybe"}})
+	if !st.hasToken("card") {
+		t.Fatalf("level 5 at 0.8 must learn under its 0.75 threshold")
+	}
+	if st.hasToken("drugs") {
+		t.Fatalf("level 6 at 0.8 must not learn under its 0.9 threshold")
+	}
+	if st.hasToken("maybe") {
+		t.Fatalf("unlisted code must fall back to the global threshold")
+	}
+	if c.ThresholdFor(models.StatusSuspicious) != 0.85 || !c.Confident(models.AIResult{StatusCode: models.StatusDangerousIllegal, Confidence: 0.95}) {
+		t.Fatalf("unexpected threshold resolution")
+	}
+}
+
+func TestCalibrateConfidencePreventsLearning(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9, TriggerTokens: []string{"fresh"}}}
+	st := newMockStorage("bad")
+	c := New(Options{
+		AIAnalyzer:          ai,
+		Storage:             st,
+		ConfidenceThreshold: 0.7,
+		AutoLearn:           true,
+		SyncLearn:           true,
+		CalibrateConfidence: func(r models.AIResult) float64 {
+			if r.StatusCode == models.StatusSuspicious {
+				return r.Confidence / 2
+			}
+			return r.Confidence
+		},
+	})
+	_ = c.SyncOnce(context.Background())
+	v, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.AIResult.Confidence != 0.45 {
+		t.Fatalf("expected calibrated confidence 0.45, got %v", v.AIResult.Confidence)
+	}
+	if st.hasToken("fresh") {
+		t.Fatal("calibrated confidence must keep the token from being learned")
+	}
+	cached, err := c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 2, Data: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.AIResult.Confidence != 0.45 || ai.callCount.Load() != 1 {
+		t.Fatalf("expected calibrated cache hit, got %+v after %d calls", cached.AIResult, ai.callCount.Load())
+	}
+
+	c = New(Options{AIAnalyzer: ai, Storage: st, ConfidenceThreshold: 0.7, AutoLearn: true, SyncLearn: true})
+	_ = c.SyncOnce(context.Background())
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "bad"}); err != nil {
+		t.Fatal(err)
+	}
+	if !st.hasToken("fresh") {
+		t.Fatal("without calibration the token must be learned")
+	}
+}
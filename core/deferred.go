@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// deferFailed enqueues messages whose analysis failed with err and reports
+// whether the batch can resolve them as deferred. Cancellations and shadow
+// runs are not deferred.
+func (c *Core) deferFailed(ctx context.Context, messages []models.Message, err error, opt ProcessOptions) bool {
+	if c.deferStore == nil || opt.ShadowMode || ctx.Err() != nil {
+		return false
+	}
+	if enqErr := c.deferStore.Enqueue(ctx, messages); enqErr != nil {
+		c.logWarn("defer enqueue failed", map[string]any{"error": enqErr.Error(), "ai_error": err.Error(), "messages": len(messages)})
+		return false
+	}
+	c.counters.deferred.Add(int64(len(messages)))
+	c.logWarn("ai failed, messages deferred", map[string]any{"error": err.Error(), "messages": len(messages)})
+	return true
+}
+
+// ProcessDeferred drains the defer store through the pipeline and returns how
+// many messages got a real decision. Replayed messages are not added to dialog
+// context again. Messages whose analysis fails again are
+// deferred again. If the batch fails outright, the drained messages are put
+// back and the error is returned.
+func (c *Core) ProcessDeferred(ctx context.Context) (int, error) {
+	if c.deferStore == nil {
+		return 0, errors.New("core: defer store is nil")
+	}
+	messages, err := c.deferStore.Drain(ctx)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+	out, err := c.ProcessBatchWithOptions(ctx, messages, ProcessOptions{replay: true})
+	if err != nil {
+		if enqErr := c.deferStore.Enqueue(context.WithoutCancel(ctx), messages); enqErr != nil {
+			c.logWarn("defer requeue failed", map[string]any{"error": enqErr.Error(), "messages": len(messages)})
+		}
+		return 0, err
+	}
+	done := 0
+	for _, v := range out {
+		if !v.Deferred {
+			done++
+		}
+	}
+	return done, nil
+}
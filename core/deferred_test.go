@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/elum-utils/censor/models"
+)
+
+type memDeferStore struct {
+	mu       sync.Mutex
+	messages []models.Message
+}
+
+func (s *memDeferStore) Enqueue(_ context.Context, messages []models.Message) error {
+	s.mu.Lock()
+	s.messages = append(s.messages, messages...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memDeferStore) Drain(context.Context) ([]models.Message, error) {
+	s.mu.Lock()
+	out := s.messages
+	s.messages = nil
+	s.mu.Unlock()
+	return out, nil
+}
+
+func TestDeferOnAIError(t *testing.T) {
+	ctx := context.Background()
+	ai := &mockAI{err: errors.New("provider down"), result: models.AIResult{StatusCode: models.StatusCritical, Confidence: 0.9}}
+	ds := &memDeferStore{}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DisableAutoLearn: true, DeferStore: ds})
+	defer c.Close()
+	_ = c.SyncOnce(ctx)
+
+	msgs := []models.Message{{ID: 1, User: 1, Data: "bad offer"}, {ID: 2, User: 2, Data: "hello"}, {ID: 3, User: 3, Data: "bad offer"}}
+	out, err := c.ProcessBatch(ctx, msgs)
+	if err != nil {
+		t.Fatalf("AI error must be deferred, got %v", err)
+	}
+	for _, i := range []int{0, 2} {
+		v := out[i]
+		if !v.Deferred || v.AIResult.StatusCode != models.StatusSuspicious || v.AIResult.Reason != "deferred" || v.AIResult.MessageID != msgs[i].ID {
+			t.Fatalf("unexpected deferred result %+v", v)
+		}
+	}
+	if out[1].Deferred || out[1].AIResult.StatusCode != models.StatusClean {
+		t.Fatalf("untriggered message must not be deferred: %+v", out[1])
+	}
+	if len(ds.messages) != 2 || ds.messages[0].ID != 1 || ds.messages[1].ID != 3 {
+		t.Fatalf("expected both triggered messages queued, got %+v", ds.messages)
+	}
+	if got := c.RuntimeStats().Deferred; got != 2 {
+		t.Fatalf("Deferred = %d, want 2", got)
+	}
+
+	// Still down: messages go back to the queue.
+	if n, err := c.ProcessDeferred(ctx); err != nil || n != 0 || len(ds.messages) != 2 {
+		t.Fatalf("ProcessDeferred while down = %d, %v, queued %d", n, err, len(ds.messages))
+	}
+
+	ai.err = nil
+	n, err := c.ProcessDeferred(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("ProcessDeferred = %d, %v", n, err)
+	}
+	if len(ds.messages) != 0 {
+		t.Fatalf("queue must be empty, got %+v", ds.messages)
+	}
+	if n, err := c.ProcessDeferred(ctx); err != nil || n != 0 {
+		t.Fatalf("empty queue ProcessDeferred = %d, %v", n, err)
+	}
+}
+
+func TestProcessDeferredKeepsDialogContext(t *testing.T) {
+	ctx := context.Background()
+	ai := &dialogRecordingAI{mockAI: mockAI{err: errors.New("provider down"), result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}}
+	ds := &memDeferStore{}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DialogContextSize: 4, DisableAutoLearn: true, DeferStore: ds})
+	defer c.Close()
+	_ = c.SyncOnce(ctx)
+
+	if v, err := c.ProcessMessage(ctx, models.Message{ID: 1, DialogID: "d", User: 1, Data: "bad offer"}); err != nil || !v.Deferred {
+		t.Fatalf("expected deferred decision, got %+v, %v", v, err)
+	}
+	ai.err = nil
+	if n, err := c.ProcessDeferred(ctx); err != nil || n != 1 {
+		t.Fatalf("ProcessDeferred = %d, %v", n, err)
+	}
+	if _, err := c.ProcessMessage(ctx, models.Message{ID: 2, DialogID: "d", User: 2, Data: "bad reply"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ContextMessage{{User: 1, Data: "bad offer"}}
+	if got := ai.contextOf(2); !reflect.DeepEqual(got, want) {
+		t.Fatalf("context after replay = %+v, want %+v", got, want)
+	}
+}
+
+func TestProcessDeferredWithoutStore(t *testing.T) {
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage()})
+	defer c.Close()
+	if _, err := c.ProcessDeferred(context.Background()); err == nil {
+		t.Fatal("expected error without a defer store")
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+const (
+	defaultDialogContextTTL = 30 * time.Minute
+	defaultDialogContexts   = 10000
+)
+
+// dialogContexts keeps the latest turns of recently active dialogs, bounded
+// in turns per dialog and in dialogs.
+type dialogContexts struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	max     int
+	dialogs map[string]*dialogTurns
+	// lru orders dialogs by last update, most recent at the front.
+	lru *list.List
+}
+
+type dialogTurns struct {
+	id string
+	// turns holds up to size turns, oldest first.
+	turns   []models.ContextMessage
+	updated time.Time
+	elem    *list.Element
+}
+
+func newDialogContexts(size int, ttl time.Duration, max int) *dialogContexts {
+	if size <= 0 {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = defaultDialogContextTTL
+	}
+	if max <= 0 {
+		max = defaultDialogContexts
+	}
+	return &dialogContexts{size: size, ttl: ttl, max: max, dialogs: make(map[string]*dialogTurns), lru: list.New()}
+}
+
+// observe returns a copy of the dialog's turns before msg and, when push is
+// set, appends msg to them.
+func (d *dialogContexts) observe(msg models.Message, push bool, now time.Time) []models.ContextMessage {
+	if d == nil || msg.DialogID == "" {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dt, ok := d.dialogs[msg.DialogID]
+	if ok && now.Sub(dt.updated) >= d.ttl {
+		d.removeLocked(dt)
+		dt, ok = nil, false
+	}
+	var prior []models.ContextMessage
+	if ok && len(dt.turns) > 0 {
+		prior = append([]models.ContextMessage(nil), dt.turns...)
+	}
+	if !push {
+		return prior
+	}
+	if !ok {
+		if len(d.dialogs) >= d.max {
+			d.evictLocked(now)
+		}
+		dt = &dialogTurns{id: msg.DialogID, turns: make([]models.ContextMessage, 0, d.size)}
+		dt.elem = d.lru.PushFront(dt)
+		d.dialogs[msg.DialogID] = dt
+	}
+	turn := models.ContextMessage{User: msg.User, Data: msg.Data}
+	if len(dt.turns) < d.size {
+		dt.turns = append(dt.turns, turn)
+	} else {
+		copy(dt.turns, dt.turns[1:])
+		dt.turns[d.size-1] = turn
+	}
+	dt.updated = now
+	d.lru.MoveToFront(dt.elem)
+	return prior
+}
+
+// evictLocked drops expired dialogs from the back of the LRU list, then the
+// least recently updated one if still at capacity.
+func (d *dialogContexts) evictLocked(now time.Time) {
+	for elem := d.lru.Back(); elem != nil; elem = d.lru.Back() {
+		dt := elem.Value.(*dialogTurns)
+		if now.Sub(dt.updated) < d.ttl && len(d.dialogs) < d.max {
+			return
+		}
+		d.removeLocked(dt)
+		if now.Sub(dt.updated) < d.ttl {
+			return
+		}
+	}
+}
+
+func (d *dialogContexts) removeLocked(dt *dialogTurns) {
+	d.lru.Remove(dt.elem)
+	delete(d.dialogs, dt.id)
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+type dialogRecordingAI struct {
+	mockAI
+	mu       sync.Mutex
+	contexts map[int64][]models.ContextMessage
+}
+
+func (r *dialogRecordingAI) AnalyzeBatch(ctx context.Context, msgs []models.Message) ([]models.AIResult, error) {
+	r.mu.Lock()
+	if r.contexts == nil {
+		r.contexts = make(map[int64][]models.ContextMessage)
+	}
+	for _, msg := range msgs {
+		r.contexts[msg.ID] = msg.Context
+	}
+	r.mu.Unlock()
+	return r.mockAI.AnalyzeBatch(ctx, msgs)
+}
+
+func (r *dialogRecordingAI) contextOf(id int64) []models.ContextMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.contexts[id]
+}
+
+func TestDialogContextForwardedToAI(t *testing.T) {
+	ai := &dialogRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DialogContextSize: 2, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	ctx := context.Background()
+
+	if _, err := c.ProcessBatch(ctx, []models.Message{
+		{ID: 1, DialogID: "d1", User: 10, Data: "hello"},
+		{ID: 2, DialogID: "d1", User: 20, Data: "bad one"},
+		{ID: 3, DialogID: "d2", User: 30, Data: "bad two"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ContextMessage{{User: 10, Data: "hello"}}
+	if got := ai.contextOf(2); !reflect.DeepEqual(got, want) {
+		t.Fatalf("context within batch = %+v, want %+v", got, want)
+	}
+	if got := ai.contextOf(3); len(got) != 0 {
+		t.Fatalf("other dialog leaked context: %+v", got)
+	}
+
+	if _, err := c.ProcessMessage(ctx, models.Message{ID: 4, DialogID: "d1", User: 10, Data: "bad three"}); err != nil {
+		t.Fatal(err)
+	}
+	want = []models.ContextMessage{{User: 10, Data: "hello"}, {User: 20, Data: "bad one"}}
+	if got := ai.contextOf(4); !reflect.DeepEqual(got, want) {
+		t.Fatalf("context across calls = %+v, want %+v", got, want)
+	}
+
+	if _, err := c.ProcessMessage(ctx, models.Message{ID: 5, DialogID: "d1", User: 20, Data: "bad four"}); err != nil {
+		t.Fatal(err)
+	}
+	want = []models.ContextMessage{{User: 20, Data: "bad one"}, {User: 10, Data: "bad three"}}
+	if got := ai.contextOf(5); !reflect.DeepEqual(got, want) {
+		t.Fatalf("context not bounded to size: %+v, want %+v", got, want)
+	}
+
+	own := []models.ContextMessage{{User: 1, Data: "caller"}}
+	if _, err := c.ProcessMessage(ctx, models.Message{ID: 6, DialogID: "d1", User: 20, Data: "bad five", Context: own}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ai.contextOf(6); !reflect.DeepEqual(got, own) {
+		t.Fatalf("caller context replaced: %+v", got)
+	}
+}
+
+func TestDialogContextShadowDoesNotExtend(t *testing.T) {
+	ai := &dialogRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DialogContextSize: 4, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	ctx := context.Background()
+
+	if _, err := c.ProcessBatchWithOptions(ctx, []models.Message{{ID: 1, DialogID: "d", User: 1, Data: "shadow"}}, ProcessOptions{ShadowMode: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ProcessMessage(ctx, models.Message{ID: 2, DialogID: "d", User: 1, Data: "bad"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ai.contextOf(2); len(got) != 0 {
+		t.Fatalf("shadow message stored as context: %+v", got)
+	}
+}
+
+func TestDialogContextBypassesCacheAndDedup(t *testing.T) {
+	ai := &dialogRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DialogContextSize: 2, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	ctx := context.Background()
+
+	if _, err := c.ProcessBatch(ctx, []models.Message{
+		{ID: 1, DialogID: "d1", User: 10, Data: "want to buy?"},
+		{ID: 2, DialogID: "d2", User: 30, Data: "you are rude"},
+		{ID: 3, DialogID: "d1", User: 20, Data: "bad"},
+		{ID: 4, DialogID: "d2", User: 40, Data: "bad"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ai.contextOf(3)) != 1 || len(ai.contextOf(4)) != 1 {
+		t.Fatalf("same text in different dialogs must be analyzed apart: %+v %+v", ai.contextOf(3), ai.contextOf(4))
+	}
+	if c.CacheLen() != 0 {
+		t.Fatalf("verdicts reached under dialog context must not be cached, len=%d", c.CacheLen())
+	}
+	res, _ := c.ProcessMessage(ctx, models.Message{ID: 5, DialogID: "d1", User: 20, Data: "bad"})
+	if res.CacheHit || len(ai.contextOf(5)) != 2 {
+		t.Fatalf("expected a fresh verdict under dialog context: %+v", res)
+	}
+}
+
+func TestDialogContextsTTLAndBound(t *testing.T) {
+	d := newDialogContexts(3, time.Minute, 2)
+	now := time.Unix(1000, 0)
+	d.observe(models.Message{DialogID: "a", Data: "a1"}, true, now)
+	d.observe(models.Message{DialogID: "b", Data: "b1"}, true, now.Add(time.Second))
+	d.observe(models.Message{DialogID: "c", Data: "c1"}, true, now.Add(2*time.Second))
+	if len(d.dialogs) != 2 {
+		t.Fatalf("dialogs = %d, want 2", len(d.dialogs))
+	}
+	if _, ok := d.dialogs["a"]; ok {
+		t.Fatal("oldest dialog not evicted")
+	}
+	if got := d.observe(models.Message{DialogID: "b", Data: "b2"}, false, now.Add(time.Minute+time.Second)); got != nil {
+		t.Fatalf("expired dialog returned context: %+v", got)
+	}
+	if got := d.observe(models.Message{DialogID: "c", Data: "c2"}, false, now.Add(30*time.Second)); len(got) != 1 {
+		t.Fatalf("live dialog context = %+v", got)
+	}
+	d.observe(models.Message{DialogID: "b", Data: "b3"}, true, now.Add(40*time.Second))
+	d.observe(models.Message{DialogID: "e", Data: "e1"}, true, now.Add(41*time.Second))
+	if _, ok := d.dialogs["c"]; ok || len(d.dialogs) != 2 || d.lru.Len() != 2 {
+		t.Fatalf("least recently updated dialog not evicted: %v", d.dialogs)
+	}
+	if newDialogContexts(0, time.Minute, 2) != nil {
+		t.Fatal("zero size should disable")
+	}
+}
@@ -0,0 +1,22 @@
+package core
+
+import (
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// newTrace returns the decision trace when opt.Explain is set, otherwise nil.
+func newTrace(opt ProcessOptions, start time.Time, triggers []string, cacheHit, aiCalled bool, raw string) *models.ProcessTrace {
+	if !opt.Explain {
+		return nil
+	}
+	return &models.ProcessTrace{
+		TriggerMatched: len(triggers) > 0,
+		Triggers:       append([]string(nil), triggers...),
+		CacheHit:       cacheHit,
+		AICalled:       aiCalled,
+		RawAIContent:   raw,
+		Duration:       time.Since(start),
+	}
+}
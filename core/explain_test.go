@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestExplainTraces(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9, Reason: "offer", Raw: `{"a":3,"b":"offer","c":0.9}`}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DisableAutoLearn: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+	explain := ProcessOptions{Explain: true}
+
+	// Trigger hit analyzed by AI.
+	out, err := c.ProcessBatchWithOptions(context.Background(), []models.Message{{ID: 1, User: 1, Data: "bad deal"}}, explain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := out[0].Trace
+	if tr == nil || !tr.TriggerMatched || len(tr.Triggers) != 1 || tr.Triggers[0] != "bad" || tr.CacheHit || !tr.AICalled {
+		t.Fatalf("unexpected trigger-hit trace: %+v", tr)
+	}
+	if tr.RawAIContent != `{"a":3,"b":"offer","c":0.9}` || tr.Duration <= 0 {
+		t.Fatalf("unexpected raw content or duration: %+v", tr)
+	}
+
+	// Same text again comes from the cache.
+	out, err = c.ProcessBatchWithOptions(context.Background(), []models.Message{{ID: 2, User: 2, Data: "bad deal"}}, explain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr = out[0].Trace; tr == nil || !tr.CacheHit || tr.AICalled || !tr.TriggerMatched || tr.RawAIContent != "" {
+		t.Fatalf("unexpected cache-hit trace: %+v", tr)
+	}
+
+	// No trigger: resolved without AI.
+	out, err = c.ProcessBatchWithOptions(context.Background(), []models.Message{{ID: 3, User: 3, Data: "hello"}}, explain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr = out[0].Trace; tr == nil || tr.TriggerMatched || len(tr.Triggers) != 0 || tr.CacheHit || tr.AICalled {
+		t.Fatalf("unexpected no-trigger trace: %+v", tr)
+	}
+
+	// Without Explain decisions are identical and carry no trace.
+	plain, err := c.ProcessBatch(context.Background(), []models.Message{{ID: 2, User: 2, Data: "bad deal"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain[0].Trace != nil || plain[0].AIResult.StatusCode != models.StatusSuspicious || !plain[0].CacheHit {
+		t.Fatalf("unexpected plain result: %+v", plain[0])
+	}
+	if got := ai.callCount.Load(); got != 1 {
+		t.Fatalf("expected one AI call, got %d", got)
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elum-utils/censor/interfaces"
+)
+
+// ExportTokens returns the in-memory token set, sorted, in the form storage
+// keeps it. Engines without export support return nil.
+func (c *Core) ExportTokens() []string {
+	if ex, ok := c.engine.(exporter); ok {
+		return ex.Export()
+	}
+	return nil
+}
+
+// ImportTokens writes tokens to storage and memory. With replace, tokens not
+// in the list are removed and the engine is reloaded from storage; otherwise
+// the list is merged into the current set. Every token is validated before
+// anything is written.
+func (c *Core) ImportTokens(ctx context.Context, tokens []string, replace bool) error {
+	if c.storage == nil {
+		return ErrNoStorage
+	}
+	set := make(map[string]struct{}, len(tokens))
+	normalized := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		n := c.normalizeToken(token)
+		if n == "" {
+			return fmt.Errorf("core: token %q is empty or invalid", token)
+		}
+		if _, dup := set[n]; dup {
+			continue
+		}
+		set[n] = struct{}{}
+		normalized = append(normalized, n)
+	}
+
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	c.checkpoint = nil
+	if replace {
+		current, err := c.storage.GetTokens(ctx)
+		if err != nil {
+			return err
+		}
+		for _, token := range current {
+			if _, keep := set[token]; keep {
+				continue
+			}
+			if err := c.storage.RemoveToken(ctx, token); err != nil {
+				return err
+			}
+			c.recentlyPersisted.release(token)
+		}
+	}
+	if err := c.storeTokens(ctx, normalized); err != nil {
+		return err
+	}
+	if replace {
+		return c.reloadTokens(ctx)
+	}
+	for _, token := range normalized {
+		c.engine.AddToken(token)
+	}
+	return nil
+}
+
+// storeTokens adds tokens to storage in one call when supported.
+func (c *Core) storeTokens(ctx context.Context, tokens []string) error {
+	if bs, ok := c.storage.(interfaces.BulkStorage); ok {
+		return bs.AddTokens(ctx, tokens)
+	}
+	for _, token := range tokens {
+		if err := c.storage.AddToken(ctx, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/elum-utils/censor/engine"
+)
+
+func TestImportExportTokens(t *testing.T) {
+	ctx := context.Background()
+	st := newMockStorage("old")
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st, DisableAutoLearn: true})
+	defer c.Close()
+	if err := c.SyncOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merge keeps existing tokens.
+	set := []string{" Spam ", "buy now", engine.EncodeCategory("drugs", "weed"), "spam"}
+	if err := c.ImportTokens(ctx, set, false); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"buy now", "cat:drugs:weed", "old", "spam"}
+	if got := c.ExportTokens(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after merge ExportTokens = %v, want %v", got, want)
+	}
+	if !st.hasToken("spam") || !st.hasToken("cat:drugs:weed") || !st.hasToken("old") {
+		t.Fatal("merged tokens must be persisted")
+	}
+
+	// Replace drops tokens missing from the list, in storage too.
+	if err := c.ImportTokens(ctx, []string{"spam", "scam"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.ExportTokens(); !reflect.DeepEqual(got, []string{"scam", "spam"}) {
+		t.Fatalf("after replace ExportTokens = %v", got)
+	}
+	stored, _ := st.GetTokens(ctx)
+	sort.Strings(stored)
+	if !reflect.DeepEqual(stored, []string{"scam", "spam"}) {
+		t.Fatalf("storage after replace = %v", stored)
+	}
+
+	// An export round-trips into a fresh core.
+	c2 := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage(), DisableAutoLearn: true})
+	defer c2.Close()
+	if err := c2.ImportTokens(ctx, c.ExportTokens(), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := c2.ExportTokens(); !reflect.DeepEqual(got, c.ExportTokens()) {
+		t.Fatalf("round trip = %v, want %v", got, c.ExportTokens())
+	}
+
+	// Invalid input writes nothing.
+	if err := c.ImportTokens(ctx, []string{"fresh", "re:("}, false); err == nil {
+		t.Fatal("expected error for invalid token")
+	}
+	if st.hasToken("fresh") || c.TokenCount() != 2 {
+		t.Fatal("a rejected import must not write any token")
+	}
+}
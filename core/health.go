@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elum-utils/censor/interfaces"
+)
+
+// HealthComponent names the part of the filter a health check failed on.
+type HealthComponent string
+
+const (
+	HealthAI      HealthComponent = "ai"
+	HealthStorage HealthComponent = "storage"
+	HealthEngine  HealthComponent = "engine"
+)
+
+// HealthError is returned by HealthCheck for the first failing component.
+type HealthError struct {
+	Component HealthComponent
+	Err       error
+}
+
+func (e *HealthError) Error() string {
+	return "core: " + string(e.Component) + " unhealthy: " + e.Err.Error()
+}
+
+func (e *HealthError) Unwrap() error { return e.Err }
+
+// HealthCheck is a readiness probe: it checks that an AI analyzer is set,
+// that storage answers (Ping when supported, otherwise a TokenExists
+// lookup) and, with Options.HealthMinTokens, that enough tokens are loaded.
+// Failures are *HealthError.
+func (c *Core) HealthCheck(ctx context.Context) error {
+	if c.ai == nil {
+		return &HealthError{Component: HealthAI, Err: ErrNoAnalyzer}
+	}
+	if c.storage == nil {
+		return &HealthError{Component: HealthStorage, Err: ErrNoStorage}
+	}
+	var err error
+	if ps, ok := c.storage.(interfaces.PingStorage); ok {
+		err = ps.Ping(ctx)
+	} else {
+		_, err = c.storage.TokenExists(ctx, "")
+	}
+	if err != nil {
+		return &HealthError{Component: HealthStorage, Err: err}
+	}
+	if c.healthMinTokens > 0 {
+		if n := c.engine.Count(); n < c.healthMinTokens {
+			return &HealthError{Component: HealthEngine, Err: fmt.Errorf("%d tokens loaded, want at least %d", n, c.healthMinTokens)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type pingStorage struct {
+	*mockStorage
+	err error
+}
+
+func (p pingStorage) Ping(context.Context) error { return p.err }
+
+type existsErrStorage struct{ *mockStorage }
+
+func (existsErrStorage) TokenExists(context.Context, string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	component := func(t *testing.T, err error) HealthComponent {
+		t.Helper()
+		var he *HealthError
+		if !errors.As(err, &he) {
+			t.Fatalf("expected *HealthError, got %v", err)
+		}
+		return he.Component
+	}
+
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage("bad"), HealthMinTokens: 1})
+	if got := component(t, c.HealthCheck(ctx)); got != HealthEngine {
+		t.Fatalf("empty engine reported as %q", got)
+	}
+	_ = c.SyncOnce(ctx)
+	if err := c.HealthCheck(ctx); err != nil {
+		t.Fatalf("expected healthy, got %v", err)
+	}
+
+	noAI := New(Options{Storage: newMockStorage()}).HealthCheck(ctx)
+	if got := component(t, noAI); got != HealthAI || !errors.Is(noAI, ErrNoAnalyzer) {
+		t.Fatalf("missing AI reported as %q: %v", got, noAI)
+	}
+	noStorage := New(Options{AIAnalyzer: &mockAI{}}).HealthCheck(ctx)
+	if got := component(t, noStorage); got != HealthStorage || !errors.Is(noStorage, ErrNoStorage) {
+		t.Fatalf("missing storage reported as %q: %v", got, noStorage)
+	}
+
+	down := errors.New("ping timeout")
+	err := New(Options{AIAnalyzer: &mockAI{}, Storage: pingStorage{newMockStorage(), down}}).HealthCheck(ctx)
+	if component(t, err) != HealthStorage || !errors.Is(err, down) {
+		t.Fatalf("ping failure not surfaced: %v", err)
+	}
+	if err := New(Options{AIAnalyzer: &mockAI{}, Storage: pingStorage{newMockStorage(), nil}}).HealthCheck(ctx); err != nil {
+		t.Fatalf("expected healthy with ping, got %v", err)
+	}
+	if got := component(t, New(Options{AIAnalyzer: &mockAI{}, Storage: existsErrStorage{newMockStorage()}}).HealthCheck(ctx)); got != HealthStorage {
+		t.Fatalf("lookup failure reported as %q", got)
+	}
+}
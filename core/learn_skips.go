@@ -0,0 +1,63 @@
+package core
+
+import "sync/atomic"
+
+// LearnSkipReason labels why auto-learn dropped a candidate token.
+type LearnSkipReason string
+
+const (
+	LearnSkipBelowStatus     LearnSkipReason = "below_status"
+	LearnSkipBelowConfidence LearnSkipReason = "below_confidence"
+	LearnSkipEmpty           LearnSkipReason = "empty"
+	LearnSkipTooLong         LearnSkipReason = "too_long"
+	LearnSkipKnown           LearnSkipReason = "known"
+	LearnSkipRecent          LearnSkipReason = "recently_persisted"
+	LearnSkipExists          LearnSkipReason = "exists_in_storage"
+	// LearnSkipSpecial counts regex, wildcard and category tokens: a model
+	// reply must not install patterns, so only literals are learned.
+	LearnSkipSpecial LearnSkipReason = "special"
+)
+
+var learnSkipReasons = [...]LearnSkipReason{
+	LearnSkipBelowStatus,
+	LearnSkipBelowConfidence,
+	LearnSkipEmpty,
+	LearnSkipTooLong,
+	LearnSkipKnown,
+	LearnSkipRecent,
+	LearnSkipExists,
+	LearnSkipSpecial,
+}
+
+// learnSkipCounters counts skipped candidate tokens per reason.
+type learnSkipCounters [len(learnSkipReasons)]atomic.Int64
+
+func (l *learnSkipCounters) add(reason LearnSkipReason, n int) {
+	for i, r := range learnSkipReasons {
+		if r == reason {
+			l[i].Add(int64(n))
+			return
+		}
+	}
+}
+
+// LearnSkips returns how many candidate tokens auto-learn skipped, by reason.
+// Results rejected as a whole count every trigger token they carried.
+func (c *Core) LearnSkips() map[LearnSkipReason]int64 {
+	out := make(map[LearnSkipReason]int64, len(learnSkipReasons))
+	for i, r := range learnSkipReasons {
+		out[r] = c.learnSkips[i].Load()
+	}
+	return out
+}
+
+// LearnStats counts learned tokens written to storage and failed writes.
+type LearnStats struct {
+	Persisted int64
+	Failed    int64
+}
+
+// LearnStats returns storage outcomes of auto-learn since start.
+func (c *Core) LearnStats() LearnStats {
+	return LearnStats{Persisted: c.learnPersisted.Load(), Failed: c.learnFailed.Load()}
+}
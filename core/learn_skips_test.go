@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestLearnSkipCounters(t *testing.T) {
+	st := newMockStorage("known")
+	c := New(Options{Storage: st, AIAnalyzer: &mockAI{}, MaxLearnTokenLength: 10, LearnDedupWindow: 0})
+	c.engine.ReplaceAll([]string{"known"})
+
+	c.learn(models.AIResult{StatusCode: models.StatusNonCriticalAbuse, Confidence: 1, TriggerTokens: []string{"a", "b"}})
+	c.learn(models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.1, TriggerTokens: []string{"c"}})
+	c.learn(models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 1, TriggerTokens: []string{
+		" ", strings.Repeat("x", 11), "known", "fresh", `re:.*`, "wild:*a", "cat:x:y",
+	}})
+	c.engine.RemoveToken("fresh")
+	c.learn(models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 1, TriggerTokens: []string{"fresh"}})
+
+	got := c.LearnSkips()
+	want := map[LearnSkipReason]int64{
+		LearnSkipBelowStatus:     2,
+		LearnSkipBelowConfidence: 1,
+		LearnSkipEmpty:           1,
+		LearnSkipTooLong:         1,
+		LearnSkipKnown:           1,
+		LearnSkipRecent:          1,
+		LearnSkipSpecial:         3,
+	}
+	for reason, n := range want {
+		if got[reason] != n {
+			t.Fatalf("%s: expected %d, got %d (all: %v)", reason, n, got[reason], got)
+		}
+	}
+	if c.engine.Count() != 2 {
+		t.Fatalf("special tokens must not be learned, count=%d", c.engine.Count())
+	}
+}
+
+func TestSyncLearnPersistsBeforeReturn(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9, TriggerTokens: []string{"new token"}}}
+	st := newMockStorage("bad")
+	c := New(Options{AIAnalyzer: ai, Storage: st, AutoLearn: true, SyncLearn: true})
+	_ = c.SyncOnce(context.Background())
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "bad"}); err != nil {
+		t.Fatal(err)
+	}
+	if !st.hasToken("new token") {
+		t.Fatalf("expected token persisted before return")
+	}
+	if got := c.LearnStats(); got.Persisted != 1 || got.Failed != 0 {
+		t.Fatalf("unexpected stats: %+v", got)
+	}
+}
+
+func TestSyncLearnCountsFailures(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9, TriggerTokens: []string{"x", "y"}}}
+	c := New(Options{AIAnalyzer: ai, Storage: errStorage{}, AutoLearn: true, SyncLearn: true})
+	c.engine.ReplaceAll([]string{"bad"})
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 2, Data: "bad"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.LearnStats(); got.Persisted != 0 || got.Failed != 2 {
+		t.Fatalf("unexpected stats: %+v", got)
+	}
+}
+
+type writeCountingStorage struct {
+	*mockStorage
+	writes atomic.Int64
+}
+
+func (s *writeCountingStorage) AddToken(ctx context.Context, token string) error {
+	s.writes.Add(1)
+	return s.mockStorage.AddToken(ctx, token)
+}
+
+func TestSkipLearnIfExists(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		st := &writeCountingStorage{mockStorage: newMockStorage("stored")}
+		c := New(Options{Storage: st, AIAnalyzer: &mockAI{}, SyncLearn: true, SkipLearnIfExists: skip})
+		// The engine is empty, as after a restart before the first sync.
+		c.learn(models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 1, TriggerTokens: []string{"stored", "fresh"}})
+
+		wantWrites, wantSkips := int64(2), int64(0)
+		if skip {
+			wantWrites, wantSkips = 1, 1
+		}
+		if got := st.writes.Load(); got != wantWrites {
+			t.Fatalf("skip=%v: %d storage writes, want %d", skip, got, wantWrites)
+		}
+		if got := c.LearnSkips()[LearnSkipExists]; got != wantSkips {
+			t.Fatalf("skip=%v: %d exists skips, want %d", skip, got, wantSkips)
+		}
+		if !st.hasToken("fresh") || c.TokenCount() != 2 {
+			t.Fatalf("skip=%v: fresh token must be stored and both learned in memory", skip)
+		}
+		_ = c.Close()
+	}
+}
+
+func TestTokenLearnedEvent(t *testing.T) {
+	c := New(Options{Storage: newMockStorage("known"), AIAnalyzer: &mockAI{}, AutoLearn: true, SyncLearn: true, MaxLearnTokenLength: 10})
+	c.engine.ReplaceAll([]string{"known"})
+
+	var events []ViolationEvent
+	_ = c.OnTokenLearned(func(_ context.Context, e ViolationEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	var statusEvents atomic.Int64
+	_ = c.On(EventAutoRestrict, func(context.Context, ViolationEvent) error {
+		statusEvents.Add(1)
+		return nil
+	})
+
+	result := models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9, MessageID: 7, ViolatorUserID: 3, TriggerTokens: []string{
+		"Fresh", "known", strings.Repeat("x", 11), "fresh", "other",
+	}}
+	c.learn(result)
+	c.learn(result)
+
+	if len(events) != 2 {
+		t.Fatalf("expected one event per new token, got %+v", events)
+	}
+	for i, token := range []string{"fresh", "other"} {
+		e := events[i]
+		if len(e.TriggerTokens) != 1 || e.TriggerTokens[0] != token {
+			t.Fatalf("event %d: expected token %q, got %v", i, token, e.TriggerTokens)
+		}
+		if e.StatusCode != models.StatusSuspicious || e.Confidence != 0.9 || e.MessageID != 7 || e.ViolatorUserID != 3 {
+			t.Fatalf("event %d: unexpected payload %+v", i, e)
+		}
+	}
+	if statusEvents.Load() != 0 {
+		t.Fatal("learning must not fire status events")
+	}
+}
@@ -63,6 +63,24 @@ func (c *negativeResultCache) Get(key string, now time.Time) (models.AIResult, b
 	return entry.value, true
 }
 
+// Peek returns an unexpired entry without touching LRU order or evicting it.
+func (c *negativeResultCache) Peek(key string, now time.Time) (models.AIResult, bool) {
+	if c == nil || key == "" {
+		return models.AIResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return models.AIResult{}, false
+	}
+	entry := elem.Value.(*negativeCacheEntry)
+	if now.After(entry.expiresAt) {
+		return models.AIResult{}, false
+	}
+	return entry.value, true
+}
+
 func (c *negativeResultCache) Set(key string, value models.AIResult, ttl time.Duration, now time.Time) {
 	if c == nil || key == "" || ttl <= 0 {
 		return
@@ -115,6 +133,46 @@ func (c *negativeResultCache) RemoveExpired(now time.Time) {
 	}
 }
 
+// ExpiringWithin returns up to limit keys of live entries that expire within window,
+// most recently used first.
+func (c *negativeResultCache) ExpiringWithin(window time.Duration, now time.Time, limit int) []string {
+	if c == nil || limit <= 0 {
+		return nil
+	}
+	deadline := now.Add(window)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []string
+	for elem := c.lru.Front(); elem != nil && len(out) < limit; elem = elem.Next() {
+		entry := elem.Value.(*negativeCacheEntry)
+		if now.After(entry.expiresAt) || entry.expiresAt.After(deadline) {
+			continue
+		}
+		out = append(out, entry.key)
+	}
+	return out
+}
+
+// Remove deletes key if present.
+func (c *negativeResultCache) Remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeElement(c.items[key])
+}
+
+// Len returns the number of entries, including expired ones not yet removed.
+func (c *negativeResultCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
 func (c *negativeResultCache) removeElement(elem *list.Element) {
 	if elem == nil {
 		return
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestNegativeCachePeekKeepsLRUOrder(t *testing.T) {
+	now := time.Now()
+	res := models.AIResult{StatusCode: models.StatusSuspicious, Reason: "x"}
+	size := int64(estimateEntrySizeBytes("a", res))
+	c := newNegativeResultCache(2 * size)
+	c.Set("a", res, time.Hour, now)
+	c.Set("b", res, time.Hour, now)
+
+	if _, ok := c.Peek("a", now); !ok {
+		t.Fatalf("expected peek hit")
+	}
+	// "a" stays least recently used, so inserting "c" evicts it.
+	c.Set("c", res, time.Hour, now)
+	if _, ok := c.Peek("a", now); ok {
+		t.Fatalf("peek must not refresh LRU position")
+	}
+	if _, ok := c.Peek("b", now.Add(2*time.Hour)); ok {
+		t.Fatalf("expired entry must not be returned")
+	}
+	if _, ok := c.Peek("b", now); !ok {
+		t.Fatalf("peek must not evict expired entries")
+	}
+}
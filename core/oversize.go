@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// oversizeWindows splits data into windows of at most size bytes on rune
+// boundaries. Consecutive windows overlap by about size/8 bytes so a token
+// crossing a boundary is seen whole by one of them.
+func oversizeWindows(data string, size int) []string {
+	overlap := size / 8
+	var out []string
+	for start := 0; ; {
+		end := start + size
+		if end >= len(data) {
+			return append(out, data[start:])
+		}
+		for end > start && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		if end == start {
+			// A single rune wider than size; keep it whole.
+			_, n := utf8.DecodeRuneInString(data[start:])
+			end = start + n
+		}
+		out = append(out, data[start:end])
+		next := end - overlap
+		for next > start && !utf8.RuneStart(data[next]) {
+			next--
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+}
+
+// processOversize analyzes msg window by window without side effects and
+// resolves it to the most severe window decision, which is then learned
+// from and recorded for the whole message. The decision is not cached. If
+// the AI fails, the whole message is deferred as the batch path would.
+func (c *Core) processOversize(ctx context.Context, msg models.Message, dialogContext []models.ContextMessage, opt ProcessOptions, stale bool, start time.Time) (models.Violation, error) {
+	windows := oversizeWindows(msg.Data, c.maxMessageSize)
+	parts := make([]models.Message, len(windows))
+	for i, w := range windows {
+		// Window IDs only need to be unique within the sub-batch; the
+		// dialog was already checked and its context is passed explicitly.
+		parts[i] = models.Message{ID: int64(i + 1), User: msg.User, Data: w, Lang: msg.Lang, Context: msg.Context}
+		if len(parts[i].Context) == 0 {
+			parts[i].Context = dialogContext
+		}
+	}
+	sub := opt
+	sub.ShadowMode = true
+	results, err := c.processBatch(ctx, parts, sub)
+	var missing *MissingAIResultError
+	if err != nil && !errors.As(err, &missing) && c.deferFailed(ctx, []models.Message{msg}, err, opt) {
+		triggers := c.engine.FindTriggers(msg.Data)
+		v := models.Violation{Message: msg, Triggered: len(triggers) > 0, Deferred: true, AIResult: models.AIResult{
+			StatusCode:     models.StatusSuspicious,
+			Reason:         c.reasons.Deferred,
+			Confidence:     0,
+			TriggerTokens:  triggers,
+			ViolatorUserID: msg.User,
+			MessageID:      msg.ID,
+		}}
+		v.Trace = newTrace(opt, start, triggers, false, true, "")
+		return c.finish(ctx, v, opt, stale), nil
+	}
+	if err != nil {
+		return models.Violation{}, err
+	}
+
+	best := results[0]
+	triggered := false
+	for _, v := range results {
+		triggered = triggered || v.Triggered
+		if moreSevere(v.AIResult, best.AIResult) {
+			best = v
+		}
+	}
+	v := best
+	v.Message = msg
+	v.Triggered = triggered
+	v.AIResult.MessageID = msg.ID
+	if v.AIResult.ViolatorUserID == 0 {
+		v.AIResult.ViolatorUserID = msg.User
+	}
+	if !opt.ShadowMode {
+		c.learn(v.AIResult)
+	}
+	c.recordFor(v, opt)
+	return v, nil
+}
+
+// moreSevere orders decisions by status code, then confidence.
+func moreSevere(a, b models.AIResult) bool {
+	if a.StatusCode != b.StatusCode {
+		return a.StatusCode > b.StatusCode
+	}
+	return a.Confidence > b.Confidence
+}
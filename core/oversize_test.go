@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestSplitOversizeMessagesFindsTail(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusDangerousIllegal, Confidence: 0.9}}
+	long := strings.Repeat("hello world ", 40) + "buy bad stuff"
+	msg := models.Message{ID: 42, User: 7, Data: long}
+
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxMessageSize: 100, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	res, err := c.ProcessMessage(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Triggered || res.AIResult.StatusCode != models.StatusClean {
+		t.Fatalf("truncation must miss the tail: %+v", res.AIResult)
+	}
+
+	cb := &countCallbacks{}
+	c = New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxMessageSize: 100, SplitOversizeMessages: true, CallbackHandler: cb, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	res, err = c.ProcessMessage(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Triggered || res.AIResult.StatusCode != models.StatusDangerousIllegal {
+		t.Fatalf("split analysis must find the tail violation: %+v", res.AIResult)
+	}
+	if res.AIResult.MessageID != 42 || res.Message.ID != 42 || res.Message.Data != long {
+		t.Fatalf("decision must keep the original message: %+v", res)
+	}
+	if cb.clean.Load() != 0 || cb.critical.Load() != 1 {
+		t.Fatalf("expected one recorded decision, got clean=%d critical=%d", cb.clean.Load(), cb.critical.Load())
+	}
+	if c.CacheLen() != 0 {
+		t.Fatalf("combined decision must not be cached, cache has %d entries", c.CacheLen())
+	}
+}
+
+func TestOversizeWindows(t *testing.T) {
+	data := strings.Repeat("ж", 100) // 200 bytes
+	windows := oversizeWindows(data, 64)
+	if len(windows) < 4 {
+		t.Fatalf("expected several windows, got %d", len(windows))
+	}
+	for i, w := range windows {
+		if len(w) > 64 || !utf8.ValidString(w) {
+			t.Fatalf("window %d invalid: %q", i, w)
+		}
+	}
+	if !strings.HasSuffix(data, windows[len(windows)-1]) {
+		t.Fatal("last window must reach the end")
+	}
+
+	// A token straddling the first boundary is whole in the next window.
+	text := strings.Repeat("a", 60) + "token" + strings.Repeat("b", 60)
+	found := false
+	for _, w := range oversizeWindows(text, 64) {
+		found = found || strings.Contains(w, "token")
+	}
+	if !found {
+		t.Fatal("overlap must keep a boundary token whole")
+	}
+}
+
+func TestSplitOversizeKeepsDialogContextTruncated(t *testing.T) {
+	ai := &dialogRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxMessageSize: 100, SplitOversizeMessages: true, DialogContextSize: 2, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	long := strings.Repeat("hello world ", 40)
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, DialogID: "d1", User: 10, Data: long}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 2, DialogID: "d1", User: 20, Data: "bad one"}); err != nil {
+		t.Fatal(err)
+	}
+	got := ai.contextOf(2)
+	if len(got) != 1 || got[0].Data != long[:100] {
+		t.Fatalf("split message must enter dialog context truncated: %+v", got)
+	}
+}
+
+func TestSplitOversizeDefersWholeMessage(t *testing.T) {
+	ds := &memDeferStore{}
+	ai := &mockAI{err: errors.New("provider down")}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), MaxMessageSize: 100, SplitOversizeMessages: true, DeferStore: ds, DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+	msg := models.Message{ID: 42, User: 7, Data: strings.Repeat("hello world ", 40) + "buy bad stuff"}
+	res, err := c.ProcessMessage(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("AI error must be deferred, got %v", err)
+	}
+	if !res.Deferred || !res.Triggered || res.AIResult.StatusCode != models.StatusSuspicious || res.AIResult.MessageID != 42 {
+		t.Fatalf("unexpected deferred decision: %+v", res)
+	}
+	if len(ds.messages) != 1 || ds.messages[0].ID != 42 || ds.messages[0].Data != msg.Data {
+		t.Fatalf("the original message must be deferred, not its windows: %+v", ds.messages)
+	}
+}
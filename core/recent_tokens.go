@@ -0,0 +1,65 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// recentTokens is a bounded set of tokens persisted within a time window.
+type recentTokens struct {
+	mu     sync.Mutex
+	window time.Duration
+	max    int
+	items  map[string]time.Time
+}
+
+func newRecentTokens(window time.Duration, max int) *recentTokens {
+	if window <= 0 || max <= 0 {
+		return nil
+	}
+	return &recentTokens{window: window, max: max, items: make(map[string]time.Time)}
+}
+
+// reserve marks token as persisted at now. It returns false when the token was
+// already persisted within the window and the write can be skipped.
+func (r *recentTokens) reserve(token string, now time.Time) bool {
+	if r == nil {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if at, ok := r.items[token]; ok && now.Sub(at) < r.window {
+		return false
+	}
+	if len(r.items) >= r.max {
+		r.evictLocked(now)
+	}
+	r.items[token] = now
+	return true
+}
+
+// release forgets token, e.g. after a failed write.
+func (r *recentTokens) release(token string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.items, token)
+	r.mu.Unlock()
+}
+
+func (r *recentTokens) evictLocked(now time.Time) {
+	oldestKey, oldestAt := "", now
+	for k, at := range r.items {
+		if now.Sub(at) >= r.window {
+			delete(r.items, k)
+			continue
+		}
+		if at.Before(oldestAt) || oldestKey == "" {
+			oldestKey, oldestAt = k, at
+		}
+	}
+	if len(r.items) >= r.max && oldestKey != "" {
+		delete(r.items, oldestKey)
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestRecentTokensReserveWindowAndBound(t *testing.T) {
+	now := time.Now()
+	r := newRecentTokens(time.Minute, 2)
+	if !r.reserve("a", now) {
+		t.Fatalf("first reserve must succeed")
+	}
+	if r.reserve("a", now.Add(time.Second)) {
+		t.Fatalf("reserve within window must be skipped")
+	}
+	if !r.reserve("a", now.Add(2*time.Minute)) {
+		t.Fatalf("reserve after window must succeed")
+	}
+	r.release("a")
+	if !r.reserve("a", now) {
+		t.Fatalf("released token must be reservable")
+	}
+	_ = r.reserve("b", now.Add(time.Second))
+	_ = r.reserve("c", now.Add(2*time.Second))
+	if len(r.items) > 2 {
+		t.Fatalf("set must stay bounded, got %d", len(r.items))
+	}
+}
+
+type countingStorage struct {
+	*mockStorage
+	adds atomic.Int64
+}
+
+func (c *countingStorage) AddToken(ctx context.Context, token string) error {
+	c.adds.Add(1)
+	return c.mockStorage.AddToken(ctx, token)
+}
+
+func TestLearnSkipsRecentlyPersistedToken(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.9, TriggerTokens: []string{"promo"}}}
+	st := &countingStorage{mockStorage: newMockStorage("bad")}
+	c := New(Options{AIAnalyzer: ai, Storage: st, CacheMaxBytes: 1})
+	_ = c.SyncOnce(context.Background())
+
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad one"})
+	time.Sleep(20 * time.Millisecond)
+	// A reload from a storage replica that has not seen the write yet drops the token.
+	c.engine.RemoveToken("promo")
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 1, Data: "bad two"})
+	time.Sleep(20 * time.Millisecond)
+
+	if st.adds.Load() != 1 {
+		t.Fatalf("expected a single storage write, got %d", st.adds.Load())
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// reloadState coalesces concurrent Reload calls.
+type reloadState struct {
+	// run serializes reloads; it holds a token while one is in progress.
+	run chan struct{}
+	mu  sync.Mutex
+	// next is the reload waiting for run; callers arriving meanwhile share it.
+	next *reloadCall
+	// wake is TriggerReload's signal to Run.
+	wake chan struct{}
+	// joined, when set, is called by each caller that joins a pending reload.
+	joined func()
+}
+
+type reloadCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Reload syncs the token set from storage now. It is safe to call
+// concurrently: calls that arrive while a reload is in progress share a single
+// follow-up reload, which starts after the in-progress one and so sees every
+// storage change made before they were called. A shared reload runs with the
+// context of the caller that started it, so if that context ends before the
+// reload starts, every caller sharing it gets the context's error. Otherwise
+// ctx only bounds how long this caller waits.
+func (c *Core) Reload(ctx context.Context) error {
+	r := &c.reload
+	r.mu.Lock()
+	call := r.next
+	leader := call == nil
+	if leader {
+		call = &reloadCall{done: make(chan struct{})}
+		r.next = call
+	}
+	joined := r.joined
+	r.mu.Unlock()
+	if !leader && joined != nil {
+		joined()
+	}
+
+	if leader {
+		var started bool
+		select {
+		case r.run <- struct{}{}:
+			started = true
+		case <-ctx.Done():
+		}
+		r.mu.Lock()
+		r.next = nil
+		r.mu.Unlock()
+		if started {
+			call.err = c.SyncOnce(ctx)
+			<-r.run
+		} else {
+			call.err = ctx.Err()
+		}
+		close(call.done)
+		return call.err
+	}
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TriggerReload asks Run to reload the token set without waiting for its
+// ticker. It never blocks; triggers made before Run picks one up collapse.
+func (c *Core) TriggerReload() {
+	select {
+	case c.reload.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// gatedStorage counts token reads and blocks each one until released.
+type gatedStorage struct {
+	*mockStorage
+	reads   atomic.Int64
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *gatedStorage) GetTokens(ctx context.Context) ([]string, error) {
+	s.reads.Add(1)
+	s.started <- struct{}{}
+	<-s.release
+	return s.mockStorage.GetTokens(ctx)
+}
+
+func TestConcurrentReloadsCoalesce(t *testing.T) {
+	st := &gatedStorage{mockStorage: newMockStorage("old"), started: make(chan struct{}, 16), release: make(chan struct{})}
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st})
+	defer c.Close()
+	const n = 8
+	joins := make(chan struct{}, n)
+	c.reload.joined = func() { joins <- struct{}{} }
+
+	ctx := context.Background()
+	first := make(chan error, 1)
+	go func() { first <- c.Reload(ctx) }()
+	<-st.started
+
+	// Arrives while the first read is in flight: must see the new token.
+	_ = st.AddToken(ctx, "fresh")
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Reload(ctx)
+		}()
+	}
+	// Release the first read only once every caller shares the follow-up.
+	timeout := time.After(2 * time.Second)
+	for i := 0; i < n-1; i++ {
+		select {
+		case <-joins:
+		case <-timeout:
+			t.Fatal("callers did not join the follow-up reload")
+		}
+	}
+	close(st.release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := st.reads.Load(); got != 2 {
+		t.Fatalf("expected 2 storage reads, got %d", got)
+	}
+	res, err := c.ProcessMessage(ctx, models.Message{ID: 1, User: 1, Data: "fresh"})
+	if err != nil || !res.Triggered {
+		t.Fatalf("engine must reflect the latest tokens: %+v, %v", res, err)
+	}
+}
+
+func TestReloadLeaderWaitBoundedByContext(t *testing.T) {
+	st := &gatedStorage{mockStorage: newMockStorage("old"), started: make(chan struct{}, 16), release: make(chan struct{})}
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st})
+	defer c.Close()
+
+	first := make(chan error, 1)
+	go func() { first <- c.Reload(context.Background()) }()
+	<-st.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Reload(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting leader must stop with its context, got %v", err)
+	}
+	close(st.release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(context.Background()); err != nil || st.reads.Load() != 2 {
+		t.Fatalf("later reload must run: reads=%d err=%v", st.reads.Load(), err)
+	}
+}
+
+func TestTriggerReloadWakesRun(t *testing.T) {
+	st := newMockStorage("old")
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st, SyncInterval: time.Hour})
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Run(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.LastSync().IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("initial sync did not happen")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_ = st.AddToken(ctx, "fresh")
+	c.TriggerReload()
+	c.TriggerReload()
+	for c.TokenCount() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("TriggerReload did not reload tokens")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// ReprocessOptions controls re-moderation of historical messages.
+type ReprocessOptions struct {
+	ProcessOptions
+	// ReportOnly suppresses callbacks, events, metrics, cache writes and learning.
+	ReportOnly bool
+	// Previous holds prior verdicts by message ID to compare new decisions against.
+	Previous map[int64]models.StatusCode
+}
+
+// StatusChange describes a message whose verdict differs from the prior one.
+type StatusChange struct {
+	MessageID int64
+	Previous  models.StatusCode
+	Current   models.StatusCode
+}
+
+// ReprocessReport is the outcome of Reprocess.
+type ReprocessReport struct {
+	Violations []models.Violation
+	// Compared is the number of messages that had a prior verdict.
+	Compared int
+	Changed  []StatusChange
+}
+
+// Reprocess runs messages through the pipeline with fresh AI decisions (cache reads
+// are bypassed) and compares them with prior verdicts when provided.
+func (c *Core) Reprocess(ctx context.Context, messages []models.Message, opt ReprocessOptions) (ReprocessReport, error) {
+	popt := opt.ProcessOptions
+	popt.SkipCache = true
+	if opt.ReportOnly {
+		popt.ShadowMode = true
+	}
+	res, err := c.ProcessBatchWithOptions(ctx, messages, popt)
+	if err != nil {
+		return ReprocessReport{}, err
+	}
+
+	report := ReprocessReport{Violations: res}
+	for _, v := range res {
+		prev, ok := opt.Previous[v.Message.ID]
+		if !ok {
+			continue
+		}
+		report.Compared++
+		if prev != v.AIResult.StatusCode {
+			report.Changed = append(report.Changed, StatusChange{
+				MessageID: v.Message.ID,
+				Previous:  prev,
+				Current:   v.AIResult.StatusCode,
+			})
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestReprocessBypassesCacheAndCompares(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("buy"), DisableAutoLearn: true})
+	_ = c.SyncOnce(context.Background())
+
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "buy now"})
+	if ai.callCount.Load() != 1 {
+		t.Fatalf("expected warm-up AI call")
+	}
+
+	report, err := c.Reprocess(context.Background(), []models.Message{
+		{ID: 1, User: 1, Data: "buy now"},
+		{ID: 2, User: 2, Data: "buy later"},
+		{ID: 3, User: 3, Data: "hello"},
+	}, ReprocessOptions{Previous: map[int64]models.StatusCode{
+		1: models.StatusCommercialOffPlatform,
+		2: models.StatusClean,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ai.callCount.Load() != 3 {
+		t.Fatalf("expected cache bypass, got %d AI calls", ai.callCount.Load())
+	}
+	if report.Compared != 2 || len(report.Changed) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	ch := report.Changed[0]
+	if ch.MessageID != 2 || ch.Previous != models.StatusClean || ch.Current != models.StatusCommercialOffPlatform {
+		t.Fatalf("unexpected change: %+v", ch)
+	}
+}
+
+func TestReprocessReportOnlyHasNoSideEffects(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.9, TriggerTokens: []string{"fresh"}}}
+	st := newMockStorage("buy")
+	c := New(Options{AIAnalyzer: ai, Storage: st})
+	_ = c.SyncOnce(context.Background())
+
+	var events atomic.Int64
+	_ = c.OnAutoBanEscalate(func(context.Context, ViolationEvent) error {
+		events.Add(1)
+		return nil
+	})
+
+	report, err := c.Reprocess(context.Background(), []models.Message{{ID: 1, User: 1, Data: "buy now"}}, ReprocessOptions{ReportOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 1 || report.Violations[0].AIResult.StatusCode != models.StatusCommercialOffPlatform {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if events.Load() != 0 || c.Metrics()[models.StatusCommercialOffPlatform] != 0 {
+		t.Fatalf("report-only must not dispatch or count")
+	}
+	if _, ok := c.PeekCache("buy now"); ok {
+		t.Fatalf("report-only must not write cache")
+	}
+	if st.hasToken("fresh") || c.TokenCount() != 1 {
+		t.Fatalf("report-only must not learn")
+	}
+}
+
+func TestEvaluateHasNoSideEffects(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCritical, Confidence: 1, TriggerTokens: []string{"weapon"}}}
+	st := newMockStorage("bad")
+	cb := &countCallbacks{}
+	c := New(Options{AIAnalyzer: ai, Storage: st, CallbackHandler: cb})
+	_ = c.SyncOnce(context.Background())
+
+	v, err := c.Evaluate(context.Background(), models.Message{ID: 1, User: 2, Data: "bad"}, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.AIResult.StatusCode != models.StatusCritical || !v.Triggered {
+		t.Fatalf("unexpected decision: %+v", v)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if cb.critical.Load() != 0 || c.Metrics()[models.StatusCritical] != 0 {
+		t.Fatalf("evaluate must not dispatch or count")
+	}
+	if _, ok := c.PeekCache("bad"); ok || st.hasToken("weapon") {
+		t.Fatalf("evaluate must not cache or learn")
+	}
+}
+
+func TestEvaluateLeavesStatsAndCacheOrder(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("buy"), CacheTTL: time.Hour, CacheMaxBytes: 64 * KB, DisableAutoLearn: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	for i, text := range []string{"buy one", "buy two"} {
+		if _, err := c.ProcessMessage(context.Background(), models.Message{ID: int64(i + 1), User: 1, Data: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := c.EngineStats()
+
+	v, err := c.Evaluate(context.Background(), models.Message{ID: 3, User: 2, Data: "buy one"}, ProcessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.CacheHit || !v.Triggered || v.AIResult.MessageID != 3 || v.AIResult.ViolatorUserID != 2 {
+		t.Fatalf("unexpected decision: %+v", v)
+	}
+	after := c.EngineStats()
+	if after.TotalLookups != before.TotalLookups || after.TotalTokenHits != before.TotalTokenHits {
+		t.Fatalf("evaluate must not count lookups: before %+v, after %+v", before, after)
+	}
+	if oldest := c.negativeCache.lru.Back().Value.(*negativeCacheEntry); oldest.key != "buy one" {
+		t.Fatalf("evaluate must not reorder the cache, oldest is %q", oldest.key)
+	}
+}
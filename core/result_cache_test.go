@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// sharedCache stands in for an external cache shared by several instances.
+type sharedCache struct {
+	mu    sync.Mutex
+	items map[string]models.AIResult
+	ttls  map[string]time.Duration
+}
+
+func newSharedCache() *sharedCache {
+	return &sharedCache{items: map[string]models.AIResult{}, ttls: map[string]time.Duration{}}
+}
+
+func (s *sharedCache) Get(key string) (models.AIResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.items[key]
+	return r, ok
+}
+
+func (s *sharedCache) Set(key string, r models.AIResult, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = r
+	s.ttls[key] = ttl
+}
+
+func (s *sharedCache) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+}
+
+func (s *sharedCache) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func TestSharedResultCacheAcrossInstances(t *testing.T) {
+	cache := newSharedCache()
+	aiA := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.95}}
+	aiB := &mockAI{result: models.AIResult{StatusCode: models.StatusClean, Confidence: 0.95}}
+	a := New(Options{AIAnalyzer: aiA, Storage: newMockStorage("bad"), ResultCache: cache, DisableAutoLearn: true, CacheTTL: time.Minute})
+	b := New(Options{AIAnalyzer: aiB, Storage: newMockStorage("bad"), ResultCache: cache, DisableAutoLearn: true})
+	_ = a.SyncOnce(context.Background())
+	_ = b.SyncOnce(context.Background())
+
+	if _, err := a.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad offer"}); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 1 || cache.ttls["bad offer"] != time.Minute {
+		t.Fatalf("expected verdict stored in shared cache with TTL, got %v", cache.ttls)
+	}
+
+	res, err := b.ProcessMessage(context.Background(), models.Message{ID: 2, User: 7, Data: "bad offer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.CacheHit || res.AIResult.StatusCode != models.StatusCommercialOffPlatform || res.AIResult.ViolatorUserID != 7 {
+		t.Fatalf("expected shared cache hit, got %+v", res)
+	}
+	if aiB.callCount.Load() != 0 {
+		t.Fatal("second instance must not call AI")
+	}
+	if b.CacheLen() != 1 {
+		t.Fatalf("expected CacheLen from shared cache, got %d", b.CacheLen())
+	}
+
+	b.InvalidateCache("bad offer")
+	if _, ok := a.PeekCache("bad offer"); ok {
+		t.Fatal("invalidation must reach the shared cache")
+	}
+}
+
+func TestInvalidateMemoryCache(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusClean, Confidence: 0.9}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad")})
+	_ = c.SyncOnce(context.Background())
+	_, _ = c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "bad"})
+	if c.CacheLen() != 1 {
+		t.Fatalf("expected one cached verdict, got %d", c.CacheLen())
+	}
+	c.InvalidateCache("bad")
+	if c.CacheLen() != 0 {
+		t.Fatal("expected cache entry removed")
+	}
+}
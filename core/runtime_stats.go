@@ -0,0 +1,122 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/elum-utils/censor/engine"
+)
+
+// RuntimeStats counts AI requests and verdict cache lookups since start.
+type RuntimeStats struct {
+	// AICalls counts analyzer requests; a batch sent in one request counts once.
+	AICalls     int64
+	AIErrors    int64
+	CacheHits   int64
+	CacheMisses int64
+	// Deferred counts messages queued in the defer store after AI errors.
+	Deferred int64
+}
+
+type runtimeCounters struct {
+	aiCalls     atomic.Int64
+	aiErrors    atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+	deferred    atomic.Int64
+}
+
+// AIStats describes analyzer call latency since start. Every Analyze or
+// AnalyzeBatch invocation counts as one call, failed ones included.
+type AIStats struct {
+	Calls      int64
+	Errors     int64
+	TotalNanos int64
+	LastNanos  int64
+	MinNanos   int64
+	MaxNanos   int64
+}
+
+// Avg returns the mean call duration, or 0 before the first call.
+func (s AIStats) Avg() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return time.Duration(s.TotalNanos / s.Calls)
+}
+
+type aiLatency struct {
+	calls      atomic.Int64
+	errors     atomic.Int64
+	totalNanos atomic.Int64
+	lastNanos  atomic.Int64
+	minNanos   atomic.Int64
+	maxNanos   atomic.Int64
+}
+
+func (l *aiLatency) observe(d time.Duration, failed bool) {
+	n := d.Nanoseconds()
+	l.calls.Add(1)
+	if failed {
+		l.errors.Add(1)
+	}
+	l.totalNanos.Add(n)
+	l.lastNanos.Store(n)
+	for {
+		cur := l.minNanos.Load()
+		if (cur != 0 && cur <= n) || l.minNanos.CompareAndSwap(cur, n) {
+			break
+		}
+	}
+	for {
+		cur := l.maxNanos.Load()
+		if cur >= n || l.maxNanos.CompareAndSwap(cur, n) {
+			break
+		}
+	}
+}
+
+// AIStats returns a snapshot of analyzer call latency.
+func (c *Core) AIStats() AIStats {
+	return AIStats{
+		Calls:      c.aiLatency.calls.Load(),
+		Errors:     c.aiLatency.errors.Load(),
+		TotalNanos: c.aiLatency.totalNanos.Load(),
+		LastNanos:  c.aiLatency.lastNanos.Load(),
+		MinNanos:   c.aiLatency.minNanos.Load(),
+		MaxNanos:   c.aiLatency.maxNanos.Load(),
+	}
+}
+
+// timeAI records one analyzer call that started at start.
+func (c *Core) timeAI(start time.Time, messages int, err error) {
+	d := time.Since(start)
+	c.aiLatency.observe(d, err != nil)
+	if c.logger != nil {
+		c.logger.Debug("ai call", map[string]any{"duration": d.String(), "messages": messages, "failed": err != nil})
+	}
+}
+
+// RuntimeStats returns a snapshot of AI and cache counters.
+func (c *Core) RuntimeStats() RuntimeStats {
+	return RuntimeStats{
+		AICalls:     c.counters.aiCalls.Load(),
+		AIErrors:    c.counters.aiErrors.Load(),
+		CacheHits:   c.counters.cacheHits.Load(),
+		CacheMisses: c.counters.cacheMisses.Load(),
+		Deferred:    c.counters.deferred.Load(),
+	}
+}
+
+// EngineStats returns the trigger engine's lookup and reload counters.
+func (c *Core) EngineStats() engine.Stats {
+	return c.engine.Stats()
+}
+
+// ResetEngineStats zeroes the engine's lookup and reload counters, e.g. at the
+// start of a reporting interval. Engines without ResetStats are left as is.
+func (c *Core) ResetEngineStats() {
+	if r, ok := c.engine.(statsResetter); ok {
+		r.ResetStats()
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+type delayedAI struct {
+	delay time.Duration
+	err   error
+}
+
+func (delayedAI) Name() string { return "delayed" }
+
+func (a delayedAI) Analyze(ctx context.Context, m models.Message) (models.AIResult, error) {
+	time.Sleep(a.delay)
+	if a.err != nil {
+		return models.AIResult{}, a.err
+	}
+	return models.AIResult{MessageID: m.ID, StatusCode: models.StatusClean, Confidence: 0.9}, nil
+}
+
+func TestAIStatsRecordsLatency(t *testing.T) {
+	logger := &testLogger{}
+	c := New(Options{AIAnalyzer: delayedAI{delay: 5 * time.Millisecond}, Storage: newMockStorage("bad"), Logger: logger})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	if s := c.AIStats(); s.Calls != 0 || s.Avg() != 0 {
+		t.Fatalf("expected empty stats: %+v", s)
+	}
+	// Single-message analyzers are called once per message.
+	if _, err := c.ProcessBatch(context.Background(), []models.Message{{ID: 1, Data: "bad a"}, {ID: 2, Data: "bad b"}}); err != nil {
+		t.Fatal(err)
+	}
+	s := c.AIStats()
+	if s.Calls != 2 || s.Errors != 0 {
+		t.Fatalf("unexpected counts: %+v", s)
+	}
+	if s.LastNanos < int64(5*time.Millisecond) || s.MinNanos <= 0 || s.MaxNanos < s.MinNanos || s.TotalNanos < s.MaxNanos {
+		t.Fatalf("unexpected durations: %+v", s)
+	}
+	if s.Avg() < 5*time.Millisecond {
+		t.Fatalf("unexpected average: %v", s.Avg())
+	}
+	if logger.debugged.Load() != 2 {
+		t.Fatalf("expected a debug entry per call, got %d", logger.debugged.Load())
+	}
+}
+
+func TestAIStatsCountsErrors(t *testing.T) {
+	c := New(Options{AIAnalyzer: delayedAI{delay: time.Millisecond, err: errors.New("down")}, Storage: newMockStorage("bad")})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 1, Data: "bad"}); err == nil {
+		t.Fatalf("expected AI error")
+	}
+	if s := c.AIStats(); s.Calls != 1 || s.Errors != 1 || s.LastNanos <= 0 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,30 @@
+package core
+
+import "time"
+
+// LastSync returns the time of the last successful SyncOnce, or zero if none.
+func (c *Core) LastSync() time.Time {
+	n := c.lastSync.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// TokensStale reports whether the token set is older than Options.StaleAfter.
+// A core that never synced is stale. Always false when StaleAfter is unset.
+func (c *Core) TokensStale() bool {
+	if c.staleAfter <= 0 {
+		return false
+	}
+	last := c.LastSync()
+	stale := last.IsZero() || time.Since(last) > c.staleAfter
+	if stale && c.staleWarned.CompareAndSwap(false, true) {
+		fields := map[string]any{"stale_after": c.staleAfter.String()}
+		if !last.IsZero() {
+			fields["last_sync"] = last.Format(time.RFC3339)
+		}
+		c.logWarn("token set is stale", fields)
+	}
+	return stale
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestStaleTokensFlag(t *testing.T) {
+	st := &flakyStorage{}
+	var events []ViolationEvent
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st, StaleAfter: 20 * time.Millisecond})
+	_ = c.On(EventAllowClean, func(_ context.Context, e ViolationEvent) error {
+		events = append(events, e)
+		return nil
+	})
+
+	if !c.LastSync().IsZero() || !c.TokensStale() {
+		t.Fatal("a core that never synced must be stale")
+	}
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.LastSync().IsZero() || c.TokensStale() {
+		t.Fatal("expected fresh token set after sync")
+	}
+	res, _ := c.ProcessMessage(context.Background(), models.Message{ID: 1, User: 1, Data: "hello"})
+	if res.StaleTokens {
+		t.Fatal("fresh decision must not be flagged")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := c.SyncOnce(context.Background()); err == nil {
+		t.Fatal("expected failing sync")
+	}
+	res, _ = c.ProcessMessage(context.Background(), models.Message{ID: 2, User: 1, Data: "hello"})
+	if !res.StaleTokens {
+		t.Fatal("expected stale flag after failed syncs")
+	}
+	if len(events) != 2 || events[0].StaleTokens || !events[1].StaleTokens {
+		t.Fatalf("expected stale flag on events, got %+v", events)
+	}
+}
+
+func TestStaleTokensDisabledByDefault(t *testing.T) {
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage()})
+	if c.TokensStale() {
+		t.Fatal("staleness must be opt-in")
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+const (
+	defaultStreamBatch         = 100
+	defaultStreamFlushInterval = 100 * time.Millisecond
+)
+
+// StreamOptions configures ProcessStream.
+type StreamOptions struct {
+	// MaxBatch flushes once this many messages are buffered. Defaults to 100.
+	MaxBatch int
+	// FlushInterval flushes a partial batch this long after its first message
+	// arrived. Defaults to 100ms.
+	FlushInterval time.Duration
+	// Process is passed to ProcessBatchWithOptions for every batch.
+	Process ProcessOptions
+	// OnError is called with a batch that failed; its messages produce no
+	// violations. Nil logs a warning.
+	OnError func(messages []models.Message, err error)
+}
+
+// ProcessStream reads messages from in, processes them in batches bounded by
+// MaxBatch and FlushInterval, and emits their violations in input order. When
+// in is closed the buffered messages are flushed and the output channel is
+// closed; cancelling ctx stops the stream without flushing.
+func (c *Core) ProcessStream(ctx context.Context, in <-chan models.Message, opt StreamOptions) <-chan models.Violation {
+	maxBatch := defaultStreamBatch
+	if opt.MaxBatch > 0 {
+		maxBatch = opt.MaxBatch
+	}
+	interval := defaultStreamFlushInterval
+	if opt.FlushInterval > 0 {
+		interval = opt.FlushInterval
+	}
+	out := make(chan models.Violation, maxBatch)
+	go func() {
+		defer close(out)
+		buf := make([]models.Message, 0, maxBatch)
+		timer := time.NewTimer(interval)
+		timer.Stop()
+		defer timer.Stop()
+
+		// flush processes buf and reports whether the stream should go on.
+		flush := func() bool {
+			timer.Stop()
+			if len(buf) == 0 {
+				return true
+			}
+			batch := buf
+			buf = make([]models.Message, 0, maxBatch)
+			res, err := c.ProcessBatchWithOptions(ctx, batch, opt.Process)
+			if err != nil {
+				if ctx.Err() != nil {
+					return false
+				}
+				if opt.OnError != nil {
+					opt.OnError(batch, err)
+				} else {
+					c.logWarn("stream batch failed", map[string]any{"error": err.Error(), "messages": len(batch)})
+				}
+				return true
+			}
+			for _, v := range res {
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				if len(buf) == 0 {
+					timer.Reset(interval)
+				}
+				buf = append(buf, msg)
+				if len(buf) >= maxBatch && !flush() {
+					return
+				}
+			case <-timer.C:
+				if !flush() {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
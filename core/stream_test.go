@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func newStreamCore(t *testing.T) (*Core, *chunkRecordingAI) {
+	t.Helper()
+	ai := &chunkRecordingAI{mockAI: mockAI{result: models.AIResult{StatusCode: models.StatusSuspicious, Confidence: 0.5}}}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DisableAutoLearn: true})
+	t.Cleanup(func() { _ = c.Close() })
+	_ = c.SyncOnce(context.Background())
+	return c, ai
+}
+
+func streamMsg(id int64) models.Message {
+	return models.Message{ID: id, User: id, Data: fmt.Sprintf("bad %d", id)}
+}
+
+func receive(t *testing.T, out <-chan models.Violation, n int) []int64 {
+	t.Helper()
+	ids := make([]int64, 0, n)
+	for len(ids) < n {
+		select {
+		case v, ok := <-out:
+			if !ok {
+				t.Fatalf("stream closed after %v", ids)
+			}
+			ids = append(ids, v.Message.ID)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out after %v", ids)
+		}
+	}
+	return ids
+}
+
+func TestProcessStreamSizeFlush(t *testing.T) {
+	c, ai := newStreamCore(t)
+	in := make(chan models.Message)
+	out := c.ProcessStream(context.Background(), in, StreamOptions{MaxBatch: 3, FlushInterval: time.Hour})
+
+	go func() {
+		for id := int64(1); id <= 6; id++ {
+			in <- streamMsg(id)
+		}
+	}()
+	if got := receive(t, out, 6); !reflect.DeepEqual(got, []int64{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("unexpected order %v", got)
+	}
+	if got := ai.recorded(); !reflect.DeepEqual(got, [][]int64{{1, 2, 3}, {4, 5, 6}}) {
+		t.Fatalf("expected two full batches, got %v", got)
+	}
+
+	// Closing the input drains a partial batch and closes the output.
+	in <- streamMsg(7)
+	close(in)
+	if got := receive(t, out, 1); got[0] != 7 {
+		t.Fatalf("expected drained message 7, got %v", got)
+	}
+	if _, ok := <-out; ok {
+		t.Fatal("output must be closed after input closes")
+	}
+}
+
+func TestProcessStreamTimeFlush(t *testing.T) {
+	c, ai := newStreamCore(t)
+	in := make(chan models.Message)
+	out := c.ProcessStream(context.Background(), in, StreamOptions{MaxBatch: 100, FlushInterval: 20 * time.Millisecond})
+	defer close(in)
+
+	in <- streamMsg(1)
+	in <- streamMsg(2)
+	if got := receive(t, out, 2); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("unexpected order %v", got)
+	}
+	in <- streamMsg(3)
+	if got := receive(t, out, 1); got[0] != 3 {
+		t.Fatalf("unexpected %v", got)
+	}
+	if got := ai.recorded(); !reflect.DeepEqual(got, [][]int64{{1, 2}, {3}}) {
+		t.Fatalf("expected one flush per interval, got %v", got)
+	}
+}
+
+func TestProcessStreamBatchError(t *testing.T) {
+	ai := &mockAI{err: fmt.Errorf("down")}
+	c := New(Options{AIAnalyzer: ai, Storage: newMockStorage("bad"), DisableAutoLearn: true})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	failed := make(chan []models.Message, 1)
+	in := make(chan models.Message, 2)
+	in <- streamMsg(1)
+	in <- models.Message{ID: 2, User: 2, Data: "hello"}
+	close(in)
+	out := c.ProcessStream(context.Background(), in, StreamOptions{OnError: func(msgs []models.Message, _ error) { failed <- msgs }})
+	for range out {
+		t.Fatal("a failed batch must not emit violations")
+	}
+	if msgs := <-failed; len(msgs) != 2 {
+		t.Fatalf("OnError got %v", msgs)
+	}
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/elum-utils/censor/engine"
+	"github.com/elum-utils/censor/interfaces"
+)
+
+const defaultSyncResumeWindow = 10 * time.Minute
+
+// syncCheckpoint is a streaming reload that stopped part way. Guarded by
+// syncMu.
+type syncCheckpoint struct {
+	reload *engine.Reload
+	// cursor is the last token added to reload.
+	cursor  string
+	started time.Time
+}
+
+// resumableReload streams the token set in order into a staged reload. When
+// the read fails, the staged tokens and cursor are kept so the next SyncOnce
+// within the resume window continues after the cursor instead of starting
+// over.
+func (c *Core) resumableReload(ctx context.Context, rs interfaces.ResumableStreamStorage, rl reloader) error {
+	cp := c.checkpoint
+	c.checkpoint = nil
+	if cp == nil || time.Since(cp.started) > c.syncResumeWindow {
+		cp = &syncCheckpoint{reload: rl.BeginReload(), started: time.Now()}
+	}
+	err := rs.GetTokensAfter(ctx, cp.cursor, func(token string) error {
+		if err := cp.reload.Add(ctx, token); err != nil {
+			return err
+		}
+		cp.cursor = token
+		return nil
+	})
+	if err == nil {
+		err = cp.reload.Commit(ctx)
+	}
+	if err != nil {
+		c.checkpoint = cp
+		c.logWarn("token sync interrupted, next sync resumes", map[string]any{"error": err.Error(), "tokens": cp.reload.Len()})
+		return err
+	}
+	return c.reloadMeta(ctx)
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+// flakyStream streams mockStorage tokens in order and fails the first read
+// after failAfter tokens.
+type flakyStream struct {
+	*mockStorage
+	failAfter int
+	afters    []string
+	read      int
+}
+
+func (f *flakyStream) GetTokensFunc(ctx context.Context, fn func(string) error) error {
+	tokens, _ := f.GetTokens(ctx)
+	for _, token := range tokens {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errStreamBroken = errors.New("stream broken")
+
+func (f *flakyStream) GetTokensAfter(ctx context.Context, after string, fn func(string) error) error {
+	f.afters = append(f.afters, after)
+	tokens, _ := f.GetTokens(ctx)
+	sort.Strings(tokens)
+	n := 0
+	for _, token := range tokens {
+		if token <= after {
+			continue
+		}
+		if f.failAfter > 0 && n == f.failAfter {
+			f.failAfter = 0
+			return errStreamBroken
+		}
+		n++
+		f.read++
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSyncResumesFromCheckpoint(t *testing.T) {
+	st := &flakyStream{mockStorage: newMockStorage("a", "b", "c", "d"), failAfter: 2}
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st})
+	c.engine.ReplaceAll([]string{"old"})
+
+	if err := c.SyncOnce(context.Background()); !errors.Is(err, errStreamBroken) {
+		t.Fatalf("expected stream error, got %v", err)
+	}
+	if got := c.engine.FindTriggers("old"); len(got) != 1 {
+		t.Fatal("interrupted sync must keep the current set")
+	}
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.afters) != 2 || st.afters[1] != "b" || st.read != 4 {
+		t.Fatalf("expected resume after b reading each token once, got afters=%q read=%d", st.afters, st.read)
+	}
+	if c.engine.Count() != 4 || len(c.engine.FindTriggers("old")) != 0 || len(c.engine.FindTriggers("a d")) != 2 {
+		t.Fatalf("unexpected engine set after resume: %d tokens", c.engine.Count())
+	}
+
+	// A manual edit discards the checkpoint, so the next sync starts over.
+	st.failAfter = 1
+	_ = c.SyncOnce(context.Background())
+	if err := c.AddToken(context.Background(), "e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if last := st.afters[len(st.afters)-1]; last != "" || c.engine.Count() != 5 {
+		t.Fatalf("expected a full reload after the edit, got after=%q count=%d", last, c.engine.Count())
+	}
+}
+
+func TestSyncResumeDisabled(t *testing.T) {
+	st := &flakyStream{mockStorage: newMockStorage("a", "b")}
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: st, SyncResumeWindow: -1})
+	if err := c.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.afters) != 0 || c.engine.Count() != 2 {
+		t.Fatalf("expected a plain streaming reload, got afters=%q count=%d", st.afters, c.engine.Count())
+	}
+}
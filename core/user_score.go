@@ -0,0 +1,168 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+// UserScoring configures per-user risk scores: every recorded decision adds
+// its status weight to the violator's score, which halves every HalfLife.
+type UserScoring struct {
+	// HalfLife is how long a score takes to halve. Zero disables scoring.
+	HalfLife time.Duration
+	// Weights are the points added per decision by status. Statuses without a
+	// weight add nothing. Nil means 2: 1, 4: 3, 5: 5, 6: 10.
+	Weights map[models.StatusCode]float64
+	// MaxUsers caps how many users are tracked; the lowest scores are dropped
+	// first. Defaults to 100000.
+	MaxUsers int
+}
+
+// UserScore is a user's current decayed risk score.
+type UserScore struct {
+	UserID int64
+	Score  float64
+}
+
+const defaultMaxScoredUsers = 100000
+
+var defaultScoreWeights = map[models.StatusCode]float64{
+	models.StatusNonCriticalAbuse:      1,
+	models.StatusSuspicious:            3,
+	models.StatusCommercialOffPlatform: 5,
+	models.StatusDangerousIllegal:      10,
+}
+
+type userScores struct {
+	mu       sync.Mutex
+	halfLife time.Duration
+	weights  map[models.StatusCode]float64
+	maxUsers int
+	users    map[int64]scoreEntry
+	now      func() time.Time
+}
+
+type scoreEntry struct {
+	score float64
+	at    time.Time
+}
+
+func newUserScores(opt UserScoring) *userScores {
+	if opt.HalfLife <= 0 {
+		return nil
+	}
+	s := &userScores{
+		halfLife: opt.HalfLife,
+		weights:  defaultScoreWeights,
+		maxUsers: defaultMaxScoredUsers,
+		users:    make(map[int64]scoreEntry),
+		now:      time.Now,
+	}
+	if opt.Weights != nil {
+		s.weights = make(map[models.StatusCode]float64, len(opt.Weights))
+		for code, w := range opt.Weights {
+			s.weights[code] = w
+		}
+	}
+	if opt.MaxUsers > 0 {
+		s.maxUsers = opt.MaxUsers
+	}
+	return s
+}
+
+// decayed returns e's score as of now.
+func (s *userScores) decayed(e scoreEntry, now time.Time) float64 {
+	elapsed := now.Sub(e.at)
+	if elapsed <= 0 {
+		return e.score
+	}
+	return e.score * math.Exp2(-float64(elapsed)/float64(s.halfLife))
+}
+
+func (s *userScores) add(user int64, code models.StatusCode) {
+	w := s.weights[code]
+	if w == 0 || user == 0 {
+		return
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.users[user]
+	if !ok && len(s.users) >= s.maxUsers {
+		s.evictLocked(now)
+	}
+	s.users[user] = scoreEntry{score: s.decayed(e, now) + w, at: now}
+}
+
+// evictLocked drops the lowest tenth of scores to make room.
+func (s *userScores) evictLocked(now time.Time) {
+	all := s.snapshotLocked(now)
+	drop := len(all) - s.maxUsers*9/10
+	for i := len(all) - 1; i >= 0 && drop > 0; i, drop = i-1, drop-1 {
+		delete(s.users, all[i].UserID)
+	}
+}
+
+// snapshotLocked returns every score, highest first.
+func (s *userScores) snapshotLocked(now time.Time) []UserScore {
+	out := make([]UserScore, 0, len(s.users))
+	for user, e := range s.users {
+		out = append(out, UserScore{UserID: user, Score: s.decayed(e, now)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].UserID < out[j].UserID
+	})
+	return out
+}
+
+// scoreDecision adds a recorded decision to the violator's score. Zero
+// confidence placeholders (timeouts, deferred messages) do not count.
+func (c *Core) scoreDecision(v models.Violation, code models.StatusCode) {
+	if c.userScores == nil || v.Deferred || v.AIResult.Confidence <= 0 {
+		return
+	}
+	user := v.AIResult.ViolatorUserID
+	if user == 0 {
+		user = v.Message.User
+	}
+	c.userScores.add(user, code)
+}
+
+// UserScore returns the user's current risk score, or 0 when scoring is off
+// or the user has none.
+func (c *Core) UserScore(userID int64) float64 {
+	s := c.userScores
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.users[userID]
+	if !ok {
+		return 0
+	}
+	return s.decayed(e, s.now())
+}
+
+// TopOffenders returns up to n users with the highest current scores, highest
+// first.
+func (c *Core) TopOffenders(n int) []UserScore {
+	s := c.userScores
+	if s == nil || n <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	all := s.snapshotLocked(s.now())
+	s.mu.Unlock()
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/elum-utils/censor/models"
+)
+
+func TestUserScoreRisesAndDecays(t *testing.T) {
+	ai := &mockAI{result: models.AIResult{StatusCode: models.StatusCommercialOffPlatform, Confidence: 0.9}}
+	c := New(Options{
+		AIAnalyzer:       ai,
+		Storage:          newMockStorage("sell"),
+		DisableAutoLearn: true,
+		UserScoring:      UserScoring{HalfLife: time.Hour},
+	})
+	defer c.Close()
+	_ = c.SyncOnce(context.Background())
+
+	now := time.Unix(1_700_000_000, 0)
+	c.userScores.now = func() time.Time { return now }
+
+	for i := 1; i <= 3; i++ {
+		msg := models.Message{ID: int64(i), User: 7, Data: fmt.Sprintf("sell %d", i)}
+		if _, err := c.ProcessMessage(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := c.UserScore(7), 5*float64(i); got != want {
+			t.Fatalf("score after %d violations = %v, want %v", i, got, want)
+		}
+	}
+	// Clean messages add nothing.
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 9, User: 8, Data: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.UserScore(8); got != 0 {
+		t.Fatalf("clean user score = %v", got)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if got := c.UserScore(7); math.Abs(got-15.0/4) > 1e-9 {
+		t.Fatalf("score after two half-lives = %v, want 3.75", got)
+	}
+
+	ai.result.StatusCode = models.StatusNonCriticalAbuse
+	if _, err := c.ProcessMessage(context.Background(), models.Message{ID: 10, User: 9, Data: "sell 10"}); err != nil {
+		t.Fatal(err)
+	}
+	top := c.TopOffenders(5)
+	if len(top) != 2 || top[0].UserID != 7 || top[1].UserID != 9 || top[1].Score != 1 {
+		t.Fatalf("unexpected top offenders %+v", top)
+	}
+	if top := c.TopOffenders(1); len(top) != 1 || top[0].UserID != 7 {
+		t.Fatalf("TopOffenders(1) = %+v", top)
+	}
+}
+
+func TestUserScoresEvictLowest(t *testing.T) {
+	s := newUserScores(UserScoring{HalfLife: time.Hour, MaxUsers: 10, Weights: map[models.StatusCode]float64{models.StatusCritical: 1}})
+	for user := int64(1); user <= 10; user++ {
+		for i := int64(0); i < user; i++ {
+			s.add(user, models.StatusCritical)
+		}
+	}
+	s.add(11, models.StatusCritical)
+	if len(s.users) > 10 {
+		t.Fatalf("tracked %d users, cap is 10", len(s.users))
+	}
+	if _, ok := s.users[1]; ok {
+		t.Fatal("lowest score must be evicted first")
+	}
+	if _, ok := s.users[10]; !ok {
+		t.Fatal("highest score must be kept")
+	}
+}
+
+func TestUserScoringDisabled(t *testing.T) {
+	c := New(Options{AIAnalyzer: &mockAI{}, Storage: newMockStorage()})
+	defer c.Close()
+	if c.UserScore(1) != 0 || c.TopOffenders(3) != nil {
+		t.Fatal("scoring must be off without a half-life")
+	}
+}
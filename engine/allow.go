@@ -0,0 +1,53 @@
+package engine
+
+// AddAllow registers a safe phrase: a trigger found only inside an occurrence
+// of the phrase is suppressed, so "class" can hide an "ass" match while
+// "you ass" still fires. Allow phrases match on word boundaries and survive
+// ReplaceAll.
+func (e *Engine) AddAllow(phrase string) bool {
+	p := e.norm(phrase)
+	if p == "" {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, existing := range e.allow {
+		if existing == p {
+			return false
+		}
+	}
+	e.allow = append(e.allow, p)
+	return true
+}
+
+// RemoveAllow deletes an allow phrase.
+func (e *Engine) RemoveAllow(phrase string) bool {
+	p := e.norm(phrase)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list, ok := removeString(e.allow, p)
+	e.allow = list
+	return ok
+}
+
+// allowedLocked reports whether m lies entirely inside an allow phrase
+// occurrence. occ caches allow occurrences for one message. Caller holds e.mu.
+func (e *Engine) allowedLocked(lower string, m Match, occ *[]span) bool {
+	if *occ == nil {
+		found := make([]span, 0, 2)
+		for _, p := range e.allow {
+			for _, at := range indexAll(lower, p) {
+				if atWordBoundary(lower, at, at+len(p)) {
+					found = append(found, span{at, at + len(p)})
+				}
+			}
+		}
+		*occ = found
+	}
+	for _, a := range *occ {
+		if a.start <= m.Start && m.End <= a.end {
+			return true
+		}
+	}
+	return false
+}
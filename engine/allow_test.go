@@ -0,0 +1,41 @@
+package engine
+
+import "testing"
+
+func TestAllowSuppressesSubstringMatches(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"sex shop", "cheap"})
+	if err := e.AddPattern("ass", `ass`); err != nil {
+		t.Fatal(err)
+	}
+	if !e.AddAllow("class") || !e.AddAllow("Unisex Shopping") || e.AddAllow("class") {
+		t.Fatal("unexpected add result")
+	}
+
+	if got := e.FindTriggers("first class seats"); len(got) != 0 {
+		t.Fatalf("match inside allow phrase must be suppressed, got %v", got)
+	}
+	if got := e.FindTriggers("unisex shopping, cheap"); len(got) != 1 || got[0] != "cheap" {
+		t.Fatalf("only the allowlisted span is suppressed, got %v", got)
+	}
+	if got := e.FindTriggers("you ass"); len(got) != 1 || got[0] != "ass" {
+		t.Fatalf("standalone match must fire, got %v", got)
+	}
+	if got := e.FindTriggers("class act, you ass"); len(got) != 1 {
+		t.Fatalf("occurrence outside allow phrase must fire, got %v", got)
+	}
+	if got := e.Stats().AllowPhrases; got != 2 {
+		t.Fatalf("expected 2 allow phrases in stats, got %d", got)
+	}
+
+	e.ReplaceAll([]string{"sex shop"})
+	if got := e.FindTriggers("unisex shopping"); len(got) != 0 {
+		t.Fatalf("allow phrases must survive ReplaceAll, got %v", got)
+	}
+	if !e.RemoveAllow("unisex shopping") || e.RemoveAllow("unisex shopping") {
+		t.Fatal("unexpected remove result")
+	}
+	if got := e.FindTriggers("unisex shopping"); len(got) != 1 {
+		t.Fatalf("expected match after allow removal, got %v", got)
+	}
+}
@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultAutomatonThreshold is the literal token count above which the engine
+// matches through an Aho-Corasick automaton instead of per-phrase scans.
+const defaultAutomatonThreshold = 2048
+
+// automaton is an Aho-Corasick matcher over the bytes of literal tokens. It is
+// immutable once built and is swapped together with the state it indexes.
+type automaton struct {
+	nodes  []acNode
+	tokens []string
+	// bound is how each token must be delimited to match.
+	bound []acBound
+}
+
+// acBound is the boundary rule of an automaton token.
+type acBound uint8
+
+const (
+	// boundNone matches anywhere: phrases.
+	boundNone acBound = iota
+	// boundWord matches whole word spans: single words, and phrases under
+	// Options.WholeWordPhrases.
+	boundWord
+	// boundScript matches unspaced-script words under Options.UnspacedScripts.
+	boundScript
+)
+
+type acEdge struct {
+	b    byte
+	next int32
+}
+
+type acNode struct {
+	edges []acEdge // sorted by b
+	fail  int32
+	// dict is the nearest terminal node on the fail chain, or -1.
+	dict int32
+	// term is the token ending at this node, or -1.
+	term int32
+}
+
+func (n *acNode) child(b byte) int32 {
+	i := sort.Search(len(n.edges), func(i int) bool { return n.edges[i].b >= b })
+	if i < len(n.edges) && n.edges[i].b == b {
+		return n.edges[i].next
+	}
+	return -1
+}
+
+// matchableLiteral reports how a literal token is matched: phrases (with a
+// space) match anywhere, words only as whole word spans. Tokens that the word
+// splitter can never produce are not matchable.
+func matchableLiteral(token string) (word, ok bool) {
+	hasSpace := false
+	allWord := true
+	for _, r := range token {
+		if r == ' ' {
+			hasSpace = true
+		} else if !isWordRune(r) {
+			allWord = false
+		}
+	}
+	if hasSpace {
+		return false, true
+	}
+	return true, allWord
+}
+
+// buildAutomaton indexes the literal tokens of st under the engine's boundary
+// options. Regex and wildcard tokens are left to the pattern pass.
+func (e *Engine) buildAutomaton(st *state) *automaton {
+	skip := make(map[string]struct{}, len(st.patterns))
+	for _, p := range st.patterns {
+		skip[p.key] = struct{}{}
+	}
+	a := &automaton{nodes: []acNode{{fail: 0, dict: -1, term: -1}}}
+	for tok := range st.tokens {
+		if _, ok := skip[tok]; ok {
+			continue
+		}
+		if e.tokenMatchMode == Substring && !strings.ContainsRune(tok, ' ') {
+			a.insert(tok, boundNone)
+			continue
+		}
+		if _, ok := st.unspaced[tok]; ok && e.unspacedScripts {
+			a.insert(tok, boundScript)
+			continue
+		}
+		word, ok := matchableLiteral(tok)
+		switch {
+		case !ok:
+			continue
+		case word || e.wholeWordPhrases:
+			a.insert(tok, boundWord)
+		default:
+			a.insert(tok, boundNone)
+		}
+	}
+	a.link()
+	return a
+}
+
+func (a *automaton) insert(token string, bound acBound) {
+	cur := int32(0)
+	for i := 0; i < len(token); i++ {
+		b := token[i]
+		next := a.nodes[cur].child(b)
+		if next < 0 {
+			next = int32(len(a.nodes))
+			a.nodes = append(a.nodes, acNode{dict: -1, term: -1})
+			n := &a.nodes[cur]
+			at := sort.Search(len(n.edges), func(i int) bool { return n.edges[i].b >= b })
+			n.edges = append(n.edges, acEdge{})
+			copy(n.edges[at+1:], n.edges[at:])
+			n.edges[at] = acEdge{b: b, next: next}
+		}
+		cur = next
+	}
+	a.nodes[cur].term = int32(len(a.tokens))
+	a.tokens = append(a.tokens, token)
+	a.bound = append(a.bound, bound)
+}
+
+// link computes fail and dictionary links breadth-first.
+func (a *automaton) link() {
+	queue := make([]int32, 0, len(a.nodes))
+	for _, e := range a.nodes[0].edges {
+		a.nodes[e.next].fail = 0
+		queue = append(queue, e.next)
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, e := range a.nodes[u].edges {
+			v := e.next
+			f := a.nodes[u].fail
+			for f != 0 && a.nodes[f].child(e.b) < 0 {
+				f = a.nodes[f].fail
+			}
+			if c := a.nodes[f].child(e.b); c >= 0 && c != v {
+				a.nodes[v].fail = c
+			} else {
+				a.nodes[v].fail = 0
+			}
+			fv := a.nodes[v].fail
+			if a.nodes[fv].term >= 0 {
+				a.nodes[v].dict = fv
+			} else {
+				a.nodes[v].dict = a.nodes[fv].dict
+			}
+			queue = append(queue, v)
+		}
+	}
+}
+
+// scan reports every token occurrence in s in one pass, subject to each
+// token's boundary rule.
+func (a *automaton) scan(s string, emit func(token string, start, end int)) {
+	cur := int32(0)
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		for cur != 0 && a.nodes[cur].child(b) < 0 {
+			cur = a.nodes[cur].fail
+		}
+		if next := a.nodes[cur].child(b); next >= 0 {
+			cur = next
+		}
+		for n := cur; n > 0; n = a.nodes[n].dict {
+			if t := a.nodes[n].term; t >= 0 {
+				end := i + 1
+				start := end - len(a.tokens[t])
+				if a.bounded(t, s, start, end) {
+					emit(a.tokens[t], start, end)
+				}
+			}
+		}
+	}
+}
+
+func (a *automaton) bounded(t int32, s string, start, end int) bool {
+	switch a.bound[t] {
+	case boundWord:
+		return atWordBoundary(s, start, end)
+	case boundScript:
+		return atScriptBoundary(s, start, end)
+	}
+	return true
+}
+
+// invalidateAutomatonLocked drops the automaton after an incremental change
+// and schedules a background rebuild. Until it lands, lookups use the scan
+// path, so results stay correct. Caller holds the write lock.
+func (e *Engine) invalidateAutomatonLocked() {
+	e.state.ac = nil
+	e.state.gen++
+	if !e.wantsAutomatonLocked(&e.state) {
+		return
+	}
+	if e.rebuilding.CompareAndSwap(false, true) {
+		go e.rebuildAutomaton()
+	}
+}
+
+func (e *Engine) wantsAutomatonLocked(st *state) bool {
+	return e.automatonThreshold >= 0 && e.tokenizer == nil && len(st.tokens)-len(st.patterns) > e.automatonThreshold
+}
+
+func (e *Engine) rebuildAutomaton() {
+	for {
+		e.buildAutomatonUntilStable()
+		e.rebuilding.Store(false)
+		// A change may land after the swap but before the flag clears.
+		e.mu.RLock()
+		again := e.state.ac == nil && e.wantsAutomatonLocked(&e.state)
+		e.mu.RUnlock()
+		if !again || !e.rebuilding.CompareAndSwap(false, true) {
+			return
+		}
+	}
+}
+
+func (e *Engine) buildAutomatonUntilStable() {
+	for {
+		e.mu.RLock()
+		gen := e.state.gen
+		if e.state.ac != nil || !e.wantsAutomatonLocked(&e.state) {
+			e.mu.RUnlock()
+			return
+		}
+		ac := e.buildAutomaton(&e.state)
+		e.mu.RUnlock()
+
+		e.mu.Lock()
+		if e.state.gen == gen {
+			e.state.ac = ac
+			e.mu.Unlock()
+			return
+		}
+		e.mu.Unlock()
+	}
+}
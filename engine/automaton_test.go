@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sortedTriggers(e *Engine, msg string) []string {
+	got := e.FindTriggers(msg)
+	sort.Strings(got)
+	return got
+}
+
+func TestAutomatonMatchesScanPath(t *testing.T) {
+	tokens := []string{"buy", "buy now", "now", "спам", "free money", "a-b", "he", "she", "hers", "re:\\d{3}"}
+	scan := NewWithOptions(Options{AutomatonThreshold: -1})
+	ac := NewWithOptions(Options{AutomatonThreshold: 1})
+	scan.ReplaceAll(tokens)
+	ac.ReplaceAll(tokens)
+	if ac.state.ac == nil || scan.state.ac != nil {
+		t.Fatal("unexpected automaton state")
+	}
+
+	words := []string{"buy", "now", "buyer", "спам", "спамер", "free", "money", "a-b", "ushers", "she", "123", ",", "!"}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		parts := make([]string, 1+rng.Intn(6))
+		for j := range parts {
+			parts[j] = words[rng.Intn(len(words))]
+		}
+		msg := strings.Join(parts, " ")
+		if want, got := sortedTriggers(scan, msg), sortedTriggers(ac, msg); fmt.Sprint(want) != fmt.Sprint(got) {
+			t.Fatalf("%q: scan=%v automaton=%v", msg, want, got)
+		}
+	}
+}
+
+func TestAutomatonRebuiltAfterIncrementalChanges(t *testing.T) {
+	e := NewWithOptions(Options{AutomatonThreshold: 2})
+	e.ReplaceAll([]string{"one", "two", "three"})
+	if e.state.ac == nil {
+		t.Fatal("expected automaton after ReplaceAll")
+	}
+	e.AddToken("four five")
+	if got := e.FindTriggers("four five"); len(got) != 1 {
+		t.Fatalf("new token must match before the rebuild lands, got %v", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		e.mu.RLock()
+		ready := e.state.ac != nil
+		e.mu.RUnlock()
+		if ready {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("automaton was not rebuilt")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	e.RemoveToken("one")
+	if got := e.FindTriggers("one four five"); len(got) != 1 || got[0] != "four five" {
+		t.Fatalf("unexpected triggers after remove: %v", got)
+	}
+}
+
+func TestAutomatonConcurrentRebuild(t *testing.T) {
+	e := NewWithOptions(Options{AutomatonThreshold: 8})
+	base := make([]string, 0, 64)
+	for i := 0; i < 64; i++ {
+		base = append(base, fmt.Sprintf("tok%d", i))
+	}
+	e.ReplaceAll(base)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if got := e.FindTriggers("tok1 and tok2"); len(got) != 2 {
+					t.Errorf("unexpected triggers during rebuild: %v", got)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		e.AddToken(fmt.Sprintf("extra%d", i))
+		if i%50 == 0 {
+			e.ReplaceAll(base)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func benchTokens(n int) []string {
+	rng := rand.New(rand.NewSource(7))
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	out := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		word := func() string {
+			b := make([]byte, 4+rng.Intn(6))
+			for j := range b {
+				b[j] = letters[rng.Intn(len(letters))]
+			}
+			return string(b)
+		}
+		if i%3 == 0 {
+			out = append(out, word()+" "+word())
+		} else {
+			out = append(out, word())
+		}
+	}
+	return out
+}
+
+func benchmarkFindTriggers(b *testing.B, threshold int) {
+	e := NewWithOptions(Options{AutomatonThreshold: threshold})
+	e.ReplaceAll(benchTokens(80000))
+	msg := strings.Repeat("hello there this is a fairly ordinary message about nothing ", 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.FindTriggers(msg)
+	}
+}
+
+func BenchmarkFindTriggersScan80k(b *testing.B)      { benchmarkFindTriggers(b, -1) }
+func BenchmarkFindTriggersAutomaton80k(b *testing.B) { benchmarkFindTriggers(b, 1) }
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// collapseRuns replaces every run of at least min identical letters with a
+// single letter, so "spaaaam" becomes "spam". Digits and other runes are kept:
+// repeated digits carry meaning in prices and phone numbers. s is returned
+// unchanged, without allocating, when it has no such run.
+func collapseRuns(s string, min int) string {
+	if min < 2 || !hasRun(s, min) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	var prev rune = -1
+	run := 0
+	flush := func() {
+		if prev < 0 {
+			return
+		}
+		n := run
+		if run >= min && unicode.IsLetter(prev) {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			b.WriteRune(prev)
+		}
+	}
+	for _, r := range s {
+		if r == prev {
+			run++
+			continue
+		}
+		flush()
+		prev, run = r, 1
+	}
+	flush()
+	return b.String()
+}
+
+func hasRun(s string, min int) bool {
+	var prev rune = -1
+	run := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if r == prev {
+			run++
+			if run >= min && unicode.IsLetter(r) {
+				return true
+			}
+			continue
+		}
+		prev, run = r, 1
+	}
+	return false
+}
+
+func (e *Engine) collapse(s string) string {
+	return collapseRuns(s, e.collapseRepeats)
+}
+
+// normalize applies the configured Normalizer, or lowercases and trims.
+func (e *Engine) normalize(s string) string {
+	if e.normalizer != nil {
+		return e.normalizer(s)
+	}
+	return normalizeToken(s)
+}
+
+// norm normalizes a literal token, phrase or message for lookup.
+func (e *Engine) norm(s string) string {
+	return e.collapse(e.normalize(s))
+}
+
+// foldMessage prepares a message for matching. Without a Normalizer it is
+// only lowercased, so match offsets keep referring to the original text.
+func (e *Engine) foldMessage(message string) string {
+	if e.normalizer != nil {
+		return e.collapse(e.normalizer(message))
+	}
+	return e.collapse(strings.ToLower(message))
+}
+
+// parse parses a token and applies repeat collapsing to literal keys.
+func (e *Engine) parse(raw string) (parsedToken, bool) {
+	p, ok := parseToken(raw, e.normalize)
+	if !ok || p.pattern != nil {
+		return p, ok
+	}
+	p.key = e.collapse(p.key)
+	return p, true
+}
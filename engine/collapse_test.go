@@ -0,0 +1,50 @@
+package engine
+
+import "testing"
+
+func TestCollapseRuns(t *testing.T) {
+	cases := map[string]string{
+		"spaaaaam":    "spam",
+		"cool":        "cool",
+		"coool":       "col",
+		"ну дааааа":   "ну да",
+		"1000000 руб": "1000000 руб",
+		"!!!!":        "!!!!",
+		"":            "",
+	}
+	for in, want := range cases {
+		if got := collapseRuns(in, 3); got != want {
+			t.Fatalf("collapseRuns(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := collapseRuns("spaaam", 0); got != "spaaam" {
+		t.Fatalf("disabled collapsing must not change input, got %q", got)
+	}
+}
+
+func TestEngineCollapseRepeats(t *testing.T) {
+	e := NewWithOptions(Options{CollapseRepeats: 3})
+	e.ReplaceAll([]string{"spam", "buy now", "cool", "скидка"})
+
+	if got := e.FindTriggers("SPAAAAM here"); len(got) != 1 || got[0] != "spam" {
+		t.Fatalf("expected collapsed word match, got %v", got)
+	}
+	if got := e.FindTriggers("buuuuy nooow"); len(got) != 1 || got[0] != "buy now" {
+		t.Fatalf("expected collapsed phrase match, got %v", got)
+	}
+	if got := e.FindTriggers("so cool"); len(got) != 1 {
+		t.Fatalf("two-letter runs must stay intact, got %v", got)
+	}
+	if got := e.FindTriggers("скиииидка 50000"); len(got) != 1 {
+		t.Fatalf("expected Unicode collapsed match, got %v", got)
+	}
+	if e.AddToken("spaaaam") {
+		t.Fatal("token collapsing to an existing one must be rejected")
+	}
+
+	plain := New()
+	plain.ReplaceAll([]string{"spam"})
+	if got := plain.FindTriggers("spaaam"); len(got) != 0 {
+		t.Fatalf("collapsing must be opt-in, got %v", got)
+	}
+}
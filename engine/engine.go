@@ -1,11 +1,14 @@
 package engine
 
 import (
+	"context"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unicode"
+
+	"github.com/elum-utils/censor/models"
 )
 
 // Stats contains runtime in-memory engine metrics.
@@ -16,18 +19,65 @@ type Stats struct {
 	TotalTokenHits   int64
 	LastReloadNanos  int64
 	TotalReloadCount int64
+	AllowPhrases     int64
+}
+
+// tokenState holds per-token runtime data.
+type tokenState struct {
+	// lastHit is the unix-nano time of the last match, or of insertion if never matched.
+	lastHit atomic.Int64
+}
+
+func newTokenState(now time.Time) *tokenState {
+	ts := &tokenState{}
+	ts.lastHit.Store(now.UnixNano())
+	return ts
 }
 
 type state struct {
-	tokens  map[string]struct{}
-	phrases []string
+	tokens     map[string]*tokenState
+	phrases    []string
+	patterns   []*tokenPattern
+	categories map[string]string
+	// dual holds categorized literals that are also stored plain: the two
+	// forms share one match key but are added, removed and exported apart.
+	dual map[string]struct{}
+	// unspaced holds word tokens in scripts without word spaces; see
+	// Options.UnspacedScripts.
+	unspaced map[string]struct{}
+	// named holds AddPattern triggers. Unlike tokens they survive ReplaceAll.
+	named []*tokenPattern
+	// ac indexes literal tokens when the set is large; nil means scan matching.
+	ac *automaton
+	// gen increments on every change so stale automaton builds are discarded.
+	gen uint64
 }
 
 // Engine stores trigger tokens and executes case-insensitive lookup.
 type Engine struct {
-	mu    sync.RWMutex
-	state state
+	mu        sync.RWMutex
+	state     state
+	negations negationRules
+	allow     []string
+	statuses  map[string]models.StatusCode
+	meta      map[string]models.Token
+
+	automatonThreshold int
+	collapseRepeats    int
+	wholeWordPhrases   bool
+	unspacedScripts    bool
+	tokenMatchMode     MatchMode
+	normalizer         func(string) string
+	tokenizer          func(string) []string
+	maxPatternLength   int
+	rebuilding         atomic.Bool
 
+	// counters is swapped whole by ResetStats, so every lookup lands in exactly
+	// one generation of counters.
+	counters atomic.Pointer[statCounters]
+}
+
+type statCounters struct {
 	lastLookupNanos atomic.Int64
 	totalLookups    atomic.Int64
 	totalTokenHits  atomic.Int64
@@ -35,135 +85,354 @@ type Engine struct {
 	totalReloads    atomic.Int64
 }
 
+func (c *statCounters) lookup(start time.Time, hits int) {
+	if hits > 0 {
+		c.totalTokenHits.Add(int64(hits))
+	}
+	c.lastLookupNanos.Store(time.Since(start).Nanoseconds())
+	c.totalLookups.Add(1)
+}
+
+// MatchMode selects how single-word tokens match message text.
+type MatchMode int
+
+const (
+	// WordExact matches a token only as a whole word: "spam" does not fire in
+	// "spammer".
+	WordExact MatchMode = iota
+	// Substring matches a token anywhere, like a phrase: "spam" fires in
+	// "spammer" and also in unrelated words that contain it.
+	Substring
+)
+
+// Options configures an engine.
+type Options struct {
+	// AutomatonThreshold is the literal token count above which matching uses an
+	// Aho-Corasick automaton: one linear pass per message instead of one scan per
+	// phrase. Zero means 2048; negative disables the automaton.
+	AutomatonThreshold int
+	// CollapseRepeats collapses runs of at least this many identical letters to
+	// one letter in both tokens and messages, so "spaaam" matches "spam". Values
+	// below 2 disable it. Costs one extra pass over each message, plus a copy when
+	// a run is present; match offsets refer to the collapsed text.
+	CollapseRepeats int
+	// MaxPatternLength rejects AddPattern expressions longer than this many
+	// bytes. Zero means no limit.
+	MaxPatternLength int
+	// WholeWordPhrases matches phrases only when bounded by non-word runes on
+	// both ends, so "sex shop" no longer fires inside "unisex shopping".
+	// Single-word tokens always match whole words.
+	WholeWordPhrases bool
+	// UnspacedScripts matches word tokens written in Chinese, Japanese, Thai,
+	// Lao, Khmer or Myanmar script as substrings, since those scripts do not
+	// separate words with spaces and a sentence splits into a single word.
+	// Without a dictionary segmenter this over-matches: a one-character token
+	// also fires inside longer words that contain it, so prefer multi-character
+	// tokens. Each such token costs one substring scan per message when the
+	// automaton is not in use.
+	UnspacedScripts bool
+	// TokenMatchMode selects how single-word tokens match. Defaults to
+	// WordExact. Substring costs one containment scan per token per message
+	// when the automaton is not in use.
+	TokenMatchMode MatchMode
+	// Normalizer maps tokens, phrases and messages to the form they are matched
+	// in, e.g. a Unicode fold that keeps case for product codes. It must be
+	// idempotent. Nil means lowercase and trim. Regex tokens stay
+	// case-insensitive and keep their body as is.
+	Normalizer func(string) string
+	// Tokenizer splits a normalized message into the words single-word
+	// tokens are looked up by, e.g. to keep "@handle" or "user.name" whole.
+	// Nil splits on runs of letters, digits and underscores. Phrase, pattern
+	// and substring matching do not use it. A custom tokenizer disables the
+	// automaton, whose word boundaries are fixed.
+	Tokenizer func(string) []string
+}
+
 // New creates a new engine.
 func New() *Engine {
-	return &Engine{state: state{tokens: make(map[string]struct{})}}
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates a new engine with custom options.
+func NewWithOptions(opt Options) *Engine {
+	e := &Engine{
+		state:              state{tokens: make(map[string]*tokenState)},
+		negations:          negationRules{window: defaultNegationWindow},
+		automatonThreshold: defaultAutomatonThreshold,
+	}
+	e.counters.Store(&statCounters{})
+	if opt.AutomatonThreshold != 0 {
+		e.automatonThreshold = opt.AutomatonThreshold
+	}
+	if opt.MaxPatternLength > 0 {
+		e.maxPatternLength = opt.MaxPatternLength
+	}
+	if opt.CollapseRepeats >= 2 {
+		e.collapseRepeats = opt.CollapseRepeats
+	}
+	e.wholeWordPhrases = opt.WholeWordPhrases
+	e.unspacedScripts = opt.UnspacedScripts
+	e.tokenMatchMode = opt.TokenMatchMode
+	e.normalizer = opt.Normalizer
+	e.tokenizer = opt.Tokenizer
+	return e
 }
 
 func normalizeToken(token string) string {
 	return strings.ToLower(strings.TrimSpace(token))
 }
 
-// AddToken inserts one token.
+// AddToken inserts one token. Special encodings (see RegexPrefix) are parsed;
+// invalid ones are rejected.
 func (e *Engine) AddToken(token string) bool {
-	t := normalizeToken(token)
-	if t == "" {
+	p, ok := e.parse(token)
+	if !ok {
 		return false
 	}
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	if _, exists := e.state.tokens[t]; exists {
-		return false
-	}
-	e.state.tokens[t] = struct{}{}
-	if strings.ContainsRune(t, ' ') {
-		e.state.phrases = append(e.state.phrases, t)
+	if _, exists := e.state.tokens[p.key]; exists {
+		return e.state.addFormLocked(p)
 	}
+	e.state.tokens[p.key] = newTokenState(time.Now())
+	e.state.indexLocked(p)
+	e.invalidateAutomatonLocked()
 	return true
 }
 
 // RemoveToken deletes one token.
 func (e *Engine) RemoveToken(token string) bool {
-	t := normalizeToken(token)
-	if t == "" {
+	p, ok := e.parse(token)
+	if !ok {
 		return false
 	}
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	if _, exists := e.state.tokens[t]; !exists {
+	if _, exists := e.state.tokens[p.key]; !exists {
 		return false
 	}
-	delete(e.state.tokens, t)
-	if strings.ContainsRune(t, ' ') {
-		phrases := e.state.phrases[:0]
-		for _, p := range e.state.phrases {
-			if p != t {
-				phrases = append(phrases, p)
-			}
-		}
-		e.state.phrases = phrases
+	if kept, ok := e.state.removeFormLocked(p); !ok || kept {
+		return ok
 	}
+	delete(e.state.tokens, p.key)
+	e.state.unindexLocked(p)
+	e.invalidateAutomatonLocked()
 	return true
 }
 
+// replaceCheckEvery is how many tokens ReplaceAllContext builds between context checks.
+const replaceCheckEvery = 1024
+
 // ReplaceAll replaces all tokens atomically.
 func (e *Engine) ReplaceAll(tokens []string) {
-	start := time.Now()
-	next := state{tokens: make(map[string]struct{}, len(tokens))}
-	for _, token := range tokens {
-		t := normalizeToken(token)
-		if t == "" {
-			continue
+	_ = e.ReplaceAllContext(context.Background(), tokens)
+}
+
+// ReplaceAllContext replaces all tokens atomically. If ctx is cancelled while the
+// new set is being built, the current set is kept and the context error is returned.
+func (e *Engine) ReplaceAllContext(ctx context.Context, tokens []string) error {
+	return e.replaceAll(ctx, len(tokens), func(add func(token string) error) error {
+		for _, token := range tokens {
+			if err := add(token); err != nil {
+				return err
+			}
 		}
-		if _, exists := next.tokens[t]; exists {
-			continue
+		return nil
+	})
+}
+
+// ReplaceAllFunc replaces all tokens atomically with those fill passes to add,
+// so a large set can be streamed without holding it in a slice. If fill fails
+// or ctx is cancelled, the current set is kept and the error is returned.
+func (e *Engine) ReplaceAllFunc(ctx context.Context, fill func(add func(token string) error) error) error {
+	return e.replaceAll(ctx, 0, fill)
+}
+
+func (e *Engine) replaceAll(ctx context.Context, sizeHint int, fill func(add func(token string) error) error) error {
+	r := e.beginReload(sizeHint)
+	if err := fill(func(token string) error { return r.Add(ctx, token) }); err != nil {
+		return err
+	}
+	return r.Commit(ctx)
+}
+
+// Reload builds a replacement token set that can be filled across several
+// calls, so an interrupted load resumes instead of starting over. The
+// current set stays in use until Commit. A Reload is not safe for concurrent
+// use.
+type Reload struct {
+	e     *Engine
+	next  state
+	start time.Time
+	n     int
+}
+
+// BeginReload starts building a replacement token set.
+func (e *Engine) BeginReload() *Reload {
+	return e.beginReload(0)
+}
+
+func (e *Engine) beginReload(sizeHint int) *Reload {
+	return &Reload{e: e, next: state{tokens: make(map[string]*tokenState, sizeHint)}, start: time.Now()}
+}
+
+// Add adds token to the pending set. It checks ctx every 1024 tokens.
+func (r *Reload) Add(ctx context.Context, token string) error {
+	if r.n%replaceCheckEvery == 0 {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		next.tokens[t] = struct{}{}
-		if strings.ContainsRune(t, ' ') {
-			next.phrases = append(next.phrases, t)
+	}
+	r.n++
+	p, ok := r.e.parse(token)
+	if !ok {
+		return nil
+	}
+	if _, exists := r.next.tokens[p.key]; exists {
+		r.next.addFormLocked(p)
+		return nil
+	}
+	r.next.tokens[p.key] = nil
+	r.next.indexLocked(p)
+	return nil
+}
+
+// Len returns the number of distinct tokens added so far.
+func (r *Reload) Len() int { return len(r.next.tokens) + len(r.next.dual) }
+
+// Commit swaps the pending set in atomically. If ctx is cancelled first the
+// current set is kept and the Reload can still be committed later. A
+// committed Reload must not be used again.
+func (r *Reload) Commit(ctx context.Context) error {
+	e := r.e
+	next := r.next
+	if e.wantsAutomatonLocked(&next) {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		next.ac = e.buildAutomaton(&next)
 	}
 
 	e.mu.Lock()
+	// Keep hit history for tokens that survive the reload.
+	for t := range next.tokens {
+		if ts, ok := e.state.tokens[t]; ok {
+			next.tokens[t] = ts
+		} else {
+			next.tokens[t] = newTokenState(r.start)
+		}
+	}
+	next.gen = e.state.gen + 1
+	next.named = e.state.named
 	e.state = next
 	e.mu.Unlock()
 
-	e.lastReloadNanos.Store(time.Since(start).Nanoseconds())
-	e.totalReloads.Add(1)
+	counters := e.counters.Load()
+	counters.lastReloadNanos.Store(time.Since(r.start).Nanoseconds())
+	counters.totalReloads.Add(1)
+	return nil
 }
 
-// Clear removes all tokens.
+// Clear removes all tokens. Named patterns are kept.
 func (e *Engine) Clear() {
 	e.mu.Lock()
-	e.state = state{tokens: make(map[string]struct{})}
+	e.state = state{tokens: make(map[string]*tokenState), named: e.state.named, gen: e.state.gen + 1}
 	e.mu.Unlock()
 }
 
 // Count returns token count.
 func (e *Engine) Count() int {
 	e.mu.RLock()
-	count := len(e.state.tokens)
+	count := len(e.state.tokens) + len(e.state.dual)
 	e.mu.RUnlock()
 	return count
 }
 
+// Breakdown returns the number of single-word tokens and multi-word phrases.
+// Regex and wildcard tokens are counted by Patterns.
+func (e *Engine) Breakdown() (words, phrases int) {
+	e.mu.RLock()
+	phrases = len(e.state.phrases)
+	words = len(e.state.tokens) - phrases - len(e.state.patterns)
+	e.mu.RUnlock()
+	return words, phrases
+}
+
 // FindTriggers returns unique tokens found in the message.
 func (e *Engine) FindTriggers(message string) []string {
 	start := time.Now()
-	lower := strings.ToLower(message)
+	counters := e.counters.Load()
+	lower := e.foldMessage(message)
 	e.mu.RLock()
-	if len(e.state.tokens) == 0 || lower == "" {
+	if (len(e.state.tokens) == 0 && len(e.state.named) == 0) || lower == "" {
 		e.mu.RUnlock()
-		e.lastLookupNanos.Store(time.Since(start).Nanoseconds())
-		e.totalLookups.Add(1)
+		counters.lookup(start, 0)
 		return nil
 	}
 
 	found := make(map[string]struct{}, 4)
+	if e.hasFiltersLocked() {
+		// Span-aware path: suppression rules need match offsets.
+		spans := e.wordSpans(lower)
+		for _, m := range e.filterLocked(lower, spans, e.matchLocked(lower, spans)) {
+			found[m.Token] = struct{}{}
+		}
+	} else if ac := e.state.ac; ac != nil {
+		// Literal words and phrases in one automaton pass.
+		ac.scan(lower, func(token string, _, _ int) {
+			found[token] = struct{}{}
+		})
+		for _, m := range e.state.patternMatchesLocked(lower, true) {
+			found[m.Token] = struct{}{}
+		}
+	} else {
+		// First pass: single-word tokens.
+		if e.tokenMatchMode == Substring {
+			for _, m := range e.substringMatchesLocked(lower, true) {
+				found[m.Token] = struct{}{}
+			}
+		} else {
+			for _, tok := range e.tokenize(lower) {
+				if _, ok := e.state.tokens[tok]; ok {
+					found[tok] = struct{}{}
+				}
+			}
+		}
 
-	// First pass: word-level exact matches.
-	for _, tok := range splitTokens(lower) {
-		if _, ok := e.state.tokens[tok]; ok {
-			found[tok] = struct{}{}
+		// Second pass: multi-word phrases.
+		for _, phrase := range e.state.phrases {
+			if _, already := found[phrase]; already {
+				continue
+			}
+			if e.containsPhrase(lower, phrase) {
+				found[phrase] = struct{}{}
+			}
 		}
-	}
 
-	// Second pass: multi-word phrases.
-	for _, phrase := range e.state.phrases {
-		if _, already := found[phrase]; already {
-			continue
+		// Unspaced-script words as substrings.
+		if e.unspacedScripts && e.tokenMatchMode != Substring {
+			for _, m := range e.unspacedMatchesLocked(lower, true) {
+				found[m.Token] = struct{}{}
+			}
+		}
+
+		// Third pass: regex and wildcard tokens.
+		for _, m := range e.state.patternMatchesLocked(lower, true) {
+			found[m.Token] = struct{}{}
 		}
-		if strings.Contains(lower, phrase) {
-			found[phrase] = struct{}{}
+	}
+	hitAt := start.UnixNano()
+	for tok := range found {
+		if ts, ok := e.state.tokens[tok]; ok {
+			ts.lastHit.Store(hitAt)
 		}
 	}
 	e.mu.RUnlock()
 
 	if len(found) == 0 {
-		e.lastLookupNanos.Store(time.Since(start).Nanoseconds())
-		e.totalLookups.Add(1)
+		counters.lookup(start, 0)
 		return nil
 	}
 
@@ -172,17 +441,23 @@ func (e *Engine) FindTriggers(message string) []string {
 		out = append(out, token)
 	}
 
-	e.totalTokenHits.Add(int64(len(out)))
-	e.lastLookupNanos.Store(time.Since(start).Nanoseconds())
-	e.totalLookups.Add(1)
+	counters.lookup(start, len(out))
 	return out
 }
 
+// tokenize applies the configured Tokenizer, or splitTokens.
+func (e *Engine) tokenize(s string) []string {
+	if e.tokenizer != nil {
+		return e.tokenizer(s)
+	}
+	return splitTokens(s)
+}
+
 func splitTokens(s string) []string {
 	res := make([]string, 0, 16)
 	start := -1
 	for i, r := range s {
-		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+		if isWordRune(r) {
 			if start == -1 {
 				start = i
 			}
@@ -199,14 +474,76 @@ func splitTokens(s string) []string {
 	return res
 }
 
+// LastHit returns when the token last matched a message. Tokens that never
+// matched report their insertion time. The bool is false for unknown tokens.
+func (e *Engine) LastHit(token string) (time.Time, bool) {
+	p, _ := e.parse(token)
+	e.mu.RLock()
+	ts, ok := e.state.tokens[p.key]
+	e.mu.RUnlock()
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ts.lastHit.Load()), true
+}
+
+// IdleTokens returns tokens that have not matched since cutoff.
+func (e *Engine) IdleTokens(cutoff time.Time) []string {
+	limit := cutoff.UnixNano()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var out []string
+	for t, ts := range e.state.tokens {
+		if ts.lastHit.Load() < limit {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Export returns every token in its storage form, sorted, as one consistent
+// snapshot. Feeding the result to ReplaceAll rebuilds the same set; named
+// patterns are not included.
+func (e *Engine) Export() []string {
+	e.mu.RLock()
+	out := make([]string, 0, len(e.state.tokens)+len(e.state.dual))
+	for t := range e.state.tokens {
+		if category, ok := e.state.categories[t]; ok {
+			if _, plain := e.state.dual[t]; plain {
+				out = append(out, t)
+			}
+			t = EncodeCategory(category, t)
+		}
+		out = append(out, t)
+	}
+	e.mu.RUnlock()
+	sort.Strings(out)
+	return out
+}
+
+func (e *Engine) allowCount() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.allow)
+}
+
 // Stats returns current metrics.
 func (e *Engine) Stats() Stats {
+	counters := e.counters.Load()
 	return Stats{
 		TokenCount:       int64(e.Count()),
-		LastLookupNanos:  e.lastLookupNanos.Load(),
-		TotalLookups:     e.totalLookups.Load(),
-		TotalTokenHits:   e.totalTokenHits.Load(),
-		LastReloadNanos:  e.lastReloadNanos.Load(),
-		TotalReloadCount: e.totalReloads.Load(),
+		LastLookupNanos:  counters.lastLookupNanos.Load(),
+		TotalLookups:     counters.totalLookups.Load(),
+		TotalTokenHits:   counters.totalTokenHits.Load(),
+		LastReloadNanos:  counters.lastReloadNanos.Load(),
+		TotalReloadCount: counters.totalReloads.Load(),
+		AllowPhrases:     int64(e.allowCount()),
 	}
 }
+
+// ResetStats zeroes lookup and reload counters. Tokens are untouched, so
+// TokenCount and AllowPhrases keep their values. A lookup running during the
+// reset is counted entirely before or entirely after it.
+func (e *Engine) ResetStats() {
+	e.counters.Store(&statCounters{})
+}
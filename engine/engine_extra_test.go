@@ -1,6 +1,15 @@
 package engine
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elum-utils/censor/models"
+)
 
 func TestEngineAddRemoveBranchesAndStats(t *testing.T) {
 	e := New()
@@ -36,3 +45,311 @@ func TestFindTriggersEmptyAndNoToken(t *testing.T) {
 		t.Fatalf("expected nil")
 	}
 }
+
+func TestReplaceAllContextCancelledKeepsState(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"old"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := e.ReplaceAllContext(ctx, []string{"new"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if got := e.FindTriggers("old new"); len(got) != 1 || got[0] != "old" {
+		t.Fatalf("expected previous set to be kept, got %v", got)
+	}
+}
+
+func TestEngineBreakdown(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"spam", "buy now", "scam", "click here now"})
+	words, phrases := e.Breakdown()
+	if words != 2 || phrases != 2 {
+		t.Fatalf("unexpected breakdown: words=%d phrases=%d", words, phrases)
+	}
+	e.RemoveToken("buy now")
+	if words, phrases = e.Breakdown(); words != 2 || phrases != 1 {
+		t.Fatalf("unexpected breakdown after remove: words=%d phrases=%d", words, phrases)
+	}
+}
+
+func TestEngineExactStatus(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"scam.example", "bad"})
+	e.SetTokenStatus("SCAM.example", models.StatusCommercialOffPlatform)
+	e.SetTokenStatus("unknown", models.StatusCritical)
+
+	if tok, st, ok := e.ExactStatus("  Scam.Example "); !ok || tok != "scam.example" || st != models.StatusCommercialOffPlatform {
+		t.Fatalf("expected exact status, got %q %d %v", tok, st, ok)
+	}
+	if _, _, ok := e.ExactStatus("visit scam.example now"); ok {
+		t.Fatalf("status applies to whole-message matches only")
+	}
+	if _, _, ok := e.ExactStatus("unknown"); ok {
+		t.Fatalf("status requires a known token")
+	}
+	e.ReplaceAll([]string{"scam.example"})
+	if _, _, ok := e.ExactStatus("scam.example"); !ok {
+		t.Fatalf("statuses must survive ReplaceAll")
+	}
+	e.ReplaceTokenStatuses(nil)
+	if _, _, ok := e.ExactStatus("scam.example"); ok {
+		t.Fatalf("expected statuses cleared")
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"spam", "scam"})
+	e.FindTriggers("spam here")
+	e.FindTriggers("nothing")
+	if st := e.Stats(); st.TotalLookups != 2 || st.TotalTokenHits != 1 || st.TotalReloadCount != 1 {
+		t.Fatalf("unexpected stats before reset: %+v", st)
+	}
+	e.ResetStats()
+	st := e.Stats()
+	if st.TotalLookups != 0 || st.TotalTokenHits != 0 || st.LastLookupNanos != 0 || st.TotalReloadCount != 0 || st.LastReloadNanos != 0 {
+		t.Fatalf("counters must be zero after reset: %+v", st)
+	}
+	if st.TokenCount != 2 {
+		t.Fatalf("token count must survive reset: %+v", st)
+	}
+}
+
+func TestResetStatsConcurrentLookups(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"spam"})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				e.FindTriggers("spam")
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		e.ResetStats()
+		_ = e.Stats()
+	}
+	wg.Wait()
+	e.ResetStats()
+	e.FindTriggers("spam")
+	if st := e.Stats(); st.TotalLookups != 1 || st.TotalTokenHits != 1 {
+		t.Fatalf("unexpected stats after concurrent reset: %+v", st)
+	}
+}
+
+func TestFindTriggersMeta(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"casino", EncodeCategory("drugs", "weed"), "plain"})
+	e.ReplaceTokenMeta([]models.Token{{Value: " Casino ", Category: "gambling", Severity: 3, Source: models.TokenSourceImport}})
+
+	got := map[string]models.Token{}
+	for _, token := range e.FindTriggersMeta("casino weed plain") {
+		got[token.Value] = token
+	}
+	if len(got) != 3 {
+		t.Fatalf("unexpected triggers: %+v", got)
+	}
+	if c := got["casino"]; c.Category != "gambling" || c.Severity != 3 || c.Source != models.TokenSourceImport {
+		t.Fatalf("unexpected casino meta: %+v", c)
+	}
+	if w := got["weed"]; w.Category != "drugs" {
+		t.Fatalf("categorized literal should report its category: %+v", w)
+	}
+	if p := got["plain"]; p != (models.Token{Value: "plain"}) {
+		t.Fatalf("unexpected plain meta: %+v", p)
+	}
+
+	// Metadata survives token reloads.
+	e.ReplaceAll([]string{"casino"})
+	if res := e.FindTriggersMeta("casino"); len(res) != 1 || res[0].Severity != 3 {
+		t.Fatalf("metadata lost on ReplaceAll: %+v", res)
+	}
+	if res := e.FindTriggersMeta("nothing here"); res != nil {
+		t.Fatalf("expected no triggers: %+v", res)
+	}
+}
+
+func TestReplaceAllFunc(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"old"})
+	err := e.ReplaceAllFunc(context.Background(), func(add func(string) error) error {
+		for _, token := range []string{"alpha", "beta gamma", "alpha"} {
+			if err := add(token); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Count() != 2 || len(e.FindTriggers("old")) != 0 || len(e.FindTriggers("x beta gamma")) != 1 {
+		t.Fatalf("unexpected token set, count=%d", e.Count())
+	}
+
+	failed := errors.New("read failed")
+	err = e.ReplaceAllFunc(context.Background(), func(add func(string) error) error {
+		_ = add("delta")
+		return failed
+	})
+	if !errors.Is(err, failed) || e.Count() != 2 || len(e.FindTriggers("delta")) != 0 {
+		t.Fatalf("failed fill must keep current set: err=%v count=%d", err, e.Count())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = e.ReplaceAllFunc(ctx, func(add func(string) error) error { return add("delta") })
+	if !errors.Is(err, context.Canceled) || e.Count() != 2 {
+		t.Fatalf("cancelled fill must keep current set: err=%v", err)
+	}
+}
+
+func TestWholeWordPhrases(t *testing.T) {
+	tokens := []string{"sex shop", "free money", "filler"}
+	for _, tc := range []struct {
+		name      string
+		threshold int
+	}{{"scan", -1}, {"automaton", 1}} {
+		t.Run(tc.name, func(t *testing.T) {
+			loose := NewWithOptions(Options{AutomatonThreshold: tc.threshold})
+			loose.ReplaceAll(tokens)
+			if got := loose.FindTriggers("visit our unisex shop"); len(got) != 1 {
+				t.Fatalf("default mode matches phrases anywhere: %v", got)
+			}
+
+			e := NewWithOptions(Options{AutomatonThreshold: tc.threshold, WholeWordPhrases: true})
+			e.ReplaceAll(tokens)
+			for _, msg := range []string{"visit our unisex shop", "the sex shopping mall", "essex shop"} {
+				if got := e.FindTriggers(msg); len(got) != 0 {
+					t.Fatalf("%q must not match: %v", msg, got)
+				}
+			}
+			if got := e.FindTriggers("the sex shop, downtown"); len(got) != 1 || got[0] != "sex shop" {
+				t.Fatalf("bounded phrase must match: %v", got)
+			}
+			if got := e.FindTriggerMatches("unisex shop and free money"); len(got) != 1 || got[0].Token != "free money" {
+				t.Fatalf("unexpected matches: %+v", got)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"spam", "Buy Now", EncodeCategory("drugs", "weed"), EncodeRegex(`f+ree`)})
+	want := []string{"buy now", "cat:drugs:weed", "re:f+ree", "spam"}
+	if got := e.Export(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Export = %v, want %v", got, want)
+	}
+
+	other := New()
+	other.ReplaceAll(e.Export())
+	if !reflect.DeepEqual(other.Export(), want) {
+		t.Fatalf("round trip = %v", other.Export())
+	}
+	if cat, ok := other.Category("weed"); !ok || cat != "drugs" {
+		t.Fatalf("category lost in round trip: %q %v", cat, ok)
+	}
+}
+
+func TestTokenMatchModeSubstring(t *testing.T) {
+	word := New()
+	word.ReplaceAll([]string{"spam"})
+	if got := word.FindTriggers("no spammer here"); len(got) != 0 {
+		t.Fatalf("WordExact must not match inside words, got %v", got)
+	}
+
+	for name, threshold := range map[string]int{"scan": -1, "automaton": 1} {
+		t.Run(name, func(t *testing.T) {
+			e := NewWithOptions(Options{TokenMatchMode: Substring, AutomatonThreshold: threshold})
+			e.ReplaceAll([]string{"spam", "buy now", EncodeRegex(`fr[e3]{2}`)})
+			if got := e.FindTriggers("no spammer here"); len(got) != 1 || got[0] != "spam" {
+				t.Fatalf("Substring must match inside words, got %v", got)
+			}
+			if got := e.FindTriggers("spam"); len(got) != 1 {
+				t.Fatalf("whole words still match, got %v", got)
+			}
+			if got := e.FindTriggers("re:fr[e3]{2}"); len(got) != 0 {
+				t.Fatalf("pattern keys must not match literally, got %v", got)
+			}
+			matches := e.FindTriggerMatches("antispam, spam")
+			if len(matches) != 2 || matches[0].Start != 4 || matches[1].Start != 10 {
+				t.Fatalf("unexpected matches %+v", matches)
+			}
+		})
+	}
+}
+
+func TestCustomNormalizerPreservesCase(t *testing.T) {
+	e := NewWithOptions(Options{Normalizer: strings.TrimSpace})
+	if !e.AddToken("  SKU42A ") || !e.AddToken("Buy Now") {
+		t.Fatal("add failed")
+	}
+	if e.AddToken("SKU42A") {
+		t.Fatal("normalized duplicate must be rejected")
+	}
+	if !e.AddToken("sku42a") {
+		t.Fatal("case variant is a distinct token")
+	}
+	if got := e.FindTriggers("order SKU42A today"); !reflect.DeepEqual(got, []string{"SKU42A"}) {
+		t.Fatalf("case-sensitive match = %v", got)
+	}
+	if got := e.FindTriggers("please Buy Now"); !reflect.DeepEqual(got, []string{"Buy Now"}) {
+		t.Fatalf("phrase match = %v", got)
+	}
+	if got := e.FindTriggers("please buy now"); len(got) != 0 {
+		t.Fatalf("lowercase phrase must not match: %v", got)
+	}
+	if got := e.NormalizeToken(" SKU42A "); got != "SKU42A" {
+		t.Fatalf("NormalizeToken = %q", got)
+	}
+	if !e.RemoveToken(" SKU42A") || e.RemoveToken("SKU42A") {
+		t.Fatal("remove must use the same normalization")
+	}
+	if got := e.FindTriggers("order SKU42A and sku42a"); !reflect.DeepEqual(got, []string{"sku42a"}) {
+		t.Fatalf("after remove = %v", got)
+	}
+
+	e.ReplaceAll([]string{" Promo ", "promo"})
+	if e.Count() != 2 {
+		t.Fatalf("replace kept %d tokens, want 2", e.Count())
+	}
+	if got := e.FindTriggers("Promo inside"); !reflect.DeepEqual(got, []string{"Promo"}) {
+		t.Fatalf("after replace = %v", got)
+	}
+
+	def := New()
+	def.AddToken(" SKU42A ")
+	if got := def.FindTriggers("order sku42a"); !reflect.DeepEqual(got, []string{"sku42a"}) {
+		t.Fatalf("default must lowercase: %v", got)
+	}
+}
+
+func TestCustomTokenizerKeepsMentions(t *testing.T) {
+	e := NewWithOptions(Options{Tokenizer: strings.Fields})
+	if !e.AddToken("@spammer") || !e.AddToken("buy now") {
+		t.Fatal("add failed")
+	}
+	if got := e.FindTriggers("ping @spammer please"); !reflect.DeepEqual(got, []string{"@spammer"}) {
+		t.Fatalf("mention match = %v", got)
+	}
+	if got := e.FindTriggers("ping @spammers please"); len(got) != 0 {
+		t.Fatalf("mention must match whole: %v", got)
+	}
+	if got := e.FindTriggers("just BUY NOW"); !reflect.DeepEqual(got, []string{"buy now"}) {
+		t.Fatalf("phrase pass = %v", got)
+	}
+	matches := e.FindTriggerMatches("hi @spammer")
+	if len(matches) != 1 || matches[0].Start != 3 || matches[0].End != 11 {
+		t.Fatalf("unexpected matches %+v", matches)
+	}
+
+	def := New()
+	def.AddToken("@spammer")
+	if got := def.FindTriggers("ping @spammer please"); len(got) != 0 {
+		t.Fatalf("default tokenizer splits on @: %v", got)
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestEngineFindTriggersCaseInsensitive(t *testing.T) {
@@ -44,3 +45,35 @@ func TestEngineConcurrentAccess(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestEngineLastHitAndIdleTokens(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"spam", "buy now", "idle"})
+	if _, ok := e.LastHit("missing"); ok {
+		t.Fatalf("unknown token must not report a hit")
+	}
+	added, ok := e.LastHit("spam")
+	if !ok {
+		t.Fatalf("expected insertion time for spam")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	_ = e.FindTriggers("SPAM, buy now!")
+
+	hit, _ := e.LastHit("spam")
+	if !hit.After(added) {
+		t.Fatalf("expected last hit to move forward")
+	}
+	idle := e.IdleTokens(cutoff)
+	if len(idle) != 1 || idle[0] != "idle" {
+		t.Fatalf("unexpected idle tokens: %v", idle)
+	}
+
+	// Reload keeps hit history of surviving tokens.
+	e.ReplaceAll([]string{"spam", "idle"})
+	if again, _ := e.LastHit("spam"); !again.Equal(hit) {
+		t.Fatalf("reload must preserve last hit: %v vs %v", again, hit)
+	}
+}
@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Match is one trigger occurrence in a message. Start and End are byte offsets
+// into the lowercased message text.
+type Match struct {
+	Token string
+	Start int
+	End   int
+}
+
+type span struct{ start, end int }
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// splitSpans returns byte spans of words using the same rules as splitTokens.
+func splitSpans(s string) []span {
+	res := make([]span, 0, 16)
+	start := -1
+	for i, r := range s {
+		if isWordRune(r) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			res = append(res, span{start, i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		res = append(res, span{start, len(s)})
+	}
+	return res
+}
+
+// wordSpans locates the words of the configured Tokenizer in s, in order.
+// Words that do not occur verbatim after the previous one are dropped.
+func (e *Engine) wordSpans(s string) []span {
+	if e.tokenizer == nil {
+		return splitSpans(s)
+	}
+	words := e.tokenizer(s)
+	res := make([]span, 0, len(words))
+	off := 0
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		i := strings.Index(s[off:], w)
+		if i < 0 {
+			continue
+		}
+		res = append(res, span{off + i, off + i + len(w)})
+		off += i + len(w)
+	}
+	return res
+}
+
+// atWordBoundary reports whether s[start:end] is not glued to word runes on either side.
+func atWordBoundary(s string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(s[:start]); isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(s) {
+		if r, _ := utf8.DecodeRuneInString(s[end:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// indexAll returns the start offsets of every occurrence of sub in s.
+func indexAll(s, sub string) []int {
+	var out []int
+	for off := 0; off <= len(s)-len(sub); {
+		i := strings.Index(s[off:], sub)
+		if i < 0 {
+			break
+		}
+		out = append(out, off+i)
+		off += i + 1
+	}
+	return out
+}
+
+// containsPhrase reports whether phrase occurs in lower, on word boundaries
+// under WholeWordPhrases.
+func (e *Engine) containsPhrase(lower, phrase string) bool {
+	if !e.wholeWordPhrases {
+		return strings.Contains(lower, phrase)
+	}
+	for _, at := range indexAll(lower, phrase) {
+		if atWordBoundary(lower, at, at+len(phrase)) {
+			return true
+		}
+	}
+	return false
+}
+
+// substringMatchesLocked returns occurrences of single-word literal tokens
+// anywhere in lower, for Substring mode. Caller holds e.mu.
+func (e *Engine) substringMatchesLocked(lower string, limitOne bool) []Match {
+	var out []Match
+	for tok := range e.state.tokens {
+		if strings.ContainsRune(tok, ' ') || strings.HasPrefix(tok, RegexPrefix) || strings.HasPrefix(tok, WildcardPrefix) {
+			continue
+		}
+		for _, at := range indexAll(lower, tok) {
+			out = append(out, Match{Token: tok, Start: at, End: at + len(tok)})
+			if limitOne {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// FindTriggerMatches returns every trigger occurrence in the message ordered by position.
+// Unlike FindTriggers it does not update lookup stats.
+func (e *Engine) FindTriggerMatches(message string) []Match {
+	lower := e.foldMessage(message)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if (len(e.state.tokens) == 0 && len(e.state.named) == 0) || lower == "" {
+		return nil
+	}
+	spans := e.wordSpans(lower)
+	return e.filterLocked(lower, spans, e.matchLocked(lower, spans))
+}
+
+// matchLocked collects word, phrase and pattern occurrences. Caller holds e.mu.
+func (e *Engine) matchLocked(lower string, spans []span) []Match {
+	var out []Match
+	if ac := e.state.ac; ac != nil {
+		ac.scan(lower, func(token string, start, end int) {
+			out = append(out, Match{Token: token, Start: start, End: end})
+		})
+	} else {
+		if e.tokenMatchMode == Substring {
+			out = append(out, e.substringMatchesLocked(lower, false)...)
+		} else {
+			for _, sp := range spans {
+				word := lower[sp.start:sp.end]
+				if _, ok := e.state.unspaced[word]; ok && e.unspacedScripts {
+					continue // reported by the substring pass
+				}
+				if _, ok := e.state.tokens[word]; ok {
+					out = append(out, Match{Token: word, Start: sp.start, End: sp.end})
+				}
+			}
+		}
+		for _, phrase := range e.state.phrases {
+			for _, at := range indexAll(lower, phrase) {
+				if e.wholeWordPhrases && !atWordBoundary(lower, at, at+len(phrase)) {
+					continue
+				}
+				out = append(out, Match{Token: phrase, Start: at, End: at + len(phrase)})
+			}
+		}
+		if e.unspacedScripts && e.tokenMatchMode != Substring {
+			out = append(out, e.unspacedMatchesLocked(lower, false)...)
+		}
+	}
+	out = append(out, e.state.patternMatchesLocked(lower, false)...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Start != out[j].Start {
+			return out[i].Start < out[j].Start
+		}
+		return out[i].End < out[j].End
+	})
+	return out
+}
+
+// hasFiltersLocked reports whether matches need span-aware post-filtering.
+func (e *Engine) hasFiltersLocked() bool {
+	return !e.negations.empty() || len(e.allow) > 0
+}
+
+// filterLocked drops suppressed matches. Caller holds e.mu.
+func (e *Engine) filterLocked(lower string, spans []span, matches []Match) []Match {
+	if !e.hasFiltersLocked() || len(matches) == 0 {
+		return matches
+	}
+	occ := make(map[string][]span)
+	var allowOcc []span
+	out := matches[:0]
+	for _, m := range matches {
+		if len(e.allow) > 0 && e.allowedLocked(lower, m, &allowOcc) {
+			continue
+		}
+		if e.negatedLocked(lower, spans, m, occ) {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package engine
+
+import "github.com/elum-utils/censor/models"
+
+// SetTokenMeta attaches metadata to token.Value. Metadata does not affect
+// matching and survives ReplaceAll. Empty metadata detaches it.
+func (e *Engine) SetTokenMeta(token models.Token) {
+	t := e.norm(token.Value)
+	if t == "" {
+		return
+	}
+	token.Value = t
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !token.HasMeta() {
+		delete(e.meta, t)
+		return
+	}
+	if e.meta == nil {
+		e.meta = make(map[string]models.Token)
+	}
+	e.meta[t] = token
+}
+
+// ReplaceTokenMeta replaces all token metadata atomically.
+func (e *Engine) ReplaceTokenMeta(tokens []models.Token) {
+	next := make(map[string]models.Token, len(tokens))
+	for _, token := range tokens {
+		if t := e.norm(token.Value); t != "" && token.HasMeta() {
+			token.Value = t
+			next[t] = token
+		}
+	}
+	e.mu.Lock()
+	e.meta = next
+	e.mu.Unlock()
+}
+
+// FindTriggersMeta is FindTriggers with each trigger's metadata. Triggers
+// without metadata carry the category of a categorized literal, if any.
+func (e *Engine) FindTriggersMeta(message string) []models.Token {
+	triggers := e.FindTriggers(message)
+	if len(triggers) == 0 {
+		return nil
+	}
+	out := make([]models.Token, 0, len(triggers))
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, trigger := range triggers {
+		token, ok := e.meta[trigger]
+		if !ok {
+			token = models.Token{Value: trigger, Category: e.state.categories[trigger]}
+		}
+		out = append(out, token)
+	}
+	return out
+}
+
+// Severity returns the severity recorded in token's metadata and whether
+// the token has a non-zero one.
+func (e *Engine) Severity(token string) (int, bool) {
+	t := e.norm(token)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	severity := e.meta[t].Severity
+	return severity, severity != 0
+}
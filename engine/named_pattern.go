@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AddPattern registers a named regular expression trigger, e.g. a phone number
+// or URL matcher. FindTriggers reports a match by the pattern name. Patterns
+// are matched case-insensitively against the lowercased message and survive
+// ReplaceAll. An existing pattern with the same name is replaced.
+//
+// Go regexps run in linear time, so there is no catastrophic backtracking, but
+// long patterns still cost per message; Options.MaxPatternLength rejects them.
+func (e *Engine) AddPattern(name, pattern string) error {
+	p, err := e.compileNamed(name, pattern)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.named = replaceNamed(e.state.named, p)
+	return nil
+}
+
+// RemovePattern deletes a named pattern.
+func (e *Engine) RemovePattern(name string) bool {
+	n := normalizeToken(name)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, p := range e.state.named {
+		if p.key == n {
+			e.state.named = append(e.state.named[:i:i], e.state.named[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ReplacePatterns replaces all named patterns atomically. Nothing changes if
+// any pattern fails to compile.
+func (e *Engine) ReplacePatterns(patterns map[string]string) error {
+	next := make([]*tokenPattern, 0, len(patterns))
+	for name, pattern := range patterns {
+		p, err := e.compileNamed(name, pattern)
+		if err != nil {
+			return err
+		}
+		next = replaceNamed(next, p)
+	}
+	e.mu.Lock()
+	e.state.named = next
+	e.mu.Unlock()
+	return nil
+}
+
+// PatternNames returns the names of registered named patterns.
+func (e *Engine) PatternNames() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]string, 0, len(e.state.named))
+	for _, p := range e.state.named {
+		out = append(out, p.key)
+	}
+	return out
+}
+
+func (e *Engine) compileNamed(name, pattern string) (*tokenPattern, error) {
+	n := normalizeToken(name)
+	if n == "" {
+		return nil, errors.New("engine: pattern name is empty")
+	}
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, fmt.Errorf("engine: pattern %q is empty", n)
+	}
+	if e.maxPatternLength > 0 && len(pattern) > e.maxPatternLength {
+		return nil, fmt.Errorf("engine: pattern %q exceeds %d bytes", n, e.maxPatternLength)
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("engine: compile pattern %q: %w", n, err)
+	}
+	return &tokenPattern{key: n, re: re}, nil
+}
+
+func replaceNamed(list []*tokenPattern, p *tokenPattern) []*tokenPattern {
+	out := make([]*tokenPattern, 0, len(list)+1)
+	for _, existing := range list {
+		if existing.key != p.key {
+			out = append(out, existing)
+		}
+	}
+	return append(out, p)
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNamedPatterns(t *testing.T) {
+	e := New()
+	if err := e.AddPattern("digits", `\d{6,}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddPattern("url", `https?://[^\s]+`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := e.FindTriggers("call 89991234567"); len(got) != 1 || got[0] != "digits" {
+		t.Fatalf("expected digit-run match, got %v", got)
+	}
+	if got := e.FindTriggers("see HTTPS://Example.com/x"); len(got) != 1 || got[0] != "url" {
+		t.Fatalf("expected url match, got %v", got)
+	}
+	if got := e.FindTriggers("price 1500"); len(got) != 0 {
+		t.Fatalf("short digit run must not match, got %v", got)
+	}
+
+	e.ReplaceAll([]string{"scam"})
+	got := e.FindTriggers("scam at http://x.y 1234567")
+	sort.Strings(got)
+	if strings.Join(got, ",") != "digits,scam,url" {
+		t.Fatalf("patterns must survive ReplaceAll, got %v", got)
+	}
+	if e.Count() != 1 {
+		t.Fatalf("named patterns are not tokens, count=%d", e.Count())
+	}
+	if !e.RemovePattern("url") || e.RemovePattern("url") {
+		t.Fatal("unexpected remove result")
+	}
+}
+
+func TestNamedPatternErrors(t *testing.T) {
+	e := NewWithOptions(Options{MaxPatternLength: 10})
+	if err := e.AddPattern("bad", `(`); err == nil {
+		t.Fatal("expected compile error")
+	}
+	if err := e.AddPattern(" ", `\d`); err == nil {
+		t.Fatal("expected empty name error")
+	}
+	if err := e.AddPattern("long", strings.Repeat("a", 11)); err == nil {
+		t.Fatal("expected length limit error")
+	}
+
+	_ = e.AddPattern("keep", `\d+`)
+	if err := e.ReplacePatterns(map[string]string{"ok": `x+`, "broken": `[`}); err == nil {
+		t.Fatal("expected replace error")
+	}
+	if names := e.PatternNames(); len(names) != 1 || names[0] != "keep" {
+		t.Fatalf("failed replace must keep patterns, got %v", names)
+	}
+	if err := e.ReplacePatterns(map[string]string{"ok": `x+`}); err != nil {
+		t.Fatal(err)
+	}
+	if names := e.PatternNames(); len(names) != 1 || names[0] != "ok" {
+		t.Fatalf("unexpected patterns %v", names)
+	}
+}
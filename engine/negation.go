@@ -0,0 +1,138 @@
+package engine
+
+const defaultNegationWindow = 3
+
+// negationRules holds phrases that suppress nearby triggers, e.g. "not" in "not selling".
+type negationRules struct {
+	global  []string
+	byToken map[string][]string
+	window  int
+}
+
+func (n *negationRules) empty() bool {
+	return len(n.global) == 0 && len(n.byToken) == 0
+}
+
+// AddNegation registers a phrase that suppresses every trigger it overlaps or
+// precedes within the negation window. Negations survive ReplaceAll.
+func (e *Engine) AddNegation(phrase string) bool {
+	p := e.norm(phrase)
+	if p == "" {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, g := range e.negations.global {
+		if g == p {
+			return false
+		}
+	}
+	e.negations.global = append(e.negations.global, p)
+	return true
+}
+
+// RemoveNegation deletes a global negation phrase.
+func (e *Engine) RemoveNegation(phrase string) bool {
+	p := e.norm(phrase)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list, ok := removeString(e.negations.global, p)
+	e.negations.global = list
+	return ok
+}
+
+// AddTokenNegation registers a negation phrase that applies to one token only.
+func (e *Engine) AddTokenNegation(token, phrase string) bool {
+	t, p := e.norm(token), e.norm(phrase)
+	if t == "" || p == "" {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, existing := range e.negations.byToken[t] {
+		if existing == p {
+			return false
+		}
+	}
+	if e.negations.byToken == nil {
+		e.negations.byToken = make(map[string][]string)
+	}
+	e.negations.byToken[t] = append(e.negations.byToken[t], p)
+	return true
+}
+
+// RemoveTokenNegation deletes a per-token negation phrase.
+func (e *Engine) RemoveTokenNegation(token, phrase string) bool {
+	t, p := e.norm(token), e.norm(phrase)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list, ok := removeString(e.negations.byToken[t], p)
+	if len(list) == 0 {
+		delete(e.negations.byToken, t)
+	} else {
+		e.negations.byToken[t] = list
+	}
+	return ok
+}
+
+// SetNegationWindow sets how many words may separate a negation from the trigger
+// it suppresses. Defaults to 3.
+func (e *Engine) SetNegationWindow(words int) {
+	if words < 0 {
+		words = 0
+	}
+	e.mu.Lock()
+	e.negations.window = words
+	e.mu.Unlock()
+}
+
+// negatedLocked reports whether m is suppressed by a negation phrase. occ caches
+// negation occurrences per phrase for one message. Caller holds e.mu.
+func (e *Engine) negatedLocked(lower string, spans []span, m Match, occ map[string][]span) bool {
+	window := e.negations.window
+	check := func(phrases []string) bool {
+		for _, p := range phrases {
+			found, ok := occ[p]
+			if !ok {
+				for _, at := range indexAll(lower, p) {
+					if atWordBoundary(lower, at, at+len(p)) {
+						found = append(found, span{at, at + len(p)})
+					}
+				}
+				occ[p] = found
+			}
+			for _, n := range found {
+				if n.start < m.End && m.Start < n.end {
+					return true
+				}
+				if n.end <= m.Start && wordsBetween(spans, n.end, m.Start) <= window {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return check(e.negations.global) || check(e.negations.byToken[m.Token])
+}
+
+func wordsBetween(spans []span, from, to int) int {
+	n := 0
+	for _, sp := range spans {
+		if sp.start >= to {
+			break
+		}
+		if sp.start >= from && sp.end <= to {
+			n++
+		}
+	}
+	return n
+}
+
+func removeString(list []string, s string) ([]string, bool) {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i], list[i+1:]...), true
+		}
+	}
+	return list, false
+}
@@ -0,0 +1,63 @@
+package engine
+
+import "testing"
+
+func TestNegationSuppressesNearbyTrigger(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"selling", "buy"})
+	e.AddNegation("not")
+	e.AddNegation("don't")
+
+	if got := e.FindTriggers("I'm not selling anything"); len(got) != 0 {
+		t.Fatalf("negated trigger must be suppressed, got %v", got)
+	}
+	if got := e.FindTriggers("not really into selling"); len(got) != 0 {
+		t.Fatalf("trigger within window must be suppressed, got %v", got)
+	}
+	if got := e.FindTriggers("not that I care, but I am now selling"); len(got) != 1 {
+		t.Fatalf("trigger outside window must fire, got %v", got)
+	}
+	if got := e.FindTriggers("another selling point"); len(got) != 1 {
+		t.Fatalf("negation must match whole words only, got %v", got)
+	}
+	if got := e.FindTriggers("not selling, but you should buy this"); len(got) != 1 || got[0] != "buy" {
+		t.Fatalf("only the negated trigger must be suppressed, got %v", got)
+	}
+	if got := e.FindTriggers("not selling today, but yesterday I was selling"); len(got) != 1 {
+		t.Fatalf("an un-negated occurrence must still fire, got %v", got)
+	}
+}
+
+func TestTokenNegationAndWindow(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"buy", "sell"})
+	if !e.AddTokenNegation("buy", "never") || e.AddTokenNegation("buy", "never") {
+		t.Fatalf("unexpected add result")
+	}
+	if got := e.FindTriggers("never buy, never sell"); len(got) != 1 || got[0] != "sell" {
+		t.Fatalf("per-token negation must only suppress its token, got %v", got)
+	}
+
+	e.SetNegationWindow(0)
+	if got := e.FindTriggers("never ever buy"); len(got) != 1 {
+		t.Fatalf("window 0 requires adjacency, got %v", got)
+	}
+	if !e.RemoveTokenNegation("buy", "never") {
+		t.Fatalf("expected removal")
+	}
+	if got := e.FindTriggers("never buy"); len(got) != 1 {
+		t.Fatalf("removed negation must not apply, got %v", got)
+	}
+}
+
+func TestFindTriggerMatchesOffsets(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{"spam", "buy now"})
+	got := e.FindTriggerMatches("Spam and BUY NOW")
+	if len(got) != 2 {
+		t.Fatalf("unexpected matches: %+v", got)
+	}
+	if got[0] != (Match{Token: "spam", Start: 0, End: 4}) || got[1] != (Match{Token: "buy now", Start: 9, End: 16}) {
+		t.Fatalf("unexpected offsets: %+v", got)
+	}
+}
@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Special token encodings. Storage keeps tokens as plain strings, so regex,
+// wildcard and categorized tokens carry their type in a prefix:
+//
+//	re:<expr>              case-insensitive regular expression, matched anywhere
+//	wild:<glob>            glob on whole words: * is any word runes, ? is one
+//	cat:<name>:<token>     literal token tagged with a category
+const (
+	RegexPrefix    = "re:"
+	WildcardPrefix = "wild:"
+	CategoryPrefix = "cat:"
+)
+
+// EncodeRegex returns the storage form of a regex token.
+func EncodeRegex(expr string) string { return RegexPrefix + expr }
+
+// EncodeWildcard returns the storage form of a wildcard token.
+func EncodeWildcard(glob string) string { return WildcardPrefix + glob }
+
+// EncodeCategory returns the storage form of a literal token with a category.
+func EncodeCategory(category, token string) string {
+	return CategoryPrefix + category + ":" + token
+}
+
+// tokenPattern is a compiled regex or wildcard token.
+type tokenPattern struct {
+	key string
+	re  *regexp.Regexp
+	// boundary requires matches to start and end on word boundaries.
+	boundary bool
+}
+
+// parsedToken is one token routed to its index.
+type parsedToken struct {
+	// key identifies the token in the index and in trigger results.
+	key      string
+	category string
+	pattern  *tokenPattern
+}
+
+// NormalizeToken returns the canonical form of a token, keeping the case of
+// regex bodies. It returns "" for empty or invalid tokens.
+func NormalizeToken(token string) string {
+	return canonicalToken(token, normalizeToken)
+}
+
+// NormalizeToken is the package NormalizeToken with the engine's Normalizer.
+// Repeat collapsing is not applied, matching what storage keeps.
+func (e *Engine) NormalizeToken(token string) string {
+	return canonicalToken(token, e.normalize)
+}
+
+func canonicalToken(token string, norm func(string) string) string {
+	p, ok := parseToken(token, norm)
+	if !ok {
+		return ""
+	}
+	if p.category != "" {
+		return EncodeCategory(p.category, p.key)
+	}
+	return p.key
+}
+
+// parseToken parses raw, normalizing literal keys and globs with norm.
+func parseToken(raw string, norm func(string) string) (parsedToken, bool) {
+	t := strings.TrimSpace(raw)
+	switch {
+	case strings.HasPrefix(t, RegexPrefix):
+		expr := strings.TrimSpace(t[len(RegexPrefix):])
+		if expr == "" {
+			return parsedToken{}, false
+		}
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return parsedToken{}, false
+		}
+		key := RegexPrefix + expr
+		return parsedToken{key: key, pattern: &tokenPattern{key: key, re: re}}, true
+	case strings.HasPrefix(t, WildcardPrefix):
+		glob := norm(t[len(WildcardPrefix):])
+		if strings.Trim(glob, "*? ") == "" {
+			return parsedToken{}, false
+		}
+		re, err := regexp.Compile(globToRegexp(glob))
+		if err != nil {
+			return parsedToken{}, false
+		}
+		key := WildcardPrefix + glob
+		return parsedToken{key: key, pattern: &tokenPattern{key: key, re: re, boundary: true}}, true
+	case strings.HasPrefix(t, CategoryPrefix):
+		name, token, ok := strings.Cut(t[len(CategoryPrefix):], ":")
+		name, token = normalizeToken(name), norm(token)
+		if !ok || name == "" || token == "" {
+			return parsedToken{}, false
+		}
+		return parsedToken{key: token, category: name}, true
+	}
+	key := norm(t)
+	if key == "" {
+		return parsedToken{}, false
+	}
+	return parsedToken{key: key}, true
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("(?i)")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(`[\p{L}\p{N}_]*`)
+		case '?':
+			b.WriteString(`[\p{L}\p{N}_]`)
+		case ' ':
+			b.WriteString(`\s+`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// indexLocked adds a parsed token to st. Caller holds the write lock or owns st.
+func (st *state) indexLocked(p parsedToken) {
+	switch {
+	case p.pattern != nil:
+		st.patterns = append(st.patterns, p.pattern)
+	case strings.ContainsRune(p.key, ' '):
+		st.phrases = append(st.phrases, p.key)
+	case unspacedToken(p.key):
+		if st.unspaced == nil {
+			st.unspaced = make(map[string]struct{})
+		}
+		st.unspaced[p.key] = struct{}{}
+	}
+	if p.category != "" {
+		if st.categories == nil {
+			st.categories = make(map[string]string)
+		}
+		st.categories[p.key] = p.category
+	}
+}
+
+// addFormLocked records the plain or categorized form of a literal whose key
+// is already indexed under the other form. It reports whether p was new.
+func (st *state) addFormLocked(p parsedToken) bool {
+	if p.pattern != nil {
+		return false
+	}
+	if _, both := st.dual[p.key]; both {
+		return false
+	}
+	if _, tagged := st.categories[p.key]; tagged == (p.category != "") {
+		return false
+	}
+	if p.category != "" {
+		if st.categories == nil {
+			st.categories = make(map[string]string)
+		}
+		st.categories[p.key] = p.category
+	}
+	if st.dual == nil {
+		st.dual = make(map[string]struct{})
+	}
+	st.dual[p.key] = struct{}{}
+	return true
+}
+
+// removeFormLocked checks that the form of p is stored under its key and, if
+// the other form is stored too, drops only p's. ok is false when p's form is
+// absent; kept is true when the key stays indexed for the other form.
+func (st *state) removeFormLocked(p parsedToken) (kept, ok bool) {
+	if p.pattern != nil {
+		return false, true
+	}
+	category, tagged := st.categories[p.key]
+	if p.category != "" && category != p.category {
+		return false, false
+	}
+	if _, both := st.dual[p.key]; !both {
+		return false, p.category != "" || !tagged
+	}
+	delete(st.dual, p.key)
+	if p.category != "" {
+		delete(st.categories, p.key)
+	}
+	return true, true
+}
+
+// unindexLocked removes a parsed token's secondary index entries.
+func (st *state) unindexLocked(p parsedToken) {
+	switch {
+	case p.pattern != nil:
+		patterns := st.patterns[:0]
+		for _, existing := range st.patterns {
+			if existing.key != p.key {
+				patterns = append(patterns, existing)
+			}
+		}
+		st.patterns = patterns
+	case strings.ContainsRune(p.key, ' '):
+		st.phrases, _ = removeString(st.phrases, p.key)
+	default:
+		delete(st.unspaced, p.key)
+	}
+	delete(st.categories, p.key)
+	delete(st.dual, p.key)
+}
+
+// patternMatchesLocked returns occurrences of regex and wildcard tokens and of
+// named patterns.
+func (st *state) patternMatchesLocked(lower string, limitOne bool) []Match {
+	var out []Match
+	out = appendPatternMatches(out, st.patterns, lower, limitOne)
+	return appendPatternMatches(out, st.named, lower, limitOne)
+}
+
+func appendPatternMatches(out []Match, patterns []*tokenPattern, lower string, limitOne bool) []Match {
+	for _, p := range patterns {
+		for _, loc := range p.re.FindAllStringIndex(lower, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if p.boundary && !atWordBoundary(lower, loc[0], loc[1]) {
+				continue
+			}
+			out = append(out, Match{Token: p.key, Start: loc[0], End: loc[1]})
+			if limitOne {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Category returns the category of a categorized literal token.
+func (e *Engine) Category(token string) (string, bool) {
+	t := e.norm(token)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	name, ok := e.state.categories[t]
+	return name, ok
+}
+
+// Patterns returns the number of regex and wildcard tokens.
+func (e *Engine) Patterns() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.state.patterns)
+}
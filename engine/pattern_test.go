@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestReplaceAllMixedTokenTypes(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{
+		"scam",
+		"free money",
+		EncodeRegex(`\+7\d{10}`),
+		EncodeWildcard("прода*"),
+		EncodeCategory("Drugs", "соль"),
+		"re:(", // invalid regex is dropped
+		"wild:*",
+	})
+	if got := e.Count(); got != 5 {
+		t.Fatalf("expected 5 tokens, got %d", got)
+	}
+	if words, phrases := e.Breakdown(); words != 2 || phrases != 1 {
+		t.Fatalf("unexpected breakdown words=%d phrases=%d", words, phrases)
+	}
+	if got := e.Patterns(); got != 2 {
+		t.Fatalf("expected 2 patterns, got %d", got)
+	}
+
+	got := e.FindTriggers("SCAM: продам соль, free money, звони +79991234567")
+	sort.Strings(got)
+	want := []string{`re:\+7\d{10}`, "free money", "scam", "wild:прода*", "соль"}
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if name, ok := e.Category("соль"); !ok || name != "drugs" {
+		t.Fatalf("expected drugs category, got %q ok=%v", name, ok)
+	}
+	if got := e.FindTriggers("распродажа"); len(got) != 0 {
+		t.Fatalf("wildcard must match whole words only, got %v", got)
+	}
+}
+
+func TestSpecialTokensAddRemove(t *testing.T) {
+	e := New()
+	if !e.AddToken(EncodeRegex(`\S+@\S+`)) || e.AddToken(EncodeRegex(`\S+@\S+`)) {
+		t.Fatal("unexpected add result for regex token")
+	}
+	if e.AddToken("cat::token") || e.AddToken("cat:name:") {
+		t.Fatal("malformed category tokens must be rejected")
+	}
+	if got := e.FindTriggers("mail me: a@b.c"); len(got) != 1 {
+		t.Fatalf("expected regex match, got %v", got)
+	}
+	if _, ok := e.LastHit(`re:\S+@\S+`); !ok {
+		t.Fatal("expected hit history for regex token")
+	}
+	if !e.RemoveToken(`re:\S+@\S+`) || e.Patterns() != 0 {
+		t.Fatal("expected regex token removed")
+	}
+	e.AddToken(EncodeCategory("spam", "promo code"))
+	if !e.RemoveToken("cat:spam:promo code") {
+		t.Fatal("expected categorized phrase removed")
+	}
+	if _, ok := e.Category("promo code"); ok {
+		t.Fatal("category must be dropped with its token")
+	}
+	if w, p := e.Breakdown(); w != 0 || p != 0 {
+		t.Fatalf("expected empty engine, got words=%d phrases=%d", w, p)
+	}
+}
+
+func TestSpecialTokensWithNegation(t *testing.T) {
+	e := New()
+	e.ReplaceAll([]string{EncodeWildcard("sell*")})
+	e.AddNegation("not")
+	if got := e.FindTriggers("not selling"); len(got) != 0 {
+		t.Fatalf("negation must suppress wildcard match, got %v", got)
+	}
+	if got := e.FindTriggers("sellers welcome"); len(got) != 1 {
+		t.Fatalf("expected wildcard match, got %v", got)
+	}
+}
+
+func TestNormalizeToken(t *testing.T) {
+	cases := map[string]string{
+		" Scam ":          "scam",
+		`re:\S+`:          `re:\S+`,
+		"wild:ПРОДА*":     "wild:прода*",
+		"cat:Drugs:СОЛЬ ": "cat:drugs:соль",
+		"re:[":            "",
+	}
+	for in, want := range cases {
+		if got := NormalizeToken(in); got != want {
+			t.Fatalf("NormalizeToken(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCategoryAndPlainFormsStayApart(t *testing.T) {
+	e := New()
+	if !e.AddToken("salt") || !e.AddToken(EncodeCategory("drugs", "salt")) || e.AddToken("cat:drugs:salt") {
+		t.Fatal("plain and categorized forms must be added once each")
+	}
+	if got := e.Export(); len(got) != 2 || got[0] != "cat:drugs:salt" || got[1] != "salt" {
+		t.Fatalf("both forms must round-trip, got %v", got)
+	}
+	if e.RemoveToken("cat:other:salt") {
+		t.Fatal("a different category must not remove the token")
+	}
+	if !e.RemoveToken("salt") || e.Count() != 1 {
+		t.Fatalf("removing the plain form must keep the categorized one, count=%d", e.Count())
+	}
+	if name, ok := e.Category("salt"); !ok || name != "drugs" || len(e.FindTriggers("salt")) != 1 {
+		t.Fatalf("categorized form must stay matchable, category=%q ok=%v", name, ok)
+	}
+	if e.RemoveToken("salt") {
+		t.Fatal("the plain form is gone")
+	}
+
+	e.ReplaceAll([]string{EncodeCategory("drugs", "salt"), "salt"})
+	if got := e.Export(); len(got) != 2 || e.Count() != 2 {
+		t.Fatalf("reload must keep both forms, got %v", got)
+	}
+	if !e.RemoveToken("cat:drugs:salt") {
+		t.Fatal("expected categorized form removed")
+	}
+	if _, ok := e.Category("salt"); ok || len(e.FindTriggers("salt")) != 1 {
+		t.Fatal("plain form must remain uncategorized")
+	}
+}
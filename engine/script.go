@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// unspacedScripts are scripts written without spaces between words, so the
+// word splitter sees a whole sentence as one word.
+var unspacedScripts = []*unicode.RangeTable{
+	unicode.Han,
+	unicode.Hiragana,
+	unicode.Katakana,
+	unicode.Thai,
+	unicode.Lao,
+	unicode.Khmer,
+	unicode.Myanmar,
+}
+
+func isUnspacedRune(r rune) bool {
+	return r >= 0x0e00 && unicode.In(r, unspacedScripts...)
+}
+
+// unspacedToken reports whether a literal single-word token contains runes of
+// an unspaced script.
+func unspacedToken(token string) bool {
+	if strings.ContainsRune(token, ' ') {
+		return false
+	}
+	for _, r := range token {
+		if isUnspacedRune(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// atScriptBoundary is atWordBoundary for unspaced tokens: neighbouring runes
+// of an unspaced script do not count as glue.
+func atScriptBoundary(s string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(s[:start]); isWordRune(r) && !isUnspacedRune(r) {
+			return false
+		}
+	}
+	if end < len(s) {
+		if r, _ := utf8.DecodeRuneInString(s[end:]); isWordRune(r) && !isUnspacedRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// unspacedMatchesLocked returns substring occurrences of unspaced tokens.
+// Caller holds e.mu.
+func (e *Engine) unspacedMatchesLocked(lower string, limitOne bool) []Match {
+	var out []Match
+	for tok := range e.state.unspaced {
+		for _, at := range indexAll(lower, tok) {
+			if !atScriptBoundary(lower, at, at+len(tok)) {
+				continue
+			}
+			out = append(out, Match{Token: tok, Start: at, End: at + len(tok)})
+			if limitOne {
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestUnspacedScripts(t *testing.T) {
+	tokens := []string{"傻瓜", "垃圾", "ばか", "spam"}
+	const msg = "你这个傻瓜真是垃圾啊"
+
+	plain := New()
+	plain.ReplaceAll(tokens)
+	if got := plain.FindTriggers(msg); len(got) != 0 {
+		t.Fatalf("without the option a space-free sentence is one word, got %v", got)
+	}
+
+	for name, threshold := range map[string]int{"scan": -1, "automaton": 1} {
+		t.Run(name, func(t *testing.T) {
+			e := NewWithOptions(Options{UnspacedScripts: true, AutomatonThreshold: threshold})
+			e.ReplaceAll(tokens)
+
+			got := e.FindTriggers(msg)
+			sort.Strings(got)
+			if want := []string{"傻瓜", "垃圾"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("FindTriggers = %v, want %v", got, want)
+			}
+			if got := e.FindTriggers("お前はばかだ"); len(got) != 1 || got[0] != "ばか" {
+				t.Fatalf("expected japanese match, got %v", got)
+			}
+			if got := e.FindTriggers("傻瓜"); len(got) != 1 {
+				t.Fatalf("expected standalone match, got %v", got)
+			}
+			if got := e.FindTriggers("spammer 傻"); len(got) != 0 {
+				t.Fatalf("latin words must still match whole words only, got %v", got)
+			}
+			if got := e.FindTriggers("xx傻瓜"); len(got) != 0 {
+				t.Fatalf("unspaced token glued to latin letters must not match, got %v", got)
+			}
+
+			matches := e.FindTriggerMatches(msg)
+			if len(matches) != 2 || msg[matches[0].Start:matches[0].End] != "傻瓜" || matches[1].Token != "垃圾" {
+				t.Fatalf("unexpected matches %+v", matches)
+			}
+		})
+	}
+}
+
+func TestUnspacedScriptsRemoveToken(t *testing.T) {
+	e := NewWithOptions(Options{UnspacedScripts: true, AutomatonThreshold: -1})
+	e.AddToken("傻瓜")
+	if got := e.FindTriggers("你这个傻瓜"); len(got) != 1 {
+		t.Fatalf("expected match, got %v", got)
+	}
+	e.RemoveToken("傻瓜")
+	if got := e.FindTriggers("你这个傻瓜"); len(got) != 0 {
+		t.Fatalf("removed token must not match, got %v", got)
+	}
+}
@@ -0,0 +1,55 @@
+package engine
+
+import "github.com/elum-utils/censor/models"
+
+// SetTokenStatus attaches a direct verdict to a token: a message consisting of
+// exactly that token resolves to status without AI. A zero status detaches it.
+// Statuses survive ReplaceAll.
+func (e *Engine) SetTokenStatus(token string, status models.StatusCode) {
+	t := e.norm(token)
+	if t == "" {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if status == 0 {
+		delete(e.statuses, t)
+		return
+	}
+	if e.statuses == nil {
+		e.statuses = make(map[string]models.StatusCode)
+	}
+	e.statuses[t] = status
+}
+
+// ReplaceTokenStatuses replaces all direct token verdicts atomically.
+func (e *Engine) ReplaceTokenStatuses(statuses map[string]models.StatusCode) {
+	next := make(map[string]models.StatusCode, len(statuses))
+	for token, status := range statuses {
+		if t := e.norm(token); t != "" && status != 0 {
+			next[t] = status
+		}
+	}
+	e.mu.Lock()
+	e.statuses = next
+	e.mu.Unlock()
+}
+
+// ExactStatus reports the direct verdict when the whole message equals a known
+// token with an attached status.
+func (e *Engine) ExactStatus(message string) (string, models.StatusCode, bool) {
+	t := e.norm(message)
+	if t == "" {
+		return "", 0, false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	status, ok := e.statuses[t]
+	if !ok {
+		return "", 0, false
+	}
+	if _, known := e.state.tokens[t]; !known {
+		return "", 0, false
+	}
+	return t, status, true
+}
@@ -2,6 +2,17 @@ module github.com/elum-utils/censor
 
 go 1.25.4
 
-require github.com/go-resty/resty/v2 v2.17.2
+require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/go-resty/resty/v2 v2.17.2
+	github.com/redis/go-redis/v9 v9.22.0
+	golang.org/x/time v0.14.0
+)
 
-require golang.org/x/net v0.43.0 // indirect
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	go.uber.org/atomic v1.11.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+)
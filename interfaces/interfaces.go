@@ -2,7 +2,9 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
+	"github.com/elum-utils/censor/engine"
 	"github.com/elum-utils/censor/models"
 )
 
@@ -12,12 +14,31 @@ type AIAnalyzer interface {
 	Analyze(ctx context.Context, message models.Message) (models.AIResult, error)
 }
 
-// BatchAIAnalyzer extends AIAnalyzer with batch processing.
+// BatchAIAnalyzer extends AIAnalyzer with batch processing. Results carry
+// the MessageID of the message they judge; when messages share an ID,
+// results are returned in input order.
 type BatchAIAnalyzer interface {
 	AIAnalyzer
 	AnalyzeBatch(ctx context.Context, messages []models.Message) ([]models.AIResult, error)
 }
 
+// RetryAfterError is returned by analyzers when the provider asks callers to
+// back off. Core pauses all AI dispatch for RetryAfter.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// Engine matches trigger tokens in message text. *engine.Engine is the default implementation.
+type Engine interface {
+	AddToken(token string) bool
+	RemoveToken(token string) bool
+	ReplaceAll(tokens []string)
+	FindTriggers(message string) []string
+	Count() int
+	Stats() engine.Stats
+}
+
 // Storage persists trigger tokens.
 type Storage interface {
 	AddToken(ctx context.Context, token string) error
@@ -26,6 +47,88 @@ type Storage interface {
 	TokenExists(ctx context.Context, token string) (bool, error)
 }
 
+// StatusStorage extends Storage with direct per-token verdicts.
+type StatusStorage interface {
+	Storage
+	SetTokenStatus(ctx context.Context, token string, status models.StatusCode) error
+	GetTokenStatuses(ctx context.Context) (map[string]models.StatusCode, error)
+}
+
+// BulkStorage extends Storage with a batched insert. Tokens that already exist
+// are ignored.
+type BulkStorage interface {
+	Storage
+	AddTokens(ctx context.Context, tokens []string) error
+}
+
+// StreamStorage extends Storage with a streaming token read for large sets.
+type StreamStorage interface {
+	Storage
+	// GetTokensFunc calls fn for every token. An error from fn stops the
+	// iteration and is returned.
+	GetTokensFunc(ctx context.Context, fn func(token string) error) error
+}
+
+// ResumableStreamStorage streams tokens in ascending order from a cursor, so
+// an interrupted load can continue where it stopped.
+type ResumableStreamStorage interface {
+	StreamStorage
+	// GetTokensAfter calls fn for every token greater than after, in
+	// ascending byte order. An empty after starts from the first token.
+	GetTokensAfter(ctx context.Context, after string, fn func(token string) error) error
+}
+
+// RichStorage extends Storage with per-token metadata. Tokens added through
+// the plain Storage methods are reported with empty metadata.
+type RichStorage interface {
+	Storage
+	// AddTokenMeta adds token.Value if missing and replaces its metadata.
+	AddTokenMeta(ctx context.Context, token models.Token) error
+	GetTokensMeta(ctx context.Context) ([]models.Token, error)
+}
+
+// StreamRichStorage extends RichStorage with a streaming metadata read, so a
+// reload need not hold every token in memory.
+type StreamRichStorage interface {
+	RichStorage
+	// GetTokensMetaFunc calls fn for every token with its metadata. An error
+	// from fn stops the iteration and is returned.
+	GetTokensMetaFunc(ctx context.Context, fn func(token models.Token) error) error
+}
+
+// BulkRichStorage extends RichStorage with a batched metadata write.
+type BulkRichStorage interface {
+	RichStorage
+	// AddTokensMeta adds every token.Value if missing and replaces its
+	// metadata.
+	AddTokensMeta(ctx context.Context, tokens []models.Token) error
+}
+
+// PingStorage extends Storage with a cheap reachability check used by
+// Core.HealthCheck.
+type PingStorage interface {
+	Storage
+	Ping(ctx context.Context) error
+}
+
+// ResultCache stores AI verdicts by message text. Implementations may be shared
+// across instances (e.g. backed by Redis); they must be safe for concurrent use.
+type ResultCache interface {
+	Get(key string) (models.AIResult, bool)
+	// Set stores result for ttl, which is always positive.
+	Set(key string, result models.AIResult, ttl time.Duration)
+	Remove(key string)
+	Len() int
+}
+
+// DeferStore queues messages whose AI analysis failed so they can be
+// processed later. Implementations must be safe for concurrent use.
+type DeferStore interface {
+	Enqueue(ctx context.Context, messages []models.Message) error
+	// Drain removes and returns every queued message in queue order.
+	Drain(ctx context.Context) ([]models.Message, error)
+}
+
 // CallbackHandler handles results by status code.
 type CallbackHandler interface {
 	OnClean(ctx context.Context, event models.Violation) error
@@ -2,8 +2,26 @@ package models
 
 // Message is an input unit for moderation.
 type Message struct {
+	// ID should be unique within a batch: verdicts are matched to messages by
+	// it. Batches with duplicate IDs are aligned by position instead.
 	ID       int64  `json:"id"`
 	DialogID string `json:"dialog_id,omitempty"`
 	User     int64  `json:"user"`
 	Data     string `json:"data"`
+	// Triggers holds the prefilter matches. Core sets it only on messages it
+	// passes to the AI stage.
+	Triggers []string `json:"triggers,omitempty"`
+	// Context holds prior dialog turns, oldest first, so the AI can tell e.g. a
+	// buyer from a seller. Nil keeps single-message classification. Verdicts
+	// reached with context are not cached.
+	Context []ContextMessage `json:"context,omitempty"`
+	// Lang is the message language as a BCP 47 tag such as "en" or "es-MX".
+	// Empty means unknown.
+	Lang string `json:"lang,omitempty"`
+}
+
+// ContextMessage is one prior turn of a dialog.
+type ContextMessage struct {
+	User int64  `json:"user"`
+	Data string `json:"data"`
 }
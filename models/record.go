@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ModerationRecordSchema identifies the ModerationRecord JSON layout.
+const ModerationRecordSchema = "censor.moderation.v1"
+
+// ModerationRecord is the stable export form of a Violation for external
+// trust-and-safety tooling. It does not follow the compact AI wire format.
+type ModerationRecord struct {
+	Schema          string    `json:"schema"`
+	MessageID       int64     `json:"message_id"`
+	DialogID        string    `json:"dialog_id,omitempty"`
+	UserID          int64     `json:"user_id"`
+	Status          string    `json:"status"`
+	StatusCode      int       `json:"status_code"`
+	Reason          string    `json:"reason"`
+	ReasonCode      string    `json:"reason_code"`
+	Confidence      float64   `json:"confidence"`
+	Triggers        []string  `json:"triggers"`
+	TriggeredByRule bool      `json:"triggered_by_rule"`
+	CacheHit        bool      `json:"cache_hit"`
+	StaleTokens     bool      `json:"stale_tokens"`
+	Timestamp       time.Time `json:"timestamp"`
+	// RawAI is the model's original verdict JSON, when the adapter kept it.
+	RawAI string `json:"raw_ai,omitempty"`
+}
+
+// ToRecord converts the decision to a ModerationRecord.
+func (v Violation) ToRecord() ModerationRecord {
+	user := v.AIResult.ViolatorUserID
+	if user == 0 {
+		user = v.Message.User
+	}
+	id := v.Message.ID
+	if id == 0 {
+		id = v.AIResult.MessageID
+	}
+	triggers := append([]string{}, v.AIResult.TriggerTokens...)
+	return ModerationRecord{
+		Schema:          ModerationRecordSchema,
+		MessageID:       id,
+		DialogID:        v.Message.DialogID,
+		UserID:          user,
+		Status:          v.AIResult.StatusCode.Name(),
+		StatusCode:      int(v.AIResult.StatusCode),
+		Reason:          v.AIResult.Reason,
+		ReasonCode:      reasonCode(v.AIResult.Reason),
+		Confidence:      v.AIResult.Confidence,
+		Triggers:        triggers,
+		TriggeredByRule: v.Triggered,
+		CacheHit:        v.CacheHit,
+		StaleTokens:     v.StaleTokens,
+		Timestamp:       v.ProcessedAt,
+		RawAI:           v.AIResult.Raw,
+	}
+}
+
+// reasonCode turns a free-form reason into a snake_case slug.
+func reasonCode(reason string) string {
+	var b strings.Builder
+	pendingSep := false
+	for _, r := range strings.ToLower(reason) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingSep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			pendingSep = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingSep = true
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestViolationToRecord(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	v := Violation{
+		Message:     Message{ID: 7, DialogID: "d1", User: 42, Data: "x"},
+		AIResult:    AIResult{StatusCode: StatusCommercialOffPlatform, Reason: "Missing AI result!", Confidence: 0.9},
+		Triggered:   true,
+		ProcessedAt: at,
+	}
+	rec := v.ToRecord()
+	if rec.Status != "commercial_off_platform" || rec.StatusCode != 5 || rec.UserID != 42 || rec.MessageID != 7 {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if rec.ReasonCode != "missing_ai_result" {
+		t.Fatalf("unexpected reason code %q", rec.ReasonCode)
+	}
+
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"schema":"censor.moderation.v1"`, `"triggers":[]`, `"dialog_id":"d1"`, `"timestamp":"2026-03-01T12:00:00Z"`} {
+		if !strings.Contains(string(raw), want) {
+			t.Fatalf("expected %s in %s", want, raw)
+		}
+	}
+}
+
+func TestStatusName(t *testing.T) {
+	if StatusClean.Name() != "clean" || StatusDangerousIllegal.Name() != "dangerous_illegal" || StatusCode(9).Name() != "unknown" {
+		t.Fatal("unexpected status names")
+	}
+	if got := reasonCode("  нет триггеров "); got != "нет_триггеров" {
+		t.Fatalf("unexpected slug %q", got)
+	}
+}
@@ -3,6 +3,9 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // StatusCode is a moderation decision code from AI.
@@ -27,6 +30,79 @@ func (s StatusCode) Valid() bool {
 	return s >= StatusClean && s <= StatusCritical
 }
 
+var statusNames = [...]string{
+	StatusClean:                 "clean",
+	StatusNonCriticalAbuse:      "non_critical_abuse",
+	StatusHumanReview:           "human_review",
+	StatusSuspicious:            "suspicious",
+	StatusCommercialOffPlatform: "commercial_off_platform",
+	StatusDangerousIllegal:      "dangerous_illegal",
+}
+
+// Name returns the stable snake_case name of the status, or "unknown".
+func (s StatusCode) Name() string {
+	if !s.Valid() {
+		return "unknown"
+	}
+	return statusNames[s]
+}
+
+// String returns Name for valid codes and "unknown(N)" otherwise.
+func (s StatusCode) String() string {
+	if !s.Valid() {
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+	return statusNames[s]
+}
+
+// MarshalText emits the status name, so JSON values, map keys and text loggers
+// read as names. Codes without a name, such as custom ones above 6, are
+// emitted as decimal numbers.
+func (s StatusCode) MarshalText() ([]byte, error) {
+	if !s.Valid() {
+		return strconv.AppendInt(nil, int64(s), 10), nil
+	}
+	return []byte(statusNames[s]), nil
+}
+
+// UnmarshalText accepts a status name, the deprecated "critical" alias, or a
+// positive decimal code.
+func (s *StatusCode) UnmarshalText(text []byte) error {
+	name := strings.ToLower(strings.TrimSpace(string(text)))
+	if name == "critical" {
+		*s = StatusCritical
+		return nil
+	}
+	for code, n := range statusNames {
+		if n != "" && n == name {
+			*s = StatusCode(code)
+			return nil
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		*s = StatusCode(n)
+		return nil
+	}
+	return fmt.Errorf("models: unknown status %q", string(text))
+}
+
+// UnmarshalJSON accepts a number or a quoted name.
+func (s *StatusCode) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+		return s.UnmarshalText([]byte(name))
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*s = StatusCode(n)
+	return nil
+}
+
 // AIResult is a normalized AI response.
 type AIResult struct {
 	StatusCode     StatusCode `json:"status_code"`
@@ -35,6 +111,10 @@ type AIResult struct {
 	TriggerTokens  []string   `json:"trigger_tokens"`
 	ViolatorUserID int64      `json:"violator_user_id,omitempty"`
 	MessageID      int64      `json:"message_id,omitempty"`
+	// Raw is the model's JSON for this verdict exactly as returned, set by
+	// the adapter for audits. Verdicts fanned out from one response share it.
+	// It is not part of the compact wire format.
+	Raw string `json:"-"`
 }
 
 type aiResultAlias struct {
@@ -44,15 +124,16 @@ type aiResultAlias struct {
 	TriggerTokens  []string   `json:"trigger_tokens"`
 	ViolatorUserID int64      `json:"violator_user_id,omitempty"`
 	MessageID      int64      `json:"message_id,omitempty"`
+	Raw            string     `json:"-"`
 }
 
 type aiCompact struct {
-	A StatusCode `json:"a"`
-	B string     `json:"b"`
-	C float64    `json:"c"`
-	D []string   `json:"d"`
-	E int64      `json:"e,omitempty"`
-	F int64      `json:"f,omitempty"`
+	A int      `json:"a"`
+	B string   `json:"b"`
+	C float64  `json:"c"`
+	D []string `json:"d"`
+	E int64    `json:"e,omitempty"`
+	F int64    `json:"f,omitempty"`
 }
 
 // UnmarshalJSON supports full and compact response formats.
@@ -65,7 +146,7 @@ func (r *AIResult) UnmarshalJSON(data []byte) error {
 
 	var compact aiCompact
 	if err := json.Unmarshal(data, &compact); err == nil && compact.A != 0 {
-		r.StatusCode = compact.A
+		r.StatusCode = StatusCode(compact.A)
 		r.Reason = compact.B
 		r.Confidence = compact.C
 		r.TriggerTokens = compact.D
@@ -77,10 +158,11 @@ func (r *AIResult) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("models: unsupported AI result format")
 }
 
-// MarshalJSON emits compact format for payload size efficiency.
+// MarshalJSON emits compact format for payload size efficiency. The status
+// stays numeric there.
 func (r AIResult) MarshalJSON() ([]byte, error) {
 	return json.Marshal(aiCompact{
-		A: r.StatusCode,
+		A: int(r.StatusCode),
 		B: r.Reason,
 		C: r.Confidence,
 		D: r.TriggerTokens,
@@ -95,4 +177,27 @@ type Violation struct {
 	AIResult  AIResult
 	Triggered bool
 	CacheHit  bool
+	// StaleTokens reports that the decision was made on a token set older than
+	// the configured staleness threshold.
+	StaleTokens bool
+	// ProcessedAt is when the decision was made.
+	ProcessedAt time.Time
+	// Trace explains how the decision was reached. Set only when requested.
+	Trace *ProcessTrace
+	// Deferred reports a placeholder decision for a message queued in the
+	// defer store after an AI error.
+	Deferred bool
+}
+
+// ProcessTrace is a diagnostic record of the pipeline steps behind a decision.
+type ProcessTrace struct {
+	TriggerMatched bool
+	Triggers       []string
+	CacheHit       bool
+	AICalled       bool
+	// RawAIContent is the model's verdict JSON for the message as the adapter
+	// returned it (AIResult.Raw); empty when the analyzer keeps none.
+	RawAIContent string
+	// Duration is the time from the start of the call to the decision.
+	Duration time.Duration
 }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -20,3 +21,67 @@ func TestAIResultUnmarshalInvalid(t *testing.T) {
 		t.Fatalf("expected error")
 	}
 }
+
+func TestStatusCodeStringAndText(t *testing.T) {
+	want := map[StatusCode]string{
+		StatusClean:                 "clean",
+		StatusNonCriticalAbuse:      "non_critical_abuse",
+		StatusHumanReview:           "human_review",
+		StatusSuspicious:            "suspicious",
+		StatusCommercialOffPlatform: "commercial_off_platform",
+		StatusDangerousIllegal:      "dangerous_illegal",
+	}
+	for code, name := range want {
+		if code.String() != name {
+			t.Fatalf("%d: expected %q, got %q", int(code), name, code.String())
+		}
+		var back StatusCode
+		if err := back.UnmarshalText([]byte(name)); err != nil || back != code {
+			t.Fatalf("%q: round trip failed: %v %v", name, back, err)
+		}
+	}
+	var alias StatusCode
+	if err := alias.UnmarshalText([]byte("critical")); err != nil || alias != StatusCritical {
+		t.Fatalf("expected critical alias, got %v %v", alias, err)
+	}
+	if StatusCode(9).String() != "unknown(9)" || StatusCode(0).String() != "unknown(0)" {
+		t.Fatalf("unexpected unknown names")
+	}
+	var bad StatusCode
+	if err := bad.UnmarshalText([]byte("unknown(9)")); err == nil {
+		t.Fatalf("expected error for unknown name")
+	}
+	if err := bad.UnmarshalText([]byte("0")); err == nil {
+		t.Fatalf("expected error for a non-positive code")
+	}
+	var custom StatusCode
+	if err := custom.UnmarshalText([]byte("7")); err != nil || custom != 7 {
+		t.Fatalf("custom codes must decode: %v %v", custom, err)
+	}
+}
+
+func TestStatusCodeJSON(t *testing.T) {
+	raw, err := json.Marshal(AIResult{StatusCode: StatusSuspicious, Confidence: 1})
+	if err != nil || !strings.Contains(string(raw), `"a":4`) {
+		t.Fatalf("compact field must stay numeric: %s err=%v", raw, err)
+	}
+	raw, err = json.Marshal(map[StatusCode]int{StatusClean: 3})
+	if err != nil || string(raw) != `{"clean":3}` {
+		t.Fatalf("map keys must use names: %s err=%v", raw, err)
+	}
+	raw, err = json.Marshal(struct {
+		Status StatusCode `json:"status"`
+		Custom StatusCode `json:"custom"`
+	}{StatusSuspicious, 7})
+	if err != nil || string(raw) != `{"status":"suspicious","custom":"7"}` {
+		t.Fatalf("JSON values must use names: %s err=%v", raw, err)
+	}
+	var back struct{ Status, Custom, Number StatusCode }
+	if err := json.Unmarshal([]byte(`{"Status":"suspicious","Custom":"7","Number":5}`), &back); err != nil || back.Status != StatusSuspicious || back.Custom != 7 || back.Number != StatusCommercialOffPlatform {
+		t.Fatalf("names and numbers must decode: %+v err=%v", back, err)
+	}
+	var r AIResult
+	if err := json.Unmarshal([]byte(`{"a":7,"c":0.5}`), &r); err != nil || r.StatusCode != 7 {
+		t.Fatalf("expected custom compact status to decode: %+v err=%v", r, err)
+	}
+}
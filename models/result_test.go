@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -36,3 +37,13 @@ func TestAIResultMarshalCompact(t *testing.T) {
 		t.Fatalf("unexpected payload: %s", string(raw))
 	}
 }
+
+func TestAIResultMarshalOmitsRaw(t *testing.T) {
+	raw, err := json.Marshal(AIResult{StatusCode: StatusClean, Confidence: 1, Raw: `{"a":1}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "raw") || strings.Contains(string(raw), `{\"a\"`) {
+		t.Fatalf("raw leaked into compact payload: %s", raw)
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Token sources recorded in Token.Source.
+const (
+	TokenSourceManual = "manual"
+	TokenSourceAuto   = "auto"
+	TokenSourceImport = "import"
+)
+
+// Token is a trigger token with audit metadata. Matching only uses Value.
+type Token struct {
+	Value    string
+	Category string
+	Severity int
+	Source   string
+	AddedAt  time.Time
+}
+
+// HasMeta reports whether any field besides Value is set.
+func (t Token) HasMeta() bool {
+	return t.Category != "" || t.Severity != 0 || t.Source != "" || !t.AddedAt.IsZero()
+}